@@ -0,0 +1,357 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	// rebalancePath is the path of the admin endpoint triggering a rebalance.
+	rebalancePath = "/rebalance"
+
+	// endpointsPath is the path of the admin endpoint describing the state of the endpoints.
+	endpointsPath = "/endpoints"
+
+	// ringPath is the path of the admin endpoint describing the ring of each signal.
+	ringPath = "/ring"
+
+	// routingKeyPath is the path of the admin endpoint switching the routing key.
+	routingKeyPath = "/routing_key"
+)
+
+// adminServers holds the admin servers shared by the exporters for the different signals of the same component,
+// keyed by the component's configuration.
+var adminServers = newAdminServerRegistry()
+
+type adminServerRegistry struct {
+	lock    sync.Mutex
+	servers map[*Config]*adminServer
+}
+
+func newAdminServerRegistry() *adminServerRegistry {
+	return &adminServerRegistry{servers: map[*Config]*adminServer{}}
+}
+
+// register adds the load balancer to the admin server of its component, starting the server for the first one.
+func (r *adminServerRegistry) register(lb *loadBalancer) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if s, ok := r.servers[lb.cfg]; ok {
+		s.add(lb)
+		return nil
+	}
+
+	s, err := startAdminServer(lb)
+	if err != nil {
+		return err
+	}
+	r.servers[lb.cfg] = s
+	return nil
+}
+
+// unregister removes the load balancer from the admin server of its component, shutting the server down once
+// no load balancer is left.
+func (r *adminServerRegistry) unregister(ctx context.Context, lb *loadBalancer) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	s, ok := r.servers[lb.cfg]
+	if !ok || s.remove(lb) > 0 {
+		return nil
+	}
+	delete(r.servers, lb.cfg)
+	return s.shutdown(ctx)
+}
+
+// adminServer serves the admin endpoint of a component, acting on the load balancers for all of its signals.
+type adminServer struct {
+	server   *http.Server
+	serverWG sync.WaitGroup
+
+	lock sync.Mutex
+	lbs  []*loadBalancer
+}
+
+func startAdminServer(lb *loadBalancer) (*adminServer, error) {
+	s := &adminServer{lbs: []*loadBalancer{lb}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(rebalancePath, s.handleRebalance)
+	mux.HandleFunc(endpointsPath, s.handleEndpoints)
+	mux.HandleFunc(ringPath, s.handleRing)
+	mux.HandleFunc(routingKeyPath, s.handleRoutingKey)
+
+	var err error
+	s.server, err = lb.cfg.Admin.ToServer(lb.host, lb.settings, mux)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := lb.cfg.Admin.ToListener()
+	if err != nil {
+		return nil, err
+	}
+
+	s.serverWG.Add(1)
+	go func() {
+		defer s.serverWG.Done()
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			lb.logger.Error("the admin server failed", zap.Error(err))
+		}
+	}()
+	return s, nil
+}
+
+func (s *adminServer) add(lb *loadBalancer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lbs = append(s.lbs, lb)
+}
+
+// remove removes the load balancer, returning the number of load balancers left.
+func (s *adminServer) remove(lb *loadBalancer) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, candidate := range s.lbs {
+		if candidate == lb {
+			s.lbs = append(s.lbs[:i], s.lbs[i+1:]...)
+			break
+		}
+	}
+	return len(s.lbs)
+}
+
+func (s *adminServer) loadBalancers() []*loadBalancer {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*loadBalancer(nil), s.lbs...)
+}
+
+func (s *adminServer) shutdown(ctx context.Context) error {
+	err := s.server.Shutdown(ctx)
+	s.serverWG.Wait()
+	return err
+}
+
+// handleRebalance rebalances the load balancers of all the signals on POST requests.
+func (s *adminServer) handleRebalance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var errs error
+	for _, lb := range s.loadBalancers() {
+		errs = multierr.Append(errs, lb.rebalance(r.Context()))
+	}
+	if errs != nil {
+		http.Error(w, errs.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRoutingKey switches the routing key of the signals supporting it to the one given with the "key" parameter
+// on POST requests, without a restart. The routing key is left unchanged when it's invalid.
+func (s *adminServer) handleRoutingKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !r.URL.Query().Has("key") {
+		http.Error(w, "missing key parameter", http.StatusBadRequest)
+		return
+	}
+
+	updated := false
+	var errs error
+	for _, lb := range s.loadBalancers() {
+		if lb.updateRouting == nil {
+			continue
+		}
+		cfg := *lb.cfg
+		cfg.RoutingKey = r.URL.Query().Get("key")
+		if err := lb.updateRouting(&cfg); err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		updated = true
+	}
+	switch {
+	case errs != nil:
+		http.Error(w, errs.Error(), http.StatusBadRequest)
+	case !updated:
+		http.Error(w, "no signal supports switching the routing key", http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// endpointStatus describes the state of an endpoint.
+type endpointStatus struct {
+	Endpoint      string     `json:"endpoint"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+}
+
+// handleEndpoints describes the endpoints of the load balancers of all the signals, sorted by endpoint. The last
+// error of an endpoint is the most recent one among the signals whose latest send to the endpoint failed.
+func (s *adminServer) handleEndpoints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	byEndpoint := map[string]endpointStatus{}
+	for _, lb := range s.loadBalancers() {
+		for _, status := range lb.endpointStatuses() {
+			existing, ok := byEndpoint[status.Endpoint]
+			if !ok || existing.LastErrorTime == nil ||
+				(status.LastErrorTime != nil && status.LastErrorTime.After(*existing.LastErrorTime)) {
+				byEndpoint[status.Endpoint] = status
+			}
+		}
+	}
+	statuses := make([]endpointStatus, 0, len(byEndpoint))
+	for _, status := range byEndpoint {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Endpoint < statuses[j].Endpoint
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statuses)
+}
+
+// endpointStatuses returns the state of the endpoints having an exporter.
+func (lb *loadBalancer) endpointStatuses() []endpointStatus {
+	lb.updateLock.RLock()
+	defer lb.updateLock.RUnlock()
+
+	statuses := make([]endpointStatus, 0, len(lb.exporters))
+	for endpoint, exp := range lb.exporters {
+		status := endpointStatus{Endpoint: endpoint}
+		if at, err := exp.lastError(); err != nil {
+			status.LastError = err.Error()
+			status.LastErrorTime = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// ringStatus describes the ring of a load balancer and, when a routing key was given, the endpoint it maps to.
+type ringStatus struct {
+	Signal      string               `json:"signal,omitempty"`
+	Fingerprint string               `json:"fingerprint"`
+	Resolved    []string             `json:"resolved"`
+	Endpoints   []ringEndpointStatus `json:"endpoints"`
+	Draining    []string             `json:"draining,omitempty"`
+	KeyEndpoint string               `json:"key_endpoint,omitempty"`
+}
+
+// ringEndpointStatus describes an endpoint of the ring. The positions and the share are only known for the rings
+// assigning fixed positions to the endpoints.
+type ringEndpointStatus struct {
+	Endpoint    string  `json:"endpoint"`
+	Positions   int     `json:"positions,omitempty"`
+	Share       float64 `json:"share,omitempty"`
+	Evicted     bool    `json:"evicted,omitempty"`
+	CircuitOpen bool    `json:"circuit_open,omitempty"`
+	LastError   string  `json:"last_error,omitempty"`
+}
+
+// handleRing describes the ring of the load balancers of all the signals. The routing key given with the "key"
+// parameter, or the trace ID given in hexadecimal with the "trace_id" parameter, is mapped to its endpoint.
+func (s *adminServer) handleRing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var key []byte
+	switch query := r.URL.Query(); {
+	case query.Has("trace_id"):
+		traceID, err := hex.DecodeString(query.Get("trace_id"))
+		if err != nil || len(traceID) != 16 {
+			http.Error(w, fmt.Sprintf("invalid trace_id %q, it must be 32 hexadecimal digits", query.Get("trace_id")), http.StatusBadRequest)
+			return
+		}
+		key = traceID
+	case query.Has("key"):
+		key = []byte(query.Get("key"))
+	}
+
+	lbs := s.loadBalancers()
+	statuses := make([]ringStatus, 0, len(lbs))
+	for _, lb := range lbs {
+		statuses = append(statuses, lb.ringStatus(key))
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Signal < statuses[j].Signal
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statuses)
+}
+
+// ringStatus describes the ring of the load balancer, mapping the given routing key to its endpoint when not nil.
+// The key is mapped with the ring only: at send time, the bounded load, the ramp up or an open circuit can still
+// route the data to another endpoint.
+func (lb *loadBalancer) ringStatus(key []byte) ringStatus {
+	lb.updateLock.RLock()
+	defer lb.updateLock.RUnlock()
+
+	status := ringStatus{
+		Signal:    lb.signal.String(),
+		Resolved:  append([]string{}, lb.resolved...),
+		Endpoints: []ringEndpointStatus{},
+		Draining:  lb.drainingEndpoints(),
+	}
+	if lb.ring == nil {
+		return status
+	}
+	status.Fingerprint = lb.ring.fingerprint()
+	if key != nil {
+		status.KeyEndpoint = lb.ring.endpointFor(key)
+	}
+
+	var layout map[string]endpointLayout
+	if l, ok := lb.ring.(ringLayout); ok {
+		layout = l.layout()
+	}
+	for _, endpoint := range lb.ring.endpoints() {
+		endpointStatus := ringEndpointStatus{
+			Endpoint:  endpoint,
+			Positions: layout[endpoint].positions,
+			Share:     layout[endpoint].share,
+			Evicted:   lb.healthChecks.evicted(endpoint),
+		}
+		if exp, ok := lb.exporters[endpointWithPort(endpoint)]; ok {
+			endpointStatus.CircuitOpen = exp.breaker.isOpen()
+			if _, err := exp.lastError(); err != nil {
+				endpointStatus.LastError = err.Error()
+			}
+		}
+		status.Endpoints = append(status.Endpoints, endpointStatus)
+	}
+	return status
+}
@@ -0,0 +1,339 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestAdminRebalance(t *testing.T) {
+	// prepare
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	resolutions := 0
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	})
+	require.NoError(t, err)
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			resolutions++
+			return endpoints, nil
+		},
+	}
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+	s := &adminServer{lbs: []*loadBalancer{lb}}
+
+	// test
+	endpoints = []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+	w := httptest.NewRecorder()
+	s.handleRebalance(w, httptest.NewRequest(http.MethodPost, rebalancePath, nil))
+
+	// verify
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, 2, resolutions)
+	assert.Equal(t, newHashRing(endpoints), lb.ring)
+	assert.Len(t, lb.exporters, 3)
+}
+
+func TestAdminRebalanceWithRampUp(t *testing.T) {
+	// prepare
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	lb := newRampUpLoadBalancer(t, &endpoints)
+	s := &adminServer{lbs: []*loadBalancer{lb}}
+
+	// test
+	endpoints = []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+	w := httptest.NewRecorder()
+	s.handleRebalance(w, httptest.NewRequest(http.MethodPost, rebalancePath, nil))
+
+	// verify
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, newHashRing(endpoints), lb.ring)
+	require.NotNil(t, lb.ramp)
+	assert.Equal(t, []string{"endpoint-3"}, lb.ramp.added)
+}
+
+func TestAdminRebalanceFailure(t *testing.T) {
+	// prepare
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), nil)
+	require.NoError(t, err)
+	lb.res = &mockResolver{
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return nil, errors.New("resolution failed")
+		},
+	}
+	s := &adminServer{lbs: []*loadBalancer{lb}}
+
+	// test
+	w := httptest.NewRecorder()
+	s.handleRebalance(w, httptest.NewRequest(http.MethodPost, rebalancePath, nil))
+
+	// verify
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "resolution failed")
+}
+
+func TestAdminRebalanceMethodNotAllowed(t *testing.T) {
+	// prepare
+	s := &adminServer{}
+
+	// test
+	w := httptest.NewRecorder()
+	s.handleRebalance(w, httptest.NewRequest(http.MethodGet, rebalancePath, nil))
+
+	// verify
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, http.MethodPost, w.Header().Get("Allow"))
+}
+
+func TestAdminServerSharedAcrossSignals(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Admin = &confighttp.ServerConfig{Endpoint: availableLocalAddress(t)}
+	te, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	me, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	for _, lb := range []*loadBalancer{te.loadBalancer, me.loadBalancer} {
+		lb.componentFactory = func(ctx context.Context, endpoint string) (component.Component, error) {
+			return newNopMockExporter(), nil
+		}
+	}
+
+	// test
+	require.NoError(t, te.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, me.Start(context.Background(), componenttest.NewNopHost()))
+
+	// verify
+	require.Contains(t, adminServers.servers, cfg)
+	assert.Len(t, adminServers.servers[cfg].loadBalancers(), 2)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post("http://"+cfg.Admin.Endpoint+rebalancePath, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	require.NoError(t, te.Shutdown(context.Background()))
+	assert.Contains(t, adminServers.servers, cfg)
+	require.NoError(t, me.Shutdown(context.Background()))
+	assert.NotContains(t, adminServers.servers, cfg)
+	_, err = client.Post("http://"+cfg.Admin.Endpoint+rebalancePath, "", nil)
+	assert.Error(t, err)
+}
+
+func TestAdminEndpoints(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2"}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(func(context.Context, ptrace.Traces) error {
+			if endpoint == "endpoint-2:4317" {
+				return errors.New("connection refused")
+			}
+			return nil
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+	s := &adminServer{lbs: []*loadBalancer{lb}}
+
+	for _, endpoint := range []string{"endpoint-1:4317", "endpoint-2:4317"} {
+		err = lb.exporters[endpoint].ConsumeTraces(context.Background(), simpleTraces())
+		require.Equal(t, endpoint == "endpoint-2:4317", err != nil)
+	}
+
+	// test
+	w := httptest.NewRecorder()
+	s.handleEndpoints(w, httptest.NewRequest(http.MethodGet, endpointsPath, nil))
+
+	// verify
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var statuses []endpointStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 2)
+	assert.Equal(t, endpointStatus{Endpoint: "endpoint-1:4317"}, statuses[0])
+	assert.Equal(t, "endpoint-2:4317", statuses[1].Endpoint)
+	assert.Equal(t, "connection refused", statuses[1].LastError)
+	assert.NotNil(t, statuses[1].LastErrorTime)
+}
+
+func TestAdminEndpointsMethodNotAllowed(t *testing.T) {
+	// prepare
+	s := &adminServer{}
+
+	// test
+	w := httptest.NewRecorder()
+	s.handleEndpoints(w, httptest.NewRequest(http.MethodPost, endpointsPath, nil))
+
+	// verify
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, http.MethodGet, w.Header().Get("Allow"))
+}
+
+func TestAdminRing(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2"}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockTracesExporter(), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	lb.signal = component.DataTypeTraces
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+	s := &adminServer{lbs: []*loadBalancer{lb}}
+
+	// test
+	w := httptest.NewRecorder()
+	s.handleRing(w, httptest.NewRequest(http.MethodGet, ringPath+"?key=service-1", nil))
+
+	// verify
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var statuses []ringStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 1)
+	status := statuses[0]
+	assert.Equal(t, "traces", status.Signal)
+	assert.Equal(t, lb.ring.fingerprint(), status.Fingerprint)
+	assert.Equal(t, []string{"endpoint-1", "endpoint-2"}, status.Resolved)
+	assert.Equal(t, lb.ring.endpointFor([]byte("service-1")), status.KeyEndpoint)
+
+	require.Len(t, status.Endpoints, 2)
+	positions, share := 0, 0.0
+	for i, endpoint := range status.Endpoints {
+		assert.Equal(t, cfg.Resolver.Static.Hostnames[i], endpoint.Endpoint)
+		assert.Positive(t, endpoint.Positions)
+		positions += endpoint.Positions
+		share += endpoint.Share
+	}
+	assert.Len(t, lb.ring.(*hashRing).items, positions)
+	assert.InDelta(t, 1, share, 1e-9)
+}
+
+func TestAdminRingTraceID(t *testing.T) {
+	// prepare
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockTracesExporter(), nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+	s := &adminServer{lbs: []*loadBalancer{lb}}
+
+	// test
+	w := httptest.NewRecorder()
+	s.handleRing(w, httptest.NewRequest(http.MethodGet, ringPath+"?trace_id=0102030405060708090a0b0c0d0e0f10", nil))
+
+	// verify
+	require.Equal(t, http.StatusOK, w.Code)
+	var statuses []ringStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "endpoint-1", statuses[0].KeyEndpoint)
+
+	w = httptest.NewRecorder()
+	s.handleRing(w, httptest.NewRequest(http.MethodGet, ringPath+"?trace_id=invalid", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// availableLocalAddress returns a local address with a port that is currently free.
+func availableLocalAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestAdminRoutingKey(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Admin = &confighttp.ServerConfig{Endpoint: availableLocalAddress(t)}
+	te, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	me, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	for _, lb := range []*loadBalancer{te.loadBalancer, me.loadBalancer} {
+		lb.componentFactory = func(ctx context.Context, endpoint string) (component.Component, error) {
+			return newNopMockExporter(), nil
+		}
+	}
+	require.NoError(t, te.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, me.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, te.Shutdown(context.Background()))
+		require.NoError(t, me.Shutdown(context.Background()))
+	}()
+	client := &http.Client{Timeout: 5 * time.Second}
+	post := func(query string) int {
+		resp, err := client.Post("http://"+cfg.Admin.Endpoint+routingKeyPath+query, "", nil)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	// test and verify
+	assert.Equal(t, svcRouting, me.routing.Load().key)
+
+	assert.Equal(t, http.StatusNoContent, post("?key=metric"))
+	assert.Equal(t, metricNameRouting, me.routing.Load().key)
+
+	assert.Equal(t, http.StatusBadRequest, post("?key=unknown"))
+	assert.Equal(t, metricNameRouting, me.routing.Load().key)
+
+	assert.Equal(t, http.StatusBadRequest, post(""))
+	assert.Equal(t, metricNameRouting, me.routing.Load().key)
+}
+
+func TestAdminRoutingKeyUnsupported(t *testing.T) {
+	// prepare
+	te, err := newTracesExporter(exportertest.NewNopCreateSettings(), simpleConfig())
+	require.NoError(t, err)
+	s := &adminServer{lbs: []*loadBalancer{te.loadBalancer}}
+
+	// test
+	w := httptest.NewRecorder()
+	s.handleRoutingKey(w, httptest.NewRequest(http.MethodPost, routingKeyPath+"?key=metric", nil))
+
+	// verify
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	s.handleRoutingKey(w, httptest.NewRequest(http.MethodGet, routingKeyPath+"?key=metric", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, http.MethodPost, w.Header().Get("Allow"))
+}
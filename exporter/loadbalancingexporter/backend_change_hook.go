@@ -0,0 +1,50 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// backendChangeHook is called with the current and the proposed endpoints before a change of the endpoints applies.
+// It returns the endpoints to use, either the proposed ones or a modified list, or an error rejecting the change,
+// in which case the current endpoints are kept.
+type backendChangeHook func(current []string, proposed []string) ([]string, error)
+
+// minBackendsHook rejects the changes that would drop the number of endpoints below the given minimum, which is
+// more likely to come from a glitch of the service discovery than from an actual scale down. Changes increasing
+// the number of endpoints are always accepted, even when still below the minimum.
+func minBackendsHook(minBackends int) backendChangeHook {
+	return func(current []string, proposed []string) ([]string, error) {
+		if len(proposed) < minBackends && len(proposed) < len(current) {
+			return nil, fmt.Errorf("the change would leave %d endpoints, below the minimum of %d set by min_backends",
+				len(proposed), minBackends)
+		}
+		return proposed, nil
+	}
+}
+
+// approveBackendChange runs the proposed endpoints through the hooks, returning the endpoints to use, or false
+// when the change was rejected.
+func (lb *loadBalancer) approveBackendChange(proposed []string) ([]string, bool) {
+	if len(lb.changeHooks) == 0 {
+		return proposed, true
+	}
+
+	lb.updateLock.RLock()
+	current := append([]string(nil), lb.endpoints...)
+	lb.updateLock.RUnlock()
+
+	for _, hook := range lb.changeHooks {
+		var err error
+		if proposed, err = hook(current, proposed); err != nil {
+			lb.logger.Warn("the change of the endpoints was rejected, keeping the current endpoints",
+				zap.Strings("current", current), zap.Error(err))
+			return nil, false
+		}
+	}
+	return proposed, true
+}
@@ -0,0 +1,115 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+)
+
+func newHookedLoadBalancer(t *testing.T, cfg *Config, endpoints *[]string, hooks ...backendChangeHook) *loadBalancer {
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	})
+	require.NoError(t, err)
+	lb.changeHooks = append(lb.changeHooks, hooks...)
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return *endpoints, nil
+		},
+	}
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	})
+	return lb
+}
+
+func TestBackendChangeHookVeto(t *testing.T) {
+	// prepare
+	var calls [][]string
+	veto := func(current []string, proposed []string) ([]string, error) {
+		calls = append(calls, current)
+		if len(proposed) < 2 {
+			return nil, errors.New("too few endpoints")
+		}
+		return proposed, nil
+	}
+	endpoints := []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+	lb := newHookedLoadBalancer(t, simpleConfig(), &endpoints, veto)
+	expected := newHashRing(endpoints)
+	require.Equal(t, expected, lb.ring)
+
+	// test
+	endpoints = []string{"endpoint-1"}
+	_, err := lb.res.resolve(context.Background())
+	require.NoError(t, err)
+
+	// verify
+	assert.Equal(t, expected, lb.ring)
+	assert.Equal(t, []string{"endpoint-1", "endpoint-2", "endpoint-3"}, lb.endpoints)
+	assert.Len(t, lb.exporters, 3)
+	assert.Equal(t, [][]string{nil, {"endpoint-1", "endpoint-2", "endpoint-3"}}, calls)
+
+	// the following changes still apply when approved
+	endpoints = []string{"endpoint-1", "endpoint-4"}
+	_, err = lb.res.resolve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, newHashRing(endpoints), lb.ring)
+	assert.Len(t, lb.exporters, 2)
+}
+
+func TestBackendChangeHookModify(t *testing.T) {
+	// prepare
+	keepCanary := func(_ []string, proposed []string) ([]string, error) {
+		return append(proposed, "canary"), nil
+	}
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+
+	// test
+	lb := newHookedLoadBalancer(t, simpleConfig(), &endpoints, keepCanary)
+
+	// verify
+	assert.Equal(t, []string{"canary", "endpoint-1", "endpoint-2"}, lb.ring.endpoints())
+	assert.Contains(t, lb.exporters, "canary:4317")
+}
+
+func TestMinBackends(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.MinBackends = 3
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+
+	// below the minimum, but the first endpoints are still accepted
+	lb := newHookedLoadBalancer(t, cfg, &endpoints)
+	require.Equal(t, newHashRing(endpoints), lb.ring)
+
+	// growing is accepted, even when still below the minimum
+	endpoints = []string{"endpoint-1", "endpoint-2", "endpoint-3", "endpoint-4"}
+	_, err := lb.res.resolve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, newHashRing(endpoints), lb.ring)
+
+	// test
+	endpoints = []string{"endpoint-1", "endpoint-2"}
+	_, err = lb.res.resolve(context.Background())
+	require.NoError(t, err)
+
+	// verify
+	assert.Equal(t, newHashRing([]string{"endpoint-1", "endpoint-2", "endpoint-3", "endpoint-4"}), lb.ring)
+
+	// shrinking while staying at the minimum is accepted
+	endpoints = []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+	_, err = lb.res.resolve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, newHashRing(endpoints), lb.ring)
+}
@@ -0,0 +1,131 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultBackupRecoveryInterval is the time spent on the backup endpoints before trying the primary ones again.
+const defaultBackupRecoveryInterval = 30 * time.Second
+
+// backupEndpoints holds the cold-standby endpoints of the static resolver. They replace the primary endpoints in
+// the ring once the latest send to each of the primary endpoints failed. After the recovery interval, the primary
+// endpoints are tried again, going back to the backup endpoints if they are still failing.
+type backupEndpoints struct {
+	endpoints        []string
+	recoveryInterval time.Duration
+
+	lock sync.Mutex
+	// resolved are the latest endpoints from the resolver, applied again when switching
+	resolved []string
+	// primaries are the primary endpoints in use, with their ports
+	primaries []string
+	// failing holds the primary endpoints whose latest send failed
+	failing       map[string]bool
+	active        bool
+	recoveryTimer *time.Timer
+	stopped       bool
+}
+
+func newBackupEndpoints(cfg *StaticResolver) *backupEndpoints {
+	if cfg == nil || len(cfg.BackupHostnames) == 0 {
+		return nil
+	}
+
+	endpoints := make([]string, len(cfg.BackupHostnames))
+	copy(endpoints, cfg.BackupHostnames)
+	sort.Strings(endpoints)
+
+	recoveryInterval := cfg.BackupRecoveryInterval
+	if recoveryInterval <= 0 {
+		recoveryInterval = defaultBackupRecoveryInterval
+	}
+	return &backupEndpoints{
+		endpoints:        endpoints,
+		recoveryInterval: recoveryInterval,
+		failing:          map[string]bool{},
+	}
+}
+
+// apply records the resolved endpoints, returning the endpoints to use in their place.
+func (b *backupEndpoints) apply(resolved []string) []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.resolved = resolved
+	if b.active {
+		return b.endpoints
+	}
+	b.primaries = make([]string, len(resolved))
+	for i, endpoint := range resolved {
+		b.primaries[i] = endpointWithPort(endpoint)
+	}
+	return resolved
+}
+
+// onSendResult switches to the backup endpoints after a failed send, when the latest send to each of the primary
+// endpoints failed.
+func (lb *loadBalancer) onSendResult(endpoint string, err error) {
+	b := lb.backups
+	b.lock.Lock()
+	if b.active || b.stopped || !endpointFound(endpoint, b.primaries) {
+		b.lock.Unlock()
+		return
+	}
+	if err == nil {
+		delete(b.failing, endpoint)
+		b.lock.Unlock()
+		return
+	}
+	b.failing[endpoint] = true
+	for _, primary := range b.primaries {
+		if !b.failing[primary] {
+			b.lock.Unlock()
+			return
+		}
+	}
+
+	lb.logger.Warn("all the primary endpoints are failing, switching to the backup endpoints",
+		zap.Strings("endpoints", b.endpoints), zap.Duration("recovery_interval", b.recoveryInterval))
+	b.active = true
+	b.recoveryTimer = time.AfterFunc(b.recoveryInterval, lb.recoverPrimaries)
+	resolved := b.resolved
+	b.lock.Unlock()
+
+	lb.onBackendChanges(resolved)
+}
+
+// recoverPrimaries switches back to the primary endpoints once the recovery interval is over.
+func (lb *loadBalancer) recoverPrimaries() {
+	b := lb.backups
+	b.lock.Lock()
+	b.recoveryTimer = nil
+	if b.stopped {
+		b.lock.Unlock()
+		return
+	}
+	lb.logger.Info("trying the primary endpoints again")
+	b.active = false
+	b.failing = map[string]bool{}
+	resolved := b.resolved
+	b.lock.Unlock()
+
+	lb.onBackendChanges(resolved)
+}
+
+// stop prevents any further switch between the primary and the backup endpoints.
+func (b *backupEndpoints) stop() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.stopped = true
+	if b.recoveryTimer != nil {
+		b.recoveryTimer.Stop()
+		b.recoveryTimer = nil
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestBackupEndpoints(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.BackupHostnames = []string{"backup-1"}
+	cfg.Resolver.Static.BackupRecoveryInterval = 100 * time.Millisecond
+
+	var primaryDown atomic.Bool
+	primaryDown.Store(true)
+	primarySink := new(consumertest.TracesSink)
+	backupSink := new(consumertest.TracesSink)
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		if endpoint == "backup-1:4317" {
+			return newMockTracesExporter(backupSink.ConsumeTraces), nil
+		}
+		return newMockTracesExporter(func(ctx context.Context, td ptrace.Traces) error {
+			if primaryDown.Load() {
+				return errors.New("connection refused")
+			}
+			return primarySink.ConsumeTraces(ctx, td)
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// the backups are not in use while the primary endpoints haven't failed
+	require.Equal(t, []string{"endpoint-1"}, lb.ring.endpoints())
+	assert.NotContains(t, lb.exporters, "backup-1:4317")
+
+	// test
+	assert.Error(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+
+	// verify
+	assert.Equal(t, []string{"backup-1"}, lb.ring.endpoints())
+	require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+	assert.Len(t, backupSink.AllTraces(), 1)
+
+	// the primary endpoints are tried again after the recovery interval, and used again once they recovered
+	primaryDown.Store(false)
+	assert.Eventually(t, func() bool {
+		lb.updateLock.RLock()
+		defer lb.updateLock.RUnlock()
+		return assert.ObjectsAreEqual([]string{"endpoint-1"}, lb.ring.endpoints())
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+	assert.Len(t, primarySink.AllTraces(), 1)
+	assert.Len(t, backupSink.AllTraces(), 1)
+	assert.NotContains(t, lb.exporters, "backup-1:4317")
+}
+
+func TestBackupEndpointsWhileSomePrimariesSucceed(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2"}
+	cfg.Resolver.Static.BackupHostnames = []string{"backup-1"}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(func(context.Context, ptrace.Traces) error {
+			if endpoint == "endpoint-1:4317" {
+				return errors.New("connection refused")
+			}
+			return nil
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// test
+	for _, endpoint := range []string{"endpoint-1:4317", "endpoint-2:4317", "endpoint-1:4317"} {
+		_ = lb.exporters[endpoint].ConsumeTraces(context.Background(), simpleTraces())
+	}
+
+	// verify
+	assert.Equal(t, []string{"endpoint-1", "endpoint-2"}, lb.ring.endpoints())
+	assert.NotContains(t, lb.exporters, "backup-1:4317")
+}
@@ -0,0 +1,148 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// splitTracesBySize splits the traces in halves until each part is at most maxBytes large once encoded, or holds a
+// single span. The traces are returned as they are when maxBytes is zero.
+func splitTracesBySize(td ptrace.Traces, maxBytes int) []ptrace.Traces {
+	if maxBytes <= 0 || td.SpanCount() <= 1 || tracesMarshaler.TracesSize(td) <= maxBytes {
+		return []ptrace.Traces{td}
+	}
+	first, second := splitTracesAt(td, td.SpanCount()/2)
+	return append(splitTracesBySize(first, maxBytes), splitTracesBySize(second, maxBytes)...)
+}
+
+// splitTracesAt returns the first n spans of the traces, and the other ones, along with their resources and scopes.
+func splitTracesAt(td ptrace.Traces, n int) (ptrace.Traces, ptrace.Traces) {
+	parts := [2]ptrace.Traces{ptrace.NewTraces(), ptrace.NewTraces()}
+	count := 0
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		rs := rss.At(i)
+		var destRS [2]*ptrace.ResourceSpans
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			var destSS [2]*ptrace.ScopeSpans
+			for k := 0; k < ss.Spans().Len(); k++ {
+				part := 0
+				if count >= n {
+					part = 1
+				}
+				count++
+				if destRS[part] == nil {
+					newRS := parts[part].ResourceSpans().AppendEmpty()
+					rs.Resource().CopyTo(newRS.Resource())
+					newRS.SetSchemaUrl(rs.SchemaUrl())
+					destRS[part] = &newRS
+				}
+				if destSS[part] == nil {
+					newSS := destRS[part].ScopeSpans().AppendEmpty()
+					ss.Scope().CopyTo(newSS.Scope())
+					newSS.SetSchemaUrl(ss.SchemaUrl())
+					destSS[part] = &newSS
+				}
+				ss.Spans().At(k).CopyTo(destSS[part].Spans().AppendEmpty())
+			}
+		}
+	}
+	return parts[0], parts[1]
+}
+
+// splitLogsBySize splits the logs in halves until each part is at most maxBytes large once encoded, or holds a
+// single log record. The logs are returned as they are when maxBytes is zero.
+func splitLogsBySize(ld plog.Logs, maxBytes int) []plog.Logs {
+	if maxBytes <= 0 || ld.LogRecordCount() <= 1 || logsMarshaler.LogsSize(ld) <= maxBytes {
+		return []plog.Logs{ld}
+	}
+	first, second := splitLogsAt(ld, ld.LogRecordCount()/2)
+	return append(splitLogsBySize(first, maxBytes), splitLogsBySize(second, maxBytes)...)
+}
+
+// splitLogsAt returns the first n log records, and the other ones, along with their resources and scopes.
+func splitLogsAt(ld plog.Logs, n int) (plog.Logs, plog.Logs) {
+	parts := [2]plog.Logs{plog.NewLogs(), plog.NewLogs()}
+	count := 0
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		rl := rls.At(i)
+		var destRL [2]*plog.ResourceLogs
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			sl := rl.ScopeLogs().At(j)
+			var destSL [2]*plog.ScopeLogs
+			for k := 0; k < sl.LogRecords().Len(); k++ {
+				part := 0
+				if count >= n {
+					part = 1
+				}
+				count++
+				if destRL[part] == nil {
+					newRL := parts[part].ResourceLogs().AppendEmpty()
+					rl.Resource().CopyTo(newRL.Resource())
+					newRL.SetSchemaUrl(rl.SchemaUrl())
+					destRL[part] = &newRL
+				}
+				if destSL[part] == nil {
+					newSL := destRL[part].ScopeLogs().AppendEmpty()
+					sl.Scope().CopyTo(newSL.Scope())
+					newSL.SetSchemaUrl(sl.SchemaUrl())
+					destSL[part] = &newSL
+				}
+				sl.LogRecords().At(k).CopyTo(destSL[part].LogRecords().AppendEmpty())
+			}
+		}
+	}
+	return parts[0], parts[1]
+}
+
+// splitMetricsBySize splits the metrics in halves until each part is at most maxBytes large once encoded, or holds
+// a single metric, whose data points aren't split. The metrics are returned as they are when maxBytes is zero.
+func splitMetricsBySize(md pmetric.Metrics, maxBytes int) []pmetric.Metrics {
+	if maxBytes <= 0 || md.MetricCount() <= 1 || metricsMarshaler.MetricsSize(md) <= maxBytes {
+		return []pmetric.Metrics{md}
+	}
+	first, second := splitMetricsAt(md, md.MetricCount()/2)
+	return append(splitMetricsBySize(first, maxBytes), splitMetricsBySize(second, maxBytes)...)
+}
+
+// splitMetricsAt returns the first n metrics, and the other ones, along with their resources and scopes.
+func splitMetricsAt(md pmetric.Metrics, n int) (pmetric.Metrics, pmetric.Metrics) {
+	parts := [2]pmetric.Metrics{pmetric.NewMetrics(), pmetric.NewMetrics()}
+	count := 0
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		var destRM [2]*pmetric.ResourceMetrics
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			var destSM [2]*pmetric.ScopeMetrics
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				part := 0
+				if count >= n {
+					part = 1
+				}
+				count++
+				if destRM[part] == nil {
+					newRM := parts[part].ResourceMetrics().AppendEmpty()
+					rm.Resource().CopyTo(newRM.Resource())
+					newRM.SetSchemaUrl(rm.SchemaUrl())
+					destRM[part] = &newRM
+				}
+				if destSM[part] == nil {
+					newSM := destRM[part].ScopeMetrics().AppendEmpty()
+					sm.Scope().CopyTo(newSM.Scope())
+					newSM.SetSchemaUrl(sm.SchemaUrl())
+					destSM[part] = &newSM
+				}
+				sm.Metrics().At(k).CopyTo(destSM[part].Metrics().AppendEmpty())
+			}
+		}
+	}
+	return parts[0], parts[1]
+}
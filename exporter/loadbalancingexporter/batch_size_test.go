@@ -0,0 +1,208 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// tracesOfSize returns traces with the given number of spans for each of the given services.
+func tracesOfSize(services []string, spans int) ptrace.Traces {
+	td := ptrace.NewTraces()
+	for _, service := range services {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", service)
+		ss := rs.ScopeSpans().AppendEmpty()
+		ss.Scope().SetName("scope")
+		for i := 0; i < spans; i++ {
+			span := ss.Spans().AppendEmpty()
+			span.SetName(fmt.Sprintf("%s-%d", service, i))
+			span.SetTraceID([16]byte{byte(i)})
+		}
+	}
+	return td
+}
+
+func TestSplitTracesBySize(t *testing.T) {
+	// prepare
+	td := tracesOfSize([]string{"service-1", "service-2"}, 10)
+	maxBytes := tracesMarshaler.TracesSize(td) / 3
+
+	// test
+	parts := splitTracesBySize(td, maxBytes)
+
+	// verify
+	require.Greater(t, len(parts), 1)
+	var names []string
+	for _, part := range parts {
+		assert.LessOrEqual(t, tracesMarshaler.TracesSize(part), maxBytes)
+		for i := 0; i < part.ResourceSpans().Len(); i++ {
+			rs := part.ResourceSpans().At(i)
+			service, _ := rs.Resource().Attributes().Get("service.name")
+			assert.Equal(t, "scope", rs.ScopeSpans().At(0).Scope().Name())
+			spans := rs.ScopeSpans().At(0).Spans()
+			for j := 0; j < spans.Len(); j++ {
+				assert.Contains(t, spans.At(j).Name(), service.Str())
+				names = append(names, spans.At(j).Name())
+			}
+		}
+	}
+
+	// the spans are kept in order
+	var expected []string
+	for _, service := range []string{"service-1", "service-2"} {
+		for i := 0; i < 10; i++ {
+			expected = append(expected, fmt.Sprintf("%s-%d", service, i))
+		}
+	}
+	assert.Equal(t, expected, names)
+
+	// the traces are kept whole when small enough, or when no maximum size is set
+	assert.Len(t, splitTracesBySize(td, tracesMarshaler.TracesSize(td)), 1)
+	assert.Len(t, splitTracesBySize(td, 0), 1)
+}
+
+func TestSplitLogsBySize(t *testing.T) {
+	// prepare
+	ld := plog.NewLogs()
+	sl := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty()
+	for i := 0; i < 20; i++ {
+		sl.LogRecords().AppendEmpty().Body().SetStr(fmt.Sprintf("log record %d", i))
+	}
+	maxBytes := logsMarshaler.LogsSize(ld) / 4
+
+	// test
+	parts := splitLogsBySize(ld, maxBytes)
+
+	// verify
+	require.Greater(t, len(parts), 1)
+	count := 0
+	for _, part := range parts {
+		assert.LessOrEqual(t, logsMarshaler.LogsSize(part), maxBytes)
+		count += part.LogRecordCount()
+	}
+	assert.Equal(t, 20, count)
+}
+
+func TestSplitMetricsBySize(t *testing.T) {
+	// prepare
+	md := pmetric.NewMetrics()
+	sm := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	for i := 0; i < 20; i++ {
+		m := sm.Metrics().AppendEmpty()
+		m.SetName(fmt.Sprintf("metric-%d", i))
+		m.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(int64(i))
+	}
+	maxBytes := metricsMarshaler.MetricsSize(md) / 4
+
+	// test
+	parts := splitMetricsBySize(md, maxBytes)
+
+	// verify
+	require.Greater(t, len(parts), 1)
+	count := 0
+	for _, part := range parts {
+		assert.LessOrEqual(t, metricsMarshaler.MetricsSize(part), maxBytes)
+		count += part.MetricCount()
+	}
+	assert.Equal(t, 20, count)
+
+	// a single metric is never split, whatever its size
+	single := pmetric.NewMetrics()
+	sm.Metrics().At(0).CopyTo(single.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty())
+	assert.Len(t, splitMetricsBySize(single, 1), 1)
+}
+
+func TestMaxBatchSizeBytesSplitsTheSends(t *testing.T) {
+	// prepare
+	td := tracesOfSize([]string{"service-1"}, 10)
+	cfg := simpleConfig()
+	cfg.MaxBatchSizeBytes = tracesMarshaler.TracesSize(td) / 2
+	sink := new(consumertest.TracesSink)
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	err = p.ConsumeTraces(context.Background(), td)
+
+	// verify
+	assert.NoError(t, err)
+	assert.Greater(t, len(sink.AllTraces()), 1)
+	assert.Equal(t, 10, sink.SpanCount())
+	for _, sent := range sink.AllTraces() {
+		assert.LessOrEqual(t, tracesMarshaler.TracesSize(sent), cfg.MaxBatchSizeBytes)
+	}
+}
+
+func TestMaxBatchSizeBytesPartialFailureOnlyReturnsTheFailedPart(t *testing.T) {
+	// prepare
+	ld := plog.NewLogs()
+	sl := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty()
+	for i := 0; i < 4; i++ {
+		sl.LogRecords().AppendEmpty().Body().SetStr(fmt.Sprintf("log record %d", i))
+	}
+	cfg := simpleConfig()
+	cfg.PartialFailures = true
+	cfg.MaxBatchSizeBytes = logsMarshaler.LogsSize(ld) / 2
+
+	// the second part sent fails
+	sink := new(consumertest.LogsSink)
+	sends := 0
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockLogsExporter(func(ctx context.Context, ld plog.Logs) error {
+			sends++
+			if sends == 2 {
+				return errors.New("backend unavailable")
+			}
+			return sink.ConsumeLogs(ctx, ld)
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+
+	p, err := newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	err = p.ConsumeLogs(context.Background(), ld)
+
+	// verify
+	var partial consumererror.Logs
+	require.ErrorAs(t, err, &partial)
+	require.Greater(t, sends, 2)
+	assert.Equal(t, 4, sink.LogRecordCount()+partial.Data().LogRecordCount())
+	assert.Less(t, partial.Data().LogRecordCount(), 4)
+}
@@ -0,0 +1,52 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	errInvalidBoundedLoadFactor     = errors.New("the bounded_load_factor must be greater than 1")
+	errBoundedLoadWithoutConsistent = errors.New("the bounded_load_factor is only supported by the consistent hash strategy")
+)
+
+// validateBoundedLoadFactor checks that the bounded load factor, when set, can be applied.
+func validateBoundedLoadFactor(cfg *Config) error {
+	if cfg.BoundedLoadFactor == 0 {
+		return nil
+	}
+	if cfg.BoundedLoadFactor <= 1 {
+		return errInvalidBoundedLoadFactor
+	}
+	if cfg.HashStrategy != consistentHashStrategy && cfg.HashStrategy != "" {
+		return errBoundedLoadWithoutConsistent
+	}
+	return nil
+}
+
+// boundedEndpoint returns the endpoint the ring routed the identifier to, unless its load is above the bound, in
+// which case the identifier overflows to the next endpoint on the ring under the bound. The load of an endpoint is
+// its number of sends in progress, bounded by the factor times the mean load, counting the data being routed. The
+// caller must hold the update lock.
+func (lb *loadBalancer) boundedEndpoint(identifier []byte, endpoint string) string {
+	ring, ok := lb.ring.(*hashRing)
+	if lb.cfg.BoundedLoadFactor == 0 || !ok || len(lb.endpoints) < 2 {
+		return endpoint
+	}
+
+	total := int64(1)
+	for _, candidate := range lb.endpoints {
+		if exp, found := lb.exporters[endpointWithPort(candidate)]; found {
+			total += exp.outstanding()
+		}
+	}
+	bound := int64(math.Ceil(lb.cfg.BoundedLoadFactor * float64(total) / float64(len(lb.endpoints))))
+
+	return ring.endpointForBounded(identifier, func(candidate string) bool {
+		exp, found := lb.exporters[endpointWithPort(candidate)]
+		return found && exp.outstanding()+1 <= bound
+	})
+}
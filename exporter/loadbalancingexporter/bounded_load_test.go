@@ -0,0 +1,94 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+)
+
+func TestHashRingEndpointForBounded(t *testing.T) {
+	// prepare
+	ring := newHashRing([]string{"endpoint-1", "endpoint-2", "endpoint-3"})
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		owner := ring.endpointFor(key)
+
+		// test and verify
+		assert.Equal(t, owner, ring.endpointForBounded(key, func(string) bool { return true }))
+		assert.Equal(t, owner, ring.endpointForBounded(key, func(string) bool { return false }))
+
+		overflow := ring.endpointForBounded(key, func(endpoint string) bool { return endpoint != owner })
+		assert.NotEqual(t, owner, overflow)
+		assert.NotEmpty(t, overflow)
+	}
+	assert.Equal(t, "", newHashRing(nil).endpointForBounded([]byte("key"), func(string) bool { return true }))
+}
+
+func TestBoundedLoadOverflowsToNextEndpoint(t *testing.T) {
+	// prepare
+	cfg := serviceBasedRoutingConfig()
+	cfg.BoundedLoadFactor = 1.25
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	key := []byte("service-name-1")
+	owner, overflow := lb.ring.endpointFor(key), "endpoint-1"
+	if owner == "endpoint-1" {
+		overflow = "endpoint-2"
+	}
+
+	// test and verify
+	_, endpoint, err := lb.exporterAndEndpoint(key)
+	require.NoError(t, err)
+	assert.Equal(t, owner, endpoint)
+
+	// a hot identifier keeps the owner busy
+	lb.exporters[endpointWithPort(owner)].inFlight.Add(3)
+	_, endpoint, err = lb.exporterAndEndpoint(key)
+	require.NoError(t, err)
+	assert.Equal(t, overflow, endpoint)
+
+	// the identifier goes back once the owner is under the bound again
+	lb.exporters[endpointWithPort(owner)].inFlight.Add(-2)
+	_, endpoint, err = lb.exporterAndEndpoint(key)
+	require.NoError(t, err)
+	assert.Equal(t, owner, endpoint)
+}
+
+func TestValidateBoundedLoadFactor(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		factor   float64
+		strategy string
+		err      error
+	}{
+		{name: "disabled", strategy: rendezvousHashStrategy},
+		{name: "consistent", factor: 1.25},
+		{name: "explicitly consistent", factor: 1.25, strategy: consistentHashStrategy},
+		{name: "too low", factor: 1, err: errInvalidBoundedLoadFactor},
+		{name: "rendezvous", factor: 1.25, strategy: rendezvousHashStrategy, err: errBoundedLoadWithoutConsistent},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := simpleConfig()
+			cfg.BoundedLoadFactor = tt.factor
+			cfg.HashStrategy = tt.strategy
+			assert.Equal(t, tt.err, validateBoundedLoadFactor(cfg))
+		})
+	}
+}
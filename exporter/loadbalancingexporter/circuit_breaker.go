@@ -0,0 +1,169 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCircuitBreakerFailureThreshold = 5
+	defaultCircuitBreakerCooldown         = 30 * time.Second
+)
+
+// circuitBreaker opens the circuit of an endpoint after a number of consecutive failed sends, the data being routed
+// to the next endpoints while it is open. Once the cooldown is over, the data is sent to the endpoint again: the
+// circuit closes after the first successful send, and opens again for another cooldown after the first failed one.
+type circuitBreaker struct {
+	failureThreshold int
+	cooldown         time.Duration
+
+	// onChange is called with the new state of the circuit whenever it opens or closes
+	onChange func(open bool, err error)
+
+	lock      sync.Mutex
+	failures  int
+	open      bool
+	openUntil time.Time
+}
+
+// validateCircuitBreaker checks that the circuit breaker settings, when set, can be applied.
+func validateCircuitBreaker(cfg *Config) error {
+	if cfg.CircuitBreaker == nil {
+		return nil
+	}
+	if cfg.CircuitBreaker.FailureThreshold < 0 {
+		return errors.New("invalid circuit breaker failure_threshold, it must be positive")
+	}
+	return nil
+}
+
+func newCircuitBreaker(cfg *CircuitBreakerSettings, onChange func(open bool, err error)) *circuitBreaker {
+	b := &circuitBreaker{
+		failureThreshold: cfg.FailureThreshold,
+		cooldown:         cfg.Cooldown,
+		onChange:         onChange,
+	}
+	if b.failureThreshold == 0 {
+		b.failureThreshold = defaultCircuitBreakerFailureThreshold
+	}
+	if b.cooldown <= 0 {
+		b.cooldown = defaultCircuitBreakerCooldown
+	}
+	return b
+}
+
+// allows returns whether the data can be sent to the endpoint: the circuit is closed, or its cooldown is over. A nil
+// circuit breaker always allows the data.
+func (b *circuitBreaker) allows() bool {
+	if b == nil {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return !b.open || !time.Now().Before(b.openUntil)
+}
+
+// isOpen returns whether the circuit is open, even if its cooldown is over. A nil circuit breaker is always closed.
+func (b *circuitBreaker) isOpen() bool {
+	if b == nil {
+		return false
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.open
+}
+
+// record counts the result of a send, opening or closing the circuit accordingly. The permanent errors, caused by
+// the data rather than by the endpoint, count as successful sends.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	if consumererror.IsPermanent(err) {
+		err = nil
+	}
+
+	b.lock.Lock()
+	changed := false
+	switch {
+	case err == nil:
+		b.failures = 0
+		changed = b.open
+		b.open = false
+	case b.open:
+		// the send after the cooldown failed too
+		if !time.Now().Before(b.openUntil) {
+			b.openUntil = time.Now().Add(b.cooldown)
+		}
+	default:
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.open = true
+			b.openUntil = time.Now().Add(b.cooldown)
+			changed = true
+		}
+	}
+	open := b.open
+	b.lock.Unlock()
+
+	if changed && b.onChange != nil {
+		b.onChange(open, err)
+	}
+}
+
+// newEndpointCircuitBreaker returns the circuit breaker for the exporter of the endpoint, when configured, logging
+// and recording the changes of its state.
+func (lb *loadBalancer) newEndpointCircuitBreaker(endpoint string) *circuitBreaker {
+	if lb.cfg.CircuitBreaker == nil {
+		return nil
+	}
+	return newCircuitBreaker(lb.cfg.CircuitBreaker, func(open bool, err error) {
+		if open {
+			lb.logger.Warn("the endpoint keeps failing, opening its circuit",
+				zap.String("endpoint", endpoint), zap.Error(err))
+		} else {
+			lb.logger.Info("the endpoint recovered, closing its circuit", zap.String("endpoint", endpoint))
+		}
+		lb.telemetry.recordCircuitState(endpoint, open)
+	})
+}
+
+// circuitAllows returns whether the circuit of the endpoint lets the data through. The caller must hold the update
+// lock.
+func (lb *loadBalancer) circuitAllows(endpoint string) bool {
+	exp, found := lb.exporters[endpointWithPort(endpoint)]
+	return found && exp.breaker.allows()
+}
+
+// circuitEndpoint returns the endpoint the identifier was routed to, unless its circuit is open, in which case the
+// identifier goes to the next endpoint on the ring whose circuit is closed. The endpoint is kept when all the
+// circuits are open. The caller must hold the update lock.
+func (lb *loadBalancer) circuitEndpoint(identifier []byte, endpoint string) string {
+	if lb.cfg.CircuitBreaker == nil || lb.circuitAllows(endpoint) {
+		return endpoint
+	}
+
+	if ring, ok := lb.ring.(*hashRing); ok {
+		return ring.endpointForBounded(identifier, lb.circuitAllows)
+	}
+	// the other rings don't have an order for the identifier, the endpoints in use following it are tried
+	start := 0
+	for i, candidate := range lb.endpoints {
+		if candidate == endpoint {
+			start = i
+		}
+	}
+	for i := 1; i <= len(lb.endpoints); i++ {
+		if candidate := lb.endpoints[(start+i)%len(lb.endpoints)]; lb.circuitAllows(candidate) {
+			return candidate
+		}
+	}
+	return endpoint
+}
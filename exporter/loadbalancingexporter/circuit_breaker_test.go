@@ -0,0 +1,157 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	// prepare
+	var changes []bool
+	b := newCircuitBreaker(&CircuitBreakerSettings{FailureThreshold: 2, Cooldown: 50 * time.Millisecond}, func(open bool, _ error) {
+		changes = append(changes, open)
+	})
+	failure := errors.New("connection refused")
+
+	// test
+	b.record(failure)
+	assert.True(t, b.allows())
+	b.record(failure)
+
+	// verify
+	assert.False(t, b.allows())
+	assert.Equal(t, []bool{true}, changes)
+
+	// the data goes through again once the cooldown is over, a failure opening the circuit again right away
+	assert.Eventually(t, b.allows, time.Second, 5*time.Millisecond)
+	b.record(failure)
+	assert.False(t, b.allows())
+	assert.Equal(t, []bool{true}, changes)
+
+	// a successful send closes the circuit
+	assert.Eventually(t, b.allows, time.Second, 5*time.Millisecond)
+	b.record(nil)
+	assert.True(t, b.allows())
+	assert.Equal(t, []bool{true, false}, changes)
+
+	// the permanent errors don't open the circuit
+	b.record(consumererror.NewPermanent(failure))
+	b.record(consumererror.NewPermanent(failure))
+	assert.True(t, b.allows())
+
+	// a nil circuit breaker always lets the data through
+	var disabled *circuitBreaker
+	disabled.record(failure)
+	assert.True(t, disabled.allows())
+}
+
+func TestCircuitBreakerRoutesToNextEndpoint(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2"}
+	cfg.CircuitBreaker = &CircuitBreakerSettings{FailureThreshold: 1, Cooldown: time.Hour}
+
+	sinks := map[string]*consumertest.TracesSink{}
+	var down atomic.Value
+	down.Store("")
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks[endpoint] = sink
+		return newMockTracesExporter(func(ctx context.Context, td ptrace.Traces) error {
+			if endpoint == down.Load() {
+				return errors.New("connection refused")
+			}
+			return sink.ConsumeTraces(ctx, td)
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	td := simpleTraces()
+	traceID := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).TraceID()
+	backend := endpointWithPort(lb.ring.endpointFor(traceID[:]))
+	down.Store(backend)
+
+	// test
+	assert.Error(t, p.ConsumeTraces(context.Background(), td))
+	require.NoError(t, p.ConsumeTraces(context.Background(), td))
+
+	// verify
+	assert.Empty(t, sinks[backend].AllTraces())
+	for endpoint, sink := range sinks {
+		if endpoint != backend {
+			assert.Len(t, sink.AllTraces(), 1)
+		}
+	}
+}
+
+func TestCircuitBreakerLeastOutstanding(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+	cfg.CircuitBreaker = &CircuitBreakerSettings{FailureThreshold: 1, Cooldown: time.Hour}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockTracesExporter(), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// the idle endpoint-2 has its circuit open, while the other endpoints have sends in progress
+	lb.exporters["endpoint-1:4317"].inFlight.Add(2)
+	lb.exporters["endpoint-3:4317"].inFlight.Add(1)
+	lb.exporters["endpoint-2:4317"].breaker.record(errors.New("connection refused"))
+	require.False(t, lb.circuitAllows("endpoint-2"))
+
+	// test
+	for i := 0; i < 3; i++ {
+		_, endpoint, err := lb.exporterAndEndpointWithoutAffinity(leastOutstandingRouting)
+
+		// verify
+		require.NoError(t, err)
+		assert.Equal(t, "endpoint-3", endpoint)
+	}
+
+	// the endpoints with an open circuit are used when all the circuits are open
+	lb.exporters["endpoint-1:4317"].breaker.record(errors.New("connection refused"))
+	lb.exporters["endpoint-3:4317"].breaker.record(errors.New("connection refused"))
+	_, endpoint, err := lb.exporterAndEndpointWithoutAffinity(leastOutstandingRouting)
+	require.NoError(t, err)
+	assert.Equal(t, "endpoint-2", endpoint)
+}
+
+func TestCircuitBreakerInvalid(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.CircuitBreaker = &CircuitBreakerSettings{FailureThreshold: -1}
+
+	// test
+	_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+
+	// verify
+	assert.ErrorContains(t, err, "invalid circuit breaker failure_threshold")
+}
@@ -6,7 +6,15 @@ package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry
 import (
 	"time"
 
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configauth"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/config/configtls"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
 	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	"go.opentelemetry.io/collector/exporter/otlphttpexporter"
 )
 
 type routingKey int
@@ -16,18 +24,363 @@ const (
 	svcRouting
 	metricNameRouting
 	resourceRouting
+	routingIDRouting
+	resourceAttrsRouting
+	attrsRouting
+	schemaURLRouting
+	noRouting
+	leastOutstandingRouting
+	clientMetadataRouting
+	streamIDRouting
 )
 
 // Config defines configuration for the exporter.
 type Config struct {
+	// TimeoutSettings, QueueSettings and BackOffConfig apply to the whole batches received by the exporter, before
+	// they're routed, on top of the settings of the exporters of each backend. All disabled by default.
+	exporterhelper.TimeoutSettings `mapstructure:",squash"`
+	QueueSettings                  exporterhelper.QueueSettings `mapstructure:"sending_queue"`
+	configretry.BackOffConfig      `mapstructure:"retry_on_failure"`
+
 	Protocol   Protocol         `mapstructure:"protocol"`
 	Resolver   ResolverSettings `mapstructure:"resolver"`
 	RoutingKey string           `mapstructure:"routing_key"`
+
+	// MaxRoutingIdentifiers limits the distinct routing identifiers in a single batch of metrics. Batches going
+	// above it are rejected with a permanent error instead of being split. Unlimited when zero.
+	MaxRoutingIdentifiers int `mapstructure:"max_routing_identifiers"`
+
+	// MaxBatchSizeBytes is the largest size, in the OTLP protobuf encoding, of the data sent to a backend in a single
+	// request. Larger data is split into several requests, such as to stay under the maximum message size of the
+	// gRPC servers of the backends. Unlimited when zero.
+	MaxBatchSizeBytes int `mapstructure:"max_batch_size_bytes"`
+
+	// HashStrategy selects how the routing identifiers are mapped to the endpoints: "consistent" (default), using
+	// a consistent hash ring, "rendezvous", using the highest random weight hashing, "maglev", using a lookup table,
+	// or "weighted_round_robin", distributing the identifiers in turn in proportion to the weights of the endpoints,
+	// without any affinity.
+	HashStrategy string `mapstructure:"hash_strategy"`
+
+	// VirtualNodes is the number of positions of each endpoint in the consistent hash ring, multiplied by the weight
+	// of the endpoint. More positions spread the identifiers more evenly, at the cost of memory. 100 when zero.
+	VirtualNodes int `mapstructure:"virtual_nodes"`
+
+	// HashFunction is the function hashing the endpoints and the identifiers into the consistent hash ring: "crc32"
+	// (default), "xxhash" or "fnv".
+	HashFunction string `mapstructure:"hash_function"`
+
+	// RoutingAttributes is the ordered list of resource attributes combined into the routing identifier when
+	// routing by "attributes".
+	RoutingAttributes []string `mapstructure:"routing_attributes"`
+
+	// RoutingAttributesDelimiter joins the values of the routing attributes into the routing identifier, such that
+	// the identifier can be computed elsewhere, such as for the "routingID" routing key. When empty, each value is
+	// prefixed with its length instead, so that different values never result in the same identifier.
+	RoutingAttributesDelimiter string `mapstructure:"routing_attributes_delimiter"`
+
+	// RoutingExpression is the OTTL value expression evaluated against the resource into the routing identifier
+	// when routing by "expression", such as `Concat([attributes["region"], attributes["service.name"]], ":")`.
+	RoutingExpression string `mapstructure:"routing_expression"`
+
+	// RoutingMetadataKey is the key of the client metadata, such as a tenant header, whose value is the routing
+	// identifier of all the data of a request when routing by "metadata".
+	RoutingMetadataKey string `mapstructure:"routing_metadata_key"`
+
+	// MissingAttributePlaceholder is used in place of the routing attributes missing from a resource. Data missing
+	// any of the routing attributes is rejected when not set.
+	MissingAttributePlaceholder string `mapstructure:"missing_attribute_placeholder"`
+
+	// MissingServiceName is what happens to the data without a service name when routing by "service": "reject"
+	// (default), rejecting the whole batch, "default_key", routing it with MissingServiceNameKey as its routing
+	// identifier, "resource_attributes", routing it by all its resource attributes, or "drop", dropping just the
+	// resources without a service name.
+	MissingServiceName string `mapstructure:"missing_service_name"`
+
+	// MissingServiceNameKey is the routing identifier of the data without a service name for the "default_key"
+	// fallback.
+	MissingServiceNameKey string `mapstructure:"missing_service_name_key"`
+
+	// MaxBackends limits the number of backends in use. When the resolver returns more endpoints than this,
+	// a stable subset is selected based on the hash of each endpoint. Unlimited when zero.
+	MaxBackends int `mapstructure:"max_backends"`
+
+	// MinBackends rejects the resolutions that would drop the number of backends below it, keeping the current
+	// backends instead, as such drops are more likely to come from a service discovery glitch. Disabled when zero.
+	MinBackends int `mapstructure:"min_backends"`
+
+	// RemovalGracePeriod keeps removed endpoints draining for the given period: identifiers already routed to them
+	// keep being routed there, while new identifiers avoid them. Disabled when zero.
+	RemovalGracePeriod time.Duration `mapstructure:"removal_grace_period"`
+
+	// DrainPeriod keeps the exporters of the removed endpoints running for the given period before shutting them
+	// down, so that the data they queued or are retrying is sent out instead of being dropped, such as during
+	// rolling updates of the backends. Shut down as soon as they're removed when zero.
+	DrainPeriod time.Duration `mapstructure:"drain_period"`
+
+	// ExporterShutdownTimeout bounds the shutdown of the exporters of the removed endpoints, after their drain period,
+	// such as the time they take to send out their queued data. No timeout when zero.
+	ExporterShutdownTimeout time.Duration `mapstructure:"exporter_shutdown_timeout"`
+
+	// TelemetryNamespace is added as the "namespace" tag to the telemetry about the backends, distinguishing
+	// the telemetry of different exporter instances sending data to the same backends.
+	TelemetryNamespace string `mapstructure:"telemetry_namespace"`
+
+	// LogRingChanges logs the sorted list of endpoints and the ring fingerprint every time the ring is rebuilt.
+	LogRingChanges bool `mapstructure:"log_ring_changes"`
+
+	// PartialFailures makes the errors returned when only some of the backends failed carry just the data sent
+	// to the failed backends, so that retrying components resend only that portion of the data.
+	PartialFailures bool `mapstructure:"partial_failures"`
+
+	// PersistentQueue writes the incoming batches to a storage extension before routing them, so that the batches
+	// being sent when the collector stopped are sent again once it's restarted. Disabled when not set.
+	PersistentQueue *PersistentQueueSettings `mapstructure:"persistent_queue"`
+
+	// Mirror sends a copy of a share of the batches to the backends of another resolver in the background, without
+	// affecting the sends to the backends of this exporter, such as to try out a new tier with real data. Disabled
+	// when not set.
+	Mirror *MirrorSettings `mapstructure:"mirror"`
+
+	// Subset makes this collector connect to a subset of the backends only, selected from the subset key of the
+	// collector, so that the collectors of a tier spread their connections across the backends instead of all of
+	// them connecting to every backend. Disabled when not set.
+	Subset *SubsetSettings `mapstructure:"subset"`
+
+	// Locality restricts the ring to the backends in the zone of the collector, using the backends of all the
+	// zones only while there are too few local ones. Disabled when not set.
+	Locality *LocalitySettings `mapstructure:"locality"`
+
+	// CircuitBreaker opens the circuit of the endpoints failing a number of sends in a row, routing their data to
+	// the next endpoints until the cooldown is over. Disabled when not set.
+	CircuitBreaker *CircuitBreakerSettings `mapstructure:"circuit_breaker"`
+
+	// RateLimit limits the rate of the items sent to each endpoint, so that a backend getting most of the routing
+	// keys isn't overwhelmed. Disabled when not set.
+	RateLimit *RateLimitSettings `mapstructure:"rate_limit"`
+
+	// FailoverAttempts is the number of other endpoints the data is sent to, one after the other, when its send to
+	// an endpoint in use fails, before returning the error. The endpoints following the failed one among the endpoints
+	// in use are tried. Disabled when zero.
+	FailoverAttempts int `mapstructure:"failover_attempts"`
+
+	// ConnectionPoolSize is the number of exporters, each with its own connection, created for each backend.
+	// The data for the backend is distributed among them in a round-robin fashion. A single one when not set.
+	ConnectionPoolSize int `mapstructure:"connection_pool_size"`
+
+	// ShareConnections makes the exporters for the traces, metrics and logs of the same component send the data to
+	// an endpoint over the same gRPC connection, instead of one connection per signal. Only the endpoints using the
+	// otlp protocol share their connection.
+	ShareConnections bool `mapstructure:"share_connections"`
+
+	// MaxInFlightSends limits the number of sends to the backends in progress at the same time, across all the
+	// calls to the exporter. Further sends wait for one of them to complete. Unlimited when zero.
+	MaxInFlightSends int `mapstructure:"max_in_flight_sends"`
+
+	// DispatchConcurrency is the number of backends the parts of a batch are sent to at the same time, so that the
+	// latency of a batch split across several backends isn't the sum of their latencies. One at a time when not set.
+	DispatchConcurrency int `mapstructure:"dispatch_concurrency"`
+
+	// CatchAllEndpoint receives the data whose routing identifier can't be derived, such as data without a service
+	// name when routing by service. It doesn't have to be one of the resolved endpoints. Such data is rejected when
+	// not set.
+	CatchAllEndpoint string `mapstructure:"catch_all_endpoint"`
+
+	// AllowDegradedStart lets the exporter start without backends when the resolver fails to start, retrying the
+	// resolution in the background and reporting a recoverable error status until it succeeds.
+	AllowDegradedStart bool `mapstructure:"allow_degraded_start"`
+
+	// StartupWaitTimeout makes the data consumed after the start wait for the first resolution to populate the ring,
+	// for up to the given timeout, instead of being rejected for the lack of backends. Disabled when zero.
+	StartupWaitTimeout time.Duration `mapstructure:"startup_wait_timeout"`
+
+	// EndpointSettings holds the settings specific to some of the endpoints, keyed by the endpoint as resolved,
+	// including its port.
+	EndpointSettings map[string]EndpointSettings `mapstructure:"endpoint_settings"`
+
+	// Exporter replaces the OTLP exporters created for the endpoints with exporters of another type, such as
+	// "kafka", with the endpoint templated into their configuration. The protocol settings then don't apply.
+	Exporter *ExporterTemplate `mapstructure:"exporter"`
+
+	// EndpointOverrides override the TLS, authentication and headers of the protocol templates for the endpoints
+	// matching their patterns, such as for the backends of another cluster with a distinct PKI.
+	EndpointOverrides []EndpointOverride `mapstructure:"endpoint_overrides"`
+
+	// AttributesAsMetadata maps resource attributes to the gRPC metadata headers set to their values when sending
+	// data to the backends, so that backends can do their own routing consistently with this exporter.
+	AttributesAsMetadata map[string]string `mapstructure:"attributes_as_metadata"`
+
+	// ForwardMetadataKeys are the keys of the client metadata, such as the tenant headers of the incoming requests,
+	// forwarded to the backends as gRPC metadata or HTTP headers.
+	ForwardMetadataKeys []string `mapstructure:"forward_metadata_keys"`
+
+	// ShareRing makes the exporters for the different signals of this component share a single resolver, so that
+	// their rings are always built from the same list of endpoints.
+	ShareRing bool `mapstructure:"share_ring"`
+
+	// RampUpDuration makes the endpoints added to the ring take over their share of the routing identifiers
+	// gradually over the given duration, instead of all at once. Disabled when zero.
+	RampUpDuration time.Duration `mapstructure:"ramp_up_duration"`
+
+	// BoundedLoadFactor bounds the load of each endpoint to the given factor of the mean load, the identifiers routed
+	// to an endpoint above the bound overflowing to the next endpoint on the ring. The load of an endpoint is its
+	// number of sends in progress. Only supported by the "consistent" hash strategy. Disabled when zero.
+	BoundedLoadFactor float64 `mapstructure:"bounded_load_factor"`
+
+	// HealthCheck actively probes the backends, taking the unresponsive ones out of the ring until they respond
+	// again, even while the resolver still returns them. Disabled when not set.
+	HealthCheck *HealthCheckSettings `mapstructure:"health_check"`
+
+	// Admin configures the HTTP server for the admin endpoints, such as the one triggering a rebalance on demand.
+	// Disabled when not set.
+	Admin *confighttp.ServerConfig `mapstructure:"admin"`
+
+	// VerifyRouting checks at runtime that the ring keeps routing each identifier to the same endpoint until the
+	// ring changes, logging a warning otherwise. Meant for tests and canaries.
+	VerifyRouting bool `mapstructure:"verify_routing"`
+
+	// RoutingDecisionAttribute is the name of the attribute set to the endpoint each span or log record was routed
+	// to, on a sample of them, to verify the routing from the data itself. Disabled when empty.
+	RoutingDecisionAttribute string `mapstructure:"routing_decision_attribute"`
+
+	// RoutingDecisionSamplingRate is the share of the spans and log records stamped with the routing decision
+	// attribute, greater than 0 and at most 1.
+	RoutingDecisionSamplingRate float64 `mapstructure:"routing_decision_sampling_rate"`
 }
 
-// Protocol holds the individual protocol-specific settings. Only OTLP is supported at the moment.
+// Protocol holds the individual protocol-specific settings. The default protocol is used for all the endpoints,
+// unless another one is selected for some of them in the endpoint settings.
 type Protocol struct {
-	OTLP otlpexporter.Config `mapstructure:"otlp"`
+	OTLP     otlpexporter.Config     `mapstructure:"otlp"`
+	OTLPHTTP otlphttpexporter.Config `mapstructure:"otlphttp"`
+
+	// Default is the protocol used to send data to the endpoints without one in their settings, either "otlp"
+	// (default), for OTLP over gRPC, or "otlphttp", for OTLP over HTTP.
+	Default string `mapstructure:"default"`
+}
+
+// MirrorSettings defines the backends the mirrored data is sent to
+type MirrorSettings struct {
+	// Resolver resolves the backends of the mirror, routed as the backends of this exporter.
+	Resolver ResolverSettings `mapstructure:"resolver"`
+
+	// Percentage is the share of the batches sent to the mirror, from 0 to 100. 100 when not set.
+	Percentage float64 `mapstructure:"percentage"`
+}
+
+// PersistentQueueSettings defines the storage of the batches being sent
+type PersistentQueueSettings struct {
+	// StorageID is the storage extension the batches are written to, such as file_storage.
+	StorageID component.ID `mapstructure:"storage"`
+}
+
+// SubsetSettings defines the subset of the backends this collector connects to
+type SubsetSettings struct {
+	// Size is the number of backends in the subset.
+	Size int `mapstructure:"size"`
+
+	// Key identifies this collector among the collectors sending data to the same backends, such as its pod name,
+	// each key selecting its own subset. The hostname when not set.
+	Key string `mapstructure:"key"`
+}
+
+// LocalitySettings defines the zone-aware routing
+type LocalitySettings struct {
+	// Zone is the zone of the collector, such as its availability zone or the topology.kubernetes.io/zone label of
+	// its node.
+	Zone string `mapstructure:"zone"`
+
+	// EndpointZones holds the exact, glob or suffix patterns of the endpoints in each zone, keyed by zone, as for the
+	// denylist of the resolver. The endpoints not selected by any pattern are in no zone.
+	EndpointZones map[string][]string `mapstructure:"endpoint_zones"`
+
+	// MinLocalBackends is the number of backends in the zone of the collector below which the backends of all the
+	// zones are used. 1 when not set.
+	MinLocalBackends int `mapstructure:"min_local_backends"`
+}
+
+// CircuitBreakerSettings defines the circuit breaker of each endpoint
+type CircuitBreakerSettings struct {
+	// FailureThreshold is the number of consecutive failed sends after which the circuit of the endpoint opens.
+	// 5 when not set.
+	FailureThreshold int `mapstructure:"failure_threshold"`
+
+	// Cooldown is the time the circuit stays open before the data is sent to the endpoint again. 30s when not set.
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+// RateLimitSettings defines the rate limit of each endpoint
+type RateLimitSettings struct {
+	// ItemsPerSecond is the number of spans, data points or log records that can be sent to each endpoint per second.
+	ItemsPerSecond float64 `mapstructure:"items_per_second"`
+
+	// Burst is the number of items that can be sent at once, ItemsPerSecond when not set.
+	Burst int `mapstructure:"burst"`
+
+	// Policy is what happens to the data going above the rate: "wait" (default) delays its send until the rate
+	// allows it, while "reject" fails it right away, failing it over to the next endpoints when configured.
+	Policy string `mapstructure:"policy"`
+}
+
+// HealthCheckSettings defines the active health checks of the backends
+type HealthCheckSettings struct {
+	// Protocol is how the backends are probed: "tcp" (default), opening a connection to them, or "grpc", using the
+	// gRPC health checking protocol with the TLS settings of the otlp protocol template.
+	Protocol string `mapstructure:"protocol"`
+
+	// Service is the service whose health is checked with the "grpc" protocol, the whole server when empty.
+	Service string `mapstructure:"service"`
+
+	// Interval is the time between two probes of a backend. 10s when not set.
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Timeout is the time a probe waits for the backend to respond. 2s when not set.
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// UnhealthyThreshold is the number of consecutive failed probes after which a backend is evicted from the ring.
+	// 3 when not set.
+	UnhealthyThreshold int `mapstructure:"unhealthy_threshold"`
+
+	// HealthyThreshold is the number of consecutive successful probes after which an evicted backend is restored
+	// to the ring. 2 when not set.
+	HealthyThreshold int `mapstructure:"healthy_threshold"`
+}
+
+// EndpointSettings defines the settings specific to an endpoint
+type EndpointSettings struct {
+	// Protocol is the protocol used to send data to the endpoint, either "otlp" or "otlphttp", with the
+	// configuration from the corresponding protocol template. The default protocol when not set.
+	Protocol string `mapstructure:"protocol"`
+
+	// Weight is the share of the data sent to the endpoint relative to the other endpoints, 1 by default. It is
+	// used by the "consistent" and "weighted_round_robin" hash strategies, and overrides the weight set in the
+	// hostnames of the static resolver.
+	Weight int `mapstructure:"weight"`
+}
+
+// ExporterTemplate is the template of the exporters created for the endpoints
+type ExporterTemplate struct {
+	// Type is the type of the exporters, which has to be part of the collector distribution.
+	Type string `mapstructure:"type"`
+
+	// Config is the configuration of the exporters, where "{{endpoint}}" is replaced with the endpoint in all the
+	// string values, such as in the list of brokers of a "kafka" exporter.
+	Config map[string]any `mapstructure:"config"`
+}
+
+// EndpointOverride overrides the settings of the protocol templates for the endpoints matching its pattern
+type EndpointOverride struct {
+	// Endpoint is the exact, glob or suffix pattern of the endpoints, as for the denylist of the resolver. When
+	// several patterns match an endpoint, the most specific one is used.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// TLSSetting replaces the TLS settings of the template when set.
+	TLSSetting *configtls.ClientConfig `mapstructure:"tls"`
+
+	// Auth replaces the authentication of the template when set.
+	Auth *configauth.Authentication `mapstructure:"auth"`
+
+	// Headers are added to the headers of the template, replacing the ones with the same name.
+	Headers map[string]configopaque.String `mapstructure:"headers"`
 }
 
 // ResolverSettings defines the configurations for the backend resolver
@@ -35,11 +388,49 @@ type ResolverSettings struct {
 	Static *StaticResolver `mapstructure:"static"`
 	DNS    *DNSResolver    `mapstructure:"dns"`
 	K8sSvc *K8sSvcResolver `mapstructure:"k8s"`
+	XDS    *XDSResolver    `mapstructure:"xds"`
+	Consul *ConsulResolver `mapstructure:"consul"`
+
+	AWSCloudMap *AWSCloudMapResolver `mapstructure:"aws_cloud_map"`
+	AWSECS      *AWSECSResolver      `mapstructure:"aws_ecs"`
+	Etcd        *EtcdResolver        `mapstructure:"etcd"`
+	ZooKeeper   *ZooKeeperResolver   `mapstructure:"zookeeper"`
+	Eureka      *EurekaResolver      `mapstructure:"eureka"`
+	Nomad       *NomadResolver       `mapstructure:"nomad"`
+	File        *FileResolver        `mapstructure:"file"`
+	HTTP        *HTTPResolver        `mapstructure:"http"`
+
+	// Merge uses all the configured resolvers, merging their endpoints into a single list without duplicates,
+	// instead of allowing a single one.
+	Merge bool `mapstructure:"merge"`
+
+	// Fallback is an optional fixed list of backends, used only while the configured resolver yields no endpoints.
+	Fallback *StaticResolver `mapstructure:"fallback"`
+
+	// FallbackGroups are groups of backends used in their order of priority, each one only while the resolver and
+	// the groups before it have no healthy backends, the backends evicted by the health checks being unhealthy.
+	FallbackGroups []StaticResolver `mapstructure:"fallback_groups"`
+
+	// Denylist holds exact, glob or suffix patterns for endpoints that should never be used, even if resolved.
+	Denylist []string `mapstructure:"denylist"`
+
+	// Debounce is the window during which the changes of the endpoints following a first one are coalesced, the
+	// ring being rebuilt once with the latest endpoints at the end of the window. Disabled when zero.
+	Debounce time.Duration `mapstructure:"debounce"`
 }
 
 // StaticResolver defines the configuration for the resolver providing a fixed list of backends
 type StaticResolver struct {
+	// Hostnames are the backends, each optionally followed by its weight, such as "host-a:4317 weight=3".
 	Hostnames []string `mapstructure:"hostnames"`
+
+	// BackupHostnames are cold-standby backends, used in place of the hostnames only while the latest send to
+	// each of them failed. Only supported by the static resolver, not by the fallback.
+	BackupHostnames []string `mapstructure:"backup_hostnames"`
+
+	// BackupRecoveryInterval is the time spent on the backup hostnames before trying the hostnames again.
+	// 30s when not set.
+	BackupRecoveryInterval time.Duration `mapstructure:"backup_recovery_interval"`
 }
 
 // DNSResolver defines the configuration for the DNS resolver
@@ -48,10 +439,272 @@ type DNSResolver struct {
 	Port     string        `mapstructure:"port"`
 	Interval time.Duration `mapstructure:"interval"`
 	Timeout  time.Duration `mapstructure:"timeout"`
+
+	// IPFamily restricts the resolved addresses to the ones of a family: ipv4 for the A records only, ipv6 for the
+	// AAAA records only, or dual for both, which is the default.
+	IPFamily string `mapstructure:"ip_family"`
+
+	// SRV resolves the SRV records of the hostname instead of its addresses, using the target and port of each
+	// record. Disabled when not set.
+	SRV *DNSSRVSettings `mapstructure:"srv"`
+
+	// Backoff spaces out the resolutions while they keep failing, instead of retrying at every interval. Disabled
+	// when not set.
+	Backoff *DNSBackoffSettings `mapstructure:"backoff"`
+
+	// MaxStaleness is how long the last resolved endpoints are kept while the resolutions fail, after which they're
+	// dropped so that the fallback endpoints, if any, are used. The endpoints are kept until a resolution succeeds
+	// when not set.
+	MaxStaleness time.Duration `mapstructure:"max_staleness"`
+}
+
+// DNSBackoffSettings defines the exponential backoff applied to the DNS resolutions after consecutive failures,
+// starting from the resolution interval
+type DNSBackoffSettings struct {
+	// MaxInterval is the maximum time between two resolutions, before the jitter. 1m when not set.
+	MaxInterval time.Duration `mapstructure:"max_interval"`
+
+	// Multiplier is the factor applied to the time between two resolutions after each failure. 2 when not set.
+	Multiplier float64 `mapstructure:"multiplier"`
+
+	// RandomizationFactor is the jitter applied to the time between two resolutions, 0.5 meaning that it varies from
+	// 50% to 150% of the computed value. 0.5 when not set.
+	RandomizationFactor float64 `mapstructure:"randomization_factor"`
+}
+
+// DNSSRVSettings defines the SRV records resolved by the DNS resolver, the ones of _<service>._<proto>.<hostname>
+type DNSSRVSettings struct {
+	// Service is the symbolic name of the service, otlp when not set.
+	Service string `mapstructure:"service"`
+
+	// Proto is the protocol of the service, tcp when not set.
+	Proto string `mapstructure:"proto"`
+
+	// LowestPriorityOnly keeps only the records with the lowest priority value, the other ones being meant as
+	// backups, instead of using all of them.
+	LowestPriorityOnly bool `mapstructure:"lowest_priority_only"`
 }
 
 // K8sSvcResolver defines the configuration for the DNS resolver
 type K8sSvcResolver struct {
 	Service string  `mapstructure:"service"`
 	Ports   []int32 `mapstructure:"ports"`
+
+	// Services holds more services to resolve, possibly in other namespaces, their endpoints being merged with the
+	// ones of the service into a single ring.
+	Services []string `mapstructure:"services"`
+
+	// UseEndpointSlices watches the discovery.k8s.io/v1 EndpointSlices of the service instead of its Endpoints,
+	// which are limited to 1000 addresses.
+	UseEndpointSlices bool `mapstructure:"use_endpoint_slices"`
+
+	// PodSelector is a label selector, such as role=sampling-tier, only the endpoints of the pods matching it being
+	// used. All the endpoints are used when not set.
+	PodSelector string `mapstructure:"pod_selector"`
+
+	// IncludeNotReady also uses the addresses of the endpoints that aren't ready yet, so that the ring changes once
+	// during a rollout, when the new pods are created, instead of when they get ready.
+	IncludeNotReady bool `mapstructure:"include_not_ready"`
+
+	// ReturnHostnames uses the DNS names of the pods, <hostname>.<service>.<namespace>, instead of their addresses,
+	// such as for the TLS certificates of the pods to be verified. Only the pods with a hostname are used.
+	ReturnHostnames bool `mapstructure:"return_hostnames"`
+
+	// ClusterDomain is the domain of the cluster, such as cluster.local, making the names of the pods fully qualified
+	// when returning the hostnames.
+	ClusterDomain string `mapstructure:"cluster_domain"`
+
+	// Zones holds the topology zones of the endpoints to use, only supported along with the EndpointSlices. All the
+	// endpoints are used when not set.
+	Zones []string `mapstructure:"zones"`
+
+	// AuthType selects the credentials used to connect to the API server: serviceAccount for the in-cluster service
+	// account, or kubeConfig for a kubeconfig file, such as when the collector runs outside the cluster. When not set,
+	// the kubeconfig set by the environment is used if any, and the in-cluster service account otherwise.
+	AuthType string `mapstructure:"auth_type"`
+
+	// KubeConfig is the path of the kubeconfig file used with the kubeConfig auth type. The KUBECONFIG environment
+	// variable or ~/.kube/config are used when not set.
+	KubeConfig string `mapstructure:"kube_config"`
+
+	// Context is the context of the kubeconfig file used with the kubeConfig auth type, instead of its current one.
+	Context string `mapstructure:"context"`
+}
+
+// XDSResolver defines the configuration for the resolver subscribing to the endpoints of a cluster on an xDS
+// management server
+type XDSResolver struct {
+	// Server is the address of the management server.
+	Server string `mapstructure:"server"`
+
+	// ResourceName is the name of the cluster whose endpoints are used.
+	ResourceName string `mapstructure:"resource_name"`
+
+	// NodeID identifies this collector to the management server.
+	NodeID string `mapstructure:"node_id"`
+
+	// RetryInterval is the time to wait before opening a new stream to the management server after a failure.
+	RetryInterval time.Duration `mapstructure:"retry_interval"`
+
+	// TLS configures the connection to the management server.
+	TLS configtls.ClientConfig `mapstructure:"tls"`
+}
+
+// ConsulResolver defines the configuration for the resolver watching the instances of a service in the Consul catalog
+type ConsulResolver struct {
+	// Address is the address of the Consul agent, as well as Scheme its URI scheme. The CONSUL_HTTP_ADDR
+	// environment variable, or 127.0.0.1:8500, is used when not set.
+	Address string `mapstructure:"address"`
+	Scheme  string `mapstructure:"scheme"`
+
+	// Token is the ACL token used for the queries.
+	Token configopaque.String `mapstructure:"token"`
+
+	// Service is the name of the service whose instances are used.
+	Service string `mapstructure:"service"`
+
+	// Datacenter is the datacenter of the service, the datacenter of the agent when not set.
+	Datacenter string `mapstructure:"datacenter"`
+
+	// Tags filters the instances of the service, keeping only the ones having all of them.
+	Tags []string `mapstructure:"tags"`
+
+	// WaitTime is the maximum time a blocking query waits for a change of the instances.
+	WaitTime time.Duration `mapstructure:"wait_time"`
+
+	// RetryInterval is the time to wait before querying again after a failed query.
+	RetryInterval time.Duration `mapstructure:"retry_interval"`
+}
+
+// AWSCloudMapResolver defines the configuration for the resolver discovering the instances of a service registered
+// in AWS Cloud Map
+type AWSCloudMapResolver struct {
+	// NamespaceName is the name of the Cloud Map namespace of the service.
+	NamespaceName string `mapstructure:"namespace"`
+
+	// ServiceName is the name of the service whose instances are used.
+	ServiceName string `mapstructure:"service_name"`
+
+	// HealthStatus filters the instances by their health status: HEALTHY (default), UNHEALTHY, ALL or
+	// HEALTHY_OR_ELSE_ALL, returning all the instances when none is healthy.
+	HealthStatus string `mapstructure:"health_status"`
+
+	// Port overrides the port the instances were registered with.
+	Port *uint16 `mapstructure:"port"`
+
+	// Region is the AWS region of the namespace, taken from the environment when not set.
+	Region   string        `mapstructure:"region"`
+	Interval time.Duration `mapstructure:"interval"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+// AWSECSResolver defines the configuration for the resolver discovering the running tasks of an ECS service
+type AWSECSResolver struct {
+	// Cluster is the name or ARN of the cluster of the service.
+	Cluster string `mapstructure:"cluster"`
+
+	// ServiceName is the name of the service whose tasks are used.
+	ServiceName string `mapstructure:"service_name"`
+
+	// Port is the container port the tasks receive the telemetry on, 4317 when not set.
+	Port uint16 `mapstructure:"port"`
+
+	// Region is the AWS region of the cluster, taken from the environment when not set.
+	Region   string        `mapstructure:"region"`
+	Interval time.Duration `mapstructure:"interval"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+// EtcdResolver defines the configuration for the resolver watching the backends registered under a key prefix in etcd
+type EtcdResolver struct {
+	// Endpoints are the addresses of the etcd cluster members.
+	Endpoints []string `mapstructure:"endpoints"`
+
+	// Prefix is the key prefix the backends register themselves under, one key each.
+	Prefix string `mapstructure:"prefix"`
+
+	// Username and Password authenticate the client, when the cluster has authentication enabled.
+	Username string              `mapstructure:"username"`
+	Password configopaque.String `mapstructure:"password"`
+
+	// DialTimeout is the maximum time to wait for the connection to the cluster.
+	DialTimeout time.Duration `mapstructure:"dial_timeout"`
+
+	// RetryInterval is the time to wait before watching again after the watch failed.
+	RetryInterval time.Duration `mapstructure:"retry_interval"`
+
+	// TLS configures the connection to the cluster.
+	TLS configtls.ClientConfig `mapstructure:"tls"`
+}
+
+// ZooKeeperResolver defines the configuration for the resolver watching the backends registered as the children of a
+// ZooKeeper node
+type ZooKeeperResolver struct {
+	// Servers are the addresses of the ZooKeeper ensemble members.
+	Servers []string `mapstructure:"servers"`
+
+	// Path is the node the backends register themselves under, with one ephemeral child each.
+	Path string `mapstructure:"path"`
+
+	// SessionTimeout is the timeout of the ZooKeeper session.
+	SessionTimeout time.Duration `mapstructure:"session_timeout"`
+
+	// RetryInterval is the time to wait before fetching the children again after a failure.
+	RetryInterval time.Duration `mapstructure:"retry_interval"`
+}
+
+// EurekaResolver defines the configuration for the resolver polling the instances of an application from Eureka
+type EurekaResolver struct {
+	// ServerURL is the base URL of the Eureka REST API, such as http://eureka:8761/eureka.
+	ServerURL string `mapstructure:"server_url"`
+
+	// Application is the name of the application whose instances are used.
+	Application string `mapstructure:"application"`
+
+	// VIPAddress selects the instances by their VIP address instead of their application.
+	VIPAddress string `mapstructure:"vip_address"`
+
+	// Port overrides the port the instances were registered with.
+	Port *uint16 `mapstructure:"port"`
+
+	Interval time.Duration `mapstructure:"interval"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+// NomadResolver defines the configuration for the resolver watching the instances of a service registered with the
+// Nomad native service discovery
+type NomadResolver struct {
+	// Address is the address of the Nomad HTTP API. The NOMAD_ADDR environment variable, or
+	// http://127.0.0.1:4646, is used when not set.
+	Address string `mapstructure:"address"`
+
+	// Token is the ACL token used for the queries. The NOMAD_TOKEN environment variable is used when not set.
+	Token configopaque.String `mapstructure:"token"`
+
+	// Service is the name of the service whose instances are used.
+	Service string `mapstructure:"service"`
+
+	// Namespace and Region are the ones of the service, the default ones of the agent when not set.
+	Namespace string `mapstructure:"namespace"`
+	Region    string `mapstructure:"region"`
+
+	// WaitTime is the maximum time a blocking query waits for a change of the instances.
+	WaitTime time.Duration `mapstructure:"wait_time"`
+
+	// RetryInterval is the time to wait before querying again after a failed query.
+	RetryInterval time.Duration `mapstructure:"retry_interval"`
+}
+
+// FileResolver defines the configuration for the resolver reading the backends from a local file
+type FileResolver struct {
+	// Path is the path of the file, holding either a JSON array of endpoints or one endpoint per line.
+	Path string `mapstructure:"path"`
+}
+
+// HTTPResolver defines the configuration for the resolver polling the endpoints from an HTTP API, the endpoint
+// of the client being the URL returning them as a JSON array
+type HTTPResolver struct {
+	confighttp.ClientConfig `mapstructure:",squash"`
+
+	Interval time.Duration `mapstructure:"interval"`
 }
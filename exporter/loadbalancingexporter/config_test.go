@@ -6,10 +6,13 @@ package loadbalancingexporter
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/confmap/confmaptest"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter/internal/metadata"
 )
@@ -25,3 +28,27 @@ func TestLoadConfig(t *testing.T) {
 	require.NoError(t, component.UnmarshalConfig(sub, cfg))
 	require.NotNil(t, cfg)
 }
+
+func TestLoadConfigResilience(t *testing.T) {
+	cm, err := confmaptest.LoadConf(filepath.Join("testdata", "config.yaml"))
+	require.NoError(t, err)
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+
+	// the queue and the retries of the whole batches are disabled by default
+	assert.False(t, cfg.QueueSettings.Enabled)
+	assert.False(t, cfg.BackOffConfig.Enabled)
+	assert.Zero(t, cfg.Timeout)
+
+	sub, err := cm.Sub(component.NewIDWithName(metadata.Type, "resilience").String())
+	require.NoError(t, err)
+	require.NoError(t, component.UnmarshalConfig(sub, cfg))
+
+	assert.Equal(t, 10*time.Second, cfg.Timeout)
+	assert.True(t, cfg.QueueSettings.Enabled)
+	assert.Equal(t, 1000, cfg.QueueSettings.QueueSize)
+	assert.Equal(t, exporterhelper.NewDefaultQueueSettings().NumConsumers, cfg.QueueSettings.NumConsumers)
+	assert.True(t, cfg.BackOffConfig.Enabled)
+	assert.Equal(t, time.Minute, cfg.BackOffConfig.MaxElapsedTime)
+	assert.NoError(t, component.ValidateConfig(cfg))
+}
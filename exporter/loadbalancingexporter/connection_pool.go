@@ -0,0 +1,112 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/multierr"
+)
+
+var (
+	_ exporter.Traces  = (*exporterPool)(nil)
+	_ exporter.Metrics = (*exporterPool)(nil)
+	_ exporter.Logs    = (*exporterPool)(nil)
+)
+
+// exporterPool holds several exporters for the same endpoint, each one with its own connection to the backend,
+// distributing the data among them in a round-robin fashion. This avoids a single connection becoming the
+// bottleneck when the backend limits the number of concurrent streams per connection.
+type exporterPool struct {
+	members []component.Component
+	next    atomic.Uint64
+}
+
+func newExporterPool(members []component.Component) *exporterPool {
+	return &exporterPool{members: members}
+}
+
+// newPooledExporter creates size exporters for the endpoint with the given factory, returning them as a pool.
+func newPooledExporter(ctx context.Context, factory componentFactory, endpoint string, size int) (component.Component, error) {
+	members := make([]component.Component, 0, size)
+	for i := 0; i < size; i++ {
+		exp, err := factory(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, exp)
+	}
+	return newExporterPool(members), nil
+}
+
+func (p *exporterPool) Start(ctx context.Context, host component.Host) error {
+	for i, member := range p.members {
+		if err := member.Start(ctx, host); err != nil {
+			// shut down the members already started, so that their connections aren't leaked
+			for _, started := range p.members[:i] {
+				_ = started.Shutdown(ctx)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *exporterPool) Shutdown(ctx context.Context) error {
+	var errs error
+	for _, member := range p.members {
+		errs = multierr.Append(errs, member.Shutdown(ctx))
+	}
+	return errs
+}
+
+// Capabilities returns the capabilities of the members of the pool, the data being mutated when any of them does.
+func (p *exporterPool) Capabilities() consumer.Capabilities {
+	for _, member := range p.members {
+		if c, ok := member.(interface{ Capabilities() consumer.Capabilities }); ok && c.Capabilities().MutatesData {
+			return consumer.Capabilities{MutatesData: true}
+		}
+	}
+	return consumer.Capabilities{MutatesData: false}
+}
+
+// pick returns the next member of the pool.
+func (p *exporterPool) pick() component.Component {
+	return p.members[(p.next.Add(1)-1)%uint64(len(p.members))]
+}
+
+func (p *exporterPool) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	member := p.pick()
+	te, ok := member.(exporter.Traces)
+	if !ok {
+		return fmt.Errorf("unable to export traces, unexpected exporter type: expected exporter.Traces but got %T", member)
+	}
+	return te.ConsumeTraces(ctx, td)
+}
+
+func (p *exporterPool) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	member := p.pick()
+	me, ok := member.(exporter.Metrics)
+	if !ok {
+		return fmt.Errorf("unable to export metrics, unexpected exporter type: expected exporter.Metrics but got %T", member)
+	}
+	return me.ConsumeMetrics(ctx, md)
+}
+
+func (p *exporterPool) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	member := p.pick()
+	le, ok := member.(exporter.Logs)
+	if !ok {
+		return fmt.Errorf("unable to export logs, unexpected exporter type: expected exporter.Logs but got %T", member)
+	}
+	return le.ConsumeLogs(ctx, ld)
+}
@@ -0,0 +1,162 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestExporterPoolRoundRobin(t *testing.T) {
+	// prepare
+	var sinks []*consumertest.TracesSink
+	var members []component.Component
+	for i := 0; i < 3; i++ {
+		sink := new(consumertest.TracesSink)
+		sinks = append(sinks, sink)
+		members = append(members, newMockTracesExporter(sink.ConsumeTraces))
+	}
+	p := newExporterPool(members)
+
+	// test
+	for i := 0; i < 6; i++ {
+		require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+	}
+
+	// verify
+	for _, sink := range sinks {
+		assert.Len(t, sink.AllTraces(), 2)
+	}
+}
+
+func TestExporterPoolStartFailure(t *testing.T) {
+	// prepare
+	shutdown := 0
+	started := mockComponent{
+		ShutdownFunc: func(context.Context) error {
+			shutdown++
+			return nil
+		},
+	}
+	failing := mockComponent{
+		StartFunc: func(context.Context, component.Host) error {
+			return errors.New("failed to connect")
+		},
+	}
+	p := newExporterPool([]component.Component{started, failing})
+
+	// test
+	err := p.Start(context.Background(), componenttest.NewNopHost())
+
+	// verify
+	assert.Error(t, err)
+	assert.Equal(t, 1, shutdown)
+}
+
+func TestLoadBalancerConnectionPool(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.ConnectionPoolSize = 3
+	created := map[string]int{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		created[endpoint]++
+		return newNopMockTracesExporter(), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	// test
+	err = lb.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, map[string]int{"endpoint-1:4317": 3}, created)
+	require.Contains(t, lb.exporters, "endpoint-1:4317")
+	assert.IsType(t, &exporterPool{}, lb.exporters["endpoint-1:4317"].Component)
+}
+
+func TestConnectionPoolSendsThroughWrappedExporter(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.ConnectionPoolSize = 2
+	var sinks []*consumertest.TracesSink
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks = append(sinks, sink)
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	for i := 0; i < 4; i++ {
+		require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+	}
+
+	// verify
+	require.Len(t, sinks, 2)
+	for _, sink := range sinks {
+		assert.Len(t, sink.AllTraces(), 2)
+	}
+}
+
+// streamLimitedExporter imitates a connection to a backend accepting a single concurrent stream.
+func streamLimitedExporter() component.Component {
+	streams := make(chan struct{}, 1)
+	return newMockTracesExporter(func(context.Context, ptrace.Traces) error {
+		streams <- struct{}{}
+		time.Sleep(100 * time.Microsecond)
+		<-streams
+		return nil
+	})
+}
+
+func benchConsumeTracesConnectionPool(b *testing.B, poolSize int) {
+	var members []component.Component
+	for i := 0; i < poolSize; i++ {
+		members = append(members, streamLimitedExporter())
+	}
+	p := newExporterPool(members)
+	td := simpleTraces()
+
+	b.SetParallelism(8)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = p.ConsumeTraces(context.Background(), td)
+		}
+	})
+}
+
+func BenchmarkConsumeTracesConnectionPool(b *testing.B) {
+	for _, poolSize := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("pool_size_%d", poolSize), func(b *testing.B) {
+			benchConsumeTracesConnectionPool(b, poolSize)
+		})
+	}
+}
@@ -4,13 +4,64 @@
 package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
 
 import (
+	"encoding/binary"
+	"fmt"
 	"hash/crc32"
+	"hash/fnv"
 	"sort"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+var (
+	_ ring       = (*hashRing)(nil)
+	_ ringLayout = (*hashRing)(nil)
 )
 
 const maxPositions uint32 = 36000 // 360 degrees with two decimal places
 const defaultWeight int = 100     // the number of points in the ring for each entry. For better results, it should be higher than 100.
 
+const (
+	crc32HashFunction  = "crc32"
+	xxhashHashFunction = "xxhash"
+	fnvHashFunction    = "fnv"
+)
+
+// ringHash hashes the endpoints and the identifiers into the positions of the ring.
+type ringHash func(data []byte) uint32
+
+// newRingHash returns the hash function with the given name, crc32 by default.
+func newRingHash(name string) (ringHash, error) {
+	switch name {
+	case crc32HashFunction, "":
+		return crc32.ChecksumIEEE, nil
+	case xxhashHashFunction:
+		return func(data []byte) uint32 {
+			return uint32(xxhash.Sum64(data))
+		}, nil
+	case fnvHashFunction:
+		return func(data []byte) uint32 {
+			hasher := fnv.New32a()
+			hasher.Write(data)
+			return hasher.Sum32()
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported hash_function: %q", name)
+	}
+}
+
+// hashRingOptions customizes the consistent hash rings.
+type hashRingOptions struct {
+	// virtualNodes is the number of positions of the endpoints with the default weight, defaultWeight when zero
+	virtualNodes int
+
+	// hash computes the positions in the ring, crc32 when nil
+	hash ringHash
+
+	// weight returns the weight of the endpoint, multiplying its number of positions, 1 when nil
+	weight func(endpoint string) int
+}
+
 // position represents a specific angle in the ring.
 // Each entry in the ring is positioned at an angle in a hypothetical circle, meaning that it ranges from 0 to 360.
 type position uint32
@@ -25,6 +76,12 @@ type ringItem struct {
 type hashRing struct {
 	// ringItems holds all the positions, used for the lookup the position for the closest next ring item
 	items []ringItem
+
+	// draining holds the endpoints that were removed from the ring but still accept the identifiers already routed to them
+	draining map[string]bool
+
+	// hash computes the positions in the ring, crc32 when nil
+	hash ringHash
 }
 
 // newHashRing builds a new immutable consistent hash ring based on the given endpoints.
@@ -35,18 +92,98 @@ func newHashRing(endpoints []string) *hashRing {
 	}
 }
 
+// newHashRingWithDraining builds a new consistent hash ring based on the given endpoints, keeping the draining
+// endpoints available only for the identifiers that were already routed to them.
+func newHashRingWithDraining(endpoints []string, draining []string) *hashRing {
+	return newHashRingWithOptions(endpoints, draining, hashRingOptions{})
+}
+
+// newHashRingWithOptions builds a new consistent hash ring based on the given endpoints, each of them getting a
+// number of positions in the ring in proportion to its weight. The draining endpoints are kept available only for
+// the identifiers that were already routed to them.
+func newHashRingWithOptions(endpoints []string, draining []string, opts hashRingOptions) *hashRing {
+	virtualNodes := opts.virtualNodes
+	if virtualNodes == 0 {
+		virtualNodes = defaultWeight
+	}
+	hash := opts.hash
+	if hash == nil {
+		hash = crc32.ChecksumIEEE
+	}
+	ring := &hashRing{
+		items: positionsForWeightedEndpoints(endpoints, func(endpoint string) int {
+			if opts.weight == nil {
+				return virtualNodes
+			}
+			return virtualNodes * opts.weight(endpoint)
+		}, hash),
+		hash: opts.hash,
+	}
+	if len(draining) > 0 {
+		ring.draining = make(map[string]bool, len(draining))
+		for _, endpoint := range draining {
+			ring.draining[endpoint] = true
+		}
+	}
+	return ring
+}
+
+// endpointForKnown calculates which backend is responsible for an identifier previously routed to the given
+// endpoint: while that endpoint is draining, the identifier keeps being routed to it.
+func (h *hashRing) endpointForKnown(identifier []byte, previous string) string {
+	if h != nil && h.draining[previous] {
+		return previous
+	}
+	return h.endpointFor(identifier)
+}
+
 // endpointFor calculates which backend is responsible for the given traceID
 func (h *hashRing) endpointFor(identifier []byte) string {
 	if h == nil {
 		// perhaps the ring itself couldn't get initialized yet?
 		return ""
 	}
-	hasher := crc32.NewIEEE()
-	hasher.Write(identifier)
-	hash := hasher.Sum32()
-	pos := hash % maxPositions
+	return h.findEndpoint(h.positionFor(identifier))
+}
+
+// endpointForBounded returns the endpoint for the given identifier, unless the given function rejects it, in which
+// case the next endpoints on the ring are tried in turn. The endpoint for the identifier is returned when all the
+// endpoints are rejected.
+func (h *hashRing) endpointForBounded(identifier []byte, accepts func(endpoint string) bool) string {
+	if h == nil || len(h.items) == 0 {
+		return ""
+	}
+	pos := h.positionFor(identifier)
+
+	// the first item at or after the position, wrapping around the ring, is the one found by findEndpoint
+	start := sort.Search(len(h.items), func(i int) bool {
+		return h.items[i].pos >= pos
+	})
+	first := h.items[start%len(h.items)].endpoint
+	if accepts(first) {
+		return first
+	}
 
-	return h.findEndpoint(position(pos))
+	tried := map[string]bool{first: true}
+	for i := 1; i < len(h.items); i++ {
+		endpoint := h.items[(start+i)%len(h.items)].endpoint
+		if tried[endpoint] {
+			continue
+		}
+		if accepts(endpoint) {
+			return endpoint
+		}
+		tried[endpoint] = true
+	}
+	return first
+}
+
+// positionFor returns the position of the identifier in the ring.
+func (h *hashRing) positionFor(identifier []byte) position {
+	if h.hash == nil {
+		return position(crc32.ChecksumIEEE(identifier) % maxPositions)
+	}
+	return position(h.hash(identifier) % maxPositions)
 }
 
 // findEndpoint returns the "next" endpoint starting from the given position, or an empty string in case no endpoints are available
@@ -100,13 +237,24 @@ func bsearch(pos position, left []ringItem, right []ringItem) ringItem {
 // positionFor calculates all the positions in the ring based. The numPoints indicates how many positions to calculate.
 // The slice length of the result matches the numPoints.
 func positionsFor(endpoint string, numPoints int) []position {
+	return positionsWithHash(endpoint, numPoints, crc32.ChecksumIEEE)
+}
+
+// positionsWithHash calculates the positions in the ring like positionsFor, with the given hash function.
+func positionsWithHash(endpoint string, numPoints int, hash ringHash) []position {
 	res := make([]position, 0, numPoints)
+	buf := make([]byte, 0, len(endpoint)+4)
 	for i := 0; i < numPoints; i++ {
-		h := crc32.NewIEEE()
-		h.Write([]byte(endpoint))
-		h.Write([]byte{byte(i)})
-		hash := h.Sum32()
-		pos := hash % maxPositions
+		buf = append(buf[:0], endpoint...)
+		// the first 256 points are hashed with a single byte, additional bytes only being needed for the heavier
+		// endpoints, so that the positions of the endpoints with the default weight stay the same
+		for v := i; ; v >>= 8 {
+			buf = append(buf, byte(v))
+			if v>>8 == 0 {
+				break
+			}
+		}
+		pos := hash(buf) % maxPositions
 		res = append(res, position(pos))
 	}
 
@@ -115,11 +263,18 @@ func positionsFor(endpoint string, numPoints int) []position {
 
 // positionsForEndpoints calculates all the positions for all the given endpoints
 func positionsForEndpoints(endpoints []string, weight int) []ringItem {
+	return positionsForWeightedEndpoints(endpoints, func(string) int {
+		return weight
+	}, crc32.ChecksumIEEE)
+}
+
+// positionsForWeightedEndpoints calculates all the positions for all the given endpoints, the number of positions
+// of each endpoint being returned by the given function
+func positionsForWeightedEndpoints(endpoints []string, numPoints func(endpoint string) int, hash ringHash) []ringItem {
 	var items []ringItem
 	positions := map[position]bool{} // tracking the used positions
 	for _, endpoint := range endpoints {
-		// for this initial implementation, we don't allow endpoints to have custom weights
-		for _, pos := range positionsFor(endpoint, weight) {
+		for _, pos := range positionsWithHash(endpoint, numPoints(endpoint), hash) {
 			// if this position is occupied already, skip this item
 			if _, found := positions[pos]; found {
 				continue
@@ -141,8 +296,9 @@ func positionsForEndpoints(endpoints []string, weight int) []ringItem {
 	return items
 }
 
-func (h *hashRing) equal(candidate *hashRing) bool {
-	if candidate == nil {
+func (h *hashRing) equal(other ring) bool {
+	candidate, ok := other.(*hashRing)
+	if !ok || candidate == nil {
 		return false
 	}
 
@@ -159,3 +315,48 @@ func (h *hashRing) equal(candidate *hashRing) bool {
 	}
 	return true
 }
+
+// endpoints returns the sorted list of distinct endpoints in the ring.
+func (h *hashRing) endpoints() []string {
+	seen := map[string]bool{}
+	var endpoints []string
+	for _, item := range h.items {
+		if !seen[item.endpoint] {
+			seen[item.endpoint] = true
+			endpoints = append(endpoints, item.endpoint)
+		}
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// fingerprint returns a hash of all the positions and endpoints in the ring. Two rings with the same fingerprint
+// route all identifiers in the same way.
+func (h *hashRing) fingerprint() string {
+	hasher := fnv.New64a()
+	buf := make([]byte, 4)
+	for _, item := range h.items {
+		binary.BigEndian.PutUint32(buf, uint32(item.pos))
+		hasher.Write(buf)
+		hasher.Write([]byte(item.endpoint))
+	}
+	return fmt.Sprintf("%016x", hasher.Sum64())
+}
+
+// layout counts the virtual nodes of each endpoint, each of them getting the identifiers positioned between the
+// previous virtual node and itself.
+func (h *hashRing) layout() map[string]endpointLayout {
+	result := map[string]endpointLayout{}
+	for i, item := range h.items {
+		// the first item also gets the positions after the last one, wrapping around the ring
+		previous := int64(h.items[len(h.items)-1].pos) - int64(maxPositions)
+		if i > 0 {
+			previous = int64(h.items[i-1].pos)
+		}
+		l := result[item.endpoint]
+		l.positions++
+		l.share += float64(int64(item.pos)-previous) / float64(maxPositions)
+		result[item.endpoint] = l
+	}
+	return result
+}
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewHashRing(t *testing.T) {
@@ -47,6 +48,59 @@ func TestEndpointFor(t *testing.T) {
 	}
 }
 
+func TestWeightedHashRing(t *testing.T) {
+	// prepare
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	weights := map[string]int{"endpoint-1": 3}
+
+	// test
+	ring := newHashRingWithOptions(endpoints, nil, hashRingOptions{weight: func(endpoint string) int {
+		if weight, ok := weights[endpoint]; ok {
+			return weight
+		}
+		return defaultEndpointWeight
+	}})
+
+	// verify
+	routed := map[string]int{}
+	for _, item := range ring.items {
+		routed[item.endpoint]++
+	}
+	assert.InDelta(t, 3*defaultWeight, routed["endpoint-1"], 5)
+	assert.InDelta(t, defaultWeight, routed["endpoint-2"], 5)
+
+	// the endpoints with the default weight keep their positions
+	assert.True(t, newHashRing(endpoints).equal(newHashRingWithOptions(endpoints, nil, hashRingOptions{weight: func(string) int {
+		return defaultEndpointWeight
+	}})))
+}
+
+func TestHashRingOptions(t *testing.T) {
+	// prepare
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	defaultRing := newHashRing(endpoints)
+
+	for _, name := range []string{crc32HashFunction, xxhashHashFunction, fnvHashFunction} {
+		t.Run(name, func(t *testing.T) {
+			hash, err := newRingHash(name)
+			require.NoError(t, err)
+
+			// test
+			ring := newHashRingWithOptions(endpoints, nil, hashRingOptions{virtualNodes: 500, hash: hash})
+
+			// verify
+			assert.InDelta(t, 2*500, len(ring.items), 20)
+			assert.Equal(t, endpoints, ring.endpoints())
+			assert.NotEqual(t, defaultRing.fingerprint(), ring.fingerprint())
+			assert.True(t, ring.equal(newHashRingWithOptions(endpoints, nil, hashRingOptions{virtualNodes: 500, hash: hash})))
+			assert.NotEmpty(t, ring.endpointFor([]byte("get-recommendations-1")))
+		})
+	}
+
+	_, err := newRingHash("md5")
+	assert.EqualError(t, err, `unsupported hash_function: "md5"`)
+}
+
 func TestPositionsFor(t *testing.T) {
 	// prepare
 	endpoint := "host1"
@@ -56,6 +110,13 @@ func TestPositionsFor(t *testing.T) {
 
 	// verify
 	assert.Len(t, positions, 10)
+
+	// more than 256 positions don't wrap around
+	distinct := map[position]bool{}
+	for _, pos := range positionsFor(endpoint, 1000) {
+		distinct[pos] = true
+	}
+	assert.Greater(t, len(distinct), 900)
 }
 
 func TestBinarySearch(t *testing.T) {
@@ -156,7 +217,7 @@ func TestPositionsForEndpoints(t *testing.T) {
 
 func TestEqual(t *testing.T) {
 	original := &hashRing{
-		[]ringItem{
+		items: []ringItem{
 			{pos: position(123), endpoint: "endpoint-1"},
 		},
 	}
@@ -168,7 +229,7 @@ func TestEqual(t *testing.T) {
 	}{
 		{
 			"empty",
-			&hashRing{[]ringItem{}},
+			&hashRing{items: []ringItem{}},
 			false,
 		},
 		{
@@ -179,7 +240,7 @@ func TestEqual(t *testing.T) {
 		{
 			"equal",
 			&hashRing{
-				[]ringItem{
+				items: []ringItem{
 					{pos: position(123), endpoint: "endpoint-1"},
 				},
 			},
@@ -188,7 +249,7 @@ func TestEqual(t *testing.T) {
 		{
 			"different length",
 			&hashRing{
-				[]ringItem{
+				items: []ringItem{
 					{pos: position(123), endpoint: "endpoint-1"},
 					{pos: position(124), endpoint: "endpoint-2"},
 				},
@@ -198,7 +259,7 @@ func TestEqual(t *testing.T) {
 		{
 			"different position",
 			&hashRing{
-				[]ringItem{
+				items: []ringItem{
 					{pos: position(124), endpoint: "endpoint-1"},
 				},
 			},
@@ -207,7 +268,7 @@ func TestEqual(t *testing.T) {
 		{
 			"different endpoint",
 			&hashRing{
-				[]ringItem{
+				items: []ringItem{
 					{pos: position(123), endpoint: "endpoint-2"},
 				},
 			},
@@ -219,3 +280,25 @@ func TestEqual(t *testing.T) {
 		})
 	}
 }
+
+func TestRingFingerprint(t *testing.T) {
+	// prepare
+	ring := newHashRing([]string{"endpoint-1", "endpoint-2"})
+
+	// test and verify
+	assert.Equal(t, ring.fingerprint(), newHashRing([]string{"endpoint-2", "endpoint-1"}).fingerprint())
+	assert.NotEqual(t, ring.fingerprint(), newHashRing([]string{"endpoint-1", "endpoint-3"}).fingerprint())
+	assert.NotEqual(t, ring.fingerprint(), newHashRing([]string{"endpoint-1"}).fingerprint())
+	assert.Equal(t, []string{"endpoint-1", "endpoint-2"}, ring.endpoints())
+}
+
+func TestEndpointForKnownWhileDraining(t *testing.T) {
+	// prepare
+	ring := newHashRingWithDraining([]string{"endpoint-1"}, []string{"endpoint-2"})
+
+	// test and verify
+	assert.Equal(t, "endpoint-2", ring.endpointForKnown([]byte("get-recommendations-1"), "endpoint-2"))
+	assert.Equal(t, "endpoint-1", ring.endpointForKnown([]byte("get-recommendations-1"), "endpoint-3"))
+	assert.Equal(t, "endpoint-1", ring.endpointFor([]byte("get-recommendations-1")))
+	assert.Equal(t, []string{"endpoint-1"}, ring.endpoints())
+}
@@ -0,0 +1,77 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"net"
+	"path"
+	"strings"
+)
+
+// endpointMatches determines whether the given endpoint is selected by the pattern. A pattern can be:
+//   - an exact endpoint, such as "backend-1:4317" or "backend-1"
+//   - a glob, such as "*.region-a.svc" or "backend-?:4317"
+//   - a domain suffix starting with a dot, such as ".region-a.svc"
+//
+// Patterns without a port are matched against the host part of the endpoint only.
+func endpointMatches(pattern, endpoint string) bool {
+	candidates := []string{endpoint}
+	if host, _, err := net.SplitHostPort(endpoint); err == nil && !isUnixSocket(endpoint) {
+		candidates = append(candidates, host)
+	}
+
+	for _, candidate := range candidates {
+		if pattern == candidate {
+			return true
+		}
+		if strings.HasPrefix(pattern, ".") && strings.HasSuffix(candidate, pattern) {
+			return true
+		}
+		if matched, err := path.Match(pattern, candidate); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// bestMatch returns the index of the pattern that selects the given endpoint. When more than one pattern matches,
+// an exact pattern wins over any other, followed by the pattern with the most literal characters. Ties are broken
+// by the order of the patterns, so that the precedence is always deterministic.
+func bestMatch(endpoint string, patterns []string) (int, bool) {
+	best, bestScore := -1, -1
+	for i, pattern := range patterns {
+		if !endpointMatches(pattern, endpoint) {
+			continue
+		}
+		if score := patternSpecificity(pattern); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best, best >= 0
+}
+
+// patternSpecificity ranks how specific a pattern is: exact patterns rank above all others, the remaining ones
+// are ranked by the number of literal characters they contain.
+func patternSpecificity(pattern string) int {
+	if !strings.ContainsAny(pattern, "*?[") && !strings.HasPrefix(pattern, ".") {
+		return int(^uint(0) >> 1)
+	}
+	return len(pattern) - strings.Count(pattern, "*") - strings.Count(pattern, "?")
+}
+
+// filterEndpoints returns the endpoints that aren't selected by any of the given patterns.
+func filterEndpoints(endpoints []string, denylist []string) []string {
+	if len(denylist) == 0 {
+		return endpoints
+	}
+
+	filtered := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if _, denied := bestMatch(endpoint, denylist); denied {
+			continue
+		}
+		filtered = append(filtered, endpoint)
+	}
+	return filtered
+}
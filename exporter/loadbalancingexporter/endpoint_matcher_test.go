@@ -0,0 +1,91 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointMatches(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		pattern  string
+		endpoint string
+		expected bool
+	}{
+		{"exact with port", "backend-1:4317", "backend-1:4317", true},
+		{"exact host only", "backend-1", "backend-1:4317", true},
+		{"exact different host", "backend-1", "backend-2:4317", false},
+		{"glob match", "*.region-a.svc", "backend-1.region-a.svc:4317", true},
+		{"glob no match", "*.region-a.svc", "backend-1.region-b.svc:4317", false},
+		{"glob with port", "backend-?:4317", "backend-1:4317", true},
+		{"glob with different port", "backend-?:4317", "backend-1:55690", false},
+		{"suffix match", ".region-a.svc", "backend-1.zone-1.region-a.svc:4317", true},
+		{"suffix no match", ".region-a.svc", "backend-1.region-a.svc.other:4317", false},
+		{"ipv6", "[::1]:4317", "[::1]:4317", true},
+		{"ipv6 host only", "::1", "[::1]:4317", true},
+		{"invalid glob", "[backend", "backend-1:4317", false},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.expected, endpointMatches(tt.pattern, tt.endpoint))
+		})
+	}
+}
+
+func TestBestMatchPrecedence(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		patterns []string
+		endpoint string
+		expected int
+		found    bool
+	}{
+		{
+			"exact wins over globs",
+			[]string{"*", "*.region-a.svc", "backend-1.region-a.svc"},
+			"backend-1.region-a.svc:4317",
+			2,
+			true,
+		},
+		{
+			"most specific glob wins",
+			[]string{"*", "*.region-a.svc", "*.svc"},
+			"backend-1.region-a.svc:4317",
+			1,
+			true,
+		},
+		{
+			"suffix and glob with the same specificity, first declared wins",
+			[]string{"*.region-a.svc", ".region-a.svc"},
+			"backend-1.region-a.svc:4317",
+			0,
+			true,
+		},
+		{
+			"no match",
+			[]string{"*.region-b.svc", ".region-c.svc"},
+			"backend-1.region-a.svc:4317",
+			-1,
+			false,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				idx, found := bestMatch(tt.endpoint, tt.patterns)
+				assert.Equal(t, tt.expected, idx)
+				assert.Equal(t, tt.found, found)
+			}
+		})
+	}
+}
+
+func TestFilterEndpoints(t *testing.T) {
+	endpoints := []string{"backend-1.region-a.svc:4317", "backend-2.region-b.svc:4317", "backend-3:4317"}
+
+	assert.Equal(t, endpoints, filterEndpoints(endpoints, nil))
+	assert.Equal(t, []string{"backend-2.region-b.svc:4317"}, filterEndpoints(endpoints, []string{"*.region-a.svc", "backend-3"}))
+	assert.Empty(t, filterEndpoints(endpoints, []string{"*"}))
+}
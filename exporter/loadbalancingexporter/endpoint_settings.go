@@ -0,0 +1,157 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/config/configauth"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/config/configtls"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	"go.opentelemetry.io/collector/exporter/otlphttpexporter"
+)
+
+const (
+	otlpProtocol     = "otlp"
+	otlpHTTPProtocol = "otlphttp"
+
+	defaultHTTPScheme = "https"
+
+	// defaultEndpointWeight is the weight of the endpoints without one in their settings
+	defaultEndpointWeight = 1
+)
+
+// exporterFactories holds the factories for the sub-exporters, by protocol.
+var exporterFactories = map[string]exporter.Factory{
+	otlpProtocol:     otlpexporter.NewFactory(),
+	otlpHTTPProtocol: otlphttpexporter.NewFactory(),
+}
+
+// validateEndpointSettings checks that all the endpoints use a supported protocol and have a valid weight.
+func validateEndpointSettings(cfg *Config) error {
+	if cfg.Protocol.Default != "" {
+		if _, ok := exporterFactories[cfg.Protocol.Default]; !ok {
+			return fmt.Errorf("unsupported default protocol %q", cfg.Protocol.Default)
+		}
+	}
+	for endpoint, settings := range cfg.EndpointSettings {
+		if settings.Weight < 0 {
+			return fmt.Errorf("invalid weight %d for the endpoint %q, it must be positive", settings.Weight, endpoint)
+		}
+		if settings.Protocol == "" {
+			continue
+		}
+		if _, ok := exporterFactories[settings.Protocol]; !ok {
+			return fmt.Errorf("unsupported protocol %q for the endpoint %q", settings.Protocol, endpoint)
+		}
+	}
+	if cfg.Resolver.Static != nil && cfg.Exporter == nil {
+		for _, hostname := range cfg.Resolver.Static.Hostnames {
+			endpoint, _, err := parseStaticHostname(hostname)
+			if err == nil && isUnixSocket(endpoint) && endpointProtocol(cfg, endpoint) != otlpProtocol {
+				return fmt.Errorf("the endpoint %q is a unix domain socket, which only the otlp protocol supports", endpoint)
+			}
+		}
+	}
+	for i, override := range cfg.EndpointOverrides {
+		if override.Endpoint == "" {
+			return fmt.Errorf("no endpoint pattern specified for the endpoint override %d", i)
+		}
+	}
+	return nil
+}
+
+// endpointWeights returns the function giving the weight of an endpoint: the one from its settings, or else the one
+// from the hostnames of the static resolver, 1 by default.
+func endpointWeights(cfg *Config) func(endpoint string) int {
+	static := staticEndpointWeights(cfg.Resolver.Static)
+	return func(endpoint string) int {
+		if settings, ok := cfg.EndpointSettings[endpoint]; ok && settings.Weight > 0 {
+			return settings.Weight
+		}
+		if weight, ok := static[endpoint]; ok {
+			return weight
+		}
+		return defaultEndpointWeight
+	}
+}
+
+// endpointProtocol returns the protocol used to send data to the given endpoint, the default protocol unless set in
+// the endpoint settings, OTLP over gRPC when none is configured.
+func endpointProtocol(cfg *Config, endpoint string) string {
+	if settings, ok := cfg.EndpointSettings[endpoint]; ok && settings.Protocol != "" {
+		return settings.Protocol
+	}
+	if cfg.Protocol.Default != "" {
+		return cfg.Protocol.Default
+	}
+	return otlpProtocol
+}
+
+// endpointOverride returns the override for the given endpoint, from the most specific pattern matching it.
+func endpointOverride(cfg *Config, endpoint string) (EndpointOverride, bool) {
+	if len(cfg.EndpointOverrides) == 0 {
+		return EndpointOverride{}, false
+	}
+	patterns := make([]string, len(cfg.EndpointOverrides))
+	for i, override := range cfg.EndpointOverrides {
+		patterns[i] = override.Endpoint
+	}
+	i, ok := bestMatch(endpoint, patterns)
+	if !ok {
+		return EndpointOverride{}, false
+	}
+	return cfg.EndpointOverrides[i], true
+}
+
+// apply overrides the given settings of a protocol template.
+func (o EndpointOverride) apply(tls *configtls.ClientConfig, auth **configauth.Authentication, headers *map[string]configopaque.String) {
+	if o.TLSSetting != nil {
+		*tls = *o.TLSSetting
+	}
+	if o.Auth != nil {
+		*auth = o.Auth
+	}
+	if len(o.Headers) > 0 {
+		// the template's headers are shared by all the endpoints
+		merged := make(map[string]configopaque.String, len(*headers)+len(o.Headers))
+		for name, value := range *headers {
+			merged[name] = value
+		}
+		for name, value := range o.Headers {
+			merged[name] = value
+		}
+		*headers = merged
+	}
+}
+
+// exporterFactory returns the factory for the sub-exporter of the given endpoint.
+func (lb *loadBalancer) exporterFactory(endpoint string) exporter.Factory {
+	if lb.exporterTemplate != nil {
+		return lb.exporterTemplate.factory
+	}
+	protocol := endpointProtocol(lb.cfg, endpoint)
+	if protocol == otlpProtocol && lb.cfg.ShareConnections {
+		return newSharedConnectionFactory(lb.cfg)
+	}
+	return exporterFactories[protocol]
+}
+
+// buildHTTPExporterConfig builds an OTLP/HTTP exporter configuration for the endpoint, based on the otlphttp
+// protocol template. Endpoints without a scheme get the one from the template's endpoint.
+func buildHTTPExporterConfig(cfg *Config, endpoint string) otlphttpexporter.Config {
+	oCfg := cfg.Protocol.OTLPHTTP
+	if !strings.Contains(endpoint, "://") {
+		scheme := defaultHTTPScheme
+		if i := strings.Index(oCfg.Endpoint, "://"); i > 0 {
+			scheme = oCfg.Endpoint[:i]
+		}
+		endpoint = scheme + "://" + endpoint
+	}
+	oCfg.Endpoint = endpoint
+	return oCfg
+}
@@ -0,0 +1,272 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configauth"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/config/configtls"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	"go.opentelemetry.io/collector/exporter/otlphttpexporter"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"google.golang.org/grpc"
+)
+
+func TestExporterPerEndpointProtocol(t *testing.T) {
+	// prepare
+	cfg := createDefaultConfig().(*Config)
+	cfg.Resolver.Static = &StaticResolver{Hostnames: []string{"endpoint-1", "endpoint-2", "gateway:4318"}}
+	cfg.EndpointSettings = map[string]EndpointSettings{
+		"gateway:4318": {Protocol: otlpHTTPProtocol},
+	}
+
+	factories := map[string]component.Type{}
+	configs := map[string]component.Config{}
+	var lb *loadBalancer
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		factories[endpoint] = lb.exporterFactory(endpoint).Type()
+		configs[endpoint] = lb.exporterConfig(endpoint)
+		return newNopMockExporter(), nil
+	})
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	// test
+	err = lb.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	for _, endpoint := range []string{"endpoint-1:4317", "endpoint-2:4317"} {
+		assert.Equal(t, otlpProtocol, factories[endpoint].String())
+		require.IsType(t, &otlpexporter.Config{}, configs[endpoint])
+		assert.Equal(t, endpoint, configs[endpoint].(*otlpexporter.Config).Endpoint)
+	}
+	assert.Equal(t, otlpHTTPProtocol, factories["gateway:4318"].String())
+	require.IsType(t, &otlphttpexporter.Config{}, configs["gateway:4318"])
+	assert.Equal(t, "https://gateway:4318", configs["gateway:4318"].(*otlphttpexporter.Config).Endpoint)
+}
+
+func TestExporterDefaultProtocol(t *testing.T) {
+	// prepare
+	cfg := createDefaultConfig().(*Config)
+	cfg.Protocol.Default = otlpHTTPProtocol
+	cfg.Resolver.Static = &StaticResolver{Hostnames: []string{"gateway-1:443", "gateway-2:443", "endpoint-1"}}
+	cfg.EndpointSettings = map[string]EndpointSettings{
+		"endpoint-1:4317": {Protocol: otlpProtocol},
+	}
+
+	factories := map[string]component.Type{}
+	configs := map[string]component.Config{}
+	var lb *loadBalancer
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		factories[endpoint] = lb.exporterFactory(endpoint).Type()
+		configs[endpoint] = lb.exporterConfig(endpoint)
+		return newNopMockExporter(), nil
+	})
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	// test
+	err = lb.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	for _, endpoint := range []string{"gateway-1:443", "gateway-2:443"} {
+		assert.Equal(t, otlpHTTPProtocol, factories[endpoint].String())
+		require.IsType(t, &otlphttpexporter.Config{}, configs[endpoint])
+		assert.Equal(t, "https://"+endpoint, configs[endpoint].(*otlphttpexporter.Config).Endpoint)
+	}
+	// the endpoint settings take precedence
+	assert.Equal(t, otlpProtocol, factories["endpoint-1:4317"].String())
+	require.IsType(t, &otlpexporter.Config{}, configs["endpoint-1:4317"])
+}
+
+func TestUnsupportedDefaultProtocol(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.Protocol.Default = "otlp_http"
+
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+
+	assert.Nil(t, p)
+	assert.EqualError(t, err, `unsupported default protocol "otlp_http"`)
+}
+
+func TestExporterEndpointOverrides(t *testing.T) {
+	// prepare
+	cfg := createDefaultConfig().(*Config)
+	cfg.Protocol.OTLP.TLSSetting.CAFile = "cluster-a-ca.pem"
+	cfg.Protocol.OTLP.Headers = map[string]configopaque.String{"X-Scope": "collectors"}
+	cfg.EndpointSettings = map[string]EndpointSettings{
+		"gateway.cluster-b.svc:4318": {Protocol: otlpHTTPProtocol},
+	}
+	cfg.EndpointOverrides = []EndpointOverride{
+		{
+			Endpoint: ".cluster-b.svc",
+			TLSSetting: &configtls.ClientConfig{TLSSetting: configtls.Config{
+				CAFile:   "cluster-b-ca.pem",
+				CertFile: "cluster-b-client.pem",
+				KeyFile:  "cluster-b-client-key.pem",
+			}},
+			Headers: map[string]configopaque.String{"Authorization": "Bearer cluster-b"},
+		},
+		{
+			// more specific than the pattern above
+			Endpoint: "gateway.cluster-b.svc",
+			Auth:     &configauth.Authentication{AuthenticatorID: component.MustNewID("oauth2client")},
+		},
+	}
+
+	// test
+	regular := defaultExporterConfigBuilder(cfg, "backend-1.cluster-a.svc:4317").(*otlpexporter.Config)
+	overridden := defaultExporterConfigBuilder(cfg, "backend-1.cluster-b.svc:4317").(*otlpexporter.Config)
+	gateway := defaultExporterConfigBuilder(cfg, "gateway.cluster-b.svc:4318").(*otlphttpexporter.Config)
+
+	// verify
+	assert.Equal(t, "cluster-a-ca.pem", regular.TLSSetting.CAFile)
+	assert.Equal(t, map[string]configopaque.String{"X-Scope": "collectors"}, regular.Headers)
+
+	assert.Equal(t, "cluster-b-ca.pem", overridden.TLSSetting.CAFile)
+	assert.Equal(t, "cluster-b-client.pem", overridden.TLSSetting.CertFile)
+	assert.Equal(t, map[string]configopaque.String{"X-Scope": "collectors", "Authorization": "Bearer cluster-b"}, overridden.Headers)
+	assert.Nil(t, overridden.Auth)
+
+	require.NotNil(t, gateway.Auth)
+	assert.Equal(t, component.MustNewID("oauth2client"), gateway.Auth.AuthenticatorID)
+	assert.Empty(t, gateway.TLSSetting.CAFile)
+
+	// the template is left untouched
+	assert.Equal(t, map[string]configopaque.String{"X-Scope": "collectors"}, cfg.Protocol.OTLP.Headers)
+	assert.Equal(t, "cluster-a-ca.pem", cfg.Protocol.OTLP.TLSSetting.CAFile)
+}
+
+func TestEndpointOverrideWithoutPattern(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.EndpointOverrides = []EndpointOverride{{Headers: map[string]configopaque.String{"Authorization": "Bearer token"}}}
+
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+
+	assert.Nil(t, p)
+	assert.EqualError(t, err, "no endpoint pattern specified for the endpoint override 0")
+}
+
+func TestExporterPerEndpointProtocolCreatesExporters(t *testing.T) {
+	// prepare
+	cfg := createDefaultConfig().(*Config)
+	cfg.Resolver.Static = &StaticResolver{Hostnames: []string{"endpoint-1", "gateway:4318"}}
+	cfg.EndpointSettings = map[string]EndpointSettings{
+		"gateway:4318": {Protocol: otlpHTTPProtocol},
+	}
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	// test
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+
+	// verify
+	require.NoError(t, err)
+	assert.Len(t, p.loadBalancer.exporters, 2)
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestUnsupportedEndpointProtocol(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.EndpointSettings = map[string]EndpointSettings{
+		"endpoint-1:4317": {Protocol: "zipkin"},
+	}
+
+	// test
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+
+	// verify
+	assert.Nil(t, p)
+	assert.EqualError(t, err, `unsupported protocol "zipkin" for the endpoint "endpoint-1:4317"`)
+}
+
+func TestUnixSocketEndpointRequiresOTLP(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"unix:///var/run/collector.sock"}
+	cfg.Protocol.Default = otlpHTTPProtocol
+
+	// test
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+
+	// verify
+	assert.Nil(t, p)
+	assert.EqualError(t, err, `the endpoint "unix:///var/run/collector.sock" is a unix domain socket, which only the otlp protocol supports`)
+}
+
+func TestUnixSocketEndpoint(t *testing.T) {
+	// prepare
+	path := filepath.Join(t.TempDir(), "collector.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	traces := &otlpTestServer{}
+	ptraceotlp.RegisterGRPCServer(srv, traces)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	defer srv.Stop()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Resolver.Static = &StaticResolver{Hostnames: []string{"unix://" + path}}
+	cfg.Protocol.OTLP.TLSSetting.Insecure = true
+	cfg.Protocol.OTLP.QueueConfig.Enabled = false
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	err = p.ConsumeTraces(context.Background(), simpleTraces())
+
+	// verify
+	require.NoError(t, err)
+	assert.Contains(t, p.loadBalancer.exporters, "unix://"+path)
+	traces.lock.Lock()
+	defer traces.lock.Unlock()
+	assert.Equal(t, 1, traces.spans)
+}
+
+func TestBuildHTTPExporterConfig(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		template string
+		endpoint string
+		expected string
+	}{
+		{"scheme from the template", "http://placeholder:4318", "gateway:4318", "http://gateway:4318"},
+		{"template without a scheme", "", "gateway:4318", "https://gateway:4318"},
+		{"endpoint with a scheme", "http://placeholder:4318", "https://gateway:4318", "https://gateway:4318"},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Protocol.OTLPHTTP.Endpoint = tt.template
+			assert.Equal(t, tt.expected, buildHTTPExporterConfig(cfg, tt.endpoint).Endpoint)
+		})
+	}
+}
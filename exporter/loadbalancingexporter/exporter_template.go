@@ -0,0 +1,85 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/exporter"
+)
+
+// endpointPlaceholder is replaced with the endpoint in the string values of the exporter template
+const endpointPlaceholder = "{{endpoint}}"
+
+// exporterTemplate creates the sub-exporters with a configured exporter type instead of the OTLP ones, templating
+// the endpoint into their configuration.
+type exporterTemplate struct {
+	componentType component.Type
+	config        map[string]any
+
+	// factory is looked up in the host when starting
+	factory exporter.Factory
+}
+
+func newExporterTemplate(cfg *ExporterTemplate) (*exporterTemplate, error) {
+	componentType, err := component.NewType(cfg.Type)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exporter type: %w", err)
+	}
+	return &exporterTemplate{
+		componentType: componentType,
+		config:        cfg.Config,
+	}, nil
+}
+
+// setHost looks up the factory of the exporter type in the host, and checks the configuration up front, so that
+// building it for the endpoints doesn't fail later on.
+func (t *exporterTemplate) setHost(host component.Host) error {
+	factory, ok := host.GetFactory(component.KindExporter, t.componentType).(exporter.Factory)
+	if !ok {
+		return fmt.Errorf("unknown exporter type %q", t.componentType)
+	}
+	t.factory = factory
+
+	_, err := t.exporterConfig("placeholder:4317")
+	return err
+}
+
+// exporterConfig builds the configuration of the exporter for the given endpoint.
+func (t *exporterTemplate) exporterConfig(endpoint string) (component.Config, error) {
+	oCfg := t.factory.CreateDefaultConfig()
+	conf := confmap.NewFromStringMap(templateEndpoint(t.config, endpoint).(map[string]any))
+	if err := component.UnmarshalConfig(conf, oCfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration for the %q exporter: %w", t.componentType, err)
+	}
+	if err := component.ValidateConfig(oCfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration for the %q exporter: %w", t.componentType, err)
+	}
+	return oCfg, nil
+}
+
+// templateEndpoint returns a copy of the value with the endpoint placeholder replaced in all its strings.
+func templateEndpoint(value any, endpoint string) any {
+	switch v := value.(type) {
+	case string:
+		return strings.ReplaceAll(v, endpointPlaceholder, endpoint)
+	case map[string]any:
+		templated := make(map[string]any, len(v))
+		for key, item := range v {
+			templated[key] = templateEndpoint(item, endpoint)
+		}
+		return templated
+	case []any:
+		templated := make([]any, len(v))
+		for i, item := range v {
+			templated[i] = templateEndpoint(item, endpoint)
+		}
+		return templated
+	default:
+		return v
+	}
+}
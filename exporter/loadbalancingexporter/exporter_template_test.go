@@ -0,0 +1,130 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configcompression"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/exporter/otlphttpexporter"
+)
+
+// factoryHost is a host holding the factories of some exporters.
+type factoryHost struct {
+	component.Host
+	factories map[component.Type]component.Factory
+}
+
+func (h *factoryHost) GetFactory(kind component.Kind, componentType component.Type) component.Factory {
+	if kind != component.KindExporter {
+		return nil
+	}
+	return h.factories[componentType]
+}
+
+func newFactoryHost() *factoryHost {
+	factory := otlphttpexporter.NewFactory()
+	return &factoryHost{
+		Host:      componenttest.NewNopHost(),
+		factories: map[component.Type]component.Factory{factory.Type(): factory},
+	}
+}
+
+func TestTemplateEndpoint(t *testing.T) {
+	template := map[string]any{
+		"brokers": []any{"{{endpoint}}"},
+		"auth":    map[string]any{"sasl": map[string]any{"username": "{{endpoint}}-user"}},
+		"timeout": 5,
+	}
+
+	// test
+	templated := templateEndpoint(template, "kafka-1:9092")
+
+	// verify
+	assert.Equal(t, map[string]any{
+		"brokers": []any{"kafka-1:9092"},
+		"auth":    map[string]any{"sasl": map[string]any{"username": "kafka-1:9092-user"}},
+		"timeout": 5,
+	}, templated)
+	// the template is left untouched
+	assert.Equal(t, []any{"{{endpoint}}"}, template["brokers"])
+}
+
+func TestExporterTemplate(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static = &StaticResolver{Hostnames: []string{"endpoint-1:4318", "endpoint-2:4318"}}
+	cfg.Exporter = &ExporterTemplate{
+		Type: "otlphttp",
+		Config: map[string]any{
+			"endpoint":    "http://{{endpoint}}",
+			"compression": "none",
+		},
+	}
+
+	factories := map[string]component.Type{}
+	configs := map[string]component.Config{}
+	var lb *loadBalancer
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		factories[endpoint] = lb.exporterFactory(endpoint).Type()
+		configs[endpoint] = lb.exporterConfig(endpoint)
+		return newNopMockExporter(), nil
+	})
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	// test
+	err = lb.Start(context.Background(), newFactoryHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	for _, endpoint := range []string{"endpoint-1:4318", "endpoint-2:4318"} {
+		assert.Equal(t, "otlphttp", factories[endpoint].String())
+		require.IsType(t, &otlphttpexporter.Config{}, configs[endpoint])
+		oCfg := configs[endpoint].(*otlphttpexporter.Config)
+		assert.Equal(t, "http://"+endpoint, oCfg.Endpoint)
+		assert.Equal(t, configcompression.Type("none"), oCfg.Compression)
+	}
+}
+
+func TestExporterTemplateInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		template *ExporterTemplate
+		err      string
+	}{
+		{"unknown type", &ExporterTemplate{Type: "kafka"}, `unknown exporter type "kafka"`},
+		{"invalid config", &ExporterTemplate{Type: "otlphttp", Config: map[string]any{"unknown": "{{endpoint}}"}}, "invalid configuration for the \"otlphttp\" exporter"},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := simpleConfig()
+			cfg.Exporter = tt.template
+			lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+				return newNopMockExporter(), nil
+			})
+			require.NoError(t, err)
+
+			// test
+			err = lb.Start(context.Background(), newFactoryHost())
+
+			// verify
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	// the type is checked when creating the exporter
+	cfg := simpleConfig()
+	cfg.Exporter = &ExporterTemplate{Type: "not a type"}
+	_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.ErrorContains(t, err, "invalid exporter type")
+}
@@ -1,25 +1,26 @@
 // Copyright The OpenTelemetry Authors
 // SPDX-License-Identifier: Apache-2.0
 
-//go:generate mdatagen metadata.yaml
+//go:generate go run go.opentelemetry.io/collector/cmd/mdatagen@v0.102.1 metadata.yaml
 
 package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
 
 import (
 	"context"
 
-	"go.opencensus.io/stats/view"
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
 	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	"go.opentelemetry.io/collector/exporter/otlphttpexporter"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter/internal/metadata"
 )
 
 // NewFactory creates a factory for the exporter.
 func NewFactory() exporter.Factory {
-	_ = view.Register(metricViews()...)
-
 	return exporter.NewFactory(
 		metadata.Type,
 		createDefaultConfig,
@@ -34,21 +35,64 @@ func createDefaultConfig() component.Config {
 	otlpDefaultCfg := otlpFactory.CreateDefaultConfig().(*otlpexporter.Config)
 	otlpDefaultCfg.Endpoint = "placeholder:4317"
 
+	otlpHTTPFactory := otlphttpexporter.NewFactory()
+	otlpHTTPDefaultCfg := otlpHTTPFactory.CreateDefaultConfig().(*otlphttpexporter.Config)
+	otlpHTTPDefaultCfg.Endpoint = "https://placeholder:4318"
+
+	// the queue and the retries apply to the whole batches only when enabled, as the exporters of the backends
+	// have their own
+	queueSettings := exporterhelper.NewDefaultQueueSettings()
+	queueSettings.Enabled = false
+	backOffConfig := configretry.NewDefaultBackOffConfig()
+	backOffConfig.Enabled = false
+
 	return &Config{
+		QueueSettings: queueSettings,
+		BackOffConfig: backOffConfig,
 		Protocol: Protocol{
-			OTLP: *otlpDefaultCfg,
+			OTLP:     *otlpDefaultCfg,
+			OTLPHTTP: *otlpHTTPDefaultCfg,
 		},
+		RoutingDecisionSamplingRate: defaultRoutingDecisionSamplingRate,
 	}
 }
 
-func createTracesExporter(_ context.Context, params exporter.CreateSettings, cfg component.Config) (exporter.Traces, error) {
-	return newTracesExporter(params, cfg)
+func createTracesExporter(ctx context.Context, params exporter.CreateSettings, cfg component.Config) (exporter.Traces, error) {
+	exp, err := newTracesExporter(params, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return exporterhelper.NewTracesExporter(ctx, params, cfg, exp.ConsumeTraces, exporterHelperOptions(cfg.(*Config), exp)...)
 }
 
-func createLogsExporter(_ context.Context, params exporter.CreateSettings, cfg component.Config) (exporter.Logs, error) {
-	return newLogsExporter(params, cfg)
+func createLogsExporter(ctx context.Context, params exporter.CreateSettings, cfg component.Config) (exporter.Logs, error) {
+	exp, err := newLogsExporter(params, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return exporterhelper.NewLogsExporter(ctx, params, cfg, exp.ConsumeLogs, exporterHelperOptions(cfg.(*Config), exp)...)
 }
 
-func createMetricsExporter(_ context.Context, params exporter.CreateSettings, cfg component.Config) (exporter.Metrics, error) {
-	return newMetricsExporter(params, cfg)
+func createMetricsExporter(ctx context.Context, params exporter.CreateSettings, cfg component.Config) (exporter.Metrics, error) {
+	exp, err := newMetricsExporter(params, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return exporterhelper.NewMetricsExporter(ctx, params, cfg, exp.ConsumeMetrics, exporterHelperOptions(cfg.(*Config), exp)...)
+}
+
+// exporterHelperOptions returns the options applying the timeout, queue and retry settings of the exporter to the
+// whole batches, before they're routed.
+func exporterHelperOptions(cfg *Config, exp interface {
+	component.Component
+	Capabilities() consumer.Capabilities
+}) []exporterhelper.Option {
+	return []exporterhelper.Option{
+		exporterhelper.WithStart(exp.Start),
+		exporterhelper.WithShutdown(exp.Shutdown),
+		exporterhelper.WithCapabilities(exp.Capabilities()),
+		exporterhelper.WithTimeout(cfg.TimeoutSettings),
+		exporterhelper.WithQueue(cfg.QueueSettings),
+		exporterhelper.WithRetry(cfg.BackOffConfig),
+	}
 }
@@ -0,0 +1,93 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.uber.org/zap"
+)
+
+// failover sends the data whose send to the endpoint failed to the next endpoints for its routing identifier, one
+// at a time, up to the configured number of failover attempts, returning the error of the latest attempt. The data
+// failing with a permanent error isn't sent again, nor is the data sent to an endpoint that isn't in use, such as the
+// catch-all or a draining endpoint. The send function is called with the exporter whose consumeWG was incremented
+// for the data, and with the error of the previous attempt, so that only the data it failed to send is sent again.
+func (lb *loadBalancer) failover(identifier []byte, endpoint string, err error, send func(exp *wrappedExporter, endpoint string, err error) error) error {
+	tried := []string{endpoint}
+	for attempt := 0; err != nil && attempt < lb.cfg.FailoverAttempts && !consumererror.IsPermanent(err); attempt++ {
+		exp, next, ok := lb.failoverExporter(identifier, tried)
+		if !ok {
+			break
+		}
+		lb.logger.Debug("failed to send the data, failing over to the next endpoint",
+			zap.String("endpoint", tried[len(tried)-1]), zap.String("next", next), zap.Error(err))
+		tried = append(tried, next)
+		err = send(exp, next, err)
+	}
+	return err
+}
+
+// failoverExporter returns the exporter of the next endpoint in use for the routing identifier, skipping the ones
+// already tried, with its consumeWG incremented. Nothing is returned when the first tried endpoint isn't in use, or
+// when all the endpoints in use were tried.
+func (lb *loadBalancer) failoverExporter(identifier []byte, tried []string) (*wrappedExporter, string, bool) {
+	lb.updateLock.RLock()
+	defer lb.updateLock.RUnlock()
+
+	if !endpointFound(tried[0], lb.endpoints) {
+		return nil, "", false
+	}
+	accepts := func(candidate string) bool {
+		if endpointFound(candidate, tried) || !endpointFound(candidate, lb.endpoints) {
+			return false
+		}
+		_, found := lb.exporters[endpointWithPort(candidate)]
+		return found
+	}
+	next := lb.failoverEndpoint(identifier, tried, accepts)
+	if next == "" || !accepts(next) {
+		return nil, "", false
+	}
+	exp := lb.exporters[endpointWithPort(next)]
+	exp.consumeWG.Add(1)
+	return exp, next, true
+}
+
+// failoverEndpoint returns the next endpoint for the routing identifier among the accepted ones. The consistent
+// hashing ring is walked from the position of the identifier, and the other rings routing by identifier give the
+// endpoint they'd route it to without the tried endpoints. The data without a routing identifier, or balanced
+// regardless of it, goes to the endpoints in use following the latest tried one. The caller must hold the update
+// lock.
+func (lb *loadBalancer) failoverEndpoint(identifier []byte, tried []string, accepts func(endpoint string) bool) string {
+	if identifier != nil {
+		switch ring := lb.ring.(type) {
+		case *hashRing:
+			return ring.endpointForBounded(identifier, accepts)
+		case *rendezvousRing, *maglevRing:
+			var remaining []string
+			for _, candidate := range lb.endpoints {
+				if accepts(candidate) {
+					remaining = append(remaining, candidate)
+				}
+			}
+			if len(remaining) == 0 {
+				return ""
+			}
+			return lb.ringBuilder(remaining, nil).endpointFor(identifier)
+		}
+	}
+
+	last := 0
+	for i, endpoint := range lb.endpoints {
+		if endpoint == tried[len(tried)-1] {
+			last = i
+		}
+	}
+	for i := 1; i < len(lb.endpoints); i++ {
+		if candidate := lb.endpoints[(last+i)%len(lb.endpoints)]; accepts(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
@@ -0,0 +1,244 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestFailoverTraces(t *testing.T) {
+	for _, tt := range []struct {
+		desc      string
+		attempts  int
+		err       error
+		failedOut bool
+	}{
+		{"fails over", 1, errors.New("connection refused"), true},
+		{"disabled", 0, errors.New("connection refused"), false},
+		{"permanent error", 1, consumererror.NewPermanent(errors.New("invalid data")), false},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			// prepare
+			cfg := simpleConfig()
+			cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2"}
+			cfg.FailoverAttempts = tt.attempts
+
+			sinks := map[string]*consumertest.TracesSink{}
+			var down string
+			componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+				sink := new(consumertest.TracesSink)
+				sinks[endpoint] = sink
+				return newMockTracesExporter(func(ctx context.Context, td ptrace.Traces) error {
+					if endpoint == down {
+						return tt.err
+					}
+					return sink.ConsumeTraces(ctx, td)
+				}), nil
+			}
+			lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+			require.NoError(t, err)
+			p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+			require.NoError(t, err)
+			p.loadBalancer = lb
+			require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+			defer func() {
+				require.NoError(t, p.Shutdown(context.Background()))
+			}()
+
+			// the backend of the trace is down
+			td := simpleTraces()
+			traceID := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).TraceID()
+			down = endpointWithPort(lb.ring.endpointFor(traceID[:]))
+
+			// test
+			err = p.ConsumeTraces(context.Background(), td)
+
+			// verify
+			if !tt.failedOut {
+				assert.ErrorIs(t, err, tt.err)
+				assert.Empty(t, sinks["endpoint-1:4317"].AllTraces())
+				assert.Empty(t, sinks["endpoint-2:4317"].AllTraces())
+				return
+			}
+			require.NoError(t, err)
+			for endpoint, sink := range sinks {
+				if endpoint == down {
+					assert.Empty(t, sink.AllTraces())
+				} else {
+					assert.Len(t, sink.AllTraces(), 1)
+				}
+			}
+		})
+	}
+}
+
+func TestFailoverLogsAllEndpointsFailing(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+	cfg.FailoverAttempts = 5
+
+	var attempts []string
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockLogsExporter(func(ctx context.Context, ld plog.Logs) error {
+			attempts = append(attempts, endpoint)
+			return errors.New("connection refused")
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	p, err := newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	err = p.ConsumeLogs(context.Background(), simpleLogs())
+
+	// verify
+	assert.Error(t, err)
+	// each endpoint is tried once
+	require.Len(t, attempts, 3)
+	assert.ElementsMatch(t, []string{"endpoint-1:4317", "endpoint-2:4317", "endpoint-3:4317"}, attempts)
+}
+
+func TestFailoverFollowsTheRing(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2", "endpoint-3", "endpoint-4", "endpoint-5"}
+	cfg.FailoverAttempts = 1
+
+	sinks := map[string]*consumertest.TracesSink{}
+	var down string
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks[endpoint] = sink
+		return newMockTracesExporter(func(ctx context.Context, td ptrace.Traces) error {
+			if endpoint == down {
+				return errors.New("connection refused")
+			}
+			return sink.ConsumeTraces(ctx, td)
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// the backend of the trace is down, and the endpoint owning the trace on the ring without it isn't the one
+	// following it in the sorted list of endpoints
+	var traceID pcommon.TraceID
+	var successor string
+	for i := 0; i < 256 && successor == ""; i++ {
+		traceID = pcommon.TraceID([16]byte{byte(i), 2, 3, 4})
+		owner := lb.ring.endpointFor(traceID[:])
+		var others []string
+		next := ""
+		for j, endpoint := range lb.endpoints {
+			if endpoint == owner {
+				next = lb.endpoints[(j+1)%len(lb.endpoints)]
+				continue
+			}
+			others = append(others, endpoint)
+		}
+		if candidate := lb.ringBuilder(others, nil).endpointFor(traceID[:]); candidate != next {
+			down = endpointWithPort(owner)
+			successor = endpointWithPort(candidate)
+		}
+	}
+	require.NotEmpty(t, successor)
+	td := ptrace.NewTraces()
+	appendSimpleTraceWithID(td.ResourceSpans().AppendEmpty(), traceID)
+
+	// test
+	err = p.ConsumeTraces(context.Background(), td)
+
+	// verify
+	require.NoError(t, err)
+	for endpoint, sink := range sinks {
+		if endpoint == successor {
+			assert.Len(t, sink.AllTraces(), 1)
+		} else {
+			assert.Empty(t, sink.AllTraces(), endpoint)
+		}
+	}
+}
+
+func TestFailoverResendsOnlyTheFailedData(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2"}
+	cfg.FailoverAttempts = 1
+
+	sinks := map[string]*consumertest.TracesSink{}
+	var down string
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks[endpoint] = sink
+		return newMockTracesExporter(func(ctx context.Context, td ptrace.Traces) error {
+			if endpoint != down {
+				return sink.ConsumeTraces(ctx, td)
+			}
+			// only the second resource of the traces fails to be sent
+			failed := ptrace.NewTraces()
+			td.ResourceSpans().At(1).CopyTo(failed.ResourceSpans().AppendEmpty())
+			return consumererror.NewTraces(errors.New("connection refused"), failed)
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	td := ptrace.NewTraces()
+	appendSimpleTraceWithID(td.ResourceSpans().AppendEmpty(), [16]byte{1, 2, 3, 4})
+	second := td.ResourceSpans().AppendEmpty()
+	second.Resource().Attributes().PutStr("part", "failed")
+	appendSimpleTraceWithID(second, [16]byte{1, 2, 3, 4})
+	traceID := pcommon.TraceID([16]byte{1, 2, 3, 4})
+	down = endpointWithPort(lb.ring.endpointFor(traceID[:]))
+
+	// test
+	err = p.ConsumeTraces(context.Background(), td)
+
+	// verify
+	require.NoError(t, err)
+	assert.Empty(t, sinks[down].AllTraces())
+	for endpoint, sink := range sinks {
+		if endpoint == down {
+			continue
+		}
+		require.Len(t, sink.AllTraces(), 1)
+		sent := sink.AllTraces()[0]
+		require.Equal(t, 1, sent.ResourceSpans().Len())
+		part, _ := sent.ResourceSpans().At(0).Resource().Attributes().Get("part")
+		assert.Equal(t, "failed", part.Str())
+	}
+}
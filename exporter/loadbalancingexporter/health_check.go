@@ -0,0 +1,268 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	healthCheckTCP  = "tcp"
+	healthCheckGRPC = "grpc"
+
+	defaultHealthCheckInterval           = 10 * time.Second
+	defaultHealthCheckTimeout            = 2 * time.Second
+	defaultHealthCheckUnhealthyThreshold = 3
+	defaultHealthCheckHealthyThreshold   = 2
+)
+
+// healthProbe checks whether the backend at the given endpoint is responsive.
+type healthProbe func(ctx context.Context, endpoint string) error
+
+// healthChecks probe the endpoints periodically, evicting from the ring the ones failing their probes in a row until
+// they succeed again.
+type healthChecks struct {
+	probe              healthProbe
+	interval           time.Duration
+	timeout            time.Duration
+	unhealthyThreshold int
+	healthyThreshold   int
+
+	lock sync.Mutex
+	// source are the latest endpoints passed to the load balancer, applied again when an endpoint is evicted or
+	// restored
+	source []string
+	// endpoints are the endpoints being probed
+	endpoints []string
+	states    map[string]*endpointHealth
+}
+
+// endpointHealth holds the outcome of the latest probes of an endpoint
+type endpointHealth struct {
+	failures  int
+	successes int
+	evicted   bool
+}
+
+func newHealthChecks(cfg *Config) (*healthChecks, error) {
+	hc := cfg.HealthCheck
+	if hc == nil {
+		return nil, nil
+	}
+
+	h := &healthChecks{
+		interval:           hc.Interval,
+		timeout:            hc.Timeout,
+		unhealthyThreshold: hc.UnhealthyThreshold,
+		healthyThreshold:   hc.HealthyThreshold,
+		states:             map[string]*endpointHealth{},
+	}
+	switch hc.Protocol {
+	case "", healthCheckTCP:
+		h.probe = tcpProbe
+	case healthCheckGRPC:
+		h.probe = grpcProbe(cfg, hc.Service)
+	default:
+		return nil, fmt.Errorf("unsupported health check protocol %q", hc.Protocol)
+	}
+	if h.unhealthyThreshold < 0 || h.healthyThreshold < 0 {
+		return nil, errors.New("invalid health check thresholds, they must be positive")
+	}
+
+	if h.interval <= 0 {
+		h.interval = defaultHealthCheckInterval
+	}
+	if h.timeout <= 0 {
+		h.timeout = defaultHealthCheckTimeout
+	}
+	if h.unhealthyThreshold == 0 {
+		h.unhealthyThreshold = defaultHealthCheckUnhealthyThreshold
+	}
+	if h.healthyThreshold == 0 {
+		h.healthyThreshold = defaultHealthCheckHealthyThreshold
+	}
+	return h, nil
+}
+
+// track records the endpoints passed to the load balancer along with the endpoints to probe, forgetting the state
+// of the endpoints no longer probed.
+func (h *healthChecks) track(source []string, endpoints []string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.source = source
+	h.endpoints = endpoints
+	for endpoint := range h.states {
+		if !endpointFound(endpoint, endpoints) {
+			delete(h.states, endpoint)
+		}
+	}
+}
+
+// healthy returns the given endpoints that aren't evicted. Nil health checks evict no endpoint.
+func (h *healthChecks) healthy(endpoints []string) []string {
+	if h == nil {
+		return endpoints
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	healthy := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if state, ok := h.states[endpoint]; !ok || !state.evicted {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	return healthy
+}
+
+// evicted returns whether the endpoint is evicted. Nil health checks evict no endpoint.
+func (h *healthChecks) evicted(endpoint string) bool {
+	if h == nil {
+		return false
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	state, ok := h.states[endpoint]
+	return ok && state.evicted
+}
+
+// checkHealth probes the endpoints at every interval until the load balancer is stopped.
+func (lb *loadBalancer) checkHealth() {
+	defer lb.retryWG.Done()
+
+	ticker := time.NewTicker(lb.healthChecks.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lb.stopCh:
+			return
+		case <-ticker.C:
+			lb.probeEndpoints()
+		}
+	}
+}
+
+// probeEndpoints probes all the endpoints at once, applying the endpoints again when some of them were evicted or
+// restored.
+func (lb *loadBalancer) probeEndpoints() {
+	h := lb.healthChecks
+	h.lock.Lock()
+	endpoints := h.endpoints
+	h.lock.Unlock()
+
+	results := make([]error, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+			defer cancel()
+			results[i] = h.probe(ctx, endpointWithPort(endpoint))
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	h.lock.Lock()
+	changed := false
+	for i, endpoint := range endpoints {
+		if !endpointFound(endpoint, h.endpoints) {
+			// no longer resolved while being probed
+			continue
+		}
+		state, ok := h.states[endpoint]
+		if !ok {
+			state = &endpointHealth{}
+			h.states[endpoint] = state
+		}
+
+		if err := results[i]; err != nil {
+			state.successes = 0
+			state.failures++
+			if !state.evicted && state.failures >= h.unhealthyThreshold {
+				lb.logger.Warn("the endpoint failed its health checks, evicting it from the ring",
+					zap.String("endpoint", endpoint), zap.Int("failures", state.failures), zap.Error(err))
+				state.evicted = true
+				changed = true
+				lb.telemetry.recordEviction(context.Background(), endpoint)
+			}
+			continue
+		}
+
+		state.failures = 0
+		state.successes++
+		if state.evicted && state.successes >= h.healthyThreshold {
+			lb.logger.Info("the endpoint passed its health checks again, restoring it to the ring",
+				zap.String("endpoint", endpoint))
+			state.evicted = false
+			changed = true
+			lb.telemetry.recordRestoration(context.Background(), endpoint)
+		}
+	}
+	source := h.source
+	h.lock.Unlock()
+
+	if changed {
+		lb.onBackendChanges(source)
+	}
+}
+
+// tcpProbe checks that a connection to the endpoint can be opened.
+func tcpProbe(ctx context.Context, endpoint string) error {
+	network, address := "tcp", endpoint
+	if isUnixSocket(endpoint) {
+		network, address = "unix", unixSocketPath(endpoint)
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// grpcProbe returns the probe checking the health of the given service with the gRPC health checking protocol,
+// connecting with the TLS settings of the otlp protocol template for the endpoint.
+func grpcProbe(cfg *Config, service string) healthProbe {
+	return func(ctx context.Context, endpoint string) error {
+		tlsSetting := cfg.Protocol.OTLP.TLSSetting
+		if override, ok := endpointOverride(cfg, endpoint); ok && override.TLSSetting != nil {
+			tlsSetting = *override.TLSSetting
+		}
+		tlsCfg, err := tlsSetting.LoadTLSConfig()
+		if err != nil {
+			return err
+		}
+		creds := insecure.NewCredentials()
+		if tlsCfg != nil {
+			creds = credentials.NewTLS(tlsCfg)
+		}
+
+		conn, err := grpc.DialContext(ctx, endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("the endpoint is not serving: %s", resp.GetStatus())
+		}
+		return nil
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthChecksEvictAndRestore(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2"}
+	cfg.HealthCheck = &HealthCheckSettings{
+		Interval:           10 * time.Millisecond,
+		UnhealthyThreshold: 2,
+		HealthyThreshold:   2,
+	}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockTracesExporter(), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+
+	var endpoint2Down atomic.Bool
+	endpoint2Down.Store(true)
+	lb.healthChecks.probe = func(ctx context.Context, endpoint string) error {
+		if endpoint == "endpoint-2:4317" && endpoint2Down.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	ringEndpoints := func() []string {
+		lb.updateLock.RLock()
+		defer lb.updateLock.RUnlock()
+		return lb.ring.endpoints()
+	}
+
+	// test
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"endpoint-1"}, ringEndpoints())
+	}, time.Second, 5*time.Millisecond)
+
+	// the evicted endpoint is restored once it responds again
+	endpoint2Down.Store(false)
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"endpoint-1", "endpoint-2"}, ringEndpoints())
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestHealthChecksHealthy(t *testing.T) {
+	// prepare
+	h, err := newHealthChecks(&Config{HealthCheck: &HealthCheckSettings{UnhealthyThreshold: 1}})
+	require.NoError(t, err)
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	h.track(endpoints, endpoints)
+
+	// test
+	h.states["endpoint-1"] = &endpointHealth{evicted: true}
+	healthy := h.healthy(endpoints)
+
+	// verify
+	assert.Equal(t, []string{"endpoint-2"}, healthy)
+
+	// the state of the endpoints no longer probed is forgotten
+	h.track([]string{"endpoint-2"}, []string{"endpoint-2"})
+	assert.NotContains(t, h.states, "endpoint-1")
+
+	// nil health checks evict no endpoint
+	var disabled *healthChecks
+	assert.Equal(t, endpoints, disabled.healthy(endpoints))
+}
+
+func TestNewHealthChecksInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		settings *HealthCheckSettings
+		err      string
+	}{
+		{"unknown protocol", &HealthCheckSettings{Protocol: "http"}, `unsupported health check protocol "http"`},
+		{"negative threshold", &HealthCheckSettings{UnhealthyThreshold: -1}, "invalid health check thresholds"},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := simpleConfig()
+			cfg.HealthCheck = tt.settings
+
+			// test
+			_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+
+			// verify
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	endpoint := listener.Addr().String()
+
+	// test
+	assert.NoError(t, tcpProbe(context.Background(), endpoint))
+
+	// verify
+	require.NoError(t, listener.Close())
+	assert.Error(t, tcpProbe(context.Background(), endpoint))
+}
+
+func TestTCPProbeUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collector.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	endpoint := "unix://" + path
+
+	// test
+	assert.NoError(t, tcpProbe(context.Background(), endpoint))
+
+	// verify
+	require.NoError(t, listener.Close())
+	assert.Error(t, tcpProbe(context.Background(), endpoint))
+}
+
+func TestGRPCProbe(t *testing.T) {
+	// prepare
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	defer srv.Stop()
+
+	cfg := simpleConfig()
+	cfg.Protocol.OTLP.TLSSetting.Insecure = true
+	probe := grpcProbe(cfg, "otlp")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// test
+	healthSrv.SetServingStatus("otlp", healthpb.HealthCheckResponse_SERVING)
+	err = probe(ctx, listener.Addr().String())
+
+	// verify
+	assert.NoError(t, err)
+
+	healthSrv.SetServingStatus("otlp", healthpb.HealthCheckResponse_NOT_SERVING)
+	assert.ErrorContains(t, probe(ctx, listener.Addr().String()), "NOT_SERVING")
+}
@@ -4,6 +4,10 @@
 package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
 
 import (
+	"errors"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 )
@@ -121,3 +125,69 @@ func mergeMetrics(m1 pmetric.Metrics, m2 pmetric.Metrics) pmetric.Metrics {
 
 	return mergedMetrics
 }
+
+// appendTraces copies the resource spans from src to the end of dest.
+func appendTraces(dest ptrace.Traces, src ptrace.Traces) {
+	for i := 0; i < src.ResourceSpans().Len(); i++ {
+		src.ResourceSpans().At(i).CopyTo(dest.ResourceSpans().AppendEmpty())
+	}
+}
+
+// appendMetrics copies the resource metrics from src to the end of dest.
+func appendMetrics(dest pmetric.Metrics, src pmetric.Metrics) {
+	for i := 0; i < src.ResourceMetrics().Len(); i++ {
+		src.ResourceMetrics().At(i).CopyTo(dest.ResourceMetrics().AppendEmpty())
+	}
+}
+
+// appendLogs copies the resource logs from src to the end of dest.
+func appendLogs(dest plog.Logs, src plog.Logs) {
+	for i := 0; i < src.ResourceLogs().Len(); i++ {
+		src.ResourceLogs().At(i).CopyTo(dest.ResourceLogs().AppendEmpty())
+	}
+}
+
+// appendFailedTraces appends to dest the portion of td that wasn't sent because of err.
+func appendFailedTraces(dest ptrace.Traces, td ptrace.Traces, err error) {
+	appendTraces(dest, failedTraces(td, err))
+}
+
+// failedTraces returns the portion of td that wasn't sent because of err: only the data carried by err when it is
+// itself a partial failure, or the whole td otherwise.
+func failedTraces(td ptrace.Traces, err error) ptrace.Traces {
+	var partial consumererror.Traces
+	if errors.As(err, &partial) {
+		return partial.Data()
+	}
+	return td
+}
+
+// appendFailedLogs appends to dest the portion of ld that wasn't sent because of err.
+func appendFailedLogs(dest plog.Logs, ld plog.Logs, err error) {
+	appendLogs(dest, failedLogs(ld, err))
+}
+
+// failedLogs returns the portion of ld that wasn't sent because of err: only the data carried by err when it is
+// itself a partial failure, or the whole ld otherwise.
+func failedLogs(ld plog.Logs, err error) plog.Logs {
+	var partial consumererror.Logs
+	if errors.As(err, &partial) {
+		return partial.Data()
+	}
+	return ld
+}
+
+// appendFailedMetrics appends to dest the portion of md that wasn't sent because of err.
+func appendFailedMetrics(dest pmetric.Metrics, md pmetric.Metrics, err error) {
+	appendMetrics(dest, failedMetrics(md, err))
+}
+
+// failedMetrics returns the portion of md that wasn't sent because of err: only the data carried by err when it is
+// itself a partial failure, or the whole md otherwise.
+func failedMetrics(md pmetric.Metrics, err error) pmetric.Metrics {
+	var partial consumererror.Metrics
+	if errors.As(err, &partial) {
+		return partial.Data()
+	}
+	return md
+}
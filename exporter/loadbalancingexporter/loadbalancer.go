@@ -7,34 +7,150 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/crc32"
+	"net"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter/internal/metadata"
 )
 
 const (
 	defaultPort = "4317"
+
+	// unixSocketScheme prefixes the endpoints of the backends listening on a unix domain socket
+	unixSocketScheme = "unix:"
+
+	// maxReroutes is the number of times data is routed again when its backend leaves the ring
+	// while the data is being sent, bounding the work done when the ring keeps changing.
+	maxReroutes = 3
+
+	// defaultResolverRetryInterval is the interval between the resolution attempts after a degraded start.
+	defaultResolverRetryInterval = 5 * time.Second
 )
 
 var (
 	errNoResolver                = errors.New("no resolvers specified for the exporter")
 	errMultipleResolversProvided = errors.New("only one resolver should be specified")
+	errNoEndpointsInUse          = errors.New("no endpoints to send the data to")
 )
 
 type componentFactory func(ctx context.Context, endpoint string) (component.Component, error)
 
+// exporterConfigBuilder derives the configuration for the sub-exporter of the given endpoint
+type exporterConfigBuilder func(cfg *Config, endpoint string) component.Config
+
 type loadBalancer struct {
 	logger *zap.Logger
 	host   component.Host
+	cfg    *Config
+
+	// signal is the type of the data balanced, describing the load balancer on the admin endpoints
+	signal component.DataType
+
+	// updateRouting switches the routing key of the exporter using the load balancer, nil when the exporter
+	// doesn't support switching it at runtime
+	updateRouting func(cfg *Config) error
+
+	res         resolver
+	ring        ring
+	ringBuilder ringBuilder
+
+	// denylist has the patterns for the resolved endpoints that should be ignored
+	denylist []string
+
+	// fallbackEndpoints are used in place of the resolved endpoints whenever the resolver yields none
+	fallbackEndpoints []string
+	usingFallback     bool
+
+	// limitedFrom is the number of endpoints last limited to the maximum number of backends, zero when they weren't,
+	// so that the limit is only reported when the number of endpoints changes
+	limitedFrom atomic.Int64
+
+	// fallbackGroups are used in place of the resolved endpoints, in their order of priority, while the groups of
+	// higher priority have no healthy endpoints. activeGroup is the index of the group in use, the resolved
+	// endpoints being the group 0.
+	fallbackGroups [][]string
+	activeGroup    atomic.Int64
+
+	// backups replace the primary endpoints of the static resolver while they are failing, when configured
+	backups *backupEndpoints
+
+	// healthChecks evict the unresponsive endpoints from the ring, when configured
+	healthChecks *healthChecks
+
+	// locality restricts the ring to the endpoints in the zone of the collector, when configured
+	locality *locality
+
+	// subset restricts the ring to the subset of the endpoints selected for this collector, when configured
+	subset *subset
+
+	// endpoints are the endpoints currently in use, without the draining ones
+	endpoints []string
+
+	// resolved are the endpoints last returned by the resolver, before being filtered
+	resolved []string
+
+	// debouncer coalesces the changes of the resolver within a window, when configured
+	debouncer *resolverDebouncer
+
+	// nextTurn is the turn of the next endpoint to send the data to, when the data isn't routed by identifier
+	nextTurn atomic.Uint64
+
+	// changeHooks can modify or reject the changes of the endpoints before they apply
+	changeHooks []backendChangeHook
 
-	res  resolver
-	ring *hashRing
+	// draining holds the deadline for each of the removed endpoints still in their grace period
+	draining    map[string]time.Time
+	drainTimer  *time.Timer
+	recentKeys  *recentKeys
+	gracePeriod time.Duration
 
-	componentFactory componentFactory
-	exporters        map[string]*wrappedExporter
+	// ramp holds the ramp up in progress for the endpoints recently added, if any
+	ramp      *rampUp
+	rampTimer *time.Timer
+
+	componentFactory      componentFactory
+	exporterConfigBuilder exporterConfigBuilder
+	exporters             map[string]*wrappedExporter
+
+	// exporterTemplate creates the sub-exporters with another type than OTLP, when configured
+	exporterTemplate *exporterTemplate
+
+	// sendSlots holds a token for each send in progress, when their number is limited
+	sendSlots chan struct{}
+
+	// routingDecision stamps the data with the endpoint it is routed to, when configured
+	routingDecision *routingDecisionStamper
+
+	// routingVerifier checks that the ring routes the identifiers consistently, when enabled
+	routingVerifier *routingVerifier
+
+	// tracer creates the spans for the sends to the backends
+	tracer    trace.Tracer
+	settings  component.TelemetrySettings
+	telemetry *telemetry
+
+	// startupWait holds back the data consumed before the first resolution, when configured
+	startupWait *startupWait
+
+	// reportStatus reports the component status, such as the degraded state after a failed resolver start
+	reportStatus  func(*component.StatusEvent)
+	retryInterval time.Duration
+	stopCh        chan struct{}
+	retryWG       sync.WaitGroup
+
+	// shutdownWg tracks the shutdown of the exporters of the removed endpoints
+	shutdownWg sync.WaitGroup
 
 	stopped    bool
 	updateLock sync.RWMutex
@@ -44,74 +160,640 @@ type loadBalancer struct {
 func newLoadBalancer(params exporter.CreateSettings, cfg component.Config, factory componentFactory) (*loadBalancer, error) {
 	oCfg := cfg.(*Config)
 
-	if oCfg.Resolver.DNS != nil && oCfg.Resolver.Static != nil {
-		return nil, errMultipleResolversProvided
+	tb, err := newTelemetry(params.TelemetrySettings, oCfg.TelemetryNamespace)
+	if err != nil {
+		return nil, err
 	}
 
 	var res resolver
+	if oCfg.ShareRing {
+		res, err = sharedResolvers.getOrCreate(oCfg, func() (resolver, error) {
+			return newResolver(params, oCfg, tb)
+		})
+	} else {
+		res, err = newResolver(params, oCfg, tb)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	builder, err := newRingBuilder(oCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateEndpointSettings(oCfg); err != nil {
+		return nil, err
+	}
+
+	if err = validateBoundedLoadFactor(oCfg); err != nil {
+		return nil, err
+	}
+
+	if err = validateCircuitBreaker(oCfg); err != nil {
+		return nil, err
+	}
+
+	if err = validateRateLimit(oCfg); err != nil {
+		return nil, err
+	}
+
+	if err = validateSharedConnections(oCfg); err != nil {
+		return nil, err
+	}
+
+	if err = validateMaxBackends(oCfg); err != nil {
+		return nil, err
+	}
+
+	routingDecision, err := newRoutingDecisionStamper(oCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	healthChecks, err := newHealthChecks(oCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	locality, err := newLocality(params.Logger, oCfg.Locality)
+	if err != nil {
+		return nil, err
+	}
+
+	subset, err := newSubset(oCfg.Subset)
+	if err != nil {
+		return nil, err
+	}
+
+	var template *exporterTemplate
+	if oCfg.Exporter != nil {
+		if template, err = newExporterTemplate(oCfg.Exporter); err != nil {
+			return nil, err
+		}
+	}
+
+	var fallback []string
+	if oCfg.Resolver.Fallback != nil {
+		fallback = make([]string, len(oCfg.Resolver.Fallback.Hostnames))
+		copy(fallback, oCfg.Resolver.Fallback.Hostnames)
+		sort.Strings(fallback)
+	}
+
+	lb := &loadBalancer{
+		logger:                params.Logger,
+		cfg:                   oCfg,
+		res:                   res,
+		ringBuilder:           builder,
+		denylist:              oCfg.Resolver.Denylist,
+		fallbackEndpoints:     fallback,
+		fallbackGroups:        newFallbackGroups(oCfg.Resolver.FallbackGroups),
+		backups:               newBackupEndpoints(oCfg.Resolver.Static),
+		healthChecks:          healthChecks,
+		locality:              locality,
+		subset:                subset,
+		componentFactory:      factory,
+		exporterConfigBuilder: defaultExporterConfigBuilder,
+		exporters:             map[string]*wrappedExporter{},
+		exporterTemplate:      template,
+		routingDecision:       routingDecision,
+		startupWait:           newStartupWait(oCfg.StartupWaitTimeout),
+		routingVerifier:       newRoutingVerifier(params.Logger, oCfg),
+		tracer:                metadata.Tracer(params.TelemetrySettings),
+		settings:              params.TelemetrySettings,
+		telemetry:             tb,
+		reportStatus:          params.ReportStatus,
+		retryInterval:         defaultResolverRetryInterval,
+		stopCh:                make(chan struct{}),
+	}
+	if oCfg.MaxInFlightSends > 0 {
+		lb.sendSlots = make(chan struct{}, oCfg.MaxInFlightSends)
+	}
+	if oCfg.MinBackends > 0 {
+		lb.changeHooks = append(lb.changeHooks, minBackendsHook(oCfg.MinBackends))
+	}
+	if oCfg.RemovalGracePeriod > 0 {
+		lb.gracePeriod = oCfg.RemovalGracePeriod
+		lb.draining = map[string]time.Time{}
+		lb.recentKeys = newRecentKeys(oCfg.RemovalGracePeriod)
+	}
+	lb.debouncer = newResolverDebouncer(oCfg.Resolver.Debounce, lb.onBackendChanges)
+	return lb, nil
+}
+
+// newResolver creates the resolver for the backends, as configured.
+func newResolver(params exporter.CreateSettings, oCfg *Config, tb *telemetry) (resolver, error) {
+	if !oCfg.Resolver.Merge {
+		if oCfg.Resolver.DNS != nil && oCfg.Resolver.Static != nil {
+			return nil, errMultipleResolversProvided
+		}
+		if exclusiveResolverConfigured(oCfg) && configuredResolvers(oCfg) > 1 {
+			return nil, errMultipleResolversProvided
+		}
+	}
+
+	var resolvers []resolver
 	if oCfg.Resolver.Static != nil {
-		var err error
-		res, err = newStaticResolver(oCfg.Resolver.Static.Hostnames)
+		res, err := newStaticResolver(oCfg.Resolver.Static.Hostnames, tb)
 		if err != nil {
 			return nil, err
 		}
+		resolvers = append(resolvers, res)
 	}
 	if oCfg.Resolver.DNS != nil {
 		dnsLogger := params.Logger.With(zap.String("resolver", "dns"))
 
-		var err error
-		res, err = newDNSResolver(dnsLogger, oCfg.Resolver.DNS.Hostname, oCfg.Resolver.DNS.Port, oCfg.Resolver.DNS.Interval, oCfg.Resolver.DNS.Timeout)
+		dnsRes, err := newDNSResolver(dnsLogger, oCfg.Resolver.DNS.Hostname, oCfg.Resolver.DNS.Port, oCfg.Resolver.DNS.Interval, oCfg.Resolver.DNS.Timeout, tb)
 		if err != nil {
 			return nil, err
 		}
+		if oCfg.Resolver.DNS.IPFamily != "" {
+			if err = dnsRes.useIPFamily(oCfg.Resolver.DNS.IPFamily); err != nil {
+				return nil, err
+			}
+		}
+		if oCfg.Resolver.DNS.SRV != nil {
+			if err = dnsRes.useSRV(oCfg.Resolver.DNS.SRV); err != nil {
+				return nil, err
+			}
+		}
+		if oCfg.Resolver.DNS.Backoff != nil {
+			dnsRes.useBackoff(oCfg.Resolver.DNS.Backoff)
+		}
+		dnsRes.maxStaleness = oCfg.Resolver.DNS.MaxStaleness
+		resolvers = append(resolvers, dnsRes)
 	}
 	if oCfg.Resolver.K8sSvc != nil {
 		k8sLogger := params.Logger.With(zap.String("resolver", "k8s service"))
 
-		clt, err := newInClusterClient()
+		clt, err := newK8sClient(oCfg.Resolver.K8sSvc)
 		if err != nil {
 			return nil, err
 		}
-		res, err = newK8sResolver(clt, k8sLogger, oCfg.Resolver.K8sSvc.Service, oCfg.Resolver.K8sSvc.Ports)
+
+		// one resolver watches each service, their endpoints being merged into a single ring
+		services := oCfg.Resolver.K8sSvc.Services
+		if oCfg.Resolver.K8sSvc.Service != "" || len(services) == 0 {
+			services = append([]string{oCfg.Resolver.K8sSvc.Service}, services...)
+		}
+		k8sResolvers := make([]resolver, 0, len(services))
+		for _, service := range services {
+			k8sRes, err := newConfiguredK8sResolver(clt, k8sLogger, service, oCfg.Resolver.K8sSvc, tb)
+			if err != nil {
+				return nil, err
+			}
+			k8sRes.reportStatus = params.ReportStatus
+			k8sResolvers = append(k8sResolvers, k8sRes)
+		}
+		if len(k8sResolvers) == 1 {
+			resolvers = append(resolvers, k8sResolvers[0])
+		} else {
+			resolvers = append(resolvers, newUnionResolver(k8sLogger, k8sResolvers))
+		}
+	}
+
+	if oCfg.Resolver.XDS != nil {
+		xdsLogger := params.Logger.With(zap.String("resolver", "xds"))
+
+		res, err := newXDSResolver(xdsLogger, oCfg.Resolver.XDS, tb)
+		if err != nil {
+			return nil, err
+		}
+		resolvers = append(resolvers, res)
+	}
+
+	if oCfg.Resolver.Consul != nil {
+		consulLogger := params.Logger.With(zap.String("resolver", "consul"))
+
+		res, err := newConsulResolver(consulLogger, oCfg.Resolver.Consul, tb)
+		if err != nil {
+			return nil, err
+		}
+		resolvers = append(resolvers, res)
+	}
+
+	if oCfg.Resolver.AWSCloudMap != nil {
+		cloudMapLogger := params.Logger.With(zap.String("resolver", "aws_cloud_map"))
+
+		res, err := newCloudMapResolver(cloudMapLogger, oCfg.Resolver.AWSCloudMap, tb)
+		if err != nil {
+			return nil, err
+		}
+		resolvers = append(resolvers, res)
+	}
+
+	if oCfg.Resolver.AWSECS != nil {
+		ecsLogger := params.Logger.With(zap.String("resolver", "aws_ecs"))
+
+		res, err := newECSResolver(ecsLogger, oCfg.Resolver.AWSECS, tb)
+		if err != nil {
+			return nil, err
+		}
+		resolvers = append(resolvers, res)
+	}
+
+	if oCfg.Resolver.Etcd != nil {
+		etcdLogger := params.Logger.With(zap.String("resolver", "etcd"))
+
+		res, err := newEtcdResolver(etcdLogger, oCfg.Resolver.Etcd, tb)
+		if err != nil {
+			return nil, err
+		}
+		resolvers = append(resolvers, res)
+	}
+
+	if oCfg.Resolver.ZooKeeper != nil {
+		zkLogger := params.Logger.With(zap.String("resolver", "zookeeper"))
+
+		res, err := newZooKeeperResolver(zkLogger, oCfg.Resolver.ZooKeeper, tb)
+		if err != nil {
+			return nil, err
+		}
+		resolvers = append(resolvers, res)
+	}
+
+	if oCfg.Resolver.Eureka != nil {
+		eurekaLogger := params.Logger.With(zap.String("resolver", "eureka"))
+
+		res, err := newEurekaResolver(eurekaLogger, oCfg.Resolver.Eureka, tb)
 		if err != nil {
 			return nil, err
 		}
+		resolvers = append(resolvers, res)
 	}
 
-	if res == nil {
+	if oCfg.Resolver.Nomad != nil {
+		nomadLogger := params.Logger.With(zap.String("resolver", "nomad"))
+
+		res, err := newNomadResolver(nomadLogger, oCfg.Resolver.Nomad, tb)
+		if err != nil {
+			return nil, err
+		}
+		resolvers = append(resolvers, res)
+	}
+
+	if oCfg.Resolver.File != nil {
+		fileLogger := params.Logger.With(zap.String("resolver", "file"))
+
+		res, err := newFileResolver(fileLogger, oCfg.Resolver.File, tb)
+		if err != nil {
+			return nil, err
+		}
+		resolvers = append(resolvers, res)
+	}
+
+	if oCfg.Resolver.HTTP != nil {
+		httpLogger := params.Logger.With(zap.String("resolver", "http"))
+
+		res, err := newHTTPResolver(httpLogger, params.TelemetrySettings, oCfg.Resolver.HTTP, tb)
+		if err != nil {
+			return nil, err
+		}
+		resolvers = append(resolvers, res)
+	}
+
+	if len(resolvers) == 0 {
 		return nil, errNoResolver
 	}
+	if oCfg.Resolver.Merge && len(resolvers) > 1 {
+		return newUnionResolver(params.Logger.With(zap.String("resolver", "merge")), resolvers), nil
+	}
+
+	// without merging, the k8s resolver takes precedence over the static and DNS ones, being the last one created
+	return resolvers[len(resolvers)-1], nil
+}
+
+// exclusiveResolverConfigured returns whether one of the resolvers that can't be combined with any other is configured.
+func exclusiveResolverConfigured(oCfg *Config) bool {
+	return oCfg.Resolver.XDS != nil ||
+		oCfg.Resolver.Consul != nil ||
+		oCfg.Resolver.AWSCloudMap != nil ||
+		oCfg.Resolver.AWSECS != nil ||
+		oCfg.Resolver.Etcd != nil ||
+		oCfg.Resolver.ZooKeeper != nil ||
+		oCfg.Resolver.Eureka != nil ||
+		oCfg.Resolver.Nomad != nil ||
+		oCfg.Resolver.File != nil ||
+		oCfg.Resolver.HTTP != nil
+}
+
+// configuredResolvers returns the number of resolvers configured.
+func configuredResolvers(oCfg *Config) int {
+	configured := 0
+	for _, set := range []bool{
+		oCfg.Resolver.Static != nil,
+		oCfg.Resolver.DNS != nil,
+		oCfg.Resolver.K8sSvc != nil,
+		oCfg.Resolver.XDS != nil,
+		oCfg.Resolver.Consul != nil,
+		oCfg.Resolver.AWSCloudMap != nil,
+		oCfg.Resolver.AWSECS != nil,
+		oCfg.Resolver.Etcd != nil,
+		oCfg.Resolver.ZooKeeper != nil,
+		oCfg.Resolver.Eureka != nil,
+		oCfg.Resolver.Nomad != nil,
+		oCfg.Resolver.File != nil,
+		oCfg.Resolver.HTTP != nil,
+	} {
+		if set {
+			configured++
+		}
+	}
+	return configured
+}
+
+// defaultExporterConfigBuilder builds an OTLP exporter configuration based on the template for the endpoint's protocol,
+// with the override for the endpoint, if any.
+func defaultExporterConfigBuilder(cfg *Config, endpoint string) component.Config {
+	override, overridden := endpointOverride(cfg, endpoint)
+	if endpointProtocol(cfg, endpoint) == otlpHTTPProtocol {
+		oCfg := buildHTTPExporterConfig(cfg, endpoint)
+		if overridden {
+			override.apply(&oCfg.TLSSetting, &oCfg.Auth, &oCfg.Headers)
+		}
+		if len(cfg.ForwardMetadataKeys) > 0 {
+			oCfg.CustomRoundTripper = forwardMetadataRoundTripper(cfg.ForwardMetadataKeys)
+		}
+		return &oCfg
+	}
+	oCfg := buildExporterConfig(cfg, endpoint)
+	if overridden {
+		override.apply(&oCfg.TLSSetting, &oCfg.Auth, &oCfg.Headers)
+	}
+	return &oCfg
+}
+
+// exporterConfig returns the configuration for the sub-exporter of the given endpoint. The headers of the OTLP
+// exporters are left out when the load balancer sends them along with the metadata it forwards.
+func (lb *loadBalancer) exporterConfig(endpoint string) component.Config {
+	oCfg := lb.endpointExporterConfig(endpoint)
+	if otlpCfg, ok := oCfg.(*otlpexporter.Config); ok && lb.forwardsMetadata() {
+		otlpCfg.Headers = nil
+	}
+	return oCfg
+}
 
-	return &loadBalancer{
-		logger:           params.Logger,
-		res:              res,
-		componentFactory: factory,
-		exporters:        map[string]*wrappedExporter{},
-	}, nil
+// endpointExporterConfig builds the configuration for the sub-exporter of the given endpoint, from the exporter
+// template or from the protocol templates.
+func (lb *loadBalancer) endpointExporterConfig(endpoint string) component.Config {
+	if lb.exporterTemplate != nil {
+		oCfg, err := lb.exporterTemplate.exporterConfig(endpoint)
+		if err != nil {
+			// not expected, as the template was checked when starting
+			lb.logger.Error("failed to build the exporter configuration", zap.String("endpoint", endpoint), zap.Error(err))
+		}
+		return oCfg
+	}
+	return lb.exporterConfigBuilder(lb.cfg, endpoint)
 }
 
 func (lb *loadBalancer) Start(ctx context.Context, host component.Host) error {
-	lb.res.onChange(lb.onBackendChanges)
+	if lb.debouncer != nil {
+		lb.res.onChange(lb.debouncer.onChange)
+	} else {
+		lb.res.onChange(lb.onBackendChanges)
+	}
 	lb.host = host
-	return lb.res.start(ctx)
+	if lb.exporterTemplate != nil {
+		if err := lb.exporterTemplate.setHost(host); err != nil {
+			return err
+		}
+	}
+	if res, ok := lb.res.(hostAwareResolver); ok {
+		res.setHost(host)
+	}
+	lb.startupWait.start()
+	err := lb.res.start(ctx)
+	if err != nil && !lb.cfg.AllowDegradedStart {
+		return err
+	}
+	if err != nil {
+		lb.logger.Warn("failed to start the resolver, starting without backends and retrying in the background", zap.Error(err))
+		lb.reportStatusEvent(component.NewRecoverableErrorEvent(err))
+		lb.retryWG.Add(1)
+		go lb.retryResolve()
+	}
+	if lb.healthChecks != nil {
+		lb.retryWG.Add(1)
+		go lb.checkHealth()
+	}
+
+	if lb.cfg.Admin != nil {
+		return adminServers.register(lb)
+	}
+	return nil
+}
+
+// rebalance resolves the backends right away, instead of waiting for the resolver to notice the changes. The ring
+// is rebuilt when the resolved endpoints changed, ramping up the added endpoints when configured.
+func (lb *loadBalancer) rebalance(ctx context.Context) error {
+	endpoints, err := lb.res.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	// the endpoints are applied right away, whether a debounce window is in progress or not
+	lb.debouncer.flush()
+	lb.logger.Info("rebalance requested, backends resolved", zap.Strings("endpoints", endpoints))
+	return nil
+}
+
+// retryResolve periodically resolves the backends after a degraded start, until the resolver yields endpoints.
+func (lb *loadBalancer) retryResolve() {
+	defer lb.retryWG.Done()
+
+	ticker := time.NewTicker(lb.retryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lb.stopCh:
+			return
+		case <-ticker.C:
+			endpoints, err := lb.res.resolve(context.Background())
+			if err != nil || len(endpoints) == 0 {
+				lb.logger.Debug("the resolver hasn't recovered yet", zap.Error(err))
+				continue
+			}
+			lb.logger.Info("the resolver recovered, backends are now available", zap.Strings("endpoints", endpoints))
+			lb.reportStatusEvent(component.NewStatusEvent(component.StatusOK))
+			return
+		}
+	}
+}
+
+func (lb *loadBalancer) reportStatusEvent(ev *component.StatusEvent) {
+	if lb.reportStatus != nil {
+		lb.reportStatus(ev)
+	}
 }
 
 func (lb *loadBalancer) onBackendChanges(resolved []string) {
-	newRing := newHashRing(resolved)
+	lb.updateLock.Lock()
+	if lb.stopped {
+		// the resolver may still report changes while it's being shut down
+		lb.updateLock.Unlock()
+		return
+	}
+	lb.resolved = resolved
+	lb.updateLock.Unlock()
+
+	source := resolved
+	resolved = filterEndpoints(resolved, lb.denylist)
+	if lb.backups != nil {
+		resolved = lb.backups.apply(resolved)
+	}
+	if lb.healthChecks != nil {
+		// the endpoints of the fallback groups are probed too, to know whether they can take over
+		lb.healthChecks.track(source, append(resolved[:len(resolved):len(resolved)], lb.fallbackGroupEndpoints()...))
+	}
+	resolved = lb.selectGroup(resolved)
+	if lb.locality != nil {
+		resolved = lb.locality.apply(resolved)
+	}
+	if lb.subset != nil {
+		resolved = lb.subset.apply(resolved)
+	}
+
+	useFallback := len(resolved) == 0 && len(lb.fallbackEndpoints) > 0
+	if useFallback {
+		resolved = lb.fallbackEndpoints
+	}
+
+	if lb.cfg.MaxBackends > 0 && len(resolved) > lb.cfg.MaxBackends {
+		if lb.limitedFrom.Swap(int64(len(resolved))) != int64(len(resolved)) {
+			lb.logger.Warn("the number of endpoints exceeds the maximum number of backends, using a subset of them",
+				zap.Int("endpoints", len(resolved)), zap.Int("max_backends", lb.cfg.MaxBackends))
+		}
+		resolved = limitEndpoints(resolved, lb.cfg.MaxBackends)
+	} else {
+		lb.limitedFrom.Store(0)
+	}
+
+	resolved, approved := lb.approveBackendChange(resolved)
+	if !approved {
+		return
+	}
+
+	newRing := lb.ringBuilder(resolved, nil)
 
 	if !newRing.equal(lb.ring) {
 		lb.updateLock.Lock()
 		defer lb.updateLock.Unlock()
+		if lb.stopped {
+			return
+		}
 
-		lb.ring = newRing
+		if useFallback != lb.usingFallback {
+			if useFallback {
+				lb.logger.Warn("the resolver returned no endpoints, using the fallback endpoints", zap.Strings("endpoints", resolved))
+			} else {
+				lb.logger.Info("the resolver returned endpoints again, no longer using the fallback endpoints")
+			}
+			lb.usingFallback = useFallback
+		}
+
+		if lb.gracePeriod > 0 {
+			lb.startDraining(resolved)
+		}
+		previous := lb.ring
+		lb.endpoints = resolved
+		lb.rebuildRing()
+		if len(resolved) > 0 {
+			lb.startupWait.end()
+		}
+		if lb.cfg.RampUpDuration > 0 {
+			lb.startRampUp(previous)
+		}
 
 		// TODO: set a timeout?
 		ctx := context.Background()
 
 		// add the missing exporters first
-		lb.addMissingExporters(ctx, resolved)
-		lb.removeExtraExporters(ctx, resolved)
+		lb.addMissingExporters(ctx, lb.withCatchAll(resolved))
+		lb.removeExtraExporters(ctx, lb.withCatchAll(append(lb.drainingEndpoints(), resolved...)))
+	}
+}
+
+// withCatchAll returns the given endpoints along with the catch-all endpoint, when one is configured.
+func (lb *loadBalancer) withCatchAll(endpoints []string) []string {
+	if !lb.hasCatchAll() {
+		return endpoints
+	}
+	return append(endpoints[:len(endpoints):len(endpoints)], lb.cfg.CatchAllEndpoint)
+}
+
+// rebuildRing builds the ring for the current and draining endpoints. The caller must hold the update lock.
+func (lb *loadBalancer) rebuildRing() {
+	lb.ring = lb.ringBuilder(lb.endpoints, lb.drainingEndpoints())
+	lb.telemetry.recordRing(context.Background(), len(lb.ring.endpoints()))
+	if lb.cfg.LogRingChanges {
+		lb.logger.Info("the ring has been rebuilt",
+			zap.Strings("endpoints", lb.ring.endpoints()),
+			zap.String("fingerprint", lb.ring.fingerprint()))
+	}
+}
+
+// startDraining moves the endpoints no longer resolved into the draining state, and takes the endpoints
+// resolved again out of it. The caller must hold the update lock.
+func (lb *loadBalancer) startDraining(resolved []string) {
+	deadline := time.Now().Add(lb.gracePeriod)
+	for _, endpoint := range lb.endpoints {
+		if !endpointFound(endpoint, resolved) {
+			lb.logger.Debug("endpoint removed, draining", zap.String("endpoint", endpoint), zap.Time("deadline", deadline))
+			lb.draining[endpoint] = deadline
+		}
+	}
+	for endpoint := range lb.draining {
+		if endpointFound(endpoint, resolved) {
+			delete(lb.draining, endpoint)
+		}
+	}
+	if len(lb.draining) > 0 && lb.drainTimer == nil {
+		lb.drainTimer = time.AfterFunc(lb.gracePeriod, lb.expireDraining)
+	}
+}
+
+// expireDraining removes the draining endpoints whose grace period is over, shutting down their exporters.
+func (lb *loadBalancer) expireDraining() {
+	lb.updateLock.Lock()
+	defer lb.updateLock.Unlock()
+
+	lb.drainTimer = nil
+	if lb.stopped {
+		return
 	}
+
+	now := time.Now()
+	next := time.Duration(0)
+	for endpoint, deadline := range lb.draining {
+		if remaining := deadline.Sub(now); remaining > 0 {
+			if next == 0 || remaining < next {
+				next = remaining
+			}
+			continue
+		}
+		lb.logger.Debug("endpoint drained", zap.String("endpoint", endpoint))
+		delete(lb.draining, endpoint)
+	}
+
+	lb.rebuildRing()
+	lb.removeExtraExporters(context.Background(), lb.withCatchAll(append(lb.drainingEndpoints(), lb.endpoints...)))
+
+	if next > 0 {
+		lb.drainTimer = time.AfterFunc(next, lb.expireDraining)
+	}
+}
+
+// drainingEndpoints returns the sorted list of the endpoints currently draining.
+func (lb *loadBalancer) drainingEndpoints() []string {
+	if len(lb.draining) == 0 {
+		return nil
+	}
+	endpoints := make([]string, 0, len(lb.draining))
+	for endpoint := range lb.draining {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	return endpoints
 }
 
 func (lb *loadBalancer) addMissingExporters(ctx context.Context, endpoints []string) {
@@ -119,12 +801,21 @@ func (lb *loadBalancer) addMissingExporters(ctx context.Context, endpoints []str
 		endpoint = endpointWithPort(endpoint)
 
 		if _, exists := lb.exporters[endpoint]; !exists {
-			exp, err := lb.componentFactory(ctx, endpoint)
+			exp, err := lb.newExporter(ctx, endpoint)
 			if err != nil {
 				lb.logger.Error("failed to create new exporter for endpoint", zap.String("endpoint", endpoint), zap.Error(err))
 				continue
 			}
 			we := newWrappedExporter(exp)
+			we.headers = lb.outgoingHeaders(endpoint)
+			we.breaker = lb.newEndpointCircuitBreaker(endpoint)
+			we.limiter = newRateLimiter(lb.cfg.RateLimit)
+			if lb.backups != nil {
+				endpoint := endpoint
+				we.onResult = func(err error) {
+					lb.onSendResult(endpoint, err)
+				}
+			}
 			if err = we.Start(ctx, lb.host); err != nil {
 				lb.logger.Error("failed to start new exporter for endpoint", zap.String("endpoint", endpoint), zap.Error(err))
 				continue
@@ -134,11 +825,74 @@ func (lb *loadBalancer) addMissingExporters(ctx context.Context, endpoints []str
 	}
 }
 
+// newExporter creates the exporter for the endpoint, pooling several exporters when a connection pool is configured.
+func (lb *loadBalancer) newExporter(ctx context.Context, endpoint string) (component.Component, error) {
+	if isUnixSocket(endpoint) && lb.exporterTemplate == nil && endpointProtocol(lb.cfg, endpoint) != otlpProtocol {
+		return nil, fmt.Errorf("the endpoint %q is a unix domain socket, which only the otlp protocol supports", endpoint)
+	}
+	if lb.cfg.ConnectionPoolSize > 1 {
+		return newPooledExporter(ctx, lb.componentFactory, endpoint, lb.cfg.ConnectionPoolSize)
+	}
+	return lb.componentFactory(ctx, endpoint)
+}
+
+// validateMaxBackends checks that the maximum number of backends isn't negative.
+func validateMaxBackends(cfg *Config) error {
+	if cfg.MaxBackends < 0 {
+		return errors.New("invalid max_backends, it must not be negative")
+	}
+	return nil
+}
+
+// limitEndpoints selects up to max endpoints from the given list. The selection is based on the hash of each
+// endpoint, so that the same endpoints are selected regardless of their order, and adding a new endpoint to the
+// list replaces at most one of the selected endpoints. The result is sorted.
+func limitEndpoints(endpoints []string, max int) []string {
+	if len(endpoints) <= max {
+		return endpoints
+	}
+
+	candidates := make([]string, len(endpoints))
+	copy(candidates, endpoints)
+	sort.Slice(candidates, func(i, j int) bool {
+		hi, hj := crc32.ChecksumIEEE([]byte(candidates[i])), crc32.ChecksumIEEE([]byte(candidates[j]))
+		if hi != hj {
+			return hi < hj
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	selected := candidates[:max]
+	sort.Strings(selected)
+	return selected
+}
+
+// isUnixSocket returns whether the endpoint is a unix domain socket, such as unix:///var/run/collector.sock, which
+// has no port.
+func isUnixSocket(endpoint string) bool {
+	return strings.HasPrefix(endpoint, unixSocketScheme)
+}
+
+// unixSocketPath returns the path of the unix domain socket of the endpoint, absolute with the unix:///path form, and
+// relative with the unix:path form, as for gRPC.
+func unixSocketPath(endpoint string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(endpoint, unixSocketScheme), "//")
+}
+
 func endpointWithPort(endpoint string) string {
-	if !strings.Contains(endpoint, ":") {
-		endpoint = fmt.Sprintf("%s:%s", endpoint, defaultPort)
+	if isUnixSocket(endpoint) {
+		return endpoint
 	}
-	return endpoint
+	if _, _, err := net.SplitHostPort(endpoint); err == nil {
+		return endpoint
+	}
+
+	// IPv6 literals are bracketed before adding the port, whether they already are or not
+	host := strings.TrimSuffix(strings.TrimPrefix(endpoint, "["), "]")
+	if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return endpoint
+	}
+	return net.JoinHostPort(host, defaultPort)
 }
 
 func (lb *loadBalancer) removeExtraExporters(ctx context.Context, endpoints []string) {
@@ -149,15 +903,47 @@ func (lb *loadBalancer) removeExtraExporters(ctx context.Context, endpoints []st
 	for existing := range lb.exporters {
 		if !endpointFound(existing, endpointsWithPort) {
 			exp := lb.exporters[existing]
+			exp.markRemoved()
 			// Shutdown the exporter asynchronously to avoid blocking the resolver
-			go func() {
-				_ = exp.Shutdown(ctx)
-			}()
+			lb.shutdownWg.Add(1)
+			go func(endpoint string) {
+				defer lb.shutdownWg.Done()
+				lb.waitDrainPeriod()
+				lb.shutdownExporter(ctx, endpoint, exp)
+			}(existing)
 			delete(lb.exporters, existing)
 		}
 	}
 }
 
+// shutdownExporter shuts down the exporter of a removed endpoint, giving up on the data it's still sending once the
+// exporter shutdown timeout is over.
+func (lb *loadBalancer) shutdownExporter(ctx context.Context, endpoint string, exp *wrappedExporter) {
+	if lb.cfg.ExporterShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, lb.cfg.ExporterShutdownTimeout)
+		defer cancel()
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		lb.logger.Warn("failed to shut down the exporter of a removed endpoint", zap.String("endpoint", endpoint), zap.Error(err))
+	}
+}
+
+// waitDrainPeriod waits for the drain period of a removed exporter to be over, during which it no longer gets new
+// data but keeps sending out the data it queued or is retrying. The wait is cut short when the load balancer is shut
+// down.
+func (lb *loadBalancer) waitDrainPeriod() {
+	if lb.cfg.DrainPeriod <= 0 {
+		return
+	}
+	timer := time.NewTimer(lb.cfg.DrainPeriod)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-lb.stopCh:
+	}
+}
+
 func endpointFound(endpoint string, endpoints []string) bool {
 	for _, candidate := range endpoints {
 		if candidate == endpoint {
@@ -168,24 +954,183 @@ func endpointFound(endpoint string, endpoints []string) bool {
 	return false
 }
 
-func (lb *loadBalancer) Shutdown(context.Context) error {
+func (lb *loadBalancer) Shutdown(ctx context.Context) error {
+	// the debouncer applies the changes with the update lock, and is stopped before taking it
+	lb.debouncer.stop()
+	lb.updateLock.Lock()
+	if lb.stopped {
+		lb.updateLock.Unlock()
+		return nil
+	}
 	lb.stopped = true
-	return nil
+	close(lb.stopCh)
+	lb.startupWait.end()
+	if lb.drainTimer != nil {
+		lb.drainTimer.Stop()
+		lb.drainTimer = nil
+	}
+	if lb.rampTimer != nil {
+		lb.rampTimer.Stop()
+		lb.rampTimer = nil
+	}
+	// the exporters in use are shut down along with the ones of the removed endpoints
+	lb.removeExtraExporters(ctx, nil)
+	lb.updateLock.Unlock()
+	if lb.backups != nil {
+		lb.backups.stop()
+	}
+
+	// the changes reported by the resolver while it's being shut down are ignored, the load balancer being stopped.
+	// A shared resolver is only shut down once all the load balancers sharing it are shut down.
+	errs := lb.res.shutdown(ctx)
+
+	// the background resolution and health checks need the update lock to apply its results
+	lb.retryWG.Wait()
+
+	// the exporters are given until the end of the shutdown to send out their data
+	exportersShutdown := make(chan struct{})
+	go func() {
+		lb.shutdownWg.Wait()
+		close(exportersShutdown)
+	}()
+	select {
+	case <-exportersShutdown:
+	case <-ctx.Done():
+		lb.logger.Warn("the exporters didn't shut down in time", zap.Error(ctx.Err()))
+	}
+
+	errs = errors.Join(errs, lb.telemetry.shutdown())
+	if lb.cfg.Admin != nil {
+		errs = errors.Join(errs, adminServers.unregister(ctx, lb))
+	}
+	return errs
 }
 
 // exporterAndEndpoint returns the exporter and the endpoint for the given identifier.
 func (lb *loadBalancer) exporterAndEndpoint(identifier []byte) (*wrappedExporter, string, error) {
-	// NOTE: make rolling updates of next tier of collectors work. currently, this may cause
-	// data loss because the latest batches sent to outdated backend will never find their way out.
-	// for details: https://github.com/open-telemetry/opentelemetry-collector-contrib/issues/1690
 	lb.updateLock.RLock()
 	defer lb.updateLock.RUnlock()
-	endpoint := lb.ring.endpointFor(identifier)
+	endpoint := lb.endpointFor(identifier)
 	exp, found := lb.exporters[endpointWithPort(endpoint)]
 	if !found {
 		// something is really wrong... how come we couldn't find the exporter??
 		return nil, "", fmt.Errorf("couldn't find the exporter for the endpoint %q", endpoint)
 	}
+	lb.telemetry.recordRoutingKey(context.Background(), endpoint)
+
+	return exp, endpoint, nil
+}
+
+// withoutAffinity returns whether the data routed with the given key can go to any endpoint, without routing
+// identifiers.
+func withoutAffinity(key routingKey) bool {
+	return key == noRouting || key == leastOutstandingRouting
+}
+
+// exporterAndEndpointWithoutAffinity returns the exporter and the endpoint for the data routed with a key without
+// affinity: the endpoints in use are picked in turn, or, with leastOutstandingRouting, the endpoint with the fewest
+// sends in progress is picked, the ties going to the endpoints in turn. The endpoints with an open circuit are
+// skipped, unless all of them are.
+func (lb *loadBalancer) exporterAndEndpointWithoutAffinity(key routingKey) (*wrappedExporter, string, error) {
+	lb.updateLock.RLock()
+	defer lb.updateLock.RUnlock()
+	if len(lb.endpoints) == 0 {
+		return nil, "", errNoEndpointsInUse
+	}
+	turn := int((lb.nextTurn.Add(1) - 1) % uint64(len(lb.endpoints)))
+	endpoint := lb.endpoints[turn]
+	if lb.cfg.CircuitBreaker != nil {
+		for i := range lb.endpoints {
+			if candidate := lb.endpoints[(turn+i)%len(lb.endpoints)]; lb.circuitAllows(candidate) {
+				endpoint = candidate
+				break
+			}
+		}
+	}
+	if key == leastOutstandingRouting {
+		// the endpoints whose circuit is open are skipped, unless all the circuits are open
+		anyClosed := lb.circuitAllows(endpoint)
+		fewest := int64(-1)
+		for i := range lb.endpoints {
+			candidate := lb.endpoints[(turn+i)%len(lb.endpoints)]
+			exp, found := lb.exporters[endpointWithPort(candidate)]
+			if !found || (anyClosed && !lb.circuitAllows(candidate)) {
+				continue
+			}
+			if outstanding := exp.outstanding(); fewest < 0 || outstanding < fewest {
+				fewest, endpoint = outstanding, candidate
+			}
+		}
+	}
+	exp, found := lb.exporters[endpointWithPort(endpoint)]
+	if !found {
+		return nil, "", fmt.Errorf("couldn't find the exporter for the endpoint %q", endpoint)
+	}
+
+	return exp, endpoint, nil
+}
+
+// singleExporter returns the exporter for the only endpoint in use, when all the data is routed to it whatever
+// its routing identifier: a single endpoint is resolved, none is draining, and there's no catch-all endpoint
+// nor recently routed identifiers to track.
+func (lb *loadBalancer) singleExporter() (*wrappedExporter, string, bool) {
+	if lb.hasCatchAll() || lb.recentKeys != nil {
+		return nil, "", false
+	}
+
+	lb.updateLock.RLock()
+	defer lb.updateLock.RUnlock()
+	if len(lb.endpoints) != 1 || len(lb.draining) > 0 {
+		return nil, "", false
+	}
+	endpoint := lb.endpoints[0]
+	exp, found := lb.exporters[endpointWithPort(endpoint)]
+	return exp, endpoint, found
+}
+
+// hasCatchAll returns whether a catch-all endpoint is configured.
+func (lb *loadBalancer) hasCatchAll() bool {
+	return lb.cfg.CatchAllEndpoint != ""
+}
+
+// catchAllExporterAndEndpoint returns the exporter and the endpoint for the data without a routing identifier.
+func (lb *loadBalancer) catchAllExporterAndEndpoint() (*wrappedExporter, string, error) {
+	lb.updateLock.RLock()
+	defer lb.updateLock.RUnlock()
+	endpoint := lb.cfg.CatchAllEndpoint
+	exp, found := lb.exporters[endpointWithPort(endpoint)]
+	if !found {
+		return nil, "", fmt.Errorf("couldn't find the exporter for the catch-all endpoint %q", endpoint)
+	}
 
 	return exp, endpoint, nil
 }
+
+// endpointFor returns the endpoint for the given identifier. Identifiers recently routed to an endpoint that is now
+// draining keep being routed to it, identifiers routed to an endpoint above its bounded load or with an open
+// circuit overflow to the next one, and identifiers moving to an endpoint being ramped up move only once their turn
+// comes. The caller must hold
+// the update lock.
+func (lb *loadBalancer) endpointFor(identifier []byte) string {
+	if lb.ring == nil {
+		// perhaps the ring itself couldn't get initialized yet?
+		return ""
+	}
+	if lb.recentKeys == nil {
+		endpoint := lb.ring.endpointFor(identifier)
+		lb.routingVerifier.verify(lb.ring, identifier, endpoint)
+		return lb.circuitEndpoint(identifier, lb.rampedEndpoint(identifier, lb.boundedEndpoint(identifier, endpoint)))
+	}
+
+	var endpoint string
+	if previous, known := lb.recentKeys.get(identifier); known {
+		endpoint = lb.ring.endpointForKnown(identifier, previous)
+	} else {
+		endpoint = lb.ring.endpointFor(identifier)
+		lb.routingVerifier.verify(lb.ring, identifier, endpoint)
+		endpoint = lb.boundedEndpoint(identifier, endpoint)
+	}
+	endpoint = lb.circuitEndpoint(identifier, lb.rampedEndpoint(identifier, endpoint))
+	lb.recentKeys.record(identifier, endpoint)
+	return endpoint
+}
@@ -6,7 +6,11 @@ package loadbalancingexporter
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,6 +19,8 @@ import (
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/exporter/exportertest"
 	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -134,6 +140,9 @@ func TestWithDNSResolverNoEndpoints(t *testing.T) {
 
 	err = p.Start(context.Background(), componenttest.NewNopHost())
 	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
 
 	// test
 	_, e, _ := p.exporterAndEndpoint([]byte{128, 128, 0, 0})
@@ -183,6 +192,80 @@ func TestStartFailureStaticResolver(t *testing.T) {
 	assert.Equal(t, expectedErr, res)
 }
 
+func TestDegradedStart(t *testing.T) {
+	// prepare
+	var statusLock sync.Mutex
+	var statuses []component.Status
+	params := exportertest.NewNopCreateSettings()
+	params.ReportStatus = func(ev *component.StatusEvent) {
+		statusLock.Lock()
+		defer statusLock.Unlock()
+		statuses = append(statuses, ev.Status())
+	}
+
+	cfg := simpleConfig()
+	cfg.AllowDegradedStart = true
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	}
+	p, err := newLoadBalancer(params, cfg, componentFactory)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+	p.retryInterval = 10 * time.Millisecond
+
+	// the resolution fails at start and for the first retry, then recovers
+	var attempts atomic.Int32
+	p.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(context.Context) ([]string, error) {
+			if attempts.Add(1) <= 2 {
+				return nil, errors.New("no such host")
+			}
+			return []string{"endpoint-1"}, nil
+		},
+	}
+
+	// test
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// verify
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		p.updateLock.RLock()
+		defer p.updateLock.RUnlock()
+		_, found := p.exporters["endpoint-1:4317"]
+		return found && p.ring != nil && p.ring.endpointFor([]byte("key")) == "endpoint-1"
+	}, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		statusLock.Lock()
+		defer statusLock.Unlock()
+		return assert.ObjectsAreEqual([]component.Status{component.StatusRecoverableError, component.StatusOK}, statuses)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDegradedStartDisabled(t *testing.T) {
+	// prepare
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), nil)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	expectedErr := errors.New("no such host")
+	p.res = &mockResolver{
+		onResolve: func(context.Context) ([]string, error) {
+			return nil, expectedErr
+		},
+	}
+
+	// test
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+
+	// verify
+	assert.Equal(t, expectedErr, err)
+}
+
 func TestLoadBalancerShutdown(t *testing.T) {
 	// prepare
 	cfg := simpleConfig()
@@ -209,14 +292,271 @@ func TestOnBackendChanges(t *testing.T) {
 
 	// test
 	p.onBackendChanges([]string{"endpoint-1"})
-	require.Len(t, p.ring.items, defaultWeight)
+	require.Len(t, p.ring.(*hashRing).items, defaultWeight)
 
 	// this should resolve to two endpoints
 	endpoints := []string{"endpoint-1", "endpoint-2"}
 	p.onBackendChanges(endpoints)
 
 	// verify
-	assert.Len(t, p.ring.items, 2*defaultWeight)
+	assert.Len(t, p.ring.(*hashRing).items, 2*defaultWeight)
+}
+
+func TestFallbackEndpointsWhenResolverIsEmpty(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Fallback = &StaticResolver{Hostnames: []string{"fallback-1"}}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	}
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	var resolved []string
+	res := &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return resolved, nil
+		},
+	}
+	p.res = res
+
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// the resolver yields nothing, traffic flows to the fallback
+	_, endpoint, err := p.exporterAndEndpoint([]byte("some-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "fallback-1", endpoint)
+	assert.Contains(t, p.exporters, endpointWithPort("fallback-1"))
+
+	// test: the resolver recovers, traffic returns to the primary endpoints
+	resolved = []string{"endpoint-1"}
+	_, err = res.resolve(context.Background())
+	require.NoError(t, err)
+
+	// verify
+	_, endpoint, err = p.exporterAndEndpoint([]byte("some-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "endpoint-1", endpoint)
+	assert.NotContains(t, p.exporters, endpointWithPort("fallback-1"))
+
+	// test: the resolver is empty again
+	resolved = nil
+	_, err = res.resolve(context.Background())
+	require.NoError(t, err)
+
+	// verify
+	_, endpoint, err = p.exporterAndEndpoint([]byte("some-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "fallback-1", endpoint)
+}
+
+func TestDenylistedEndpointsAreIgnored(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Denylist = []string{"*.region-b.svc"}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	}
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	// test
+	p.onBackendChanges([]string{"backend-1.region-a.svc", "backend-2.region-b.svc"})
+
+	// verify
+	assert.Len(t, p.exporters, 1)
+	assert.Contains(t, p.exporters, "backend-1.region-a.svc:4317")
+	assert.Equal(t, newHashRing([]string{"backend-1.region-a.svc"}), p.ring)
+}
+
+func TestLogRingChanges(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.LogRingChanges = true
+	core, logs := observer.New(zap.InfoLevel)
+	params := exportertest.NewNopCreateSettings()
+	params.Logger = zap.New(core)
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	}
+	p, err := newLoadBalancer(params, cfg, componentFactory)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	// test
+	p.onBackendChanges([]string{"endpoint-2", "endpoint-1"})
+	p.onBackendChanges([]string{"endpoint-1", "endpoint-2"})
+	p.onBackendChanges([]string{"endpoint-1"})
+
+	// verify
+	entries := logs.FilterMessage("the ring has been rebuilt").All()
+	require.Len(t, entries, 2)
+	assert.Equal(t, []any{"endpoint-1", "endpoint-2"}, entries[0].ContextMap()["endpoints"])
+	assert.Equal(t, []any{"endpoint-1"}, entries[1].ContextMap()["endpoints"])
+	assert.NotEqual(t, entries[0].ContextMap()["fingerprint"], entries[1].ContextMap()["fingerprint"])
+	assert.Equal(t, p.ring.fingerprint(), entries[1].ContextMap()["fingerprint"])
+}
+
+func TestRemovalGracePeriod(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.RemovalGracePeriod = time.Hour
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	}
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	p.onBackendChanges([]string{"endpoint-1", "endpoint-2"})
+
+	// both identifiers are owned by endpoint-2, but only the first one is routed before the removal
+	known, unknown := []byte("get-recommendations-1"), []byte{128, 128, 0, 0}
+	_, endpoint, err := p.exporterAndEndpoint(known)
+	require.NoError(t, err)
+	require.Equal(t, "endpoint-2", endpoint)
+	require.Equal(t, "endpoint-2", p.ring.endpointFor(unknown))
+
+	// test
+	p.onBackendChanges([]string{"endpoint-1"})
+
+	// verify
+	_, endpoint, err = p.exporterAndEndpoint(known)
+	require.NoError(t, err)
+	assert.Equal(t, "endpoint-2", endpoint)
+
+	_, endpoint, err = p.exporterAndEndpoint(unknown)
+	require.NoError(t, err)
+	assert.Equal(t, "endpoint-1", endpoint)
+
+	// the new identifier doesn't switch to the draining endpoint on subsequent calls
+	_, endpoint, err = p.exporterAndEndpoint(unknown)
+	require.NoError(t, err)
+	assert.Equal(t, "endpoint-1", endpoint)
+	assert.Contains(t, p.exporters, "endpoint-2:4317")
+
+	// test: the grace period is over
+	p.updateLock.Lock()
+	p.draining["endpoint-2"] = time.Now().Add(-time.Second)
+	p.updateLock.Unlock()
+	p.expireDraining()
+
+	// verify
+	_, endpoint, err = p.exporterAndEndpoint(known)
+	require.NoError(t, err)
+	assert.Equal(t, "endpoint-1", endpoint)
+	assert.NotContains(t, p.exporters, "endpoint-2:4317")
+	assert.Empty(t, p.draining)
+}
+
+func TestRemovalGracePeriodEndpointReturns(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.RemovalGracePeriod = time.Hour
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	}
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	p.onBackendChanges([]string{"endpoint-1", "endpoint-2"})
+	p.onBackendChanges([]string{"endpoint-1"})
+	require.Contains(t, p.draining, "endpoint-2")
+	p.onBackendChanges([]string{"endpoint-1", "endpoint-2"})
+
+	// verify
+	assert.Empty(t, p.draining)
+	assert.Equal(t, []string{"endpoint-1", "endpoint-2"}, p.ring.endpoints())
+}
+
+func TestLimitEndpoints(t *testing.T) {
+	endpoints := []string{"endpoint-1", "endpoint-2", "endpoint-3", "endpoint-4", "endpoint-5"}
+
+	// test
+	selected := limitEndpoints(endpoints, 3)
+
+	// verify
+	require.Len(t, selected, 3)
+	assert.IsIncreasing(t, selected)
+	for _, endpoint := range selected {
+		assert.Contains(t, endpoints, endpoint)
+	}
+
+	// the order of the input doesn't matter
+	reversed := []string{"endpoint-5", "endpoint-4", "endpoint-3", "endpoint-2", "endpoint-1"}
+	assert.Equal(t, selected, limitEndpoints(reversed, 3))
+
+	// a new endpoint replaces at most one of the selected ones
+	grown := limitEndpoints(append([]string{"endpoint-6"}, endpoints...), 3)
+	common := 0
+	for _, endpoint := range grown {
+		if endpointFound(endpoint, selected) {
+			common++
+		}
+	}
+	assert.GreaterOrEqual(t, common, 2)
+
+	// lists within the limit are not changed
+	assert.Equal(t, endpoints, limitEndpoints(endpoints, 5))
+}
+
+func TestMaxBackends(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.MaxBackends = 2
+	core, logs := observer.New(zap.WarnLevel)
+	params := exportertest.NewNopCreateSettings()
+	params.Logger = zap.New(core)
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	}
+	p, err := newLoadBalancer(params, cfg, componentFactory)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	var endpoints []string
+	for i := 0; i < 100; i++ {
+		endpoints = append(endpoints, fmt.Sprintf("endpoint-%d", i))
+	}
+
+	// test
+	p.onBackendChanges(endpoints)
+	p.onBackendChanges(endpoints)
+
+	// verify
+	assert.Len(t, p.exporters, 2)
+	assert.Equal(t, limitEndpoints(endpoints, 2), p.ring.endpoints())
+	// the limit is reported again only when the number of endpoints changes
+	assert.Equal(t, 1, logs.FilterMessage("the number of endpoints exceeds the maximum number of backends, using a subset of them").Len())
+	p.onBackendChanges(endpoints[:50])
+	assert.Equal(t, 2, logs.FilterMessage("the number of endpoints exceeds the maximum number of backends, using a subset of them").Len())
+}
+
+func TestValidateMaxBackends(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+
+	// test and verify
+	assert.NoError(t, validateMaxBackends(cfg))
+
+	cfg.MaxBackends = -1
+	assert.ErrorContains(t, validateMaxBackends(cfg), "max_backends")
+	_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Error(t, err)
 }
 
 func TestRemoveExtraExporters(t *testing.T) {
@@ -240,6 +580,162 @@ func TestRemoveExtraExporters(t *testing.T) {
 	assert.NotContains(t, p.exporters, endpointWithPort("endpoint-2"))
 }
 
+func TestRemoveExtraExportersDrainPeriod(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.DrainPeriod = 50 * time.Millisecond
+	var shutdown atomic.Bool
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return mockComponent{
+			ShutdownFunc: func(context.Context) error {
+				shutdown.Store(true)
+				return nil
+			},
+		}, nil
+	}
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	p.addMissingExporters(context.Background(), []string{"endpoint-1", "endpoint-2"})
+	removed := p.exporters[endpointWithPort("endpoint-2")]
+
+	// test
+	p.removeExtraExporters(context.Background(), []string{"endpoint-1"})
+
+	// verify
+	assert.NotContains(t, p.exporters, endpointWithPort("endpoint-2"))
+	assert.True(t, removed.isRemoved())
+	assert.False(t, shutdown.Load())
+	assert.Eventually(t, shutdown.Load, time.Second, 5*time.Millisecond)
+}
+
+func TestRemoveExtraExportersShutdownTimeout(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.ExporterShutdownTimeout = 10 * time.Millisecond
+	var shutdown atomic.Bool
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return mockComponent{
+			ShutdownFunc: func(context.Context) error {
+				shutdown.Store(true)
+				return nil
+			},
+		}, nil
+	}
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	p.addMissingExporters(context.Background(), []string{"endpoint-1", "endpoint-2"})
+
+	// a send to the removed endpoint doesn't complete before the end of the test
+	removed := p.exporters[endpointWithPort("endpoint-2")]
+	removed.consumeWG.Add(1)
+	defer removed.consumeWG.Done()
+
+	// test
+	p.removeExtraExporters(context.Background(), []string{"endpoint-1"})
+
+	// verify
+	assert.Eventually(t, shutdown.Load, time.Second, 5*time.Millisecond)
+}
+
+func TestShutdownWaitsForRemovedExporters(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	var shutdown atomic.Bool
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return mockComponent{
+			ShutdownFunc: func(context.Context) error {
+				time.Sleep(20 * time.Millisecond)
+				shutdown.Store(true)
+				return nil
+			},
+		}, nil
+	}
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	p.addMissingExporters(context.Background(), []string{"endpoint-2"})
+	p.removeExtraExporters(context.Background(), []string{"endpoint-1"})
+
+	// test
+	err = p.Shutdown(context.Background())
+
+	// verify
+	assert.NoError(t, err)
+	assert.True(t, shutdown.Load())
+}
+
+func TestShutdownStopsResolverAndExporters(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	var shutdowns atomic.Int64
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return mockComponent{
+			ShutdownFunc: func(context.Context) error {
+				shutdowns.Add(1)
+				return nil
+			},
+		}, nil
+	}
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	resolverShutdown := false
+	res := &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return []string{"endpoint-1", "endpoint-2"}, nil
+		},
+		onShutdown: func(context.Context) error {
+			resolverShutdown = true
+			return nil
+		},
+	}
+	p.res = res
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	require.Len(t, p.exporters, 2)
+
+	// test
+	err = p.Shutdown(context.Background())
+
+	// verify
+	assert.NoError(t, err)
+	assert.True(t, resolverShutdown)
+	assert.EqualValues(t, 2, shutdowns.Load())
+	assert.Empty(t, p.exporters)
+
+	// the changes reported once shut down don't create exporters
+	p.onBackendChanges([]string{"endpoint-3"})
+	assert.Empty(t, p.exporters)
+	assert.EqualValues(t, 2, shutdowns.Load())
+}
+
+func TestShutdownDoesNotWaitForRemovedExportersPastItsDeadline(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	blocked := make(chan struct{})
+	defer close(blocked)
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return mockComponent{
+			ShutdownFunc: func(context.Context) error {
+				<-blocked
+				return nil
+			},
+		}, nil
+	}
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	p.addMissingExporters(context.Background(), []string{"endpoint-2"})
+	p.removeExtraExporters(context.Background(), []string{"endpoint-1"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// test
+	err = p.Shutdown(ctx)
+
+	// verify
+	assert.NoError(t, err)
+}
+
 func TestAddMissingExporters(t *testing.T) {
 	// prepare
 	cfg := simpleConfig()
@@ -273,6 +769,51 @@ func TestAddMissingExporters(t *testing.T) {
 	assert.Contains(t, p.exporters, "endpoint-2:4317")
 }
 
+func TestCustomExporterConfigBuilder(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	received := map[string]component.Config{}
+
+	var p *loadBalancer
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		received[endpoint] = p.exporterConfig(endpoint)
+		return newNopMockExporter(), nil
+	})
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.exporterConfigBuilder = func(cfg *Config, endpoint string) component.Config {
+		oCfg := buildExporterConfig(cfg, "custom-"+endpoint)
+		oCfg.Compression = "none"
+		return &oCfg
+	}
+
+	// test
+	p.onBackendChanges([]string{"endpoint-1"})
+
+	// verify
+	require.Contains(t, received, "endpoint-1:4317")
+	oCfg, ok := received["endpoint-1:4317"].(*otlpexporter.Config)
+	require.True(t, ok)
+	assert.Equal(t, "custom-endpoint-1:4317", oCfg.Endpoint)
+	assert.EqualValues(t, "none", oCfg.Compression)
+}
+
+func TestDefaultExporterConfigBuilder(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	// test
+	oCfg := p.exporterConfig("endpoint-1:4317")
+
+	// verify
+	expected := buildExporterConfig(cfg, "endpoint-1:4317")
+	assert.Equal(t, &expected, oCfg)
+}
+
 func TestFailedToAddMissingExporters(t *testing.T) {
 	// prepare
 	cfg := simpleConfig()
@@ -340,6 +881,26 @@ func TestEndpointWithPort(t *testing.T) {
 			"endpoint-1:55690",
 			"endpoint-1:55690",
 		},
+		{
+			"::1",
+			"[::1]:4317",
+		},
+		{
+			"[::1]",
+			"[::1]:4317",
+		},
+		{
+			"[::1]:55690",
+			"[::1]:55690",
+		},
+		{
+			"unix:///var/run/collector.sock",
+			"unix:///var/run/collector.sock",
+		},
+		{
+			"unix:collector.sock",
+			"unix:collector.sock",
+		},
 	} {
 		assert.Equal(t, tt.expected, endpointWithPort(tt.input))
 	}
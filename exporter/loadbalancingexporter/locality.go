@@ -0,0 +1,102 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// defaultMinLocalBackends is the number of backends in the zone of the collector below which all the zones are used
+const defaultMinLocalBackends = 1
+
+var errNoLocalityZone = errors.New("no zone specified for the locality")
+
+// locality restricts the ring to the endpoints in the zone of the collector, spilling over to the endpoints of all
+// the zones while there are too few local ones.
+type locality struct {
+	logger *zap.Logger
+	zone   string
+
+	// patterns select the endpoints of each zone, the zone of the endpoints matching patterns[i] being zones[i]
+	patterns []string
+	zones    []string
+
+	minLocal int
+	spilling atomic.Bool
+}
+
+func newLocality(logger *zap.Logger, cfg *LocalitySettings) (*locality, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if cfg.Zone == "" {
+		return nil, errNoLocalityZone
+	}
+	if cfg.MinLocalBackends < 0 {
+		return nil, fmt.Errorf("invalid min_local_backends %d, it must be positive", cfg.MinLocalBackends)
+	}
+
+	l := &locality{
+		logger:   logger,
+		zone:     cfg.Zone,
+		minLocal: cfg.MinLocalBackends,
+	}
+	if l.minLocal == 0 {
+		l.minLocal = defaultMinLocalBackends
+	}
+
+	// the zones are sorted, so that the precedence between the patterns of different zones is deterministic
+	zones := make([]string, 0, len(cfg.EndpointZones))
+	for zone := range cfg.EndpointZones {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		for _, pattern := range cfg.EndpointZones[zone] {
+			l.patterns = append(l.patterns, pattern)
+			l.zones = append(l.zones, zone)
+		}
+	}
+	return l, nil
+}
+
+// zoneOf returns the zone of the endpoint, if any of the patterns selects it.
+func (l *locality) zoneOf(endpoint string) (string, bool) {
+	i, ok := bestMatch(endpoint, l.patterns)
+	if !ok {
+		return "", false
+	}
+	return l.zones[i], true
+}
+
+// apply returns the endpoints in the zone of the collector, or all the endpoints when there are fewer local ones
+// than the minimum.
+func (l *locality) apply(endpoints []string) []string {
+	local := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if zone, ok := l.zoneOf(endpoint); ok && zone == l.zone {
+			local = append(local, endpoint)
+		}
+	}
+
+	spilling := len(local) < l.minLocal
+	if l.spilling.Swap(spilling) != spilling {
+		if spilling {
+			l.logger.Warn("too few backends in the zone of the collector, using the backends of all the zones",
+				zap.String("zone", l.zone), zap.Strings("local", local), zap.Int("min_local_backends", l.minLocal))
+		} else {
+			l.logger.Info("enough backends in the zone of the collector again, using only them",
+				zap.String("zone", l.zone), zap.Strings("local", local))
+		}
+	}
+	if spilling {
+		return endpoints
+	}
+	return local
+}
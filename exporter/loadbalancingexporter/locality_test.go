@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+)
+
+func TestLocalityApply(t *testing.T) {
+	// prepare
+	l, err := newLocality(zap.NewNop(), &LocalitySettings{
+		Zone: "zone-a",
+		EndpointZones: map[string][]string{
+			"zone-a": {".zone-a.svc", "backend-3.zone-b.svc"},
+			"zone-b": {".zone-b.svc"},
+		},
+		MinLocalBackends: 2,
+	})
+	require.NoError(t, err)
+	endpoints := []string{"backend-1.zone-a.svc:4317", "backend-2.zone-b.svc:4317", "backend-3.zone-b.svc:4317", "backend-4:4317"}
+
+	// test
+	local := l.apply(endpoints)
+
+	// verify
+	// the exact pattern takes precedence over the suffix of the other zone
+	assert.Equal(t, []string{"backend-1.zone-a.svc:4317", "backend-3.zone-b.svc:4317"}, local)
+
+	// all the zones are used while there are too few local backends
+	assert.Equal(t, endpoints[:2], l.apply(endpoints[:2]))
+	assert.Equal(t, endpoints[1:], l.apply(endpoints[1:]))
+	assert.Equal(t, local, l.apply(endpoints))
+}
+
+func TestLocalityRing(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1.zone-a", "endpoint-2.zone-b", "endpoint-3.zone-a"}
+	cfg.Locality = &LocalitySettings{
+		Zone:          "zone-a",
+		EndpointZones: map[string][]string{"zone-a": {"*.zone-a"}, "zone-b": {"*.zone-b"}},
+	}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockTracesExporter(), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+
+	// test
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, []string{"endpoint-1.zone-a", "endpoint-3.zone-a"}, lb.ring.endpoints())
+	assert.NotContains(t, lb.exporters, "endpoint-2.zone-b:4317")
+}
+
+func TestLocalityInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		settings *LocalitySettings
+		err      string
+	}{
+		{"no zone", &LocalitySettings{}, errNoLocalityZone.Error()},
+		{"negative minimum", &LocalitySettings{Zone: "zone-a", MinLocalBackends: -1}, "invalid min_local_backends -1"},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := simpleConfig()
+			cfg.Locality = tt.settings
+
+			// test
+			_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+
+			// verify
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
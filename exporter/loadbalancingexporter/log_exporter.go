@@ -5,16 +5,16 @@ package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/rand"
 	"sync"
 	"time"
 
-	"go.opencensus.io/stats"
-	"go.opencensus.io/tag"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/exporter"
-	"go.opentelemetry.io/collector/exporter/otlpexporter"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
 	"go.uber.org/multierr"
@@ -27,91 +27,348 @@ var _ exporter.Logs = (*logExporterImp)(nil)
 type logExporterImp struct {
 	loadBalancer *loadBalancer
 
+	// routingKey is traceIDRouting, unless the logs are routed by their resource or sent without affinity
+	routingKey routingKey
+
+	// attributesRouting derives the routing identifiers when routing by attributes or by expression
+	attributesRouting resourceIdentifier
+
+	// metadataRouting derives the routing identifiers when routing by client metadata
+	metadataRouting *metadataRouting
+
+	// partialFailures makes the returned errors carry only the data sent to the failed backends
+	partialFailures bool
+
+	// mirror sends a copy of a share of the data to other backends, when configured
+	mirror *mirror
+
+	// persistentQueue writes the logs to a storage extension while they're being sent, when configured
+	persistentQueue *persistentQueue
+
 	started    bool
 	shutdownWg sync.WaitGroup
 }
 
 // Create new logs exporter
 func newLogsExporter(params exporter.CreateSettings, cfg component.Config) (*logExporterImp, error) {
-	exporterFactory := otlpexporter.NewFactory()
-
+	var lb *loadBalancer
 	lb, err := newLoadBalancer(params, cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
-		oCfg := buildExporterConfig(cfg.(*Config), endpoint)
-		return exporterFactory.CreateLogsExporter(ctx, params, &oCfg)
+		return lb.exporterFactory(endpoint).CreateLogsExporter(ctx, params, lb.exporterConfig(endpoint))
 	})
 	if err != nil {
 		return nil, err
 	}
+	lb.signal = component.DataTypeLogs
+
+	logExporter := logExporterImp{
+		loadBalancer:    lb,
+		routingKey:      traceIDRouting,
+		partialFailures: cfg.(*Config).PartialFailures,
+		persistentQueue: newPersistentQueue(params, cfg.(*Config).PersistentQueue, component.DataTypeLogs),
+	}
+
+	// the logs are routed by trace ID by default, the routing keys specific to metrics being unsupported
+	switch cfg.(*Config).RoutingKey {
+	case "service":
+		logExporter.routingKey = svcRouting
+		serviceRouting, err := newServiceRouting(cfg.(*Config))
+		if err != nil {
+			return nil, err
+		}
+		if serviceRouting != nil {
+			logExporter.routingKey = attrsRouting
+			logExporter.attributesRouting = serviceRouting
+		}
+	case "resource", "resourceAttributes":
+		// the logs have no metric name to combine with the resource attributes
+		logExporter.routingKey = resourceAttrsRouting
+	case "routingID":
+		logExporter.routingKey = routingIDRouting
+	case "schemaURL":
+		logExporter.routingKey = schemaURLRouting
+	case "attributes":
+		logExporter.routingKey = attrsRouting
+		logExporter.attributesRouting, err = newAttributesRouting(cfg.(*Config))
+		if err != nil {
+			return nil, err
+		}
+	case "expression":
+		logExporter.routingKey = attrsRouting
+		logExporter.attributesRouting, err = newExpressionRouting(cfg.(*Config), params.TelemetrySettings)
+		if err != nil {
+			return nil, err
+		}
+	case "metadata":
+		logExporter.routingKey = clientMetadataRouting
+		logExporter.metadataRouting, err = newMetadataRouting(cfg.(*Config))
+		if err != nil {
+			return nil, err
+		}
+	case "none":
+		logExporter.routingKey = noRouting
+	case "leastOutstanding":
+		logExporter.routingKey = leastOutstandingRouting
+	case "traceID", "":
+	default:
+		return nil, fmt.Errorf("unsupported routing_key: %s", cfg.(*Config).RoutingKey)
+	}
 
-	return &logExporterImp{
-		loadBalancer: lb,
-	}, nil
+	if cfg.(*Config).Mirror != nil {
+		mirrorCfg, err := mirrorConfig(cfg.(*Config))
+		if err != nil {
+			return nil, err
+		}
+		mirrored, err := newLogsExporter(params, mirrorCfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mirror: %w", err)
+		}
+		logExporter.mirror = newMirror(params.Logger, cfg.(*Config).Mirror, mirrored)
+	}
+	return &logExporter, nil
 }
 
 func (e *logExporterImp) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{MutatesData: false}
+	return consumer.Capabilities{MutatesData: e.loadBalancer.routingDecision.mutatesData()}
 }
 
 func (e *logExporterImp) Start(ctx context.Context, host component.Host) error {
 	e.started = true
-	return e.loadBalancer.Start(ctx, host)
+	if err := e.loadBalancer.Start(ctx, host); err != nil {
+		return err
+	}
+	if err := e.mirror.Start(ctx, host); err != nil {
+		return err
+	}
+	return e.persistentQueue.start(ctx, host, func(ctx context.Context, data []byte) error {
+		ld, err := logsUnmarshaler.UnmarshalLogs(data)
+		if err != nil {
+			return err
+		}
+		if err = e.loadBalancer.startupWait.wait(ctx); err != nil {
+			return err
+		}
+		return e.consumeLogs(ctx, ld)
+	})
 }
 
-func (e *logExporterImp) Shutdown(context.Context) error {
+func (e *logExporterImp) Shutdown(ctx context.Context) error {
 	if !e.started {
 		return nil
 	}
 	e.started = false
 	e.shutdownWg.Wait()
-	return nil
+	return multierr.Combine(e.persistentQueue.shutdown(ctx), e.mirror.Shutdown(ctx), e.loadBalancer.Shutdown(ctx))
 }
 
 func (e *logExporterImp) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	if err := e.loadBalancer.startupWait.wait(ctx); err != nil {
+		return err
+	}
+	if e.mirror.sampled() {
+		mirrored := plog.NewLogs()
+		ld.CopyTo(mirrored)
+		e.mirror.send(ctx, func(ctx context.Context) error {
+			return e.mirror.exporter.(consumer.Logs).ConsumeLogs(ctx, mirrored)
+		})
+	}
+	seq, err := e.persistentQueue.add(ctx, func() ([]byte, error) {
+		return logsMarshaler.MarshalLogs(ld)
+	})
+	if err != nil {
+		return err
+	}
+	defer e.persistentQueue.remove(ctx, seq)
+	return e.consumeLogs(ctx, ld)
+}
+
+// consumeLogs routes the logs to their backends.
+func (e *logExporterImp) consumeLogs(ctx context.Context, ld plog.Logs) error {
 	var errs error
-	batches := batchpersignal.SplitLogs(ld)
-	for _, batch := range batches {
-		errs = multierr.Append(errs, e.consumeLog(ctx, batch))
+	failed := plog.NewLogs()
+	var batches []plog.Logs
+	start := time.Now()
+	switch {
+	case withoutAffinity(e.routingKey), e.routingKey == clientMetadataRouting:
+		batches = []plog.Logs{ld}
+	case e.routingKey == traceIDRouting:
+		batches = batchpersignal.SplitLogs(ld)
+		e.loadBalancer.telemetry.recordSplit(ctx, start)
+	default:
+		// the routing identifier derives from the resource, keeping its log records together
+		batches = splitLogsByResource(ld)
+		e.loadBalancer.telemetry.recordSplit(ctx, start)
+	}
+	results := e.loadBalancer.dispatch(len(batches), func(i int) error {
+		return e.consumeLog(ctx, batches[i])
+	})
+	for i, err := range results {
+		errs = multierr.Append(errs, err)
+		if err != nil && e.partialFailures {
+			appendFailedLogs(failed, batches[i], err)
+		}
 	}
 
+	if errs != nil && e.partialFailures {
+		return consumererror.NewLogs(errs, failed)
+	}
 	return errs
 }
 
 func (e *logExporterImp) consumeLog(ctx context.Context, ld plog.Logs) error {
-	traceID := traceIDFromLogs(ld)
-	balancingKey := traceID
-	if traceID == pcommon.NewTraceIDEmpty() {
-		// every log may not contain a traceID
-		// generate a random traceID as balancingKey
-		// so the log can be routed to a random backend
-		balancingKey = random()
+	route := func() (*wrappedExporter, string, error) {
+		return e.loadBalancer.exporterAndEndpointWithoutAffinity(e.routingKey)
+	}
+	// the routing identifier the logs fail over for, nil when they have none
+	var identifier []byte
+	switch {
+	case withoutAffinity(e.routingKey):
+	case e.routingKey == clientMetadataRouting:
+		route = func() (*wrappedExporter, string, error) {
+			return e.loadBalancer.exporterAndEndpointForMetadata(ctx, e.metadataRouting)
+		}
+		identifier = e.metadataRouting.failoverIdentifier(ctx)
+	case e.routingKey != traceIDRouting:
+		// the batch holds a single resource
+		rid, err := e.resourceRoutingIdentifier(ld.ResourceLogs().At(0))
+		switch {
+		case err == nil:
+			route = func() (*wrappedExporter, string, error) {
+				return e.loadBalancer.exporterAndEndpoint([]byte(rid))
+			}
+			identifier = []byte(rid)
+		case errors.Is(err, errDroppedWithoutServiceName):
+			return nil
+		case isUnroutable(err) && e.loadBalancer.hasCatchAll():
+			// the logs without a routing identifier go to the catch-all endpoint
+			route = e.loadBalancer.catchAllExporterAndEndpoint
+		default:
+			return err
+		}
+	default:
+		traceID := traceIDFromLogs(ld)
+		balancingKey := traceID
+		if traceID == pcommon.NewTraceIDEmpty() {
+			// every log may not contain a traceID
+			// generate a random traceID as balancingKey
+			// so the log can be routed to a random backend
+			balancingKey = random()
+		}
+		route = func() (*wrappedExporter, string, error) {
+			return e.loadBalancer.exporterAndEndpoint(balancingKey[:])
+		}
+		identifier = balancingKey[:]
 	}
 
-	le, endpoint, err := e.loadBalancer.exporterAndEndpoint(balancingKey[:])
+	le, endpoint, err := route()
 	if err != nil {
 		return err
 	}
 
 	le.consumeWG.Add(1)
+	for reroutes := 0; le.isRemoved() && reroutes < maxReroutes; reroutes++ {
+		// the endpoint left the ring while the data was being routed, route it again to the new owner
+		le.consumeWG.Done()
+		le, endpoint, err = route()
+		if err != nil {
+			return err
+		}
+		le.consumeWG.Add(1)
+	}
+
+	err = e.export(ctx, le, endpoint, ld)
+	return e.loadBalancer.failover(identifier, endpoint, err, func(le *wrappedExporter, endpoint string, err error) error {
+		ld = failedLogs(ld, err)
+		return e.export(ctx, le, endpoint, ld)
+	})
+}
+
+// export sends the logs to the exporter, whose consumeWG must have been incremented for them.
+func (e *logExporterImp) export(ctx context.Context, le *wrappedExporter, endpoint string, ld plog.Logs) error {
 	defer le.consumeWG.Done()
 
+	e.loadBalancer.routingDecision.stampLogs(ld, endpoint)
+
+	if err := le.limiter.wait(ctx, ld.LogRecordCount()); err != nil {
+		return err
+	}
+
+	release, err := e.loadBalancer.acquireSendSlot(ctx)
+	if err != nil {
+		return err
+	}
+
 	start := time.Now()
-	err = le.ConsumeLogs(ctx, ld)
+	spanCtx, span := e.loadBalancer.startSendSpan(ctx, endpoint, ld.LogRecordCount())
+	err = e.consume(spanCtx, le, ld)
+	endSendSpan(span, err)
+	release()
 	duration := time.Since(start)
-	if err == nil {
-		_ = stats.RecordWithTags(
-			ctx,
-			[]tag.Mutator{tag.Upsert(endpointTagKey, endpoint), successTrueMutator},
-			mBackendLatency.M(duration.Milliseconds()))
-	} else {
-		_ = stats.RecordWithTags(
-			ctx,
-			[]tag.Mutator{tag.Upsert(endpointTagKey, endpoint), successFalseMutator},
-			mBackendLatency.M(duration.Milliseconds()))
-	}
+	e.loadBalancer.telemetry.recordBackendSend(ctx, endpoint, err == nil, duration, logsMarshaler.LogsSize(ld))
 
 	return err
 }
 
+// consume sends the logs to the exporter, in several requests when they're larger than the maximum batch size.
+// The logs of the failed requests are returned along with the error.
+func (e *logExporterImp) consume(ctx context.Context, le *wrappedExporter, ld plog.Logs) error {
+	parts := splitLogsBySize(ld, e.loadBalancer.cfg.MaxBatchSizeBytes)
+	if len(parts) == 1 {
+		return le.ConsumeLogs(e.loadBalancer.withLogsMetadata(ctx, le, ld), ld)
+	}
+
+	var errs error
+	failed := plog.NewLogs()
+	for _, part := range parts {
+		if err := le.ConsumeLogs(e.loadBalancer.withLogsMetadata(ctx, le, part), part); err != nil {
+			errs = multierr.Append(errs, err)
+			appendLogs(failed, part)
+		}
+	}
+	if errs != nil {
+		return consumererror.NewLogs(errs, failed)
+	}
+	return nil
+}
+
+// resourceRoutingIdentifier returns the routing identifier of the log records of the resource, for the routing keys
+// deriving it from the resource.
+func (e *logExporterImp) resourceRoutingIdentifier(rl plog.ResourceLogs) (string, error) {
+	switch e.routingKey {
+	case attrsRouting:
+		return e.attributesRouting.identifier(rl.Resource())
+	case schemaURLRouting:
+		return schemaURLRoutingIdentifier(rl.SchemaUrl(), rl.Resource())
+	default:
+		return resourceRoutingIdentifier(rl.Resource(), e.routingKey)
+	}
+}
+
+// splitLogsByResource returns a batch for each of the resources holding log records.
+func splitLogsByResource(ld plog.Logs) []plog.Logs {
+	var result []plog.Logs
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		if !hasLogs(rls.At(i)) {
+			continue
+		}
+		batch := plog.NewLogs()
+		rls.At(i).CopyTo(batch.ResourceLogs().AppendEmpty())
+		result = append(result, batch)
+	}
+	return result
+}
+
+// hasLogs returns whether the resource holds any log record.
+func hasLogs(rl plog.ResourceLogs) bool {
+	sls := rl.ScopeLogs()
+	for i := 0; i < sls.Len(); i++ {
+		if sls.At(i).LogRecords().Len() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func traceIDFromLogs(ld plog.Logs) pcommon.TraceID {
 	rl := ld.ResourceLogs()
 	if rl.Len() == 0 {
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -42,6 +43,14 @@ func TestNewLogsExporter(t *testing.T) {
 			&Config{},
 			errNoResolver,
 		},
+		{
+			"unsupported routing key",
+			&Config{
+				Resolver:   simpleConfig().Resolver,
+				RoutingKey: "metric",
+			},
+			errors.New("unsupported routing_key: metric"),
+		},
 	} {
 		t.Run(tt.desc, func(t *testing.T) {
 			// test
@@ -223,6 +232,130 @@ func TestLogBatchWithTwoTraces(t *testing.T) {
 	assert.Len(t, sink.AllLogs(), 2)
 }
 
+func TestConsumeLogsRoundRobin(t *testing.T) {
+	// prepare
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "none"
+	sinks := map[string]*consumertest.LogsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.LogsSink)
+		sinks[endpoint] = sink
+		return newMockLogsExporter(sink.ConsumeLogs), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	batch := plog.NewLogs()
+	simpleLogs().ResourceLogs().At(0).CopyTo(batch.ResourceLogs().AppendEmpty())
+	simpleLogWithID(pcommon.TraceID([16]byte{2, 3, 4, 5})).ResourceLogs().At(0).CopyTo(batch.ResourceLogs().AppendEmpty())
+
+	// test
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.ConsumeLogs(context.Background(), batch))
+	}
+
+	// verify
+	require.Len(t, sinks["endpoint-1:4317"].AllLogs(), 2)
+	require.Len(t, sinks["endpoint-2:4317"].AllLogs(), 1)
+	// each batch is sent as a whole
+	assert.Equal(t, batch, sinks["endpoint-2:4317"].AllLogs()[0])
+}
+
+func TestConsumeLogsResourceBased(t *testing.T) {
+	for _, tt := range []struct {
+		routingKey string
+		expected   func(rl plog.ResourceLogs) string
+	}{
+		{"service", func(rl plog.ResourceLogs) string {
+			svc, _ := rl.Resource().Attributes().Get("service.name")
+			return svc.Str()
+		}},
+		{"resource", func(rl plog.ResourceLogs) string {
+			return strings.Join(sortedMapAttrs(rl.Resource().Attributes()), "")
+		}},
+		{"resourceAttributes", func(rl plog.ResourceLogs) string {
+			return strings.Join(sortedMapAttrs(rl.Resource().Attributes()), "")
+		}},
+		{"routingID", func(rl plog.ResourceLogs) string {
+			rid, _ := rl.Resource().Attributes().Get(RoutingIDAttribute)
+			return rid.Str()
+		}},
+		{"schemaURL", func(rl plog.ResourceLogs) string {
+			return rl.SchemaUrl()
+		}},
+	} {
+		t.Run(tt.routingKey, func(t *testing.T) {
+			// prepare
+			cfg := serviceBasedRoutingConfig()
+			cfg.RoutingKey = tt.routingKey
+			sinks := map[string]*consumertest.LogsSink{}
+			componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+				sink := new(consumertest.LogsSink)
+				sinks[endpoint] = sink
+				return newMockLogsExporter(sink.ConsumeLogs), nil
+			}
+			lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+			require.NotNil(t, lb)
+			require.NoError(t, err)
+
+			p, err := newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+			require.NotNil(t, p)
+			require.NoError(t, err)
+
+			p.loadBalancer = lb
+			err = p.Start(context.Background(), componenttest.NewNopHost())
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, p.Shutdown(context.Background()))
+			}()
+
+			ld := plog.NewLogs()
+			for i := 0; i < 4; i++ {
+				rl := ld.ResourceLogs().AppendEmpty()
+				rl.SetSchemaUrl(fmt.Sprintf("https://opentelemetry.io/schemas/1.%d.0", i))
+				rl.Resource().Attributes().PutStr("service.name", fmt.Sprintf("service-%d", i))
+				rl.Resource().Attributes().PutStr("k8s.namespace.name", fmt.Sprintf("namespace-%d", i))
+				rl.Resource().Attributes().PutStr(RoutingIDAttribute, fmt.Sprintf("tenant-%d", i))
+				// the trace IDs don't matter
+				records := rl.ScopeLogs().AppendEmpty().LogRecords()
+				records.AppendEmpty().SetTraceID([16]byte{byte(i)})
+				records.AppendEmpty().SetTraceID([16]byte{byte(i + 4)})
+			}
+
+			// test
+			require.NoError(t, p.ConsumeLogs(context.Background(), ld))
+
+			// verify
+			received := 0
+			for endpoint, sink := range sinks {
+				for _, batch := range sink.AllLogs() {
+					require.Equal(t, 1, batch.ResourceLogs().Len())
+					rl := batch.ResourceLogs().At(0)
+					assert.Equal(t, 2, batch.LogRecordCount())
+
+					_, expected, err := lb.exporterAndEndpoint([]byte(tt.expected(rl)))
+					require.NoError(t, err)
+					assert.Equal(t, endpointWithPort(expected), endpoint)
+					received++
+				}
+			}
+			assert.Equal(t, 4, received)
+		})
+	}
+}
+
 func TestNoLogsInBatch(t *testing.T) {
 	for _, tt := range []struct {
 		desc  string
@@ -339,6 +472,80 @@ func TestConsumeLogs_ConcurrentResolverChange(t *testing.T) {
 	<-consumeDone
 }
 
+func TestConsumeLogsReroutesWhenEndpointLeavesTheRing(t *testing.T) {
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	var rebuildOnce sync.Once
+	var lb *loadBalancer
+	sinks := map[string]*consumertest.LogsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.LogsSink)
+		sinks[endpoint] = sink
+		return newMockLogsExporter(func(ctx context.Context, ld plog.Logs) error {
+			// the first backend receiving data becomes the only one in the ring, while the data for
+			// the other backend is still pending
+			rebuildOnce.Do(func() {
+				endpoints = []string{endpoint}
+				_, err := lb.res.resolve(ctx)
+				assert.NoError(t, err)
+			})
+			return sink.ConsumeLogs(ctx, ld)
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newLogsExporter(exportertest.NewNopCreateSettings(), simpleConfig())
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return endpoints, nil
+		},
+	}
+	p.loadBalancer = lb
+
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// find one trace ID for each of the endpoints
+	traceIDs := map[string]pcommon.TraceID{}
+	for i := byte(0); len(traceIDs) < len(endpoints); i++ {
+		traceID := pcommon.TraceID([16]byte{1, 2, 3, i})
+		endpoint := endpointWithPort(lb.ring.endpointFor(traceID[:]))
+		if _, ok := traceIDs[endpoint]; !ok {
+			traceIDs[endpoint] = traceID
+		}
+	}
+
+	ld := plog.NewLogs()
+	records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for _, traceID := range traceIDs {
+		records.AppendEmpty().SetTraceID(traceID)
+	}
+
+	// test
+	err = p.ConsumeLogs(context.Background(), ld)
+
+	// verify
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	live := endpointWithPort(endpoints[0])
+	var received []pcommon.TraceID
+	for endpoint, sink := range sinks {
+		for _, ld := range sink.AllLogs() {
+			assert.Equal(t, live, endpoint, "data was sent to a backend no longer in the ring")
+			received = append(received, traceIDFromLogs(ld))
+		}
+	}
+	assert.ElementsMatch(t, []pcommon.TraceID{traceIDs["endpoint-1:4317"], traceIDs["endpoint-2:4317"]}, received)
+}
+
 func TestRollingUpdatesWhenConsumeLogs(t *testing.T) {
 	t.Skip("Flaky Test - See https://github.com/open-telemetry/opentelemetry-collector-contrib/issues/13331")
 
@@ -348,7 +555,7 @@ func TestRollingUpdatesWhenConsumeLogs(t *testing.T) {
 
 	// simulate rolling updates, the dns resolver should resolve in the following order
 	// ["127.0.0.1"] -> ["127.0.0.1", "127.0.0.2"] -> ["127.0.0.2"]
-	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second)
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second, nil)
 	require.NoError(t, err)
 
 	mu := sync.Mutex{}
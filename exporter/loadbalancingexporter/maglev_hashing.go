@@ -0,0 +1,150 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+var (
+	_ ring       = (*maglevRing)(nil)
+	_ ringLayout = (*maglevRing)(nil)
+)
+
+// maglevTableSize is the number of entries in the lookup table of the maglev rings. It has to be a prime number, and
+// should be much larger than the number of endpoints for them to get even shares of the table.
+const maglevTableSize = 65537
+
+// maglevRing implements the Maglev hashing from Eisenbud et al.: each endpoint fills the entries of a lookup table
+// in turn, following its own permutation of the table, so that routing an identifier is a single lookup whatever
+// the number of endpoints. Removing an endpoint moves mostly the identifiers that were routed to it.
+type maglevRing struct {
+	// members holds the sorted endpoints, table holding the index of the member for each entry
+	members []string
+	table   []int32
+
+	// draining holds the endpoints that were removed but still accept the identifiers already routed to them
+	draining map[string]bool
+}
+
+// newMaglevRing builds a new immutable maglev ring based on the given endpoints.
+func newMaglevRing(endpoints []string, draining []string) *maglevRing {
+	members := make([]string, len(endpoints))
+	copy(members, endpoints)
+	sort.Strings(members)
+
+	r := &maglevRing{
+		members: members,
+	}
+	if len(members) > 0 {
+		r.table = populateMaglevTable(members)
+	}
+	if len(draining) > 0 {
+		r.draining = make(map[string]bool, len(draining))
+		for _, endpoint := range draining {
+			r.draining[endpoint] = true
+		}
+	}
+	return r
+}
+
+// populateMaglevTable fills the lookup table with the indexes of the members: each member in turn takes the next
+// free entry in its permutation of the table, until all the entries are taken.
+func populateMaglevTable(members []string) []int32 {
+	offsets := make([]uint64, len(members))
+	skips := make([]uint64, len(members))
+	for i, member := range members {
+		h := hash64([]byte(member))
+		offsets[i] = mix64(h) % maglevTableSize
+		skips[i] = mix64(^h)%(maglevTableSize-1) + 1
+	}
+
+	table := make([]int32, maglevTableSize)
+	for i := range table {
+		table[i] = -1
+	}
+	next := make([]uint64, len(members))
+	for filled := 0; ; {
+		for i := range members {
+			entry := (offsets[i] + next[i]*skips[i]) % maglevTableSize
+			for table[entry] >= 0 {
+				next[i]++
+				entry = (offsets[i] + next[i]*skips[i]) % maglevTableSize
+			}
+			table[entry] = int32(i)
+			next[i]++
+			filled++
+			if filled == maglevTableSize {
+				return table
+			}
+		}
+	}
+}
+
+func (r *maglevRing) endpointFor(identifier []byte) string {
+	if r == nil || len(r.members) == 0 {
+		return ""
+	}
+	return r.members[r.table[hash64(identifier)%maglevTableSize]]
+}
+
+func (r *maglevRing) endpointForKnown(identifier []byte, previous string) string {
+	if r != nil && r.draining[previous] {
+		return previous
+	}
+	return r.endpointFor(identifier)
+}
+
+// equal compares the members only, the table being derived from them.
+func (r *maglevRing) equal(candidate ring) bool {
+	other, ok := candidate.(*maglevRing)
+	if !ok || other == nil {
+		return false
+	}
+
+	if len(r.members) != len(other.members) {
+		return false
+	}
+	for i := range r.members {
+		if r.members[i] != other.members[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *maglevRing) endpoints() []string {
+	var endpoints []string
+	for i, member := range r.members {
+		if i == 0 || member != r.members[i-1] {
+			endpoints = append(endpoints, member)
+		}
+	}
+	return endpoints
+}
+
+func (r *maglevRing) fingerprint() string {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(maglevHashStrategy))
+	for _, member := range r.members {
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(member))
+	}
+	return fmt.Sprintf("%016x", hasher.Sum64())
+}
+
+// layout counts the entries of the lookup table filled by each endpoint.
+func (r *maglevRing) layout() map[string]endpointLayout {
+	entries := map[string]int{}
+	for _, member := range r.table {
+		entries[r.members[member]]++
+	}
+	result := make(map[string]endpointLayout, len(entries))
+	for endpoint, n := range entries {
+		result[endpoint] = endpointLayout{positions: n, share: float64(n) / float64(len(r.table))}
+	}
+	return result
+}
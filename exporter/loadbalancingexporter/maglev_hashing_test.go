@@ -0,0 +1,124 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaglevRingDeterministic(t *testing.T) {
+	// prepare
+	r1 := newMaglevRing([]string{"endpoint-1", "endpoint-2", "endpoint-3"}, nil)
+	r2 := newMaglevRing([]string{"endpoint-3", "endpoint-1", "endpoint-2"}, nil)
+
+	// test and verify
+	assert.True(t, r1.equal(r2))
+	assert.Equal(t, r1.fingerprint(), r2.fingerprint())
+	assert.Equal(t, r1.table, r2.table)
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		assert.Equal(t, r1.endpointFor(key), r2.endpointFor(key))
+	}
+}
+
+func TestMaglevRingSharesTheTableEvenly(t *testing.T) {
+	// prepare
+	var endpoints []string
+	for i := 0; i < 200; i++ {
+		endpoints = append(endpoints, fmt.Sprintf("endpoint-%d", i))
+	}
+
+	// test
+	r := newMaglevRing(endpoints, nil)
+
+	// verify
+	entries := map[int32]int{}
+	for _, member := range r.table {
+		entries[member]++
+	}
+	assert.Len(t, entries, len(endpoints))
+	for _, count := range entries {
+		assert.InDelta(t, maglevTableSize/len(endpoints), count, 1)
+	}
+}
+
+func TestMaglevRingRemovalMovesMostlyRemovedKeys(t *testing.T) {
+	// prepare
+	var endpoints []string
+	for i := 0; i < 10; i++ {
+		endpoints = append(endpoints, fmt.Sprintf("endpoint-%d", i))
+	}
+	before := newMaglevRing(endpoints, nil)
+	after := newMaglevRing(append(endpoints[:3:3], endpoints[4:]...), nil)
+
+	// test
+	kept, moved := 0, 0
+	for i := 0; i < 10000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if endpoint := before.endpointFor(key); endpoint != "endpoint-3" {
+			if endpoint == after.endpointFor(key) {
+				kept++
+			} else {
+				moved++
+			}
+		}
+	}
+
+	// verify
+	assert.Less(t, float64(moved)/float64(kept+moved), 0.05)
+}
+
+func TestMaglevRingDraining(t *testing.T) {
+	// prepare
+	r := newMaglevRing([]string{"endpoint-1"}, []string{"endpoint-2"})
+
+	// test and verify
+	assert.Equal(t, []string{"endpoint-1"}, r.endpoints())
+	assert.Equal(t, "endpoint-1", r.endpointFor([]byte("key")))
+	assert.Equal(t, "endpoint-2", r.endpointForKnown([]byte("key"), "endpoint-2"))
+	assert.Equal(t, "endpoint-1", r.endpointForKnown([]byte("key"), "endpoint-3"))
+}
+
+func TestMaglevRingEmpty(t *testing.T) {
+	var r *maglevRing
+	assert.Equal(t, "", r.endpointFor([]byte("key")))
+	assert.Equal(t, "", newMaglevRing(nil, nil).endpointFor([]byte("key")))
+	assert.False(t, newMaglevRing([]string{"endpoint-1"}, nil).equal(newRendezvousRing([]string{"endpoint-1"}, nil)))
+}
+
+func BenchmarkMaglevRingEndpointFor(b *testing.B) {
+	var endpoints []string
+	for i := 0; i < 200; i++ {
+		endpoints = append(endpoints, fmt.Sprintf("endpoint-%d", i))
+	}
+	r := newMaglevRing(endpoints, nil)
+	key := []byte("get-recommendations-1")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.endpointFor(key)
+	}
+}
+
+func TestMaglevRingLayout(t *testing.T) {
+	// prepare
+	r := newMaglevRing([]string{"endpoint-1", "endpoint-2", "endpoint-3"}, nil)
+
+	// test
+	layout := r.layout()
+
+	// verify
+	require.Len(t, layout, 3)
+	positions, share := 0, 0.0
+	for _, l := range layout {
+		positions += l.positions
+		share += l.share
+	}
+	assert.Equal(t, maglevTableSize, positions)
+	assert.InDelta(t, 1, share, 1e-9)
+}
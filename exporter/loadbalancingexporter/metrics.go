@@ -4,70 +4,212 @@
 package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
 
 import (
-	"go.opencensus.io/stats"
-	"go.opencensus.io/stats/view"
-	"go.opencensus.io/tag"
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/multierr"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter/internal/metadata"
 )
 
 var (
-	mNumResolutions = stats.Int64("loadbalancer_num_resolutions", "Number of times the resolver triggered a new resolutions", stats.UnitDimensionless)
-	mNumBackends    = stats.Int64("loadbalancer_num_backends", "Current number of backends in use", stats.UnitDimensionless)
-	mBackendLatency = stats.Int64("loadbalancer_backend_latency", "Response latency in ms for the backends", stats.UnitMilliseconds)
+	resolverAttrKey  = attribute.Key("resolver")
+	successAttrKey   = attribute.Key("success")
+	endpointAttrKey  = attribute.Key("endpoint")
+	namespaceAttrKey = attribute.Key("namespace")
 
-	endpointTagKey      = tag.MustNewKey("endpoint")
-	successTrueMutator  = tag.Upsert(tag.MustNewKey("success"), "true")
-	successFalseMutator = tag.Upsert(tag.MustNewKey("success"), "false")
+	successTrueAttr  = successAttrKey.Bool(true)
+	successFalseAttr = successAttrKey.Bool(false)
 )
 
-// metricViews return the metrics views according to given telemetry level.
-func metricViews() []*view.View {
-	return []*view.View{
-		{
-			Name:        mNumResolutions.Name(),
-			Measure:     mNumResolutions,
-			Description: mNumResolutions.Description(),
-			Aggregation: view.Count(),
-			TagKeys: []tag.Key{
-				tag.MustNewKey("resolver"),
-				tag.MustNewKey("success"),
-			},
-		},
-		{
-			Name:        mNumBackends.Name(),
-			Measure:     mNumBackends,
-			Description: mNumBackends.Description(),
-			Aggregation: view.LastValue(),
-			TagKeys: []tag.Key{
-				tag.MustNewKey("resolver"),
-			},
-		},
-		{
-			Name:        "loadbalancer_num_backend_updates", // counts the number of times the measure was changed
-			Measure:     mNumBackends,
-			Description: "Number of times the list of backends was updated",
-			Aggregation: view.Count(),
-			TagKeys: []tag.Key{
-				tag.MustNewKey("resolver"),
-			},
-		},
-		{
-			Name:        mBackendLatency.Name(),
-			Measure:     mBackendLatency,
-			Description: mBackendLatency.Description(),
-			TagKeys: []tag.Key{
-				tag.MustNewKey("endpoint"),
-			},
-			Aggregation: view.Distribution(0, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000),
-		},
-		{
-			Name:        "loadbalancer_backend_outcome",
-			Measure:     mBackendLatency,
-			Description: "Number of success/failures for each endpoint",
-			TagKeys: []tag.Key{
-				tag.MustNewKey("endpoint"),
-				tag.MustNewKey("success"),
-			},
-			Aggregation: view.Count(),
-		},
+// telemetry records the internal metrics of the exporter with the meter provider of the collector. A nil telemetry
+// records nothing.
+type telemetry struct {
+	// namespace is added as the "namespace" attribute to the metrics about the backends, when set
+	namespace string
+
+	// builder holds the instruments declared in the metadata.yaml
+	builder *metadata.TelemetryBuilder
+
+	// the gauges are observed from the latest values recorded. The ones recorded for each resolver and endpoint are
+	// registered here, as the callbacks of the telemetry builder observe a single value.
+	lock         sync.Mutex
+	backends     map[string]int64
+	circuitsOpen map[string]int64
+	ringSize     int64
+	registration metric.Registration
+}
+
+func newTelemetry(settings component.TelemetrySettings, namespace string) (*telemetry, error) {
+	t := &telemetry{
+		namespace:    namespace,
+		backends:     map[string]int64{},
+		circuitsOpen: map[string]int64{},
+	}
+	builder, err := metadata.NewTelemetryBuilder(settings,
+		metadata.WithAttributeSet(attribute.NewSet(t.namespaceAttrs()...)),
+		metadata.WithLoadbalancerRingSizeCallback(t.observeRingSize))
+	if err != nil {
+		return nil, err
+	}
+	t.builder = builder
+
+	var errs error
+	meter := metadata.Meter(settings)
+	numBackends, err := meter.Int64ObservableGauge("loadbalancer_num_backends",
+		metric.WithDescription("Current number of backends in use"),
+		metric.WithUnit("1"))
+	errs = multierr.Append(errs, err)
+	backendCircuitOpen, err := meter.Int64ObservableGauge("loadbalancer_backend_circuit_open",
+		metric.WithDescription("Whether the circuit of the backend is open (1) or closed (0)"),
+		metric.WithUnit("1"))
+	errs = multierr.Append(errs, err)
+	if errs != nil {
+		return nil, errs
+	}
+
+	t.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		for resolver, backends := range t.backends {
+			o.ObserveInt64(numBackends, backends, metric.WithAttributes(resolverAttrKey.String(resolver)))
+		}
+		for endpoint, open := range t.circuitsOpen {
+			o.ObserveInt64(backendCircuitOpen, open, metric.WithAttributes(t.endpointAttrs(endpoint)...))
+		}
+		return nil
+	}, numBackends, backendCircuitOpen)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// recordResolution counts a resolution of the given resolver, with its outcome.
+func (t *telemetry) recordResolution(ctx context.Context, resolver string, success bool) {
+	if t == nil {
+		return
+	}
+	successAttr := successTrueAttr
+	if !success {
+		successAttr = successFalseAttr
+	}
+	t.builder.LoadbalancerNumResolutions.Add(ctx, 1, metric.WithAttributes(resolverAttrKey.String(resolver), successAttr))
+}
+
+// recordBackends records the number of backends resolved by the given resolver, counting it as an update of the
+// backends.
+func (t *telemetry) recordBackends(ctx context.Context, resolver string, backends int) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	t.backends[resolver] = int64(backends)
+	t.lock.Unlock()
+	t.builder.LoadbalancerNumBackendUpdates.Add(ctx, 1, metric.WithAttributes(resolverAttrKey.String(resolver)))
+}
+
+// recordBackendSend records the latency, the outcome and the size of a send to the given endpoint.
+func (t *telemetry) recordBackendSend(ctx context.Context, endpoint string, success bool, duration time.Duration, bytes int) {
+	if t == nil {
+		return
+	}
+	successAttr := successTrueAttr
+	if !success {
+		successAttr = successFalseAttr
+	}
+	t.builder.LoadbalancerBackendLatency.Record(ctx, duration.Milliseconds(), metric.WithAttributes(t.endpointAttrs(endpoint)...))
+	t.builder.LoadbalancerBackendOutcome.Add(ctx, 1, metric.WithAttributes(t.endpointAttrs(endpoint, successAttr)...))
+	t.builder.LoadbalancerBackendBytes.Add(ctx, int64(bytes), metric.WithAttributes(t.endpointAttrs(endpoint)...))
+}
+
+// recordRoutingKey counts a routing key mapped to the given endpoint.
+func (t *telemetry) recordRoutingKey(ctx context.Context, endpoint string) {
+	if t == nil {
+		return
+	}
+	t.builder.LoadbalancerBackendRoutingKeys.Add(ctx, 1, metric.WithAttributes(t.endpointAttrs(endpoint)...))
+}
+
+// recordRing counts a change of the ring, recording its new number of endpoints.
+func (t *telemetry) recordRing(ctx context.Context, endpoints int) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	t.ringSize = int64(endpoints)
+	t.lock.Unlock()
+	t.builder.LoadbalancerRingChanges.Add(ctx, 1, metric.WithAttributes(t.namespaceAttrs()...))
+}
+
+// observeRingSize returns the number of endpoints in the latest ring, observed by the loadbalancer_ring_size gauge.
+func (t *telemetry) observeRingSize() int64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.ringSize
+}
+
+// recordSplit records the time spent splitting a batch by routing key, since the given start.
+func (t *telemetry) recordSplit(ctx context.Context, start time.Time) {
+	if t == nil {
+		return
+	}
+	t.builder.LoadbalancerSplitDuration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), metric.WithAttributes(t.namespaceAttrs()...))
+}
+
+// recordEviction counts an eviction of the given endpoint by the health checks.
+func (t *telemetry) recordEviction(ctx context.Context, endpoint string) {
+	if t == nil {
+		return
+	}
+	t.builder.LoadbalancerBackendEvictions.Add(ctx, 1, metric.WithAttributes(t.endpointAttrs(endpoint)...))
+}
+
+// recordRestoration counts a restoration of the given endpoint by the health checks.
+func (t *telemetry) recordRestoration(ctx context.Context, endpoint string) {
+	if t == nil {
+		return
+	}
+	t.builder.LoadbalancerBackendRestorations.Add(ctx, 1, metric.WithAttributes(t.endpointAttrs(endpoint)...))
+}
+
+// recordCircuitState records whether the circuit of the given endpoint is open.
+func (t *telemetry) recordCircuitState(endpoint string, open bool) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.circuitsOpen[endpoint] = 0
+	if open {
+		t.circuitsOpen[endpoint] = 1
+	}
+}
+
+// shutdown stops observing the gauges.
+func (t *telemetry) shutdown() error {
+	if t == nil {
+		return nil
+	}
+	return t.registration.Unregister()
+}
+
+// endpointAttrs returns the attributes of the telemetry about the given endpoint, including the telemetry
+// namespace for this exporter, if configured.
+func (t *telemetry) endpointAttrs(endpoint string, attrs ...attribute.KeyValue) []attribute.KeyValue {
+	return t.namespaceAttrs(append([]attribute.KeyValue{endpointAttrKey.String(endpoint)}, attrs...)...)
+}
+
+// namespaceAttrs returns the given attributes along with the telemetry namespace for this exporter, if configured.
+func (t *telemetry) namespaceAttrs(attrs ...attribute.KeyValue) []attribute.KeyValue {
+	result := make([]attribute.KeyValue, 0, len(attrs)+1)
+	result = append(result, attrs...)
+	if t.namespace != "" {
+		result = append(result, namespaceAttrKey.String(t.namespace))
 	}
+	return result
 }
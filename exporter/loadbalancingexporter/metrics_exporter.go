@@ -10,17 +10,15 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"go.opencensus.io/stats"
-	"go.opencensus.io/tag"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/exporter"
-	"go.opentelemetry.io/collector/exporter/otlpexporter"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
-	conventions "go.opentelemetry.io/collector/semconv/v1.6.1"
 	"go.uber.org/multierr"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/batchpersignal"
@@ -28,42 +26,152 @@ import (
 
 var _ exporter.Metrics = (*metricExporterImp)(nil)
 
+var (
+	errTooManyRoutingIdentifiers = errors.New("too many distinct routing identifiers in a single request")
+
+	// errEmptyMetrics is returned for the batches without any metric, whatever the routing key
+	errEmptyMetrics = errors.New("empty metrics")
+)
+
 type exporterMetrics map[*wrappedExporter]pmetric.Metrics
 
 type metricExporterImp struct {
 	loadBalancer *loadBalancer
-	routingKey   routingKey
+
+	// routing is replaced as a whole when the routing key changes, each call routing its data with
+	// the routing loaded when it started
+	routing atomic.Pointer[metricsRouting]
+
+	// partialFailures makes the returned errors carry only the data sent to the failed backends
+	partialFailures bool
+
+	// maxRoutingIdentifiers limits the distinct routing identifiers in a single call, unlimited when zero
+	maxRoutingIdentifiers int
+
+	// mirror sends a copy of a share of the data to other backends, when configured
+	mirror *mirror
+
+	// persistentQueue writes the metrics to a storage extension while they're being sent, when configured
+	persistentQueue *persistentQueue
 
 	stopped    bool
 	shutdownWg sync.WaitGroup
 }
 
 func newMetricsExporter(params exporter.CreateSettings, cfg component.Config) (*metricExporterImp, error) {
-	exporterFactory := otlpexporter.NewFactory()
-
+	var lb *loadBalancer
 	lb, err := newLoadBalancer(params, cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
-		oCfg := buildExporterConfig(cfg.(*Config), endpoint)
-		return exporterFactory.CreateMetricsExporter(ctx, params, &oCfg)
+		return lb.exporterFactory(endpoint).CreateMetricsExporter(ctx, params, lb.exporterConfig(endpoint))
 	})
 	if err != nil {
 		return nil, err
 	}
+	lb.signal = component.DataTypeMetrics
+
+	metricExporter := metricExporterImp{
+		loadBalancer:          lb,
+		partialFailures:       cfg.(*Config).PartialFailures,
+		maxRoutingIdentifiers: cfg.(*Config).MaxRoutingIdentifiers,
+		persistentQueue:       newPersistentQueue(params, cfg.(*Config).PersistentQueue, component.DataTypeMetrics),
+	}
+	if err = metricExporter.updateRouting(cfg.(*Config)); err != nil {
+		return nil, err
+	}
+	lb.updateRouting = metricExporter.updateRouting
+
+	if cfg.(*Config).Mirror != nil {
+		mirrorCfg, err := mirrorConfig(cfg.(*Config))
+		if err != nil {
+			return nil, err
+		}
+		mirrored, err := newMetricsExporter(params, mirrorCfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mirror: %w", err)
+		}
+		metricExporter.mirror = newMirror(params.Logger, cfg.(*Config).Mirror, mirrored)
+	}
+	return &metricExporter, nil
+
+}
 
-	metricExporter := metricExporterImp{loadBalancer: lb, routingKey: svcRouting}
+// metricsRouting determines the routing identifiers of the metrics.
+type metricsRouting struct {
+	key routingKey
 
-	switch cfg.(*Config).RoutingKey {
+	// attributesRouting derives the routing identifiers when routing by attributes or by expression
+	attributesRouting resourceIdentifier
+
+	// metadataRouting derives the routing identifiers when routing by client metadata
+	metadataRouting *metadataRouting
+}
+
+func newMetricsRouting(cfg *Config, settings component.TelemetrySettings) (*metricsRouting, error) {
+	routing := &metricsRouting{}
+	switch cfg.RoutingKey {
 	case "service", "":
 		// default case for empty routing key
-		metricExporter.routingKey = svcRouting
+		routing.key = svcRouting
+		serviceRouting, err := newServiceRouting(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if serviceRouting != nil {
+			routing.key = attrsRouting
+			routing.attributesRouting = serviceRouting
+		}
 	case "resource":
-		metricExporter.routingKey = resourceRouting
+		routing.key = resourceRouting
 	case "metric":
-		metricExporter.routingKey = metricNameRouting
+		routing.key = metricNameRouting
+	case "routingID":
+		routing.key = routingIDRouting
+	case "resourceAttributes":
+		routing.key = resourceAttrsRouting
+	case "schemaURL":
+		routing.key = schemaURLRouting
+	case "streamID":
+		routing.key = streamIDRouting
+	case "none":
+		routing.key = noRouting
+	case "leastOutstanding":
+		routing.key = leastOutstandingRouting
+	case "metadata":
+		routing.key = clientMetadataRouting
+		metadataRouting, err := newMetadataRouting(cfg)
+		if err != nil {
+			return nil, err
+		}
+		routing.metadataRouting = metadataRouting
+	case "attributes":
+		routing.key = attrsRouting
+		attributesRouting, err := newAttributesRouting(cfg)
+		if err != nil {
+			return nil, err
+		}
+		routing.attributesRouting = attributesRouting
+	case "expression":
+		routing.key = attrsRouting
+		expressionRouting, err := newExpressionRouting(cfg, settings)
+		if err != nil {
+			return nil, err
+		}
+		routing.attributesRouting = expressionRouting
 	default:
-		return nil, fmt.Errorf("unsupported routing_key: %q", cfg.(*Config).RoutingKey)
+		return nil, fmt.Errorf("unsupported routing_key: %q", cfg.RoutingKey)
 	}
-	return &metricExporter, nil
+	return routing, nil
+}
 
+// updateRouting switches to the routing key from the given configuration, without a restart. The calls already
+// in progress complete with the previous routing key, the following ones use the new one. The current routing
+// is kept when the configuration is invalid.
+func (e *metricExporterImp) updateRouting(cfg *Config) error {
+	routing, err := newMetricsRouting(cfg, e.loadBalancer.settings)
+	if err != nil {
+		return err
+	}
+	e.routing.Store(routing)
+	return nil
 }
 
 func (e *metricExporterImp) Capabilities() consumer.Capabilities {
@@ -71,100 +179,347 @@ func (e *metricExporterImp) Capabilities() consumer.Capabilities {
 }
 
 func (e *metricExporterImp) Start(ctx context.Context, host component.Host) error {
-	return e.loadBalancer.Start(ctx, host)
+	if err := e.loadBalancer.Start(ctx, host); err != nil {
+		return err
+	}
+	if err := e.mirror.Start(ctx, host); err != nil {
+		return err
+	}
+	return e.persistentQueue.start(ctx, host, func(ctx context.Context, data []byte) error {
+		md, err := metricsUnmarshaler.UnmarshalMetrics(data)
+		if err != nil {
+			return err
+		}
+		if err = e.loadBalancer.startupWait.wait(ctx); err != nil {
+			return err
+		}
+		return e.consumeMetrics(ctx, e.routing.Load(), md, 0)
+	})
 }
 
-func (e *metricExporterImp) Shutdown(context.Context) error {
+func (e *metricExporterImp) Shutdown(ctx context.Context) error {
 	e.stopped = true
 	e.shutdownWg.Wait()
-	return nil
+	return multierr.Combine(e.persistentQueue.shutdown(ctx), e.mirror.Shutdown(ctx), e.loadBalancer.Shutdown(ctx))
 }
 
 func (e *metricExporterImp) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
-	batches := batchpersignal.SplitMetrics(md)
+	if err := e.loadBalancer.startupWait.wait(ctx); err != nil {
+		return err
+	}
+	if e.mirror.sampled() {
+		mirrored := pmetric.NewMetrics()
+		md.CopyTo(mirrored)
+		e.mirror.send(ctx, func(ctx context.Context) error {
+			return e.mirror.exporter.(consumer.Metrics).ConsumeMetrics(ctx, mirrored)
+		})
+	}
+	seq, err := e.persistentQueue.add(ctx, func() ([]byte, error) {
+		return metricsMarshaler.MarshalMetrics(md)
+	})
+	if err != nil {
+		return err
+	}
+	defer e.persistentQueue.remove(ctx, seq)
+	return e.consumeMetrics(ctx, e.routing.Load(), md, 0)
+}
+
+// consumeMetrics routes the metrics to their backends. The reroutes indicate how many times the data
+// has already been routed again because its backend left the ring while the data was being sent.
+func (e *metricExporterImp) consumeMetrics(ctx context.Context, routing *metricsRouting, md pmetric.Metrics, reroutes int) error {
+	if withoutAffinity(routing.key) {
+		// the metrics go to any backend, without being split
+		exp, endpoint, err := e.loadBalancer.exporterAndEndpointWithoutAffinity(routing.key)
+		if err != nil {
+			return err
+		}
+		return e.sendAll(ctx, routing, exp, endpoint, nil, md, reroutes)
+	}
+	if routing.key == clientMetadataRouting {
+		// all the metrics of the request share the routing identifier from its metadata
+		exp, endpoint, err := e.loadBalancer.exporterAndEndpointForMetadata(ctx, routing.metadataRouting)
+		if err != nil {
+			return err
+		}
+		return e.sendAll(ctx, routing, exp, endpoint, routing.metadataRouting.failoverIdentifier(ctx), md, reroutes)
+	}
+	if exp, endpoint, ok := e.singleExporter(routing, md); ok {
+		// all the metrics go to the same backend, no need to split them
+		return e.sendAll(ctx, routing, exp, endpoint, nil, md, reroutes)
+	}
+
+	start := time.Now()
+	batches := routing.splitMetrics(md)
 
 	exporterSegregatedMetrics := make(exporterMetrics)
 	endpoints := make(map[*wrappedExporter]string)
+	routingIdentifiers := make(map[string]struct{})
+	// the first routing identifier of the data segregated for each exporter, which it fails over for
+	identifiers := make(map[*wrappedExporter][]byte)
+	segregate := func(exp *wrappedExporter, endpoint string, identifier []byte, md pmetric.Metrics) {
+		_, ok := exporterSegregatedMetrics[exp]
+		if !ok {
+			exp.consumeWG.Add(1)
+			exporterSegregatedMetrics[exp] = pmetric.NewMetrics()
+			identifiers[exp] = identifier
+		}
+		exporterSegregatedMetrics[exp] = mergeMetrics(exporterSegregatedMetrics[exp], md)
+
+		endpoints[exp] = endpoint
+	}
+	// release the exporters the data was segregated for when giving up before sending it
+	release := func() {
+		for exp := range exporterSegregatedMetrics {
+			exp.consumeWG.Done()
+		}
+	}
 
 	for _, batch := range batches {
-		routingIds, err := routingIdentifiersFromMetrics(batch, e.routingKey)
-		if err != nil {
-			return err
+		routingBatches, err := routing.splitBatch(batch)
+		if errors.Is(err, errDroppedWithoutServiceName) {
+			continue
 		}
+		if err != nil {
+			if !isUnroutable(err) || !e.loadBalancer.hasCatchAll() {
+				release()
+				return err
+			}
 
-		for rid := range routingIds {
-			exp, endpoint, err := e.loadBalancer.exporterAndEndpoint([]byte(rid))
+			// the data without a routing identifier goes to the catch-all endpoint
+			exp, endpoint, err := e.loadBalancer.catchAllExporterAndEndpoint()
 			if err != nil {
+				release()
 				return err
 			}
+			segregate(exp, endpoint, nil, batch)
+			continue
+		}
 
-			_, ok := exporterSegregatedMetrics[exp]
-			if !ok {
-				exp.consumeWG.Add(1)
-				exporterSegregatedMetrics[exp] = pmetric.NewMetrics()
+		for rid, md := range routingBatches {
+			routingIdentifiers[rid] = struct{}{}
+			if e.maxRoutingIdentifiers > 0 && len(routingIdentifiers) > e.maxRoutingIdentifiers {
+				release()
+				return consumererror.NewPermanent(fmt.Errorf("%w: more than the limit of %d, set by max_routing_identifiers",
+					errTooManyRoutingIdentifiers, e.maxRoutingIdentifiers))
 			}
-			exporterSegregatedMetrics[exp] = mergeMetrics(exporterSegregatedMetrics[exp], batch)
 
-			endpoints[exp] = endpoint
+			exp, endpoint, err := e.loadBalancer.exporterAndEndpoint([]byte(rid))
+			if err != nil {
+				release()
+				return err
+			}
+			segregate(exp, endpoint, []byte(rid), md)
 		}
 	}
+	e.loadBalancer.telemetry.recordSplit(ctx, start)
 
 	var errs error
+	failed := pmetric.NewMetrics()
 
-	for exp, metrics := range exporterSegregatedMetrics {
-		start := time.Now()
-		err := exp.ConsumeMetrics(ctx, metrics)
-		exp.consumeWG.Done()
-		duration := time.Since(start)
+	exporters := sortedByEndpoint(endpoints)
+	results := e.loadBalancer.dispatch(len(exporters), func(i int) error {
+		exp := exporters[i]
+		return e.send(ctx, routing, exp, endpoints[exp], identifiers[exp], exporterSegregatedMetrics[exp], reroutes)
+	})
+	for i, err := range results {
 		errs = multierr.Append(errs, err)
-
-		if err == nil {
-			_ = stats.RecordWithTags(
-				ctx,
-				[]tag.Mutator{tag.Upsert(endpointTagKey, endpoints[exp]), successTrueMutator},
-				mBackendLatency.M(duration.Milliseconds()))
-		} else {
-			_ = stats.RecordWithTags(
-				ctx,
-				[]tag.Mutator{tag.Upsert(endpointTagKey, endpoints[exp]), successFalseMutator},
-				mBackendLatency.M(duration.Milliseconds()))
+		if err != nil && e.partialFailures {
+			appendFailedMetrics(failed, exporterSegregatedMetrics[exporters[i]], err)
 		}
 	}
 
+	if errs != nil && e.partialFailures {
+		return consumererror.NewMetrics(errs, failed)
+	}
 	return errs
 }
 
-func routingIdentifiersFromMetrics(mds pmetric.Metrics, key routingKey) (map[string]bool, error) {
-	ids := make(map[string]bool)
+// sendAll sends all the metrics to the exporter, without splitting them.
+func (e *metricExporterImp) sendAll(ctx context.Context, routing *metricsRouting, exp *wrappedExporter, endpoint string, identifier []byte, md pmetric.Metrics, reroutes int) error {
+	exp.consumeWG.Add(1)
+	err := e.send(ctx, routing, exp, endpoint, identifier, md, reroutes)
+	if err != nil && e.partialFailures {
+		failed := pmetric.NewMetrics()
+		appendFailedMetrics(failed, md, err)
+		return consumererror.NewMetrics(err, failed)
+	}
+	return err
+}
 
-	// no need to test "empty labels"
-	// no need to test "empty resources"
+// send sends the metrics to the exporter, whose consumeWG must have been incremented for them. The metrics are
+// routed again when the endpoint left the ring in the meantime, and fail over to the next endpoints when the send
+// fails, if configured, following the routing identifier of the metrics, which may be nil.
+func (e *metricExporterImp) send(ctx context.Context, routing *metricsRouting, exp *wrappedExporter, endpoint string, identifier []byte, md pmetric.Metrics, reroutes int) error {
+	if exp.isRemoved() && reroutes < maxReroutes {
+		// the endpoint left the ring after the data was routed to it, route it again to the new owner
+		exp.consumeWG.Done()
+		return e.consumeMetrics(ctx, routing, md, reroutes+1)
+	}
 
-	rs := mds.ResourceMetrics()
-	if rs.Len() == 0 {
-		return nil, errors.New("empty resource metrics")
+	err := e.export(ctx, exp, endpoint, md)
+	return e.loadBalancer.failover(identifier, endpoint, err, func(exp *wrappedExporter, endpoint string, err error) error {
+		md = failedMetrics(md, err)
+		return e.export(ctx, exp, endpoint, md)
+	})
+}
+
+// export sends the metrics to the exporter, whose consumeWG must have been incremented for them.
+func (e *metricExporterImp) export(ctx context.Context, exp *wrappedExporter, endpoint string, md pmetric.Metrics) error {
+	if err := exp.limiter.wait(ctx, md.DataPointCount()); err != nil {
+		exp.consumeWG.Done()
+		return err
+	}
+
+	release, err := e.loadBalancer.acquireSendSlot(ctx)
+	if err != nil {
+		exp.consumeWG.Done()
+		return err
+	}
+
+	start := time.Now()
+	spanCtx, span := e.loadBalancer.startSendSpan(ctx, endpoint, md.DataPointCount())
+	err = e.consume(spanCtx, exp, md)
+	endSendSpan(span, err)
+	release()
+	exp.consumeWG.Done()
+	duration := time.Since(start)
+
+	e.loadBalancer.telemetry.recordBackendSend(ctx, endpoint, err == nil, duration, metricsMarshaler.MetricsSize(md))
+	return err
+}
+
+// consume sends the metrics to the exporter, in several requests when they're larger than the maximum batch size.
+// The metrics of the failed requests are returned along with the error.
+func (e *metricExporterImp) consume(ctx context.Context, exp *wrappedExporter, md pmetric.Metrics) error {
+	parts := splitMetricsBySize(md, e.loadBalancer.cfg.MaxBatchSizeBytes)
+	if len(parts) == 1 {
+		return exp.ConsumeMetrics(e.loadBalancer.withMetricsMetadata(ctx, exp, md), md)
+	}
+
+	var errs error
+	failed := pmetric.NewMetrics()
+	for _, part := range parts {
+		if err := exp.ConsumeMetrics(e.loadBalancer.withMetricsMetadata(ctx, exp, part), part); err != nil {
+			errs = multierr.Append(errs, err)
+			appendFailedMetrics(failed, part, err)
+		}
+	}
+	if errs != nil {
+		return consumererror.NewMetrics(errs, failed)
+	}
+	return nil
+}
+
+// singleExporter returns the exporter for the only backend, when all the metrics are known to be routed to it.
+// The routing identifiers then don't have to be computed, unless they could fail to be derived or have to be counted.
+func (e *metricExporterImp) singleExporter(routing *metricsRouting, md pmetric.Metrics) (*wrappedExporter, string, bool) {
+	if e.maxRoutingIdentifiers > 0 || md.MetricCount() == 0 {
+		return nil, "", false
+	}
+	exp, endpoint, ok := e.loadBalancer.singleExporter()
+	if !ok {
+		return nil, "", false
+	}
+
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		if !hasMetrics(rms.At(i)) {
+			continue
+		}
+		var err error
+		switch routing.key {
+		case metricNameRouting, resourceRouting, resourceAttrsRouting, streamIDRouting:
+			// the identifiers can always be derived
+		case attrsRouting:
+			_, err = routing.attributesRouting.identifier(rms.At(i).Resource())
+		default:
+			_, err = resourceMetricsRoutingIdentifier(rms.At(i), routing.key)
+		}
+		if err != nil {
+			return nil, "", false
+		}
+	}
+	return exp, endpoint, true
+}
+
+// splitMetrics splits the metrics into the batches routed independently, each holding a single resource. The
+// batches are only as fine-grained as the routing key needs: the keys derived from the resource keep the metrics
+// of each resource together, while the keys derived from the metrics need a batch per metric.
+func (r *metricsRouting) splitMetrics(md pmetric.Metrics) []pmetric.Metrics {
+	if r.key == metricNameRouting || r.key == resourceRouting {
+		return batchpersignal.SplitMetrics(md)
+	}
+	return splitMetricsByResource(md)
+}
+
+// splitBatch groups the metrics from the batch, holding a single resource, by their routing identifier.
+func (r *metricsRouting) splitBatch(batch pmetric.Metrics) (map[string]pmetric.Metrics, error) {
+	if r.key == metricNameRouting || r.key == resourceRouting {
+		return splitMetricsByRoutingKey(batch, r.key)
+	}
+	if batch.MetricCount() == 0 {
+		return nil, errEmptyMetrics
+	}
+	if r.key == streamIDRouting {
+		return splitMetricsByStream(batch), nil
 	}
 
-	ils := rs.At(0).ScopeMetrics()
-	if ils.Len() == 0 {
-		return nil, errors.New("empty scope metrics")
+	// the whole batch shares the identifier of its resource
+	var rid string
+	var err error
+	if r.key == attrsRouting {
+		rid, err = r.attributesRouting.identifier(batch.ResourceMetrics().At(0).Resource())
+	} else {
+		rid, err = resourceMetricsRoutingIdentifier(batch.ResourceMetrics().At(0), r.key)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]pmetric.Metrics{rid: batch}, nil
+}
+
+// splitMetricsByResource returns a batch for each of the resources holding metrics.
+func splitMetricsByResource(md pmetric.Metrics) []pmetric.Metrics {
+	var result []pmetric.Metrics
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		if !hasMetrics(rms.At(i)) {
+			continue
+		}
+		batch := pmetric.NewMetrics()
+		rms.At(i).CopyTo(batch.ResourceMetrics().AppendEmpty())
+		result = append(result, batch)
+	}
+	return result
+}
+
+// routingIdentifiersFromMetrics returns the routing identifiers for the metrics in the batch. The same rule applies
+// to all the routing keys: a batch without any metric, be it without resources, with resources without scopes or
+// with scopes without metrics, fails with errEmptyMetrics, while the resources without metrics are ignored in the
+// other batches.
+func routingIdentifiersFromMetrics(mds pmetric.Metrics, key routingKey) (map[string]bool, error) {
+	ids := make(map[string]bool)
 
-	metrics := ils.At(0).Metrics()
-	if metrics.Len() == 0 {
-		return nil, errors.New("empty metrics")
+	// no need to test "empty labels"
+
+	if mds.MetricCount() == 0 {
+		return nil, errEmptyMetrics
 	}
 
+	rs := mds.ResourceMetrics()
 	for i := 0; i < rs.Len(); i++ {
+		if !hasMetrics(rs.At(i)) {
+			continue
+		}
+
 		resource := rs.At(i).Resource()
 		switch key {
 		default:
-		case svcRouting, traceIDRouting:
-			svc, ok := resource.Attributes().Get(conventions.AttributeServiceName)
-			if !ok {
-				return nil, errors.New("unable to get service name")
+			rid, err := resourceMetricsRoutingIdentifier(rs.At(i), key)
+			if err != nil {
+				return nil, err
 			}
-			ids[svc.Str()] = true
+			ids[rid] = true
 		case metricNameRouting:
 			sm := rs.At(i).ScopeMetrics()
 			for j := 0; j < sm.Len(); j++ {
@@ -192,6 +547,94 @@ func routingIdentifiersFromMetrics(mds pmetric.Metrics, key routingKey) (map[str
 
 }
 
+// splitMetricsByRoutingKey groups the metrics from the given batch by their routing identifier. Each of the
+// resulting pmetric.Metrics contains only the metrics that map to its identifier, so that a metric is never
+// sent to an endpoint other than the one its own identifier is routed to.
+func splitMetricsByRoutingKey(md pmetric.Metrics, key routingKey) (map[string]pmetric.Metrics, error) {
+	if _, err := routingIdentifiersFromMetrics(md, key); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]pmetric.Metrics)
+	rs := md.ResourceMetrics()
+	for i := 0; i < rs.Len(); i++ {
+		rm := rs.At(i)
+		if !hasMetrics(rm) {
+			continue
+		}
+
+		if key != metricNameRouting && key != resourceRouting {
+			// the whole resource shares the same identifier
+			rid, _ := resourceMetricsRoutingIdentifier(rm, key)
+			dest, ok := result[rid]
+			if !ok {
+				dest = pmetric.NewMetrics()
+				result[rid] = dest
+			}
+			rm.CopyTo(dest.ResourceMetrics().AppendEmpty())
+			continue
+		}
+
+		// the scope metrics for each identifier within this resource
+		scopes := make(map[string]pmetric.ScopeMetrics)
+		sm := rm.ScopeMetrics()
+		for j := 0; j < sm.Len(); j++ {
+			ils := sm.At(j)
+			for k := 0; k < ils.Metrics().Len(); k++ {
+				metric := ils.Metrics().At(k)
+
+				var rid string
+				if key == metricNameRouting {
+					rid = metricRoutingKey(metric)
+				} else {
+					rid = resourceRoutingKey(metric, rm.Resource().Attributes())
+				}
+
+				scopeKey := fmt.Sprintf("%d/%s", j, rid)
+				dest, ok := scopes[scopeKey]
+				if !ok {
+					batch, found := result[rid]
+					if !found {
+						batch = pmetric.NewMetrics()
+						result[rid] = batch
+					}
+					newRM := batch.ResourceMetrics().AppendEmpty()
+					rm.Resource().CopyTo(newRM.Resource())
+					newRM.SetSchemaUrl(rm.SchemaUrl())
+
+					dest = newRM.ScopeMetrics().AppendEmpty()
+					ils.Scope().CopyTo(dest.Scope())
+					dest.SetSchemaUrl(ils.SchemaUrl())
+					scopes[scopeKey] = dest
+				}
+				metric.CopyTo(dest.Metrics().AppendEmpty())
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// resourceMetricsRoutingIdentifier returns the routing identifier for the routing keys that don't depend on the
+// metrics themselves.
+func resourceMetricsRoutingIdentifier(rm pmetric.ResourceMetrics, key routingKey) (string, error) {
+	if key == schemaURLRouting {
+		return schemaURLRoutingIdentifier(rm.SchemaUrl(), rm.Resource())
+	}
+	return resourceRoutingIdentifier(rm.Resource(), key)
+}
+
+// hasMetrics returns whether any of the scopes of the resource holds metrics.
+func hasMetrics(rm pmetric.ResourceMetrics) bool {
+	sm := rm.ScopeMetrics()
+	for i := 0; i < sm.Len(); i++ {
+		if sm.At(i).Metrics().Len() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // maintain
 func sortedMapAttrs(attrs pcommon.Map) []string {
 	keys := make([]string, 0)
@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"net"
 	"path/filepath"
@@ -21,6 +22,7 @@ import (
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/exporter/exportertest"
@@ -28,16 +30,20 @@ import (
 	"go.opentelemetry.io/collector/otelcol/otelcoltest"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
 	conventions "go.opentelemetry.io/collector/semconv/v1.9.0"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
 	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/batchpersignal"
 )
 
 const (
-	serviceRouteKey  = "service"
-	resourceRouteKey = "resource"
-	metricRouteKey   = "metric"
+	serviceRouteKey       = "service"
+	resourceRouteKey      = "resource"
+	resourceAttrsRouteKey = "resourceAttributes"
+	metricRouteKey        = "metric"
 
 	ilsName1          = "library-1"
 	ilsName2          = "library-2"
@@ -173,7 +179,7 @@ func TestConsumeMetrics(t *testing.T) {
 	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), serviceBasedRoutingConfig())
 	require.NotNil(t, p)
 	require.NoError(t, err)
-	assert.Equal(t, p.routingKey, svcRouting)
+	assert.Equal(t, p.routing.Load().key, svcRouting)
 
 	// pre-load an exporter here, so that we don't use the actual OTLP exporter
 	lb.addMissingExporters(context.Background(), []string{"endpoint-1", "endpoint-2"})
@@ -262,7 +268,7 @@ func TestConsumeMetricsServiceBased(t *testing.T) {
 	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), serviceBasedRoutingConfig())
 	require.NotNil(t, p)
 	require.NoError(t, err)
-	assert.Equal(t, p.routingKey, svcRouting)
+	assert.Equal(t, p.routing.Load().key, svcRouting)
 
 	// pre-load an exporter here, so that we don't use the actual OTLP exporter
 	lb.addMissingExporters(context.Background(), []string{"endpoint-1"})
@@ -287,45 +293,761 @@ func TestConsumeMetricsServiceBased(t *testing.T) {
 	assert.Nil(t, res)
 }
 
+func TestConsumeMetricsWithoutServiceNameToCatchAll(t *testing.T) {
+	cfg := serviceBasedRoutingConfig()
+	cfg.CatchAllEndpoint = "catch-all"
+	sinks := map[string]*consumertest.MetricsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.MetricsSink)
+		sinks[endpoint] = sink
+		return newMockMetricsExporter(sink.ConsumeMetrics), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	md := pmetric.NewMetrics()
+	named := md.ResourceMetrics().AppendEmpty()
+	named.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+	appendSimpleMetricWithID(named, signal1Name)
+	appendSimpleMetricWithID(md.ResourceMetrics().AppendEmpty(), signal2Name)
+
+	// test
+	err = p.ConsumeMetrics(context.Background(), md)
+
+	// verify
+	require.NoError(t, err)
+	require.Contains(t, sinks, "catch-all:4317")
+	require.Len(t, sinks["catch-all:4317"].AllMetrics(), 1)
+	assert.Equal(t, []string{signal2Name}, metricNames(sinks["catch-all:4317"].AllMetrics()[0]))
+
+	backend := endpointWithPort(lb.ring.endpointFor([]byte(serviceName1)))
+	require.Len(t, sinks[backend].AllMetrics(), 1)
+	assert.Equal(t, []string{signal1Name}, metricNames(sinks[backend].AllMetrics()[0]))
+}
+
+func TestConsumeMetricsWithoutServiceNameNoCatchAll(t *testing.T) {
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockMetricsExporter(), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), serviceBasedRoutingConfig(), componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), serviceBasedRoutingConfig())
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	md := pmetric.NewMetrics()
+	appendSimpleMetricWithID(md.ResourceMetrics().AppendEmpty(), signal1Name)
+
+	// test
+	err = p.ConsumeMetrics(context.Background(), md)
+
+	// verify
+	assert.ErrorIs(t, err, errMissingServiceName)
+}
+
 func TestConsumeMetricsResourceBased(t *testing.T) {
 	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
-		return newNopMockMetricsExporter(), nil
+		return newNopMockMetricsExporter(), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), resourceBasedRoutingConfig(), componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), resourceBasedRoutingConfig())
+	require.NotNil(t, p)
+	require.NoError(t, err)
+	assert.Equal(t, p.routing.Load().key, resourceRouting)
+
+	// pre-load an exporter here, so that we don't use the actual OTLP exporter
+	lb.addMissingExporters(context.Background(), []string{"endpoint-1"})
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return []string{"endpoint-1"}, nil
+		},
+	}
+	p.loadBalancer = lb
+
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	res := p.ConsumeMetrics(context.Background(), simpleMetricsWithResource())
+
+	// verify
+	assert.Nil(t, res)
+}
+
+func TestConsumeMetricsResourceAttributesKeepResourceTogether(t *testing.T) {
+	sinks := map[string]*consumertest.MetricsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.MetricsSink)
+		sinks[endpoint] = sink
+		return newMockMetricsExporter(sink.ConsumeMetrics), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), resourceAttrsBasedRoutingConfig(), componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), resourceAttrsBasedRoutingConfig())
+	require.NotNil(t, p)
+	require.NoError(t, err)
+	assert.Equal(t, resourceAttrsRouting, p.routing.Load().key)
+
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return []string{"endpoint-1", "endpoint-2"}, nil
+		},
+	}
+	p.loadBalancer = lb
+
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+	rm.Resource().Attributes().PutStr(keyAttr1, valueAttr1)
+	metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+	var expected []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("metric-%d", i)
+		metrics.AppendEmpty().SetName(name)
+		expected = append(expected, name)
+	}
+
+	// test
+	err = p.ConsumeMetrics(context.Background(), md)
+
+	// verify
+	require.NoError(t, err)
+	var received [][]string
+	for _, sink := range sinks {
+		for _, md := range sink.AllMetrics() {
+			received = append(received, metricNames(md))
+		}
+	}
+	require.Len(t, received, 1, "the metrics from the resource were sent to more than one backend")
+	assert.Equal(t, expected, received[0])
+}
+
+func TestConsumeMetricsSchemaURLBased(t *testing.T) {
+	// prepare
+	cfg := endpoint2Config()
+	cfg.RoutingKey = "schemaURL"
+	sinks := map[string]*consumertest.MetricsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.MetricsSink)
+		sinks[endpoint] = sink
+		return newMockMetricsExporter(sink.ConsumeMetrics), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+	assert.Equal(t, schemaURLRouting, p.routing.Load().key)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// find one schema URL for each of the endpoints
+	schemaURLs := map[string]string{}
+	for i := 0; len(schemaURLs) < 2; i++ {
+		schemaURL := fmt.Sprintf("https://opentelemetry.io/schemas/1.%d.0", i)
+		endpoint := endpointWithPort(lb.ring.endpointFor([]byte(schemaURL)))
+		if _, ok := schemaURLs[endpoint]; !ok {
+			schemaURLs[endpoint] = schemaURL
+		}
+	}
+
+	md := pmetric.NewMetrics()
+	for _, service := range []string{"service-a", "service-b"} {
+		for _, schemaURL := range schemaURLs {
+			rm := md.ResourceMetrics().AppendEmpty()
+			rm.SetSchemaUrl(schemaURL)
+			rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, service)
+			appendSimpleMetricWithID(rm, service)
+		}
+	}
+
+	// test
+	err = p.ConsumeMetrics(context.Background(), md)
+
+	// verify
+	require.NoError(t, err)
+	for endpoint, schemaURL := range schemaURLs {
+		require.Contains(t, sinks, endpoint)
+		require.Len(t, sinks[endpoint].AllMetrics(), 1)
+		received := sinks[endpoint].AllMetrics()[0]
+		assert.ElementsMatch(t, []string{"service-a", "service-b"}, metricNames(received))
+		for i := 0; i < received.ResourceMetrics().Len(); i++ {
+			assert.Equal(t, schemaURL, received.ResourceMetrics().At(i).SchemaUrl())
+		}
+	}
+}
+
+func TestConsumeMetricsMetricNameBased(t *testing.T) {
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockMetricsExporter(), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), metricNameBasedRoutingConfig(), componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), metricNameBasedRoutingConfig())
+	require.NotNil(t, p)
+	require.NoError(t, err)
+	assert.Equal(t, p.routing.Load().key, metricNameRouting)
+
+	// pre-load an exporter here, so that we don't use the actual OTLP exporter
+	lb.addMissingExporters(context.Background(), []string{"endpoint-1"})
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return []string{"endpoint-1"}, nil
+		},
+	}
+	p.loadBalancer = lb
+
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	res := p.ConsumeMetrics(context.Background(), simpleMetricsWithResource())
+
+	// verify
+	assert.Nil(t, res)
+}
+
+func TestServiceBasedRoutingForSameMetricName(t *testing.T) {
+
+	for _, tt := range []struct {
+		desc       string
+		batch      pmetric.Metrics
+		routingKey routingKey
+		res        map[string]bool
+	}{
+		{
+			"different services - service based routing",
+			twoServicesWithSameMetricName(),
+			svcRouting,
+			map[string]bool{serviceName1: true, serviceName2: true},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			res, err := routingIdentifiersFromMetrics(tt.batch, tt.routingKey)
+			assert.Equal(t, err, nil)
+			assert.Equal(t, res, tt.res)
+		})
+	}
+}
+
+func TestSplitMetricsByRoutingKey(t *testing.T) {
+	for _, tt := range []struct {
+		desc       string
+		routingKey routingKey
+		expected   map[string][]string
+	}{
+		{
+			"metric name based routing",
+			metricNameRouting,
+			map[string][]string{signal1Name: {signal1Name}, signal2Name: {signal2Name}},
+		},
+		{
+			"resource based routing",
+			resourceRouting,
+			map[string][]string{
+				keyAttr1 + valueAttr1 + conventions.AttributeServiceName + serviceName1 + signal1Name: {signal1Name},
+				keyAttr1 + valueAttr1 + conventions.AttributeServiceName + serviceName1 + signal2Name: {signal2Name},
+			},
+		},
+		{
+			"resource attributes based routing",
+			resourceAttrsRouting,
+			map[string][]string{
+				keyAttr1 + valueAttr1 + conventions.AttributeServiceName + serviceName1: {signal1Name, signal2Name},
+			},
+		},
+		{
+			"service based routing",
+			svcRouting,
+			map[string][]string{serviceName1: {signal1Name, signal2Name}},
+		},
+		{
+			"schema URL based routing without a schema URL",
+			schemaURLRouting,
+			map[string][]string{serviceName1: {signal1Name, signal2Name}},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			md := pmetric.NewMetrics()
+			rm := md.ResourceMetrics().AppendEmpty()
+			rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+			rm.Resource().Attributes().PutStr(keyAttr1, valueAttr1)
+			metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+			metrics.AppendEmpty().SetName(signal1Name)
+			metrics.AppendEmpty().SetName(signal2Name)
+
+			// test
+			res, err := splitMetricsByRoutingKey(md, tt.routingKey)
+
+			// verify
+			require.NoError(t, err)
+			require.Len(t, res, len(tt.expected))
+			for rid, names := range tt.expected {
+				batch, ok := res[rid]
+				require.True(t, ok, "missing routing identifier %q", rid)
+				assert.Equal(t, names, metricNames(batch))
+			}
+		})
+	}
+}
+
+func TestSplitMetricsGranularity(t *testing.T) {
+	for _, tt := range []struct {
+		desc       string
+		routingKey routingKey
+		expected   [][]string
+	}{
+		{
+			"metric name based routing",
+			metricNameRouting,
+			[][]string{{signal1Name}, {signal2Name}, {signal1Name}, {signal2Name}},
+		},
+		{
+			"resource based routing",
+			resourceRouting,
+			[][]string{{signal1Name}, {signal2Name}, {signal1Name}, {signal2Name}},
+		},
+		{
+			"service based routing",
+			svcRouting,
+			[][]string{{signal1Name, signal2Name}, {signal1Name, signal2Name}},
+		},
+		{
+			"resource attributes based routing",
+			resourceAttrsRouting,
+			[][]string{{signal1Name, signal2Name}, {signal1Name, signal2Name}},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			md := pmetric.NewMetrics()
+			for _, service := range []string{serviceName1, serviceName2} {
+				rm := md.ResourceMetrics().AppendEmpty()
+				rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, service)
+				metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+				metrics.AppendEmpty().SetName(signal1Name)
+				metrics.AppendEmpty().SetName(signal2Name)
+			}
+			// resources without metrics are left out
+			md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+
+			// test
+			batches := (&metricsRouting{key: tt.routingKey}).splitMetrics(md)
+
+			// verify
+			require.Len(t, batches, len(tt.expected))
+			for i, batch := range batches {
+				assert.Equal(t, 1, batch.ResourceMetrics().Len())
+				assert.Equal(t, tt.expected[i], metricNames(batch))
+			}
+		})
+	}
+}
+
+func TestConsumeMetricsOnlyRoutedMetricsReachEndpoint(t *testing.T) {
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	sinks := map[string]*consumertest.MetricsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.MetricsSink)
+		sinks[endpoint] = sink
+		return newMockMetricsExporter(sink.ConsumeMetrics), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), metricNameBasedRoutingConfig(), componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), metricNameBasedRoutingConfig())
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return endpoints, nil
+		},
+	}
+	p.loadBalancer = lb
+
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// find one metric name for each of the endpoints
+	names := map[string]string{}
+	for i := 0; len(names) < len(endpoints); i++ {
+		name := fmt.Sprintf("metric-%d", i)
+		endpoint := endpointWithPort(lb.ring.endpointFor([]byte(name)))
+		if _, ok := names[endpoint]; !ok {
+			names[endpoint] = name
+		}
+	}
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+	metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+	for _, name := range names {
+		metrics.AppendEmpty().SetName(name)
+	}
+
+	// test
+	err = p.ConsumeMetrics(context.Background(), md)
+
+	// verify
+	require.NoError(t, err)
+	for endpoint, name := range names {
+		sink := sinks[endpoint]
+		require.NotNil(t, sink)
+		require.Len(t, sink.AllMetrics(), 1)
+		assert.Equal(t, []string{name}, metricNames(sink.AllMetrics()[0]))
+	}
+}
+
+func TestConsumeMetricsRoutingKeyUpdate(t *testing.T) {
+	// prepare
+	inFlight := make(chan struct{})
+	release := make(chan struct{})
+	var blockOnce sync.Once
+	var lock sync.Mutex
+	received := map[string][][]string{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockMetricsExporter(func(ctx context.Context, md pmetric.Metrics) error {
+			blockOnce.Do(func() {
+				close(inFlight)
+				<-release
+			})
+			lock.Lock()
+			defer lock.Unlock()
+			received[endpoint] = append(received[endpoint], metricNames(md))
+			return nil
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), endpoint2Config(), componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), endpoint2Config())
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// find one metric name for each of the endpoints
+	names := map[string]string{}
+	for i := 0; len(names) < 2; i++ {
+		name := fmt.Sprintf("metric-%d", i)
+		endpoint := endpointWithPort(lb.ring.endpointFor([]byte(name)))
+		if _, ok := names[endpoint]; !ok {
+			names[endpoint] = name
+		}
+	}
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+	metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+	for _, name := range names {
+		metrics.AppendEmpty().SetName(name)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- p.ConsumeMetrics(context.Background(), md)
+	}()
+	<-inFlight
+
+	// test
+	require.Error(t, p.updateRouting(&Config{RoutingKey: "unknown"}))
+	assert.Equal(t, svcRouting, p.routing.Load().key)
+	require.NoError(t, p.updateRouting(metricNameBasedRoutingConfig()))
+	close(release)
+	require.NoError(t, <-done)
+
+	// verify
+	assert.Equal(t, metricNameRouting, p.routing.Load().key)
+	lock.Lock()
+	require.Len(t, received, 1, "the batch in flight must be routed by service")
+	for _, batches := range received {
+		require.Len(t, batches, 1)
+		assert.Len(t, batches[0], 2)
+	}
+	received = map[string][][]string{}
+	lock.Unlock()
+
+	require.NoError(t, p.ConsumeMetrics(context.Background(), md))
+	lock.Lock()
+	defer lock.Unlock()
+	for endpoint, name := range names {
+		assert.Equal(t, [][]string{{name}}, received[endpoint])
+	}
+}
+
+func TestConsumeMetricsMaxRoutingIdentifiers(t *testing.T) {
+	for _, tt := range []struct {
+		desc        string
+		metricCount int
+		expectedErr bool
+	}{
+		{"within the limit", 10, false},
+		{"above the limit", 1000, true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			// prepare
+			cfg := metricNameBasedRoutingConfig()
+			cfg.MaxRoutingIdentifiers = 10
+			sink := new(consumertest.MetricsSink)
+			componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+				return newMockMetricsExporter(sink.ConsumeMetrics), nil
+			}
+			lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+			require.NotNil(t, lb)
+			require.NoError(t, err)
+
+			p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+			require.NotNil(t, p)
+			require.NoError(t, err)
+
+			p.loadBalancer = lb
+			err = p.Start(context.Background(), componenttest.NewNopHost())
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, p.Shutdown(context.Background()))
+			}()
+
+			md := pmetric.NewMetrics()
+			rm := md.ResourceMetrics().AppendEmpty()
+			rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+			metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+			for i := 0; i < tt.metricCount; i++ {
+				metrics.AppendEmpty().SetName(fmt.Sprintf("metric-%d", i))
+			}
+
+			// test
+			err = p.ConsumeMetrics(context.Background(), md)
+
+			// verify
+			if !tt.expectedErr {
+				require.NoError(t, err)
+				names := 0
+				for _, md := range sink.AllMetrics() {
+					names += len(metricNames(md))
+				}
+				assert.Equal(t, tt.metricCount, names)
+				return
+			}
+			assert.ErrorIs(t, err, errTooManyRoutingIdentifiers)
+			assert.True(t, consumererror.IsPermanent(err))
+			assert.Empty(t, sink.AllMetrics())
+
+			// the exporters must not wait for data that was never sent
+			for _, exp := range lb.exporters {
+				waited := make(chan struct{})
+				go func(exp *wrappedExporter) {
+					exp.consumeWG.Wait()
+					close(waited)
+				}(exp)
+				select {
+				case <-waited:
+				case <-time.After(time.Second):
+					t.Fatal("the exporter is still waiting for data to be consumed")
+				}
+			}
+		})
+	}
+}
+
+func TestConsumeMetricsSingleBackend(t *testing.T) {
+	// prepare
+	endpoints := []string{"endpoint-1"}
+	sinks := map[string]*consumertest.MetricsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.MetricsSink)
+		sinks[endpoint] = sink
+		return newMockMetricsExporter(sink.ConsumeMetrics), nil
 	}
-	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), resourceBasedRoutingConfig(), componentFactory)
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), endpoint2Config(), componentFactory)
 	require.NotNil(t, lb)
 	require.NoError(t, err)
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return endpoints, nil
+		},
+	}
 
-	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), resourceBasedRoutingConfig())
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), endpoint2Config())
 	require.NotNil(t, p)
 	require.NoError(t, err)
-	assert.Equal(t, p.routingKey, resourceRouting)
 
-	// pre-load an exporter here, so that we don't use the actual OTLP exporter
-	lb.addMissingExporters(context.Background(), []string{"endpoint-1"})
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	services := []string{serviceName1, serviceName2}
+	md := pmetric.NewMetrics()
+	for _, service := range services {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, service)
+		appendSimpleMetricWithID(rm, service)
+	}
+
+	// test
+	require.NoError(t, p.ConsumeMetrics(context.Background(), md))
+
+	// verify
+	require.Len(t, sinks["endpoint-1:4317"].AllMetrics(), 1)
+	assert.Equal(t, md, sinks["endpoint-1:4317"].AllMetrics()[0])
+
+	// the data without a service name is still rejected
+	err = p.ConsumeMetrics(context.Background(), simpleMetricsWithNoService())
+	assert.ErrorIs(t, err, errMissingServiceName)
+	assert.Len(t, sinks["endpoint-1:4317"].AllMetrics(), 1)
+
+	// the data is routed again once the ring grows
+	endpoints = []string{"endpoint-1", "endpoint-2"}
+	_, err = lb.res.resolve(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, p.ConsumeMetrics(context.Background(), md))
+	require.Contains(t, sinks, "endpoint-2:4317")
+	var received []string
+	for _, md := range sinks["endpoint-1:4317"].AllMetrics()[1:] {
+		received = append(received, metricNames(md)...)
+	}
+	require.NotEmpty(t, sinks["endpoint-2:4317"].AllMetrics())
+	for _, md := range sinks["endpoint-2:4317"].AllMetrics() {
+		received = append(received, metricNames(md)...)
+	}
+	assert.ElementsMatch(t, services, received)
+}
+
+func TestConsumeMetricsRoundRobin(t *testing.T) {
+	// prepare
+	cfg := endpoint2Config()
+	cfg.RoutingKey = "none"
+	sinks := map[string]*consumertest.MetricsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.MetricsSink)
+		sinks[endpoint] = sink
+		return newMockMetricsExporter(sink.ConsumeMetrics), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
 	lb.res = &mockResolver{
 		triggerCallbacks: true,
 		onResolve: func(ctx context.Context) ([]string, error) {
-			return []string{"endpoint-1"}, nil
+			return []string{"endpoint-1", "endpoint-2"}, nil
 		},
 	}
-	p.loadBalancer = lb
 
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
 	err = p.Start(context.Background(), componenttest.NewNopHost())
 	require.NoError(t, err)
 	defer func() {
 		require.NoError(t, p.Shutdown(context.Background()))
 	}()
 
-	// test
-	res := p.ConsumeMetrics(context.Background(), simpleMetricsWithResource())
+	md := pmetric.NewMetrics()
+	for _, service := range []string{serviceName1, serviceName2} {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, service)
+		appendSimpleMetricWithID(rm, service)
+	}
+
+	// test, the metrics without a service name being sent as well
+	require.NoError(t, p.ConsumeMetrics(context.Background(), md))
+	require.NoError(t, p.ConsumeMetrics(context.Background(), md))
+	require.NoError(t, p.ConsumeMetrics(context.Background(), simpleMetricsWithNoService()))
 
 	// verify
-	assert.Nil(t, res)
+	require.Len(t, sinks["endpoint-1:4317"].AllMetrics(), 2)
+	require.Len(t, sinks["endpoint-2:4317"].AllMetrics(), 1)
+	// each batch is sent as a whole
+	assert.Equal(t, md, sinks["endpoint-1:4317"].AllMetrics()[0])
+	assert.Equal(t, md, sinks["endpoint-2:4317"].AllMetrics()[0])
 }
 
-func TestConsumeMetricsMetricNameBased(t *testing.T) {
+func TestConsumeMetricsReroutesWhenEndpointLeavesTheRing(t *testing.T) {
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	var rebuildOnce sync.Once
+	var lb *loadBalancer
+	sinks := map[string]*consumertest.MetricsSink{}
 	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
-		return newNopMockMetricsExporter(), nil
+		sink := new(consumertest.MetricsSink)
+		sinks[endpoint] = sink
+		return newMockMetricsExporter(func(ctx context.Context, md pmetric.Metrics) error {
+			// the first backend receiving data becomes the only one in the ring, while the data for
+			// the other backend is still pending
+			rebuildOnce.Do(func() {
+				endpoints = []string{endpoint}
+				_, err := lb.res.resolve(ctx)
+				assert.NoError(t, err)
+			})
+			return sink.ConsumeMetrics(ctx, md)
+		}), nil
 	}
 	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), metricNameBasedRoutingConfig(), componentFactory)
 	require.NotNil(t, lb)
@@ -334,14 +1056,11 @@ func TestConsumeMetricsMetricNameBased(t *testing.T) {
 	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), metricNameBasedRoutingConfig())
 	require.NotNil(t, p)
 	require.NoError(t, err)
-	assert.Equal(t, p.routingKey, metricNameRouting)
 
-	// pre-load an exporter here, so that we don't use the actual OTLP exporter
-	lb.addMissingExporters(context.Background(), []string{"endpoint-1"})
 	lb.res = &mockResolver{
 		triggerCallbacks: true,
 		onResolve: func(ctx context.Context) ([]string, error) {
-			return []string{"endpoint-1"}, nil
+			return endpoints, nil
 		},
 	}
 	p.loadBalancer = lb
@@ -352,34 +1071,224 @@ func TestConsumeMetricsMetricNameBased(t *testing.T) {
 		require.NoError(t, p.Shutdown(context.Background()))
 	}()
 
+	// find one metric name for each of the endpoints
+	names := map[string]string{}
+	for i := 0; len(names) < len(endpoints); i++ {
+		name := fmt.Sprintf("metric-%d", i)
+		endpoint := endpointWithPort(lb.ring.endpointFor([]byte(name)))
+		if _, ok := names[endpoint]; !ok {
+			names[endpoint] = name
+		}
+	}
+
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	for _, name := range names {
+		metrics.AppendEmpty().SetName(name)
+	}
+
 	// test
-	res := p.ConsumeMetrics(context.Background(), simpleMetricsWithResource())
+	err = p.ConsumeMetrics(context.Background(), md)
 
 	// verify
-	assert.Nil(t, res)
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	live := endpointWithPort(endpoints[0])
+	var received []string
+	for endpoint, sink := range sinks {
+		for _, md := range sink.AllMetrics() {
+			assert.Equal(t, live, endpoint, "data was sent to a backend no longer in the ring")
+			received = append(received, metricNames(md)...)
+		}
+	}
+	assert.ElementsMatch(t, []string{names["endpoint-1:4317"], names["endpoint-2:4317"]}, received)
 }
 
-func TestServiceBasedRoutingForSameMetricName(t *testing.T) {
+func TestConsumeMetricsPartialFailures(t *testing.T) {
+	cfg := metricNameBasedRoutingConfig()
+	cfg.PartialFailures = true
 
-	for _, tt := range []struct {
-		desc       string
-		batch      pmetric.Metrics
-		routingKey routingKey
-		res        map[string]bool
-	}{
-		{
-			"different services - service based routing",
-			twoServicesWithSameMetricName(),
-			svcRouting,
-			map[string]bool{serviceName1: true, serviceName2: true},
-		},
-	} {
-		t.Run(tt.desc, func(t *testing.T) {
-			res, err := routingIdentifiersFromMetrics(tt.batch, tt.routingKey)
-			assert.Equal(t, err, nil)
-			assert.Equal(t, res, tt.res)
-		})
+	// the second endpoint fails on its first attempt only
+	failedOnce := false
+	sinks := map[string]*consumertest.MetricsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.MetricsSink)
+		sinks[endpoint] = sink
+		return newMockMetricsExporter(func(ctx context.Context, md pmetric.Metrics) error {
+			if endpoint == "endpoint-2:4317" && !failedOnce {
+				failedOnce = true
+				return errors.New("backend unavailable")
+			}
+			return sink.ConsumeMetrics(ctx, md)
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// find one metric name for each of the endpoints
+	names := map[string]string{}
+	for i := 0; len(names) < 2; i++ {
+		name := fmt.Sprintf("metric-%d", i)
+		endpoint := endpointWithPort(lb.ring.endpointFor([]byte(name)))
+		if _, ok := names[endpoint]; !ok {
+			names[endpoint] = name
+		}
+	}
+
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	for _, name := range names {
+		metrics.AppendEmpty().SetName(name)
+	}
+
+	// test
+	err = p.ConsumeMetrics(context.Background(), md)
+
+	// verify
+	var partial consumererror.Metrics
+	require.True(t, errors.As(err, &partial))
+	assert.Equal(t, []string{names["endpoint-2:4317"]}, metricNames(partial.Data()))
+
+	// retrying the failed portion doesn't send the data again to the successful backend
+	require.NoError(t, p.ConsumeMetrics(context.Background(), partial.Data()))
+	require.Len(t, sinks["endpoint-1:4317"].AllMetrics(), 1)
+	assert.Equal(t, []string{names["endpoint-1:4317"]}, metricNames(sinks["endpoint-1:4317"].AllMetrics()[0]))
+	require.Len(t, sinks["endpoint-2:4317"].AllMetrics(), 1)
+	assert.Equal(t, []string{names["endpoint-2:4317"]}, metricNames(sinks["endpoint-2:4317"].AllMetrics()[0]))
+}
+
+func TestConsumeMetricsErrorsInEndpointOrder(t *testing.T) {
+	endpoints := []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockMetricsExporter(func(ctx context.Context, md pmetric.Metrics) error {
+			return fmt.Errorf("failed to send to %s", endpoint)
+		}), nil
+	}
+	cfg := metricNameBasedRoutingConfig()
+	cfg.Resolver.Static.Hostnames = endpoints
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// find one metric name for each of the endpoints
+	names := map[string]string{}
+	for i := 0; len(names) < len(endpoints); i++ {
+		name := fmt.Sprintf("metric-%d", i)
+		endpoint := endpointWithPort(lb.ring.endpointFor([]byte(name)))
+		if _, ok := names[endpoint]; !ok {
+			names[endpoint] = name
+		}
+	}
+
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	for _, name := range names {
+		metrics.AppendEmpty().SetName(name)
+	}
+
+	for i := 0; i < 10; i++ {
+		// test
+		err = p.ConsumeMetrics(context.Background(), md)
+
+		// verify
+		errs := multierr.Errors(err)
+		require.Len(t, errs, 3)
+		assert.EqualError(t, errs[0], "failed to send to endpoint-1:4317")
+		assert.EqualError(t, errs[1], "failed to send to endpoint-2:4317")
+		assert.EqualError(t, errs[2], "failed to send to endpoint-3:4317")
+	}
+}
+
+func TestConsumeMetricsRoutingIDFollowsTraceID(t *testing.T) {
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	resolver := func() *mockResolver {
+		return &mockResolver{
+			triggerCallbacks: true,
+			onResolve: func(ctx context.Context) ([]string, error) {
+				return endpoints, nil
+			},
+		}
+	}
+
+	traceSinks := map[string]*consumertest.TracesSink{}
+	tlb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), func(ctx context.Context, endpoint string) (component.Component, error) {
+		traceSinks[endpoint] = new(consumertest.TracesSink)
+		return newMockTracesExporter(traceSinks[endpoint].ConsumeTraces), nil
+	})
+	require.NoError(t, err)
+	tlb.res = resolver()
+	te, err := newTracesExporter(exportertest.NewNopCreateSettings(), simpleConfig())
+	require.NoError(t, err)
+	te.loadBalancer = tlb
+
+	metricSinks := map[string]*consumertest.MetricsSink{}
+	cfg := simpleConfig()
+	cfg.RoutingKey = "routingID"
+	mlb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		metricSinks[endpoint] = new(consumertest.MetricsSink)
+		return newMockMetricsExporter(metricSinks[endpoint].ConsumeMetrics), nil
+	})
+	require.NoError(t, err)
+	mlb.res = resolver()
+	me, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	assert.Equal(t, routingIDRouting, me.routing.Load().key)
+	me.loadBalancer = mlb
+
+	require.NoError(t, te.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, te.Shutdown(context.Background()))
+	}()
+	require.NoError(t, me.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, me.Shutdown(context.Background()))
+	}()
+
+	for i := 0; i < 20; i++ {
+		traceID := pcommon.TraceID([16]byte{byte(i), byte(i * 7), 3, 4})
+
+		td := ptrace.NewTraces()
+		appendSimpleTraceWithID(td.ResourceSpans().AppendEmpty(), traceID)
+		md := pmetric.NewMetrics()
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr(RoutingIDAttribute, TraceIDRoutingID(traceID))
+		appendSimpleMetricWithID(rm, signal1Name)
+
+		// test
+		require.NoError(t, te.ConsumeTraces(context.Background(), td))
+		require.NoError(t, me.ConsumeMetrics(context.Background(), md))
+
+		// verify
+		for _, endpoint := range endpoints {
+			endpoint = endpointWithPort(endpoint)
+			assert.Equal(t, traceSinks[endpoint].SpanCount(), len(metricSinks[endpoint].AllMetrics()))
+		}
 	}
+	assert.NotZero(t, traceSinks["endpoint-1:4317"].SpanCount())
+	assert.NotZero(t, traceSinks["endpoint-2:4317"].SpanCount())
 }
 
 func TestConsumeMetricsExporterNoEndpoint(t *testing.T) {
@@ -512,16 +1421,12 @@ func TestBatchWithTwoMetrics(t *testing.T) {
 
 func TestNoMetricsInBatch(t *testing.T) {
 	for _, tt := range []struct {
-		desc       string
-		batch      pmetric.Metrics
-		routingKey routingKey
-		err        error
+		desc  string
+		batch pmetric.Metrics
 	}{
 		{
 			"no resource metrics",
 			pmetric.NewMetrics(),
-			svcRouting,
-			errors.New("empty resource metrics"),
 		},
 		{
 			"no instrumentation library metrics",
@@ -530,8 +1435,6 @@ func TestNoMetricsInBatch(t *testing.T) {
 				batch.ResourceMetrics().AppendEmpty()
 				return batch
 			}(),
-			svcRouting,
-			errors.New("empty scope metrics"),
 		},
 		{
 			"no metrics",
@@ -540,15 +1443,75 @@ func TestNoMetricsInBatch(t *testing.T) {
 				batch.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
 				return batch
 			}(),
-			svcRouting,
-			errors.New("empty metrics"),
+		},
+		{
+			"no metrics in any of the resources",
+			func() pmetric.Metrics {
+				batch := pmetric.NewMetrics()
+				batch.ResourceMetrics().AppendEmpty()
+				batch.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+				return batch
+			}(),
 		},
 	} {
-		t.Run(tt.desc, func(t *testing.T) {
-			res, err := routingIdentifiersFromMetrics(tt.batch, tt.routingKey)
-			assert.Equal(t, err, tt.err)
-			assert.Equal(t, res, map[string]bool(nil))
-		})
+		for _, routingKey := range []routingKey{svcRouting, metricNameRouting, resourceRouting, routingIDRouting, resourceAttrsRouting, schemaURLRouting} {
+			t.Run(fmt.Sprintf("%s/%d", tt.desc, routingKey), func(t *testing.T) {
+				res, err := routingIdentifiersFromMetrics(tt.batch, routingKey)
+				assert.Equal(t, errEmptyMetrics, err)
+				assert.Equal(t, map[string]bool(nil), res)
+
+				split, err := splitMetricsByRoutingKey(tt.batch, routingKey)
+				assert.Equal(t, errEmptyMetrics, err)
+				assert.Nil(t, split)
+			})
+		}
+	}
+}
+
+func TestPartiallyEmptyMetricsInBatch(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		batch pmetric.Metrics
+	}{
+		{
+			"empty first scope",
+			func() pmetric.Metrics {
+				batch := pmetric.NewMetrics()
+				rm := batch.ResourceMetrics().AppendEmpty()
+				rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+				rm.Resource().Attributes().PutStr(RoutingIDAttribute, serviceName1)
+				rm.ScopeMetrics().AppendEmpty()
+				rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName(signal1Name)
+				return batch
+			}(),
+		},
+		{
+			"empty first resource without attributes",
+			func() pmetric.Metrics {
+				batch := pmetric.NewMetrics()
+				batch.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+				rm := batch.ResourceMetrics().AppendEmpty()
+				rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+				rm.Resource().Attributes().PutStr(RoutingIDAttribute, serviceName1)
+				rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName(signal1Name)
+				return batch
+			}(),
+		},
+	} {
+		for _, routingKey := range []routingKey{svcRouting, metricNameRouting, resourceRouting, routingIDRouting, resourceAttrsRouting, schemaURLRouting} {
+			t.Run(fmt.Sprintf("%s/%d", tt.desc, routingKey), func(t *testing.T) {
+				res, err := routingIdentifiersFromMetrics(tt.batch, routingKey)
+				require.NoError(t, err)
+				assert.Len(t, res, 1)
+
+				split, err := splitMetricsByRoutingKey(tt.batch, routingKey)
+				require.NoError(t, err)
+				require.Len(t, split, 1)
+				for _, md := range split {
+					assert.Equal(t, []string{signal1Name}, metricNames(md))
+				}
+			})
+		}
 	}
 }
 
@@ -597,7 +1560,7 @@ func TestRollingUpdatesWhenConsumeMetrics(t *testing.T) {
 
 	// simulate rolling updates, the dns resolver should resolve in the following order
 	// ["127.0.0.1"] -> ["127.0.0.1", "127.0.0.2"] -> ["127.0.0.2"]
-	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second)
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second, nil)
 	require.NoError(t, err)
 
 	mu := sync.Mutex{}
@@ -819,6 +1782,75 @@ func BenchmarkConsumeMetrics_10E1000T(b *testing.B) {
 	benchConsumeMetrics(b, 10, 1000)
 }
 
+func BenchmarkConsumeMetricsSingleBackend(b *testing.B) {
+	for _, tt := range []struct {
+		desc                  string
+		maxRoutingIdentifiers int
+	}{
+		{"fast_path", 0},
+		// counting the routing identifiers requires routing the metrics one by one
+		{"routed", math.MaxInt},
+	} {
+		b.Run(tt.desc, func(b *testing.B) {
+			config := endpoint2Config()
+			config.Resolver.Static.Hostnames = []string{"endpoint-1"}
+			config.MaxRoutingIdentifiers = tt.maxRoutingIdentifiers
+			p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), config)
+			require.NoError(b, err)
+			p.loadBalancer.componentFactory = func(ctx context.Context, endpoint string) (component.Component, error) {
+				return newNopMockMetricsExporter(), nil
+			}
+			require.NoError(b, p.Start(context.Background(), componenttest.NewNopHost()))
+
+			md := pmetric.NewMetrics()
+			for i := 0; i < 100; i++ {
+				appendSimpleMetricWithServiceName(md, fmt.Sprintf("service-%d", i%10), fmt.Sprintf("sig-%d", i))
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				require.NoError(b, p.ConsumeMetrics(context.Background(), md))
+			}
+
+			b.StopTimer()
+			require.NoError(b, p.Shutdown(context.Background()))
+		})
+	}
+}
+
+func BenchmarkSplitMetricsServiceBased(b *testing.B) {
+	md := pmetric.NewMetrics()
+	for i := 0; i < 10; i++ {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, fmt.Sprintf("service-%d", i))
+		metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+		for j := 0; j < 10; j++ {
+			metrics.AppendEmpty().SetName(fmt.Sprintf("sig-%d", j))
+		}
+	}
+	routing := &metricsRouting{key: svcRouting}
+
+	for _, tt := range []struct {
+		desc  string
+		split func(pmetric.Metrics) []pmetric.Metrics
+	}{
+		{"per_metric", batchpersignal.SplitMetrics},
+		{"per_resource", routing.splitMetrics},
+	} {
+		b.Run(tt.desc, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, batch := range tt.split(md) {
+					if _, err := routing.splitBatch(batch); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
 func endpoint2Config() *Config {
 	return &Config{
 		Resolver: ResolverSettings{
@@ -837,6 +1869,15 @@ func resourceBasedRoutingConfig() *Config {
 	}
 }
 
+func resourceAttrsBasedRoutingConfig() *Config {
+	return &Config{
+		Resolver: ResolverSettings{
+			Static: &StaticResolver{Hostnames: []string{"endpoint-1", "endpoint-2"}},
+		},
+		RoutingKey: resourceAttrsRouteKey,
+	}
+}
+
 func metricNameBasedRoutingConfig() *Config {
 	return &Config{
 		Resolver: ResolverSettings{
@@ -892,6 +1933,19 @@ func twoServicesWithSameMetricName() pmetric.Metrics {
 	return metrics
 }
 
+func metricNames(md pmetric.Metrics) []string {
+	var names []string
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		sm := md.ResourceMetrics().At(i).ScopeMetrics()
+		for j := 0; j < sm.Len(); j++ {
+			for k := 0; k < sm.At(j).Metrics().Len(); k++ {
+				names = append(names, sm.At(j).Metrics().At(k).Name())
+			}
+		}
+	}
+	return names
+}
+
 func appendSimpleMetricWithID(dest pmetric.ResourceMetrics, id string) {
 	dest.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName(id)
 }
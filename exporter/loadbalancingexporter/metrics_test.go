@@ -4,21 +4,198 @@
 package loadbalancingexporter
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
-func TestProcessorMetrics(t *testing.T) {
-	expectedViewNames := []string{
-		"loadbalancer_num_resolutions",
-		"loadbalancer_num_backends",
-		"loadbalancer_num_backend_updates",
-		"loadbalancer_backend_latency",
+// telemetryTestSettings returns the settings of an exporter whose metrics are collected by the returned reader.
+func telemetryTestSettings() (exporter.CreateSettings, *sdkmetric.ManualReader) {
+	tt := setupTestTelemetry()
+	return tt.NewCreateSettings(), tt.reader
+}
+
+// collectMetric returns the metric with the given name collected by the reader, failing the test when it's missing.
+func collectMetric(t *testing.T, reader *sdkmetric.ManualReader, name string) metricdata.Metrics {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	require.Failf(t, "metric not found", "no metric %q was collected", name)
+	return metricdata.Metrics{}
+}
+
+func TestTelemetryNamespace(t *testing.T) {
+	// prepare
+	settings, reader := telemetryTestSettings()
+	cfg := serviceBasedRoutingConfig()
+	cfg.TelemetryNamespace = "tier-1"
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockMetricsExporter(), nil
+	}
+	lb, err := newLoadBalancer(settings, cfg, componentFactory)
+	require.NoError(t, err)
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return []string{"endpoint-1"}, nil
+		},
+	}
+
+	p, err := newMetricsExporter(settings, cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	require.NoError(t, p.ConsumeMetrics(context.Background(), simpleMetricsWithServiceName()))
+
+	// verify
+	outcome, ok := collectMetric(t, reader, "loadbalancer_backend_outcome").Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, outcome.DataPoints, 1)
+	assert.Equal(t, int64(1), outcome.DataPoints[0].Value)
+	assert.Equal(t, attribute.NewSet(
+		endpointAttrKey.String("endpoint-1"),
+		namespaceAttrKey.String("tier-1"),
+		successTrueAttr,
+	), outcome.DataPoints[0].Attributes)
+}
+
+func TestTelemetryGauges(t *testing.T) {
+	// prepare
+	settings, reader := telemetryTestSettings()
+	tb, err := newTelemetry(settings.TelemetrySettings, "")
+	require.NoError(t, err)
+
+	// test
+	tb.recordBackends(context.Background(), "static", 2)
+	tb.recordBackends(context.Background(), "static", 3)
+	tb.recordCircuitState("endpoint-1", true)
+
+	// verify
+	backends, ok := collectMetric(t, reader, "loadbalancer_num_backends").Data.(metricdata.Gauge[int64])
+	require.True(t, ok)
+	require.Len(t, backends.DataPoints, 1)
+	assert.Equal(t, int64(3), backends.DataPoints[0].Value)
+
+	updates, ok := collectMetric(t, reader, "loadbalancer_num_backend_updates").Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, updates.DataPoints, 1)
+	assert.Equal(t, int64(2), updates.DataPoints[0].Value)
+
+	circuits, ok := collectMetric(t, reader, "loadbalancer_backend_circuit_open").Data.(metricdata.Gauge[int64])
+	require.True(t, ok)
+	require.Len(t, circuits.DataPoints, 1)
+	assert.Equal(t, int64(1), circuits.DataPoints[0].Value)
+
+	// the gauges registered by the telemetry are no longer observed once shut down, unlike the ring size from the
+	// telemetry builder, observed for as long as the meter provider lives
+	require.NoError(t, tb.shutdown())
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if gauge, ok := m.Data.(metricdata.Gauge[int64]); ok && m.Name != "loadbalancer_ring_size" {
+				assert.Empty(t, gauge.DataPoints, m.Name)
+			}
+		}
 	}
+}
+
+func TestEndpointAttrsWithoutNamespace(t *testing.T) {
+	// prepare
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), nil)
+	require.NoError(t, err)
+
+	// test
+	attrs := lb.telemetry.endpointAttrs("endpoint-1", successTrueAttr)
+
+	// verify
+	assert.Equal(t, []attribute.KeyValue{endpointAttrKey.String("endpoint-1"), successTrueAttr}, attrs)
+}
+
+func TestNilTelemetry(t *testing.T) {
+	var tb *telemetry
+
+	// test and verify
+	assert.NotPanics(t, func() {
+		tb.recordResolution(context.Background(), "static", true)
+		tb.recordBackends(context.Background(), "static", 1)
+		tb.recordBackendSend(context.Background(), "endpoint-1", true, time.Millisecond, 10)
+		tb.recordEviction(context.Background(), "endpoint-1")
+		tb.recordRestoration(context.Background(), "endpoint-1")
+		tb.recordCircuitState("endpoint-1", true)
+		assert.NoError(t, tb.shutdown())
+	})
+}
 
-	views := metricViews()
-	for i, viewName := range expectedViewNames {
-		assert.Equal(t, viewName, views[i].Name)
+func TestTelemetryRoutingMetrics(t *testing.T) {
+	// prepare
+	settings, reader := telemetryTestSettings()
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2"}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockTracesExporter(), nil
 	}
+	lb, err := newLoadBalancer(settings, cfg, componentFactory)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(settings, cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	td := simpleTraces()
+	require.NoError(t, p.ConsumeTraces(context.Background(), td))
+
+	// verify
+	ringSize, ok := collectMetric(t, reader, "loadbalancer_ring_size").Data.(metricdata.Gauge[int64])
+	require.True(t, ok)
+	require.Len(t, ringSize.DataPoints, 1)
+	assert.Equal(t, int64(2), ringSize.DataPoints[0].Value)
+
+	changes, ok := collectMetric(t, reader, "loadbalancer_ring_changes").Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, changes.DataPoints, 1)
+	assert.Equal(t, int64(1), changes.DataPoints[0].Value)
+
+	routingKeys, ok := collectMetric(t, reader, "loadbalancer_backend_routing_keys").Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, routingKeys.DataPoints, 1)
+	assert.Equal(t, int64(1), routingKeys.DataPoints[0].Value)
+
+	bytes, ok := collectMetric(t, reader, "loadbalancer_backend_bytes").Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, bytes.DataPoints, 1)
+	assert.Equal(t, int64(tracesMarshaler.TracesSize(td)), bytes.DataPoints[0].Value)
+
+	split, ok := collectMetric(t, reader, "loadbalancer_split_duration").Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, split.DataPoints, 1)
+	assert.Equal(t, uint64(1), split.DataPoints[0].Count)
 }
@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultMirrorPercentage mirrors all the batches
+	defaultMirrorPercentage = 100
+
+	// maxMirrorSends is the number of batches being sent to the mirror at the same time, above which the batches
+	// aren't mirrored, so that a slow mirror doesn't pile up copies of the data
+	maxMirrorSends = 64
+)
+
+// mirror sends a copy of a share of the batches to another set of backends in the background, through an exporter
+// of the same signal with its own load balancer. The results of the mirrored sends don't affect the primary ones.
+type mirror struct {
+	logger     *zap.Logger
+	exporter   component.Component
+	percentage float64
+
+	// sample returns a pseudo-random number in [0,100), the batches are mirrored when it is below the percentage
+	sample func() float64
+
+	sendSlots chan struct{}
+	wg        sync.WaitGroup
+}
+
+// mirrorConfig returns the configuration of the exporter sending the mirrored data: the same as the primary one,
+// with the resolver of the mirror.
+func mirrorConfig(cfg *Config) (*Config, error) {
+	percentage := cfg.Mirror.Percentage
+	if percentage < 0 || percentage > 100 {
+		return nil, fmt.Errorf("invalid mirror percentage %v, it must be between 0 and 100", percentage)
+	}
+
+	mirrorCfg := *cfg
+	mirrorCfg.Resolver = cfg.Mirror.Resolver
+	mirrorCfg.Mirror = nil
+	// the mirrored batches are already written to the persistent queue of the primary exporter
+	mirrorCfg.PersistentQueue = nil
+	// the admin server and the shared resolver belong to the primary exporter
+	mirrorCfg.Admin = nil
+	mirrorCfg.ShareRing = false
+	return &mirrorCfg, nil
+}
+
+func newMirror(logger *zap.Logger, cfg *MirrorSettings, exporter component.Component) *mirror {
+	percentage := cfg.Percentage
+	if percentage == 0 {
+		percentage = defaultMirrorPercentage
+	}
+	return &mirror{
+		logger:     logger,
+		exporter:   exporter,
+		percentage: percentage,
+		sample: func() float64 {
+			return rand.Float64() * 100
+		},
+		sendSlots: make(chan struct{}, maxMirrorSends),
+	}
+}
+
+// sampled returns whether the next batch is mirrored. A nil mirror never mirrors the data.
+func (m *mirror) sampled() bool {
+	return m != nil && m.sample() < m.percentage
+}
+
+// send runs the given send of a copy of the batch to the mirror in the background, unless too many batches are
+// already being mirrored. The send isn't canceled along with the context of the primary send.
+func (m *mirror) send(ctx context.Context, consume func(ctx context.Context) error) {
+	select {
+	case m.sendSlots <- struct{}{}:
+	default:
+		m.logger.Debug("too many batches being mirrored, skipping the batch")
+		return
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() { <-m.sendSlots }()
+		if err := consume(context.WithoutCancel(ctx)); err != nil {
+			m.logger.Debug("failed to send the batch to the mirror", zap.Error(err))
+		}
+	}()
+}
+
+func (m *mirror) Start(ctx context.Context, host component.Host) error {
+	if m == nil {
+		return nil
+	}
+	return m.exporter.Start(ctx, host)
+}
+
+// Shutdown waits for the mirrored sends in progress before shutting down the exporter of the mirror.
+func (m *mirror) Shutdown(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	m.wg.Wait()
+	return m.exporter.Shutdown(ctx)
+}
@@ -0,0 +1,119 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestMirrorTraces(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Mirror = &MirrorSettings{
+		Resolver: ResolverSettings{Static: &StaticResolver{Hostnames: []string{"mirror-1"}}},
+	}
+
+	primarySink := new(consumertest.TracesSink)
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(primarySink.ConsumeTraces), nil
+	})
+	require.NoError(t, err)
+	mirrorCfg, err := mirrorConfig(cfg)
+	require.NoError(t, err)
+	mirrorSink := new(consumertest.TracesSink)
+	mirrorLB, err := newLoadBalancer(exportertest.NewNopCreateSettings(), mirrorCfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(mirrorSink.ConsumeTraces), nil
+	})
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	require.NotNil(t, p.mirror)
+	p.loadBalancer = lb
+	p.mirror.exporter.(*traceExporterImp).loadBalancer = mirrorLB
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+
+	// verify
+	assert.Len(t, primarySink.AllTraces(), 1)
+	assert.Eventually(t, func() bool {
+		return len(mirrorSink.AllTraces()) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"mirror-1"}, mirrorLB.ring.endpoints())
+
+	// the batches above the percentage aren't mirrored
+	p.mirror.percentage = 10
+	p.mirror.sample = func() float64 { return 50 }
+	require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+	assert.Len(t, primarySink.AllTraces(), 2)
+	assert.Len(t, mirrorSink.AllTraces(), 1)
+}
+
+func TestMirrorFailureDoesNotAffectPrimary(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Mirror = &MirrorSettings{
+		Resolver: ResolverSettings{Static: &StaticResolver{Hostnames: []string{"mirror-1"}}},
+	}
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+
+	primarySink := new(consumertest.TracesSink)
+	p.loadBalancer, err = newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(primarySink.ConsumeTraces), nil
+	})
+	require.NoError(t, err)
+	mirrorCfg, err := mirrorConfig(cfg)
+	require.NoError(t, err)
+	mirrored := make(chan struct{})
+	p.mirror.exporter.(*traceExporterImp).loadBalancer, err = newLoadBalancer(exportertest.NewNopCreateSettings(), mirrorCfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(func(ctx context.Context, td ptrace.Traces) error {
+			defer close(mirrored)
+			return errors.New("the mirror is down")
+		}), nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	err = p.ConsumeTraces(context.Background(), simpleTraces())
+
+	// verify
+	assert.NoError(t, err)
+	assert.Len(t, primarySink.AllTraces(), 1)
+	<-mirrored
+}
+
+func TestMirrorInvalidPercentage(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.Mirror = &MirrorSettings{
+		Resolver:   ResolverSettings{Static: &StaticResolver{Hostnames: []string{"mirror-1"}}},
+		Percentage: 150,
+	}
+
+	// test
+	_, err := newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+
+	// verify
+	assert.ErrorContains(t, err, "invalid mirror percentage 150")
+}
@@ -0,0 +1,244 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/extension/experimental/storage"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+const (
+	// persistentQueueIndexKey is the key of the list of the batches in the persistent queue
+	persistentQueueIndexKey = "index"
+
+	// persistentQueueBatchKeyPrefix prefixes the sequence number of each batch into its key
+	persistentQueueBatchKeyPrefix = "batch-"
+)
+
+var (
+	tracesMarshaler    = &ptrace.ProtoMarshaler{}
+	tracesUnmarshaler  = &ptrace.ProtoUnmarshaler{}
+	logsMarshaler      = &plog.ProtoMarshaler{}
+	logsUnmarshaler    = &plog.ProtoUnmarshaler{}
+	metricsMarshaler   = &pmetric.ProtoMarshaler{}
+	metricsUnmarshaler = &pmetric.ProtoUnmarshaler{}
+)
+
+// errPersistentQueueClosed is returned for the batches received while the storage of the persistent queue is closed
+var errPersistentQueueClosed = errors.New("the persistent queue is not started")
+
+// persistentQueue writes the incoming batches to a storage extension before they're routed, and removes them once
+// they were sent, whatever the result of the send. The batches left over when the collector stopped are sent again
+// once it's started, routed with the ring at that time. The writes are serialized, so that the index of the batches
+// is always consistent with the stored batches.
+type persistentQueue struct {
+	logger      *zap.Logger
+	storageID   component.ID
+	exporterID  component.ID
+	storageName string
+
+	lock    sync.Mutex
+	client  storage.Client
+	pending map[uint64]bool
+	next    uint64
+
+	// cancelReplay stops the replay of the left-over batches on shutdown
+	cancelReplay context.CancelFunc
+	replayWG     sync.WaitGroup
+}
+
+func newPersistentQueue(params exporter.CreateSettings, cfg *PersistentQueueSettings, signal component.DataType) *persistentQueue {
+	if cfg == nil {
+		return nil
+	}
+	return &persistentQueue{
+		logger:      params.Logger,
+		storageID:   cfg.StorageID,
+		exporterID:  params.ID,
+		storageName: signal.String(),
+		pending:     map[uint64]bool{},
+	}
+}
+
+// start opens the storage of the queue and sends the left-over batches again in the background, with the given
+// replay function. A nil queue doesn't persist anything.
+func (q *persistentQueue) start(ctx context.Context, host component.Host, replay func(ctx context.Context, data []byte) error) error {
+	if q == nil {
+		return nil
+	}
+	ext, found := host.GetExtensions()[q.storageID]
+	if !found {
+		return fmt.Errorf("storage extension %q of the persistent queue not found", q.storageID)
+	}
+	storageExt, ok := ext.(storage.Extension)
+	if !ok {
+		return fmt.Errorf("extension %q of the persistent queue is not a storage extension", q.storageID)
+	}
+	client, err := storageExt.GetClient(ctx, component.KindExporter, q.exporterID, q.storageName)
+	if err != nil {
+		return fmt.Errorf("failed to open the storage of the persistent queue: %w", err)
+	}
+
+	index, err := client.Get(ctx, persistentQueueIndexKey)
+	if err != nil {
+		return multierr.Combine(fmt.Errorf("failed to read the index of the persistent queue: %w", err), client.Close(ctx))
+	}
+	leftOver := decodePersistentQueueIndex(index)
+
+	q.lock.Lock()
+	q.client = client
+	for _, seq := range leftOver {
+		q.pending[seq] = true
+		if seq >= q.next {
+			q.next = seq + 1
+		}
+	}
+	q.lock.Unlock()
+
+	if len(leftOver) == 0 {
+		return nil
+	}
+	q.logger.Info("sending the batches left over in the persistent queue", zap.Int("batches", len(leftOver)))
+	replayCtx, cancel := context.WithCancel(context.Background())
+	q.cancelReplay = cancel
+	q.replayWG.Add(1)
+	go func() {
+		defer q.replayWG.Done()
+		for _, seq := range leftOver {
+			if replayCtx.Err() != nil {
+				return
+			}
+			q.replayBatch(replayCtx, seq, replay)
+		}
+	}()
+	return nil
+}
+
+// replayBatch sends the left-over batch with the given sequence number again, and removes it from the queue.
+func (q *persistentQueue) replayBatch(ctx context.Context, seq uint64, replay func(ctx context.Context, data []byte) error) {
+	data, err := q.client.Get(ctx, persistentQueueBatchKey(seq))
+	if err != nil {
+		q.logger.Error("failed to read a batch of the persistent queue", zap.Uint64("batch", seq), zap.Error(err))
+		return
+	}
+	if data != nil {
+		if err = replay(ctx, data); err != nil {
+			q.logger.Error("failed to send a batch left over in the persistent queue", zap.Uint64("batch", seq), zap.Error(err))
+		}
+	}
+	q.remove(ctx, seq)
+}
+
+// add writes the batch marshaled with the given function to the queue, returning its sequence number, which
+// removes it once it was sent. A nil queue doesn't persist anything.
+func (q *persistentQueue) add(ctx context.Context, marshal func() ([]byte, error)) (uint64, error) {
+	if q == nil {
+		return 0, nil
+	}
+	data, err := marshal()
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal the batch for the persistent queue: %w", err)
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.client == nil {
+		return 0, errPersistentQueueClosed
+	}
+
+	seq := q.next
+	q.pending[seq] = true
+	err = q.client.Batch(ctx,
+		storage.SetOperation(persistentQueueBatchKey(seq), data),
+		storage.SetOperation(persistentQueueIndexKey, q.encodeIndex()))
+	if err != nil {
+		delete(q.pending, seq)
+		return 0, fmt.Errorf("failed to write the batch to the persistent queue: %w", err)
+	}
+	q.next++
+	return seq, nil
+}
+
+// remove deletes the batch with the given sequence number from the queue. A nil queue doesn't persist anything.
+func (q *persistentQueue) remove(ctx context.Context, seq uint64) {
+	if q == nil {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	delete(q.pending, seq)
+	if q.client == nil {
+		// already shut down, the batch is sent again on the next start
+		return
+	}
+	err := q.client.Batch(context.WithoutCancel(ctx),
+		storage.DeleteOperation(persistentQueueBatchKey(seq)),
+		storage.SetOperation(persistentQueueIndexKey, q.encodeIndex()))
+	if err != nil {
+		q.logger.Warn("failed to remove a batch from the persistent queue, it will be sent again on the next start",
+			zap.Uint64("batch", seq), zap.Error(err))
+	}
+}
+
+// shutdown stops the replay of the left-over batches and closes the storage of the queue.
+func (q *persistentQueue) shutdown(ctx context.Context) error {
+	if q == nil {
+		return nil
+	}
+	if q.cancelReplay != nil {
+		q.cancelReplay()
+	}
+	q.replayWG.Wait()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.client == nil {
+		return nil
+	}
+	err := q.client.Close(ctx)
+	q.client = nil
+	return err
+}
+
+// encodeIndex returns the sorted sequence numbers of the pending batches. The caller must hold the lock.
+func (q *persistentQueue) encodeIndex() []byte {
+	seqs := make([]uint64, 0, len(q.pending))
+	for seq := range q.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	index := make([]byte, 0, 8*len(seqs))
+	for _, seq := range seqs {
+		index = binary.BigEndian.AppendUint64(index, seq)
+	}
+	return index
+}
+
+// decodePersistentQueueIndex returns the sequence numbers of the given index, ignoring a truncated trailing one.
+func decodePersistentQueueIndex(index []byte) []uint64 {
+	seqs := make([]uint64, 0, len(index)/8)
+	for ; len(index) >= 8; index = index[8:] {
+		seqs = append(seqs, binary.BigEndian.Uint64(index))
+	}
+	return seqs
+}
+
+func persistentQueueBatchKey(seq uint64) string {
+	return persistentQueueBatchKeyPrefix + strconv.FormatUint(seq, 10)
+}
@@ -0,0 +1,194 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/extension/experimental/storage"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+var storageID = component.MustNewID("file_storage")
+
+// storageHost is a host holding an in-memory storage extension.
+type storageHost struct {
+	component.Host
+	storage *memoryStorage
+}
+
+func (h *storageHost) GetExtensions() map[component.ID]component.Component {
+	return map[component.ID]component.Component{storageID: h.storage}
+}
+
+// memoryStorage is a storage extension keeping the data of all its clients in memory.
+type memoryStorage struct {
+	component.StartFunc
+	component.ShutdownFunc
+
+	lock sync.Mutex
+	data map[string][]byte
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{data: map[string][]byte{}}
+}
+
+func (s *memoryStorage) GetClient(context.Context, component.Kind, component.ID, string) (storage.Client, error) {
+	return s, nil
+}
+
+func (s *memoryStorage) Get(_ context.Context, key string) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.data[key], nil
+}
+
+func (s *memoryStorage) Set(_ context.Context, key string, value []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data[key] = value
+	return nil
+}
+
+func (s *memoryStorage) Delete(_ context.Context, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+func (s *memoryStorage) Batch(ctx context.Context, ops ...storage.Operation) error {
+	for _, op := range ops {
+		switch op.Type {
+		case storage.Get:
+			op.Value, _ = s.Get(ctx, op.Key)
+		case storage.Set:
+			_ = s.Set(ctx, op.Key, op.Value)
+		case storage.Delete:
+			_ = s.Delete(ctx, op.Key)
+		}
+	}
+	return nil
+}
+
+func (s *memoryStorage) Close(context.Context) error {
+	return nil
+}
+
+func (s *memoryStorage) keys() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var keys []string
+	for key, value := range s.data {
+		if key != persistentQueueIndexKey || len(value) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestPersistentQueueRemovesSentBatches(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.PersistentQueue = &PersistentQueueSettings{StorageID: storageID}
+	host := &storageHost{Host: componenttest.NewNopHost(), storage: newMemoryStorage()}
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	sink := new(consumertest.TracesSink)
+	p.loadBalancer, err = newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(func(ctx context.Context, td ptrace.Traces) error {
+			// the batch is in the queue while it's being sent
+			assert.Len(t, host.storage.keys(), 2)
+			return sink.ConsumeTraces(ctx, td)
+		}), nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, p.Start(context.Background(), host))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	err = p.ConsumeTraces(context.Background(), simpleTraces())
+
+	// verify
+	assert.NoError(t, err)
+	assert.Len(t, sink.AllTraces(), 1)
+	assert.Empty(t, host.storage.keys())
+}
+
+func TestPersistentQueueReplaysLeftOverBatches(t *testing.T) {
+	// prepare
+	settings := exportertest.NewNopCreateSettings()
+	host := &storageHost{Host: componenttest.NewNopHost(), storage: newMemoryStorage()}
+	cfg := &PersistentQueueSettings{StorageID: storageID}
+
+	// a batch is left over in the queue, as when the collector stopped while sending it
+	previous := newPersistentQueue(settings, cfg, component.DataTypeTraces)
+	require.NoError(t, previous.start(context.Background(), host, nil))
+	_, err := previous.add(context.Background(), func() ([]byte, error) {
+		return tracesMarshaler.MarshalTraces(simpleTraces())
+	})
+	require.NoError(t, err)
+	require.NoError(t, previous.shutdown(context.Background()))
+
+	exporterCfg := simpleConfig()
+	exporterCfg.PersistentQueue = cfg
+	p, err := newTracesExporter(settings, exporterCfg)
+	require.NoError(t, err)
+	sink := new(consumertest.TracesSink)
+	p.loadBalancer, err = newLoadBalancer(settings, exporterCfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	})
+	require.NoError(t, err)
+
+	// test
+	require.NoError(t, p.Start(context.Background(), host))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Eventually(t, func() bool {
+		return len(sink.AllTraces()) == 1 && len(host.storage.keys()) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestPersistentQueueMissingStorage(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.PersistentQueue = &PersistentQueueSettings{StorageID: storageID}
+	p, err := newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+
+	// test
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+
+	// verify
+	assert.ErrorContains(t, err, `storage extension "file_storage" of the persistent queue not found`)
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestDecodePersistentQueueIndex(t *testing.T) {
+	q := newPersistentQueue(exportertest.NewNopCreateSettings(), &PersistentQueueSettings{}, component.DataTypeLogs)
+	q.pending = map[uint64]bool{3: true, 1: true, 300: true}
+
+	// test
+	index := q.encodeIndex()
+
+	// verify
+	assert.Equal(t, []uint64{1, 3, 300}, decodePersistentQueueIndex(index))
+	assert.Equal(t, []uint64{1}, decodePersistentQueueIndex(index[:12]))
+}
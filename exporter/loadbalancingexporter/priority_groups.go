@@ -0,0 +1,56 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// newFallbackGroups returns the sorted endpoints of each of the fallback groups, in their order of priority.
+func newFallbackGroups(cfg []StaticResolver) [][]string {
+	if len(cfg) == 0 {
+		return nil
+	}
+	groups := make([][]string, len(cfg))
+	for i, group := range cfg {
+		groups[i] = make([]string, len(group.Hostnames))
+		copy(groups[i], group.Hostnames)
+		sort.Strings(groups[i])
+	}
+	return groups
+}
+
+// fallbackGroupEndpoints returns the endpoints of all the fallback groups.
+func (lb *loadBalancer) fallbackGroupEndpoints() []string {
+	var endpoints []string
+	for _, group := range lb.fallbackGroups {
+		endpoints = append(endpoints, group...)
+	}
+	return endpoints
+}
+
+// selectGroup returns the healthy endpoints of the first group with any: the resolved endpoints, followed by the
+// fallback groups in their order of priority. The endpoints evicted by the health checks are unhealthy. When no
+// group has healthy endpoints, the resolved endpoints are used as they are, as there would be nowhere left to send
+// the data to otherwise.
+func (lb *loadBalancer) selectGroup(resolved []string) []string {
+	for i, group := range append([][]string{resolved}, lb.fallbackGroups...) {
+		healthy := lb.healthChecks.healthy(group)
+		if len(healthy) == 0 {
+			continue
+		}
+		if previous := lb.activeGroup.Swap(int64(i)); previous != int64(i) {
+			if i == 0 {
+				lb.logger.Info("the resolved endpoints are healthy again, no longer using the fallback groups")
+			} else {
+				lb.logger.Warn("no healthy endpoints in the groups of higher priority, using a fallback group",
+					zap.Int("group", i), zap.Strings("endpoints", healthy))
+			}
+		}
+		return healthy
+	}
+	return resolved
+}
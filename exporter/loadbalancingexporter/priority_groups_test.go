@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+)
+
+func TestFallbackGroupsFailoverAndFailback(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.FallbackGroups = []StaticResolver{
+		{Hostnames: []string{"secondary-1"}},
+		{Hostnames: []string{"tertiary-1", "tertiary-2"}},
+	}
+	cfg.HealthCheck = &HealthCheckSettings{
+		Interval:           10 * time.Millisecond,
+		UnhealthyThreshold: 1,
+		HealthyThreshold:   1,
+	}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockTracesExporter(), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+
+	var lock sync.Mutex
+	down := map[string]bool{"endpoint-1:4317": true, "secondary-1:4317": true}
+	setDown := func(endpoint string, isDown bool) {
+		lock.Lock()
+		defer lock.Unlock()
+		down[endpoint] = isDown
+	}
+	lb.healthChecks.probe = func(ctx context.Context, endpoint string) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if down[endpoint] {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	ringEndpoints := func() []string {
+		lb.updateLock.RLock()
+		defer lb.updateLock.RUnlock()
+		return lb.ring.endpoints()
+	}
+
+	// test
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	// the resolved endpoints are used until they're found unhealthy
+	assert.Equal(t, []string{"endpoint-1"}, ringEndpoints())
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"tertiary-1", "tertiary-2"}, ringEndpoints())
+	}, time.Second, 5*time.Millisecond)
+
+	// the group of higher priority is used again as soon as it's healthy
+	setDown("secondary-1:4317", false)
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"secondary-1"}, ringEndpoints())
+	}, time.Second, 5*time.Millisecond)
+	setDown("endpoint-1:4317", false)
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"endpoint-1"}, ringEndpoints())
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestSelectGroup(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.FallbackGroups = []StaticResolver{{Hostnames: []string{"secondary-2", "secondary-1"}}}
+	cfg.HealthCheck = &HealthCheckSettings{}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	require.NoError(t, err)
+
+	// test
+	selected := lb.selectGroup(nil)
+
+	// verify
+	// the fallback group is used while the resolver yields no endpoints
+	assert.Equal(t, []string{"secondary-1", "secondary-2"}, selected)
+	assert.Equal(t, []string{"endpoint-1"}, lb.selectGroup([]string{"endpoint-1"}))
+
+	// the resolved endpoints are used as they are when no group is healthy
+	lb.healthChecks.states["endpoint-1"] = &endpointHealth{evicted: true}
+	lb.healthChecks.states["secondary-1"] = &endpointHealth{evicted: true}
+	lb.healthChecks.states["secondary-2"] = &endpointHealth{evicted: true}
+	assert.Equal(t, []string{"endpoint-1"}, lb.selectGroup([]string{"endpoint-1"}))
+}
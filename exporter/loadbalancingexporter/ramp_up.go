@@ -0,0 +1,101 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"hash/fnv"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// rampUp moves the identifiers to the endpoints added to the ring gradually: during the ramp, each identifier
+// whose endpoint in the new ring is one of the added endpoints keeps being routed with the previous ring, until
+// the share of the ramp elapsed reaches its position. The identifiers are thereby moved in a stable order, each
+// of them moving once.
+type rampUp struct {
+	from     ring
+	added    []string
+	start    time.Time
+	duration time.Duration
+}
+
+// endpointFor returns the endpoint for the identifier routed to the given endpoint by the new ring.
+func (r *rampUp) endpointFor(identifier []byte, endpoint string) string {
+	if !endpointFound(endpoint, r.added) {
+		return endpoint
+	}
+	progress := float64(time.Since(r.start)) / float64(r.duration)
+	if progress >= 1 || rampPosition(identifier) < progress {
+		return endpoint
+	}
+	return r.from.endpointFor(identifier)
+}
+
+// rampPosition returns the position of the identifier in the ramp, between 0 and 1. It doesn't use the hash
+// function of the rings, as the identifiers routed to the same endpoint would otherwise have close positions.
+func rampPosition(identifier []byte) float64 {
+	h := fnv.New32a()
+	_, _ = h.Write(identifier)
+	return float64(h.Sum32()) / math.MaxUint32
+}
+
+// startRampUp starts ramping up the endpoints added since the previous ring, replacing the ramp in progress, if
+// any. Nothing is ramped up when the previous ring had no endpoints. The caller must hold the update lock.
+func (lb *loadBalancer) startRampUp(previous ring) {
+	if previous == nil || len(previous.endpoints()) == 0 {
+		return
+	}
+
+	var added []string
+	for _, endpoint := range lb.endpoints {
+		if !endpointFound(endpoint, previous.endpoints()) {
+			added = append(added, endpoint)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+
+	lb.logger.Debug("ramping up the new endpoints", zap.Strings("endpoints", added), zap.Duration("duration", lb.cfg.RampUpDuration))
+	lb.ramp = &rampUp{
+		from:     previous,
+		added:    added,
+		start:    time.Now(),
+		duration: lb.cfg.RampUpDuration,
+	}
+	if lb.rampTimer != nil {
+		lb.rampTimer.Stop()
+	}
+	lb.rampTimer = time.AfterFunc(lb.cfg.RampUpDuration, lb.endRampUp)
+}
+
+// endRampUp routes all the identifiers with the current ring once the ramp is over.
+func (lb *loadBalancer) endRampUp() {
+	lb.updateLock.Lock()
+	defer lb.updateLock.Unlock()
+
+	lb.rampTimer = nil
+	if lb.ramp == nil || time.Since(lb.ramp.start) < lb.ramp.duration {
+		// replaced by a newer ramp, which has its own timer
+		return
+	}
+	lb.logger.Debug("the new endpoints are ramped up", zap.Strings("endpoints", lb.ramp.added))
+	lb.ramp = nil
+}
+
+// rampedEndpoint returns the endpoint for the identifier routed to the given endpoint by the ring, taking the ramp
+// in progress into account. The endpoints from the previous ring without an exporter anymore aren't used. The
+// caller must hold the update lock.
+func (lb *loadBalancer) rampedEndpoint(identifier []byte, endpoint string) string {
+	if lb.ramp == nil {
+		return endpoint
+	}
+	ramped := lb.ramp.endpointFor(identifier, endpoint)
+	if _, found := lb.exporters[endpointWithPort(ramped)]; !found {
+		return endpoint
+	}
+	return ramped
+}
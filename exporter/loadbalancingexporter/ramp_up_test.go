@@ -0,0 +1,128 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+)
+
+func newRampUpLoadBalancer(t *testing.T, endpoints *[]string) *loadBalancer {
+	cfg := simpleConfig()
+	cfg.RampUpDuration = time.Hour
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	})
+	require.NoError(t, err)
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return *endpoints, nil
+		},
+	}
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	})
+	return lb
+}
+
+func TestRampUpNewEndpoint(t *testing.T) {
+	// prepare
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	lb := newRampUpLoadBalancer(t, &endpoints)
+	require.Nil(t, lb.ramp)
+	previous := lb.ring
+
+	// test
+	endpoints = []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+	_, err := lb.res.resolve(context.Background())
+	require.NoError(t, err)
+
+	// verify
+	require.NotNil(t, lb.ramp)
+	assert.Equal(t, []string{"endpoint-3"}, lb.ramp.added)
+
+	// halfway through the ramp, the identifiers in the first half of the ramp moved to the new endpoint
+	lb.ramp.start = time.Now().Add(-30 * time.Minute)
+	moved, kept := 0, 0
+	for i := 0; i < 1000; i++ {
+		identifier := []byte(fmt.Sprintf("key-%d", i))
+		if lb.ring.endpointFor(identifier) != "endpoint-3" {
+			assert.Equal(t, lb.ring.endpointFor(identifier), lb.endpointFor(identifier))
+			continue
+		}
+		switch position := rampPosition(identifier); {
+		case position < 0.49:
+			assert.Equal(t, "endpoint-3", lb.endpointFor(identifier))
+			moved++
+		case position > 0.51:
+			assert.Equal(t, previous.endpointFor(identifier), lb.endpointFor(identifier))
+			kept++
+		}
+	}
+	assert.NotZero(t, moved)
+	assert.NotZero(t, kept)
+
+	// once the ramp is over, all the identifiers are routed with the new ring
+	lb.ramp.start = time.Now().Add(-2 * time.Hour)
+	lb.endRampUp()
+	assert.Nil(t, lb.ramp)
+	for i := 0; i < 1000; i++ {
+		identifier := []byte(fmt.Sprintf("key-%d", i))
+		assert.Equal(t, lb.ring.endpointFor(identifier), lb.endpointFor(identifier))
+	}
+}
+
+func TestRampUpNotForFirstEndpoints(t *testing.T) {
+	// prepare
+	endpoints := []string{}
+	lb := newRampUpLoadBalancer(t, &endpoints)
+
+	// test
+	endpoints = []string{"endpoint-1", "endpoint-2"}
+	_, err := lb.res.resolve(context.Background())
+	require.NoError(t, err)
+
+	// verify
+	assert.Nil(t, lb.ramp)
+}
+
+func TestRampUpRemovedPreviousEndpoint(t *testing.T) {
+	// prepare
+	endpoints := []string{"endpoint-1"}
+	lb := newRampUpLoadBalancer(t, &endpoints)
+
+	// test
+	endpoints = []string{"endpoint-2"}
+	_, err := lb.res.resolve(context.Background())
+	require.NoError(t, err)
+
+	// verify
+	require.NotNil(t, lb.ramp)
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, "endpoint-2", lb.endpointFor([]byte(fmt.Sprintf("key-%d", i))))
+	}
+}
+
+func TestRampPosition(t *testing.T) {
+	below := 0
+	for i := 0; i < 10000; i++ {
+		position := rampPosition([]byte(fmt.Sprintf("key-%d", i)))
+		require.GreaterOrEqual(t, position, 0.0)
+		require.LessOrEqual(t, position, 1.0)
+		if position < 0.5 {
+			below++
+		}
+	}
+	assert.InDelta(t, 5000, below, 200)
+}
@@ -0,0 +1,94 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// rateLimitWait delays the sends going above the rate until enough items are available
+	rateLimitWait = "wait"
+
+	// rateLimitReject fails the sends going above the rate right away
+	rateLimitReject = "reject"
+)
+
+// errRateLimited is returned for the data rejected by the rate limit of its endpoint
+var errRateLimited = errors.New("the rate limit of the endpoint is exceeded")
+
+// rateLimiter limits the rate of the spans, data points or log records sent to an endpoint with a token bucket.
+type rateLimiter struct {
+	limiter *rate.Limiter
+	reject  bool
+}
+
+// validateRateLimit checks that the rate limit settings, when set, can be applied.
+func validateRateLimit(cfg *Config) error {
+	if cfg.RateLimit == nil {
+		return nil
+	}
+	if cfg.RateLimit.ItemsPerSecond <= 0 {
+		return errors.New("invalid rate limit items_per_second, it must be positive")
+	}
+	if cfg.RateLimit.Burst < 0 {
+		return errors.New("invalid rate limit burst, it must be positive")
+	}
+	switch cfg.RateLimit.Policy {
+	case "", rateLimitWait, rateLimitReject:
+		return nil
+	default:
+		return fmt.Errorf("unsupported rate limit policy %q", cfg.RateLimit.Policy)
+	}
+}
+
+// newRateLimiter returns the rate limiter of an endpoint, or nil when no rate limit is configured.
+func newRateLimiter(cfg *RateLimitSettings) *rateLimiter {
+	if cfg == nil {
+		return nil
+	}
+	burst := cfg.Burst
+	if burst == 0 {
+		burst = int(cfg.ItemsPerSecond)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		limiter: rate.NewLimiter(rate.Limit(cfg.ItemsPerSecond), burst),
+		reject:  cfg.Policy == rateLimitReject,
+	}
+}
+
+// wait takes the given number of items from the bucket, waiting until they're available unless the data is to be
+// rejected, in which case errRateLimited is returned. The batches larger than the burst are taken in several
+// steps. A nil rate limiter never limits the data.
+func (l *rateLimiter) wait(ctx context.Context, items int) error {
+	if l == nil || items == 0 {
+		return nil
+	}
+	burst := l.limiter.Burst()
+	if l.reject {
+		if items > burst || !l.limiter.AllowN(time.Now(), items) {
+			return fmt.Errorf("%w: %d items above the rate of %v per second", errRateLimited, items, l.limiter.Limit())
+		}
+		return nil
+	}
+	for items > 0 {
+		n := items
+		if n > burst {
+			n = burst
+		}
+		if err := l.limiter.WaitN(ctx, n); err != nil {
+			return fmt.Errorf("failed to wait for the rate limit of the endpoint: %w", err)
+		}
+		items -= n
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+)
+
+func TestRateLimiterReject(t *testing.T) {
+	// prepare
+	l := newRateLimiter(&RateLimitSettings{ItemsPerSecond: 0.001, Burst: 2, Policy: rateLimitReject})
+
+	// test
+	err := l.wait(context.Background(), 2)
+
+	// verify
+	assert.NoError(t, err)
+	assert.ErrorIs(t, l.wait(context.Background(), 1), errRateLimited)
+
+	// the batches larger than the burst can never go through
+	assert.ErrorIs(t, newRateLimiter(&RateLimitSettings{ItemsPerSecond: 1000, Burst: 2, Policy: rateLimitReject}).wait(context.Background(), 3), errRateLimited)
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	// prepare
+	l := newRateLimiter(&RateLimitSettings{ItemsPerSecond: 1000, Burst: 10})
+	start := time.Now()
+
+	// test
+	err := l.wait(context.Background(), 30)
+
+	// verify
+	// the batch larger than the burst is taken in several steps, waiting for the items above the burst
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	// prepare
+	l := newRateLimiter(&RateLimitSettings{ItemsPerSecond: 0.001, Burst: 1})
+	require.NoError(t, l.wait(context.Background(), 1))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// test
+	err := l.wait(ctx, 1)
+
+	// verify
+	assert.Error(t, err)
+
+	// a nil rate limiter never limits the data
+	var disabled *rateLimiter
+	assert.NoError(t, disabled.wait(ctx, 100))
+}
+
+func TestValidateRateLimit(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		settings *RateLimitSettings
+		err      string
+	}{
+		{"disabled", nil, ""},
+		{"valid", &RateLimitSettings{ItemsPerSecond: 10, Burst: 20, Policy: rateLimitReject}, ""},
+		{"missing rate", &RateLimitSettings{}, "invalid rate limit items_per_second"},
+		{"negative burst", &RateLimitSettings{ItemsPerSecond: 10, Burst: -1}, "invalid rate limit burst"},
+		{"unknown policy", &RateLimitSettings{ItemsPerSecond: 10, Policy: "drop"}, `unsupported rate limit policy "drop"`},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := simpleConfig()
+			cfg.RateLimit = tt.settings
+
+			// test
+			err := validateRateLimit(cfg)
+
+			// verify
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRateLimitRejectsTracesAboveTheRate(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.RateLimit = &RateLimitSettings{ItemsPerSecond: 0.001, Burst: 1, Policy: rateLimitReject}
+	sink := new(consumertest.TracesSink)
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	err = p.ConsumeTraces(context.Background(), simpleTraces())
+
+	// verify
+	assert.NoError(t, err)
+	assert.ErrorIs(t, p.ConsumeTraces(context.Background(), simpleTraces()), errRateLimited)
+	assert.Len(t, sink.AllTraces(), 1)
+}
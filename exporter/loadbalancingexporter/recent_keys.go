@@ -0,0 +1,75 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRecentKeys is the maximum number of routing identifiers in a generation of the recent keys.
+const maxRecentKeys = 100_000
+
+// recentKeys remembers the endpoint each routing identifier was last routed to. Identifiers are kept in two
+// generations, rotated once per period, so that an identifier is remembered for at least one period after it
+// was last seen, while the memory usage stays bounded by the identifiers seen in the last two periods. A
+// generation is also rotated early once it holds the maximum number of identifiers, bounding the memory usage
+// to twice that number, at the cost of forgetting the identifiers sooner under a high cardinality.
+type recentKeys struct {
+	period     time.Duration
+	maxEntries int
+
+	mu        sync.Mutex
+	rotatedAt time.Time
+	current   map[string]string
+	previous  map[string]string
+}
+
+func newRecentKeys(period time.Duration) *recentKeys {
+	return &recentKeys{
+		period:     period,
+		maxEntries: maxRecentKeys,
+		rotatedAt:  time.Now(),
+		current:    map[string]string{},
+		previous:   map[string]string{},
+	}
+}
+
+// get returns the endpoint the given identifier was last routed to, if it was seen recently.
+func (k *recentKeys) get(identifier []byte) (string, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.rotate()
+	if endpoint, ok := k.current[string(identifier)]; ok {
+		return endpoint, true
+	}
+	endpoint, ok := k.previous[string(identifier)]
+	return endpoint, ok
+}
+
+// record remembers that the given identifier has been routed to the endpoint.
+func (k *recentKeys) record(identifier []byte, endpoint string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.rotate()
+	if _, ok := k.current[string(identifier)]; !ok && len(k.current) >= k.maxEntries {
+		k.rotateNow()
+	}
+	k.current[string(identifier)] = endpoint
+}
+
+func (k *recentKeys) rotate() {
+	if time.Since(k.rotatedAt) < k.period {
+		return
+	}
+	k.rotateNow()
+}
+
+func (k *recentKeys) rotateNow() {
+	k.previous = k.current
+	k.current = map[string]string{}
+	k.rotatedAt = time.Now()
+}
@@ -0,0 +1,73 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentKeys(t *testing.T) {
+	// prepare
+	keys := newRecentKeys(time.Hour)
+
+	// test
+	_, found := keys.get([]byte("key-1"))
+	keys.record([]byte("key-1"), "endpoint-1")
+
+	// verify
+	assert.False(t, found)
+	endpoint, found := keys.get([]byte("key-1"))
+	assert.True(t, found)
+	assert.Equal(t, "endpoint-1", endpoint)
+}
+
+func TestRecentKeysRotation(t *testing.T) {
+	// prepare
+	keys := newRecentKeys(time.Hour)
+	keys.record([]byte("key-1"), "endpoint-1")
+
+	// test: after one rotation, the key is still remembered
+	keys.rotatedAt = time.Now().Add(-2 * time.Hour)
+	_, found := keys.get([]byte("key-1"))
+	assert.True(t, found)
+
+	// test: after two rotations, the key is forgotten
+	keys.rotatedAt = time.Now().Add(-2 * time.Hour)
+	_, found = keys.get([]byte("key-1"))
+	assert.False(t, found)
+}
+
+func TestRecentKeysMaxEntries(t *testing.T) {
+	// prepare
+	keys := newRecentKeys(time.Hour)
+	keys.maxEntries = 2
+	keys.record([]byte("key-1"), "endpoint-1")
+	keys.record([]byte("key-2"), "endpoint-2")
+
+	// test: the full generation is rotated early, the previous one still answering
+	keys.record([]byte("key-3"), "endpoint-3")
+
+	// verify
+	assert.Len(t, keys.current, 1)
+	assert.Len(t, keys.previous, 2)
+	_, found := keys.get([]byte("key-1"))
+	assert.True(t, found)
+
+	// test: recording a known identifier doesn't rotate
+	keys.record([]byte("key-3"), "endpoint-1")
+	keys.record([]byte("key-4"), "endpoint-4")
+	assert.Len(t, keys.current, 2)
+
+	// test: the next rotation forgets the oldest identifiers
+	keys.record([]byte("key-5"), "endpoint-5")
+	assert.Len(t, keys.current, 1)
+	_, found = keys.get([]byte("key-1"))
+	assert.False(t, found)
+	endpoint, found := keys.get([]byte("key-4"))
+	assert.True(t, found)
+	assert.Equal(t, "endpoint-4", endpoint)
+}
@@ -0,0 +1,124 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+var _ ring = (*rendezvousRing)(nil)
+
+// rendezvousRing implements the highest random weight (rendezvous) hashing: each identifier is routed to the
+// endpoint with the highest score for it, computed from the identifier and the endpoint. There's no precomputed
+// ring, and removing an endpoint moves only the identifiers that were routed to it.
+type rendezvousRing struct {
+	// members holds the sorted endpoints, and seeds the hash of each of them
+	members []string
+	seeds   []uint64
+
+	// draining holds the endpoints that were removed but still accept the identifiers already routed to them
+	draining map[string]bool
+}
+
+// newRendezvousRing builds a new immutable rendezvous ring based on the given endpoints.
+func newRendezvousRing(endpoints []string, draining []string) *rendezvousRing {
+	members := make([]string, len(endpoints))
+	copy(members, endpoints)
+	sort.Strings(members)
+
+	r := &rendezvousRing{
+		members: members,
+		seeds:   make([]uint64, len(members)),
+	}
+	for i, member := range members {
+		r.seeds[i] = hash64([]byte(member))
+	}
+	if len(draining) > 0 {
+		r.draining = make(map[string]bool, len(draining))
+		for _, endpoint := range draining {
+			r.draining[endpoint] = true
+		}
+	}
+	return r
+}
+
+func (r *rendezvousRing) endpointFor(identifier []byte) string {
+	if r == nil || len(r.members) == 0 {
+		return ""
+	}
+
+	key := hash64(identifier)
+	best, bestScore := 0, uint64(0)
+	for i, seed := range r.seeds {
+		// the members are sorted, so ties are resolved in the same way by all rings with the same members
+		if score := mix64(key ^ seed); i == 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return r.members[best]
+}
+
+func (r *rendezvousRing) endpointForKnown(identifier []byte, previous string) string {
+	if r != nil && r.draining[previous] {
+		return previous
+	}
+	return r.endpointFor(identifier)
+}
+
+func (r *rendezvousRing) equal(candidate ring) bool {
+	other, ok := candidate.(*rendezvousRing)
+	if !ok || other == nil {
+		return false
+	}
+
+	if len(r.members) != len(other.members) {
+		return false
+	}
+	for i := range r.members {
+		if r.members[i] != other.members[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *rendezvousRing) endpoints() []string {
+	var endpoints []string
+	for i, member := range r.members {
+		if i == 0 || member != r.members[i-1] {
+			endpoints = append(endpoints, member)
+		}
+	}
+	return endpoints
+}
+
+func (r *rendezvousRing) fingerprint() string {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(rendezvousHashStrategy))
+	for _, member := range r.members {
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(member))
+	}
+	return fmt.Sprintf("%016x", hasher.Sum64())
+}
+
+// hash64 returns the 64-bit FNV-1a hash of the given data.
+func hash64(data []byte) uint64 {
+	hasher := fnv.New64a()
+	hasher.Write(data)
+	return hasher.Sum64()
+}
+
+// mix64 scrambles the bits of the given value, following the finalizer of the SplitMix64 generator, so that
+// similar inputs result in unrelated scores.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
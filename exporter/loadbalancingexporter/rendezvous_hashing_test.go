@@ -0,0 +1,124 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRendezvousRingDeterministic(t *testing.T) {
+	// prepare
+	r1 := newRendezvousRing([]string{"endpoint-1", "endpoint-2", "endpoint-3"}, nil)
+	r2 := newRendezvousRing([]string{"endpoint-3", "endpoint-1", "endpoint-2"}, nil)
+
+	// test and verify
+	assert.True(t, r1.equal(r2))
+	assert.Equal(t, r1.fingerprint(), r2.fingerprint())
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		assert.Equal(t, r1.endpointFor(key), r2.endpointFor(key))
+	}
+}
+
+func TestRendezvousRingRemovalMovesOnlyRemovedKeys(t *testing.T) {
+	// prepare
+	before := newRendezvousRing([]string{"endpoint-1", "endpoint-2", "endpoint-3"}, nil)
+	after := newRendezvousRing([]string{"endpoint-1", "endpoint-3"}, nil)
+
+	// test and verify
+	for i := 0; i < 1000; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		if endpoint := before.endpointFor(key); endpoint != "endpoint-2" {
+			assert.Equal(t, endpoint, after.endpointFor(key))
+		}
+	}
+}
+
+func TestRendezvousRingDraining(t *testing.T) {
+	// prepare
+	r := newRendezvousRing([]string{"endpoint-1"}, []string{"endpoint-2"})
+
+	// test and verify
+	assert.Equal(t, []string{"endpoint-1"}, r.endpoints())
+	assert.Equal(t, "endpoint-1", r.endpointFor([]byte("key")))
+	assert.Equal(t, "endpoint-2", r.endpointForKnown([]byte("key"), "endpoint-2"))
+	assert.Equal(t, "endpoint-1", r.endpointForKnown([]byte("key"), "endpoint-3"))
+}
+
+func TestRendezvousRingEmpty(t *testing.T) {
+	var r *rendezvousRing
+	assert.Equal(t, "", r.endpointFor([]byte("key")))
+	assert.Equal(t, "", newRendezvousRing(nil, nil).endpointFor([]byte("key")))
+}
+
+func TestRendezvousRingNotEqualToHashRing(t *testing.T) {
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	assert.False(t, newRendezvousRing(endpoints, nil).equal(newHashRing(endpoints)))
+	assert.False(t, newHashRing(endpoints).equal(newRendezvousRing(endpoints, nil)))
+}
+
+func TestRendezvousDistributesMoreEvenlyForSmallFleets(t *testing.T) {
+	endpoints := []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+
+	// imbalance returns how far, relative to the mean, the busiest endpoint is
+	imbalance := func(r ring) float64 {
+		counts := map[string]int{}
+		keys := 30000
+		for i := 0; i < keys; i++ {
+			counts[r.endpointFor([]byte(fmt.Sprintf("key-%d", i)))]++
+		}
+		require.Len(t, counts, len(endpoints))
+
+		mean := float64(keys) / float64(len(endpoints))
+		maxCount := 0
+		for _, count := range counts {
+			if count > maxCount {
+				maxCount = count
+			}
+		}
+		return float64(maxCount)/mean - 1
+	}
+
+	consistent := imbalance(newHashRing(endpoints))
+	rendezvous := imbalance(newRendezvousRing(endpoints, nil))
+	assert.Less(t, rendezvous, consistent)
+	assert.Less(t, rendezvous, 0.02)
+}
+
+func TestNewRingBuilder(t *testing.T) {
+	for _, tt := range []struct {
+		strategy string
+		expected ring
+	}{
+		{"", &hashRing{}},
+		{consistentHashStrategy, &hashRing{}},
+		{rendezvousHashStrategy, &rendezvousRing{}},
+		{maglevHashStrategy, &maglevRing{}},
+		{weightedRoundRobinStrategy, &weightedRoundRobinRing{}},
+	} {
+		t.Run(tt.strategy, func(t *testing.T) {
+			builder, err := newRingBuilder(&Config{HashStrategy: tt.strategy})
+			require.NoError(t, err)
+			assert.IsType(t, tt.expected, builder([]string{"endpoint-1"}, nil))
+		})
+	}
+
+	_, err := newRingBuilder(&Config{HashStrategy: "round-robin"})
+	assert.EqualError(t, err, `unsupported hash_strategy: "round-robin"`)
+
+	_, err = newRingBuilder(&Config{HashFunction: "md5"})
+	assert.EqualError(t, err, `unsupported hash_function: "md5"`)
+
+	_, err = newRingBuilder(&Config{VirtualNodes: -1})
+	assert.EqualError(t, err, "invalid virtual_nodes -1, it must be positive")
+
+	// the default options build the same rings as when they're explicitly set
+	builder, err := newRingBuilder(&Config{VirtualNodes: defaultWeight, HashFunction: crc32HashFunction})
+	require.NoError(t, err)
+	assert.Equal(t, newHashRing([]string{"endpoint-1"}), builder([]string{"endpoint-1"}, nil))
+}
@@ -3,7 +3,11 @@
 
 package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
 
-import "context"
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+)
 
 // resolver determines the contract for sources of backend endpoint information
 type resolver interface {
@@ -23,3 +27,9 @@ type resolver interface {
 	// Make sure to register the callbacks before starting the exporter.
 	onChange(func([]string))
 }
+
+// hostAwareResolver is implemented by the resolvers needing the host before being started, such as to find the
+// authenticators they use.
+type hostAwareResolver interface {
+	setHost(component.Host)
+}
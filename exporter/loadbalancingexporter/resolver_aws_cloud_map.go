@@ -0,0 +1,234 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"go.uber.org/zap"
+)
+
+var _ resolver = (*cloudMapResolver)(nil)
+
+const (
+	// the attributes Cloud Map sets on the instances registered with their IP addresses and port
+	cloudMapInstanceIPv4Attribute = "AWS_INSTANCE_IPV4"
+	cloudMapInstanceIPv6Attribute = "AWS_INSTANCE_IPV6"
+	cloudMapInstancePortAttribute = "AWS_INSTANCE_PORT"
+
+	// cloudMapMaxResults is the maximum number of instances a single discovery returns
+	cloudMapMaxResults = 1000
+)
+
+var (
+	errNoCloudMapNamespace         = errors.New("no namespace specified for the aws_cloud_map resolver")
+	errNoCloudMapServiceName       = errors.New("no service name specified for the aws_cloud_map resolver")
+	errInvalidCloudMapHealthStatus = fmt.Errorf("health_status must be one of %v", servicediscovery.HealthStatusFilter_Values())
+)
+
+// cloudMapDiscoverer is the part of the Cloud Map API used by the resolver.
+type cloudMapDiscoverer interface {
+	DiscoverInstancesWithContext(ctx aws.Context, input *servicediscovery.DiscoverInstancesInput, opts ...request.Option) (*servicediscovery.DiscoverInstancesOutput, error)
+}
+
+// cloudMapResolver periodically discovers the instances of a service registered in AWS Cloud Map.
+type cloudMapResolver struct {
+	logger    *zap.Logger
+	telemetry *telemetry
+
+	namespaceName string
+	serviceName   string
+	healthStatus  string
+	port          *uint16
+	discoverer    cloudMapDiscoverer
+	resInterval   time.Duration
+	resTimeout    time.Duration
+
+	endpoints         []string
+	onChangeCallbacks []func([]string)
+
+	stopCh             chan struct{}
+	updateLock         sync.Mutex
+	shutdownWg         sync.WaitGroup
+	changeCallbackLock sync.RWMutex
+}
+
+func newCloudMapResolver(logger *zap.Logger, cfg *AWSCloudMapResolver, tb *telemetry) (*cloudMapResolver, error) {
+	if cfg.NamespaceName == "" {
+		return nil, errNoCloudMapNamespace
+	}
+	if cfg.ServiceName == "" {
+		return nil, errNoCloudMapServiceName
+	}
+	healthStatus := cfg.HealthStatus
+	if healthStatus == "" {
+		healthStatus = servicediscovery.HealthStatusFilterHealthy
+	}
+	if !endpointFound(healthStatus, servicediscovery.HealthStatusFilter_Values()) {
+		return nil, errInvalidCloudMapHealthStatus
+	}
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = defaultResInterval
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultResTimeout
+	}
+
+	awsCfg := aws.Config{}
+	if cfg.Region != "" {
+		awsCfg.Region = aws.String(cfg.Region)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            awsCfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &cloudMapResolver{
+		logger:        logger,
+		telemetry:     tb,
+		namespaceName: cfg.NamespaceName,
+		serviceName:   cfg.ServiceName,
+		healthStatus:  healthStatus,
+		port:          cfg.Port,
+		discoverer:    servicediscovery.New(sess),
+		resInterval:   interval,
+		resTimeout:    timeout,
+		stopCh:        make(chan struct{}),
+	}, nil
+}
+
+func (r *cloudMapResolver) start(ctx context.Context) error {
+	if _, err := r.resolve(ctx); err != nil {
+		r.logger.Warn("failed to resolve", zap.Error(err))
+	}
+
+	go r.periodicallyResolve()
+
+	r.logger.Debug("AWS Cloud Map resolver started",
+		zap.String("namespace", r.namespaceName), zap.String("service_name", r.serviceName),
+		zap.String("health_status", r.healthStatus), zap.Duration("interval", r.resInterval), zap.Duration("timeout", r.resTimeout))
+	return nil
+}
+
+func (r *cloudMapResolver) shutdown(_ context.Context) error {
+	r.changeCallbackLock.Lock()
+	r.onChangeCallbacks = nil
+	r.changeCallbackLock.Unlock()
+
+	close(r.stopCh)
+	r.shutdownWg.Wait()
+	return nil
+}
+
+func (r *cloudMapResolver) periodicallyResolve() {
+	ticker := time.NewTicker(r.resInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), r.resTimeout)
+			if _, err := r.resolve(ctx); err != nil {
+				r.logger.Warn("failed to resolve", zap.Error(err))
+			} else {
+				r.logger.Debug("resolved successfully")
+			}
+			cancel()
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+func (r *cloudMapResolver) resolve(ctx context.Context) ([]string, error) {
+	r.shutdownWg.Add(1)
+	defer r.shutdownWg.Done()
+
+	out, err := r.discoverer.DiscoverInstancesWithContext(ctx, &servicediscovery.DiscoverInstancesInput{
+		NamespaceName: aws.String(r.namespaceName),
+		ServiceName:   aws.String(r.serviceName),
+		HealthStatus:  aws.String(r.healthStatus),
+		MaxResults:    aws.Int64(cloudMapMaxResults),
+	})
+	if err != nil {
+		r.telemetry.recordResolution(ctx, "aws_cloud_map", false)
+		return nil, err
+	}
+
+	r.telemetry.recordResolution(ctx, "aws_cloud_map", true)
+
+	backends := make([]string, 0, len(out.Instances))
+	for _, instance := range out.Instances {
+		if backend, ok := r.backendFor(instance); ok {
+			backends = append(backends, backend)
+		}
+	}
+
+	// keep it always in the same order
+	sort.Strings(backends)
+
+	if equalStringSlice(r.endpoints, backends) {
+		return r.endpoints, nil
+	}
+
+	// the list has changed!
+	r.updateLock.Lock()
+	r.endpoints = backends
+	r.updateLock.Unlock()
+	r.telemetry.recordBackends(ctx, "aws_cloud_map", len(backends))
+
+	// propagate the change
+	r.changeCallbackLock.RLock()
+	for _, callback := range r.onChangeCallbacks {
+		callback(r.endpoints)
+	}
+	r.changeCallbackLock.RUnlock()
+
+	return r.endpoints, nil
+}
+
+// backendFor returns the endpoint of the instance, from its IP address and either the configured port or the
+// port it was registered with. The default port is used when there's none. Instances without an IP address are
+// skipped.
+func (r *cloudMapResolver) backendFor(instance *servicediscovery.HttpInstanceSummary) (string, bool) {
+	address := aws.StringValue(instance.Attributes[cloudMapInstanceIPv4Attribute])
+	if address == "" {
+		address = aws.StringValue(instance.Attributes[cloudMapInstanceIPv6Attribute])
+	}
+	if address == "" {
+		r.logger.Debug("skipping the instance without an IP address", zap.String("instance", aws.StringValue(instance.InstanceId)))
+		return "", false
+	}
+
+	port := aws.StringValue(instance.Attributes[cloudMapInstancePortAttribute])
+	if r.port != nil {
+		port = strconv.Itoa(int(*r.port))
+	}
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort(address, port), true
+}
+
+func (r *cloudMapResolver) onChange(f func([]string)) {
+	r.changeCallbackLock.Lock()
+	defer r.changeCallbackLock.Unlock()
+	r.onChangeCallbacks = append(r.onChangeCallbacks, f)
+}
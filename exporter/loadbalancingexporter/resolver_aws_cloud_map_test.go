@@ -0,0 +1,194 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/servicediscovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+)
+
+func cloudMapInstance(id string, attributes map[string]string) *servicediscovery.HttpInstanceSummary {
+	return &servicediscovery.HttpInstanceSummary{
+		InstanceId: aws.String(id),
+		Attributes: aws.StringMap(attributes),
+	}
+}
+
+func newTestCloudMapResolver(t *testing.T, cfg *AWSCloudMapResolver, discover func(*servicediscovery.DiscoverInstancesInput) ([]*servicediscovery.HttpInstanceSummary, error)) *cloudMapResolver {
+	res, err := newCloudMapResolver(zap.NewNop(), cfg, nil)
+	require.NoError(t, err)
+	res.discoverer = &mockCloudMapDiscoverer{onDiscoverInstances: discover}
+	return res
+}
+
+func TestInitialCloudMapResolution(t *testing.T) {
+	// prepare
+	var input *servicediscovery.DiscoverInstancesInput
+	res := newTestCloudMapResolver(t, &AWSCloudMapResolver{NamespaceName: "otel", ServiceName: "gateways"},
+		func(in *servicediscovery.DiscoverInstancesInput) ([]*servicediscovery.HttpInstanceSummary, error) {
+			input = in
+			return []*servicediscovery.HttpInstanceSummary{
+				cloudMapInstance("task-2", map[string]string{cloudMapInstanceIPv4Attribute: "10.0.0.2", cloudMapInstancePortAttribute: "55690"}),
+				cloudMapInstance("task-1", map[string]string{cloudMapInstanceIPv4Attribute: "10.0.0.1"}),
+				cloudMapInstance("task-3", map[string]string{cloudMapInstanceIPv6Attribute: "::1", cloudMapInstancePortAttribute: "55690"}),
+				cloudMapInstance("task-4", map[string]string{"CUSTOM": "no address"}),
+			}, nil
+		})
+
+	// test
+	var resolved []string
+	res.onChange(func(endpoints []string) {
+		resolved = endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, []string{"10.0.0.1:4317", "10.0.0.2:55690", "[::1]:55690"}, resolved)
+	assert.Equal(t, "otel", aws.StringValue(input.NamespaceName))
+	assert.Equal(t, "gateways", aws.StringValue(input.ServiceName))
+	assert.Equal(t, servicediscovery.HealthStatusFilterHealthy, aws.StringValue(input.HealthStatus))
+}
+
+func TestCloudMapResolutionWithPortAndHealthStatus(t *testing.T) {
+	// prepare
+	port := uint16(4318)
+	var input *servicediscovery.DiscoverInstancesInput
+	res := newTestCloudMapResolver(t, &AWSCloudMapResolver{
+		NamespaceName: "otel",
+		ServiceName:   "gateways",
+		HealthStatus:  servicediscovery.HealthStatusFilterHealthyOrElseAll,
+		Port:          &port,
+	}, func(in *servicediscovery.DiscoverInstancesInput) ([]*servicediscovery.HttpInstanceSummary, error) {
+		input = in
+		return []*servicediscovery.HttpInstanceSummary{
+			cloudMapInstance("task-1", map[string]string{cloudMapInstanceIPv4Attribute: "10.0.0.1", cloudMapInstancePortAttribute: "55690"}),
+		}, nil
+	})
+
+	// test
+	resolved, err := res.resolve(context.Background())
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:4318"}, resolved)
+	assert.Equal(t, servicediscovery.HealthStatusFilterHealthyOrElseAll, aws.StringValue(input.HealthStatus))
+}
+
+func TestCloudMapPeriodicallyResolve(t *testing.T) {
+	// prepare
+	var lock sync.Mutex
+	instances := []*servicediscovery.HttpInstanceSummary{
+		cloudMapInstance("task-1", map[string]string{cloudMapInstanceIPv4Attribute: "10.0.0.1"}),
+	}
+	res := newTestCloudMapResolver(t, &AWSCloudMapResolver{
+		NamespaceName: "otel",
+		ServiceName:   "gateways",
+		Interval:      10 * time.Millisecond,
+	}, func(*servicediscovery.DiscoverInstancesInput) ([]*servicediscovery.HttpInstanceSummary, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return instances, nil
+	})
+	resolved := make(chan []string, 10)
+	res.onChange(func(endpoints []string) {
+		resolved <- endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+	assert.Equal(t, []string{"10.0.0.1:4317"}, <-resolved)
+
+	// test
+	lock.Lock()
+	instances = append(instances, cloudMapInstance("task-2", map[string]string{cloudMapInstanceIPv4Attribute: "10.0.0.2"}))
+	lock.Unlock()
+
+	// verify
+	select {
+	case endpoints := <-resolved:
+		assert.Equal(t, []string{"10.0.0.1:4317", "10.0.0.2:4317"}, endpoints)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the new instance wasn't discovered")
+	}
+}
+
+func TestCloudMapCantResolve(t *testing.T) {
+	// prepare
+	expectedErr := errors.New("some expected error")
+	res := newTestCloudMapResolver(t, &AWSCloudMapResolver{NamespaceName: "otel", ServiceName: "gateways"},
+		func(*servicediscovery.DiscoverInstancesInput) ([]*servicediscovery.HttpInstanceSummary, error) {
+			return nil, expectedErr
+		})
+
+	// test
+	_, err := res.resolve(context.Background())
+
+	// verify
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestCloudMapInvalidConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cfg  *AWSCloudMapResolver
+		err  error
+	}{
+		{
+			name: "no namespace",
+			cfg:  &AWSCloudMapResolver{ServiceName: "gateways"},
+			err:  errNoCloudMapNamespace,
+		},
+		{
+			name: "no service name",
+			cfg:  &AWSCloudMapResolver{NamespaceName: "otel"},
+			err:  errNoCloudMapServiceName,
+		},
+		{
+			name: "invalid health status",
+			cfg:  &AWSCloudMapResolver{NamespaceName: "otel", ServiceName: "gateways", HealthStatus: "GREEN"},
+			err:  errInvalidCloudMapHealthStatus,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := newCloudMapResolver(zap.NewNop(), tt.cfg, nil)
+			assert.Equal(t, tt.err, err)
+			assert.Nil(t, res)
+		})
+	}
+}
+
+func TestCloudMapResolverExclusive(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.Resolver.AWSCloudMap = &AWSCloudMapResolver{NamespaceName: "otel", ServiceName: "gateways"}
+
+	_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errMultipleResolversProvided, err)
+}
+
+type mockCloudMapDiscoverer struct {
+	onDiscoverInstances func(*servicediscovery.DiscoverInstancesInput) ([]*servicediscovery.HttpInstanceSummary, error)
+}
+
+func (m *mockCloudMapDiscoverer) DiscoverInstancesWithContext(_ aws.Context, input *servicediscovery.DiscoverInstancesInput, _ ...request.Option) (*servicediscovery.DiscoverInstancesOutput, error) {
+	instances, err := m.onDiscoverInstances(input)
+	if err != nil {
+		return nil, err
+	}
+	return &servicediscovery.DiscoverInstancesOutput{Instances: instances}, nil
+}
@@ -0,0 +1,273 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"go.uber.org/zap"
+)
+
+var _ resolver = (*ecsResolver)(nil)
+
+const (
+	// the type and detail of the task attachment holding the private IP address of tasks using the awsvpc network mode
+	ecsNetworkInterfaceAttachment = "ElasticNetworkInterface"
+	ecsPrivateIPv4Detail          = "privateIPv4Address"
+
+	// ecsMaxDescribedTasks is the maximum number of tasks a single call to DescribeTasks accepts
+	ecsMaxDescribedTasks = 100
+)
+
+var (
+	errNoECSCluster     = errors.New("no cluster specified for the aws_ecs resolver")
+	errNoECSServiceName = errors.New("no service name specified for the aws_ecs resolver")
+)
+
+// ecsTasksAPI is the part of the ECS API used by the resolver.
+type ecsTasksAPI interface {
+	ListTasksPagesWithContext(ctx aws.Context, input *ecs.ListTasksInput, fn func(*ecs.ListTasksOutput, bool) bool, opts ...request.Option) error
+	DescribeTasksWithContext(ctx aws.Context, input *ecs.DescribeTasksInput, opts ...request.Option) (*ecs.DescribeTasksOutput, error)
+}
+
+// ecsResolver periodically lists the running tasks of an ECS service, using their private IP addresses along with
+// the configured container port.
+type ecsResolver struct {
+	logger    *zap.Logger
+	telemetry *telemetry
+
+	cluster     string
+	serviceName string
+	port        string
+	client      ecsTasksAPI
+	resInterval time.Duration
+	resTimeout  time.Duration
+
+	endpoints         []string
+	onChangeCallbacks []func([]string)
+
+	stopCh             chan struct{}
+	updateLock         sync.Mutex
+	shutdownWg         sync.WaitGroup
+	changeCallbackLock sync.RWMutex
+}
+
+func newECSResolver(logger *zap.Logger, cfg *AWSECSResolver, tb *telemetry) (*ecsResolver, error) {
+	if cfg.Cluster == "" {
+		return nil, errNoECSCluster
+	}
+	if cfg.ServiceName == "" {
+		return nil, errNoECSServiceName
+	}
+	port := defaultPort
+	if cfg.Port != 0 {
+		port = strconv.Itoa(int(cfg.Port))
+	}
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = defaultResInterval
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultResTimeout
+	}
+
+	awsCfg := aws.Config{}
+	if cfg.Region != "" {
+		awsCfg.Region = aws.String(cfg.Region)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            awsCfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ecsResolver{
+		logger:      logger,
+		telemetry:   tb,
+		cluster:     cfg.Cluster,
+		serviceName: cfg.ServiceName,
+		port:        port,
+		client:      ecs.New(sess),
+		resInterval: interval,
+		resTimeout:  timeout,
+		stopCh:      make(chan struct{}),
+	}, nil
+}
+
+func (r *ecsResolver) start(ctx context.Context) error {
+	if _, err := r.resolve(ctx); err != nil {
+		r.logger.Warn("failed to resolve", zap.Error(err))
+	}
+
+	go r.periodicallyResolve()
+
+	r.logger.Debug("AWS ECS resolver started",
+		zap.String("cluster", r.cluster), zap.String("service_name", r.serviceName), zap.String("port", r.port),
+		zap.Duration("interval", r.resInterval), zap.Duration("timeout", r.resTimeout))
+	return nil
+}
+
+func (r *ecsResolver) shutdown(_ context.Context) error {
+	r.changeCallbackLock.Lock()
+	r.onChangeCallbacks = nil
+	r.changeCallbackLock.Unlock()
+
+	close(r.stopCh)
+	r.shutdownWg.Wait()
+	return nil
+}
+
+func (r *ecsResolver) periodicallyResolve() {
+	ticker := time.NewTicker(r.resInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), r.resTimeout)
+			if _, err := r.resolve(ctx); err != nil {
+				r.logger.Warn("failed to resolve", zap.Error(err))
+			} else {
+				r.logger.Debug("resolved successfully")
+			}
+			cancel()
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+func (r *ecsResolver) resolve(ctx context.Context) ([]string, error) {
+	r.shutdownWg.Add(1)
+	defer r.shutdownWg.Done()
+
+	backends, err := r.runningTasks(ctx)
+	if err != nil {
+		r.telemetry.recordResolution(ctx, "aws_ecs", false)
+		return nil, err
+	}
+
+	r.telemetry.recordResolution(ctx, "aws_ecs", true)
+
+	// keep it always in the same order
+	sort.Strings(backends)
+
+	if equalStringSlice(r.endpoints, backends) {
+		return r.endpoints, nil
+	}
+
+	// the list has changed!
+	r.updateLock.Lock()
+	r.endpoints = backends
+	r.updateLock.Unlock()
+	r.telemetry.recordBackends(ctx, "aws_ecs", len(backends))
+
+	// propagate the change
+	r.changeCallbackLock.RLock()
+	for _, callback := range r.onChangeCallbacks {
+		callback(r.endpoints)
+	}
+	r.changeCallbackLock.RUnlock()
+
+	return r.endpoints, nil
+}
+
+// runningTasks returns the endpoints of the running tasks of the service. The tasks are listed first, and then
+// described in batches to get their IP addresses.
+func (r *ecsResolver) runningTasks(ctx context.Context) ([]string, error) {
+	var arns []*string
+	err := r.client.ListTasksPagesWithContext(ctx, &ecs.ListTasksInput{
+		Cluster:       aws.String(r.cluster),
+		ServiceName:   aws.String(r.serviceName),
+		DesiredStatus: aws.String(ecs.DesiredStatusRunning),
+	}, func(page *ecs.ListTasksOutput, _ bool) bool {
+		arns = append(arns, page.TaskArns...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	backends := make([]string, 0, len(arns))
+	for len(arns) > 0 {
+		batch := arns
+		if len(batch) > ecsMaxDescribedTasks {
+			batch = batch[:ecsMaxDescribedTasks]
+		}
+		arns = arns[len(batch):]
+
+		out, err := r.client.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(r.cluster),
+			Tasks:   batch,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range out.Tasks {
+			if backend, ok := r.backendFor(task); ok {
+				backends = append(backends, backend)
+			}
+		}
+	}
+	return backends, nil
+}
+
+// backendFor returns the endpoint of the task, if it's running and has a private IP address.
+func (r *ecsResolver) backendFor(task *ecs.Task) (string, bool) {
+	if aws.StringValue(task.LastStatus) != ecs.DesiredStatusRunning {
+		// the task is still starting, or already stopping
+		return "", false
+	}
+
+	address := taskPrivateIP(task)
+	if address == "" {
+		r.logger.Debug("skipping the task without a private IP address", zap.String("task", aws.StringValue(task.TaskArn)))
+		return "", false
+	}
+	return net.JoinHostPort(address, r.port), true
+}
+
+// taskPrivateIP returns the private IP address of the network interface of the task, as tasks using the awsvpc
+// network mode, such as the Fargate ones, get their own.
+func taskPrivateIP(task *ecs.Task) string {
+	for _, attachment := range task.Attachments {
+		if aws.StringValue(attachment.Type) != ecsNetworkInterfaceAttachment {
+			continue
+		}
+		for _, detail := range attachment.Details {
+			if aws.StringValue(detail.Name) == ecsPrivateIPv4Detail && aws.StringValue(detail.Value) != "" {
+				return aws.StringValue(detail.Value)
+			}
+		}
+	}
+
+	// the containers share the network interface of the task
+	for _, container := range task.Containers {
+		for _, networkInterface := range container.NetworkInterfaces {
+			if address := aws.StringValue(networkInterface.PrivateIpv4Address); address != "" {
+				return address
+			}
+		}
+	}
+	return ""
+}
+
+func (r *ecsResolver) onChange(f func([]string)) {
+	r.changeCallbackLock.Lock()
+	defer r.changeCallbackLock.Unlock()
+	r.onChangeCallbacks = append(r.onChangeCallbacks, f)
+}
@@ -0,0 +1,235 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+)
+
+func fargateTask(arn string, status string, ip string) *ecs.Task {
+	return &ecs.Task{
+		TaskArn:    aws.String(arn),
+		LastStatus: aws.String(status),
+		Attachments: []*ecs.Attachment{{
+			Type: aws.String(ecsNetworkInterfaceAttachment),
+			Details: []*ecs.KeyValuePair{
+				{Name: aws.String("subnetId"), Value: aws.String("subnet-1")},
+				{Name: aws.String(ecsPrivateIPv4Detail), Value: aws.String(ip)},
+			},
+		}},
+	}
+}
+
+func newTestECSResolver(t *testing.T, cfg *AWSECSResolver, tasks func() []*ecs.Task) (*ecsResolver, *mockECSTasksAPI) {
+	res, err := newECSResolver(zap.NewNop(), cfg, nil)
+	require.NoError(t, err)
+	client := &mockECSTasksAPI{tasks: tasks}
+	res.client = client
+	return res, client
+}
+
+func TestInitialECSResolution(t *testing.T) {
+	// prepare
+	res, client := newTestECSResolver(t, &AWSECSResolver{Cluster: "otel", ServiceName: "gateways"}, func() []*ecs.Task {
+		return []*ecs.Task{
+			fargateTask("task-2", ecs.DesiredStatusRunning, "10.0.0.2"),
+			fargateTask("task-1", ecs.DesiredStatusRunning, "10.0.0.1"),
+			fargateTask("task-3", ecs.DesiredStatusPending, "10.0.0.3"),
+			{
+				// a task using the awsvpc network mode, without the attachment details
+				TaskArn:    aws.String("task-4"),
+				LastStatus: aws.String(ecs.DesiredStatusRunning),
+				Containers: []*ecs.Container{{
+					NetworkInterfaces: []*ecs.NetworkInterface{{PrivateIpv4Address: aws.String("10.0.0.4")}},
+				}},
+			},
+			{
+				// a task without a private IP address, such as one using the bridge network mode
+				TaskArn:    aws.String("task-5"),
+				LastStatus: aws.String(ecs.DesiredStatusRunning),
+			},
+		}
+	})
+
+	// test
+	var resolved []string
+	res.onChange(func(endpoints []string) {
+		resolved = endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, []string{"10.0.0.1:4317", "10.0.0.2:4317", "10.0.0.4:4317"}, resolved)
+	assert.Equal(t, "otel", aws.StringValue(client.listInput.Cluster))
+	assert.Equal(t, "gateways", aws.StringValue(client.listInput.ServiceName))
+	assert.Equal(t, ecs.DesiredStatusRunning, aws.StringValue(client.listInput.DesiredStatus))
+}
+
+func TestECSResolutionInBatches(t *testing.T) {
+	// prepare
+	tasks := make([]*ecs.Task, 0, 250)
+	expected := make([]string, 0, 250)
+	for i := 0; i < 250; i++ {
+		tasks = append(tasks, fargateTask(fmt.Sprintf("task-%03d", i), ecs.DesiredStatusRunning, fmt.Sprintf("10.0.%d.%d", i/100, i%100)))
+		expected = append(expected, fmt.Sprintf("10.0.%d.%d:4318", i/100, i%100))
+	}
+	res, client := newTestECSResolver(t, &AWSECSResolver{Cluster: "otel", ServiceName: "gateways", Port: 4318}, func() []*ecs.Task {
+		return tasks
+	})
+
+	// test
+	resolved, err := res.resolve(context.Background())
+
+	// verify
+	require.NoError(t, err)
+	assert.ElementsMatch(t, expected, resolved)
+	assert.Equal(t, []int{100, 100, 50}, client.describedBatches)
+}
+
+func TestECSPeriodicallyResolve(t *testing.T) {
+	// prepare
+	var lock sync.Mutex
+	tasks := []*ecs.Task{
+		fargateTask("task-1", ecs.DesiredStatusRunning, "10.0.0.1"),
+	}
+	res, _ := newTestECSResolver(t, &AWSECSResolver{
+		Cluster:     "otel",
+		ServiceName: "gateways",
+		Interval:    10 * time.Millisecond,
+	}, func() []*ecs.Task {
+		lock.Lock()
+		defer lock.Unlock()
+		return tasks
+	})
+	resolved := make(chan []string, 10)
+	res.onChange(func(endpoints []string) {
+		resolved <- endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+	assert.Equal(t, []string{"10.0.0.1:4317"}, <-resolved)
+
+	// test
+	lock.Lock()
+	tasks = []*ecs.Task{
+		fargateTask("task-1", ecs.DesiredStatusStopped, "10.0.0.1"),
+		fargateTask("task-2", ecs.DesiredStatusRunning, "10.0.0.2"),
+	}
+	lock.Unlock()
+
+	// verify
+	select {
+	case endpoints := <-resolved:
+		assert.Equal(t, []string{"10.0.0.2:4317"}, endpoints)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the new task wasn't discovered")
+	}
+}
+
+func TestECSCantResolve(t *testing.T) {
+	// prepare
+	expectedErr := errors.New("some expected error")
+	res, client := newTestECSResolver(t, &AWSECSResolver{Cluster: "otel", ServiceName: "gateways"}, func() []*ecs.Task {
+		return []*ecs.Task{fargateTask("task-1", ecs.DesiredStatusRunning, "10.0.0.1")}
+	})
+	client.err = expectedErr
+
+	// test
+	_, err := res.resolve(context.Background())
+
+	// verify
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestECSInvalidConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cfg  *AWSECSResolver
+		err  error
+	}{
+		{
+			name: "no cluster",
+			cfg:  &AWSECSResolver{ServiceName: "gateways"},
+			err:  errNoECSCluster,
+		},
+		{
+			name: "no service name",
+			cfg:  &AWSECSResolver{Cluster: "otel"},
+			err:  errNoECSServiceName,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := newECSResolver(zap.NewNop(), tt.cfg, nil)
+			assert.Equal(t, tt.err, err)
+			assert.Nil(t, res)
+		})
+	}
+}
+
+func TestECSResolverExclusive(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.Resolver.AWSECS = &AWSECSResolver{Cluster: "otel", ServiceName: "gateways"}
+
+	_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errMultipleResolversProvided, err)
+}
+
+// mockECSTasksAPI lists the tasks in pages of 100 and describes them by their ARN.
+type mockECSTasksAPI struct {
+	tasks func() []*ecs.Task
+	err   error
+
+	listInput        *ecs.ListTasksInput
+	describedBatches []int
+}
+
+func (m *mockECSTasksAPI) ListTasksPagesWithContext(_ aws.Context, input *ecs.ListTasksInput, fn func(*ecs.ListTasksOutput, bool) bool, _ ...request.Option) error {
+	m.listInput = input
+	tasks := m.tasks()
+	for start := 0; start < len(tasks); start += 100 {
+		page := &ecs.ListTasksOutput{}
+		for i := start; i < len(tasks) && i < start+100; i++ {
+			page.TaskArns = append(page.TaskArns, tasks[i].TaskArn)
+		}
+		if !fn(page, start+100 >= len(tasks)) {
+			break
+		}
+	}
+	return nil
+}
+
+func (m *mockECSTasksAPI) DescribeTasksWithContext(_ aws.Context, input *ecs.DescribeTasksInput, _ ...request.Option) (*ecs.DescribeTasksOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.describedBatches = append(m.describedBatches, len(input.Tasks))
+
+	byArn := map[string]*ecs.Task{}
+	for _, task := range m.tasks() {
+		byArn[aws.StringValue(task.TaskArn)] = task
+	}
+	out := &ecs.DescribeTasksOutput{}
+	for _, arn := range input.Tasks {
+		out.Tasks = append(out.Tasks, byArn[aws.StringValue(arn)])
+	}
+	return out, nil
+}
@@ -0,0 +1,227 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"go.uber.org/zap"
+)
+
+var _ resolver = (*consulResolver)(nil)
+
+const (
+	defaultConsulWaitTime      = 5 * time.Minute
+	defaultConsulRetryInterval = 5 * time.Second
+)
+
+var (
+	errNoConsulService = errors.New("no service specified for the consul resolver")
+)
+
+// consulHealth is the part of the Consul health API used by the resolver.
+type consulHealth interface {
+	ServiceMultipleTags(service string, tags []string, passingOnly bool, q *api.QueryOptions) ([]*api.ServiceEntry, *api.QueryMeta, error)
+}
+
+// consulResolver watches the instances of a service in the Consul catalog with blocking queries, using only the
+// instances passing their health checks.
+type consulResolver struct {
+	logger    *zap.Logger
+	telemetry *telemetry
+
+	service       string
+	datacenter    string
+	tags          []string
+	waitTime      time.Duration
+	retryInterval time.Duration
+	health        consulHealth
+
+	// lastIndex is the Consul index of the latest result, the next blocking query waits for a change past it
+	lastIndex uint64
+
+	endpoints         []string
+	onChangeCallbacks []func([]string)
+
+	stopCh             chan struct{}
+	updateLock         sync.Mutex
+	shutdownWg         sync.WaitGroup
+	changeCallbackLock sync.RWMutex
+}
+
+func newConsulResolver(logger *zap.Logger, cfg *ConsulResolver, tb *telemetry) (*consulResolver, error) {
+	if cfg.Service == "" {
+		return nil, errNoConsulService
+	}
+
+	// the default configuration honors the usual CONSUL_* environment variables, such as the ones for TLS
+	clientCfg := api.DefaultConfig()
+	if cfg.Address != "" {
+		clientCfg.Address = cfg.Address
+	}
+	if cfg.Scheme != "" {
+		clientCfg.Scheme = cfg.Scheme
+	}
+	if cfg.Token != "" {
+		clientCfg.Token = string(cfg.Token)
+	}
+	client, err := api.NewClient(clientCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	waitTime := cfg.WaitTime
+	if waitTime == 0 {
+		waitTime = defaultConsulWaitTime
+	}
+	retryInterval := cfg.RetryInterval
+	if retryInterval == 0 {
+		retryInterval = defaultConsulRetryInterval
+	}
+
+	return &consulResolver{
+		logger:        logger,
+		telemetry:     tb,
+		service:       cfg.Service,
+		datacenter:    cfg.Datacenter,
+		tags:          cfg.Tags,
+		waitTime:      waitTime,
+		retryInterval: retryInterval,
+		health:        client.Health(),
+		stopCh:        make(chan struct{}),
+	}, nil
+}
+
+func (r *consulResolver) start(ctx context.Context) error {
+	if _, err := r.resolve(ctx); err != nil {
+		r.logger.Warn("failed to resolve", zap.Error(err))
+	}
+
+	r.shutdownWg.Add(1)
+	go r.watch()
+
+	r.logger.Debug("Consul resolver started",
+		zap.String("service", r.service), zap.String("datacenter", r.datacenter), zap.Strings("tags", r.tags))
+	return nil
+}
+
+func (r *consulResolver) shutdown(_ context.Context) error {
+	r.changeCallbackLock.Lock()
+	r.onChangeCallbacks = nil
+	r.changeCallbackLock.Unlock()
+
+	close(r.stopCh)
+	r.shutdownWg.Wait()
+	return nil
+}
+
+// watch runs blocking queries one after the other, each of them returning once the instances changed or the wait
+// time is over. After a failure, the next query is run after the retry interval.
+func (r *consulResolver) watch() {
+	defer r.shutdownWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-r.stopCh
+		cancel()
+	}()
+
+	for {
+		r.updateLock.Lock()
+		index := r.lastIndex
+		r.updateLock.Unlock()
+
+		_, err := r.query(ctx, index)
+		select {
+		case <-r.stopCh:
+			return
+		default:
+		}
+		if err == nil {
+			continue
+		}
+
+		r.logger.Warn("failed to resolve", zap.Error(err))
+		select {
+		case <-r.stopCh:
+			return
+		case <-time.After(r.retryInterval):
+		}
+	}
+}
+
+func (r *consulResolver) resolve(ctx context.Context) ([]string, error) {
+	return r.query(ctx, 0)
+}
+
+// query fetches the instances passing their health checks, waiting for a change past the given index, if any.
+func (r *consulResolver) query(ctx context.Context, index uint64) ([]string, error) {
+	r.shutdownWg.Add(1)
+	defer r.shutdownWg.Done()
+
+	opts := &api.QueryOptions{
+		Datacenter: r.datacenter,
+		WaitIndex:  index,
+		WaitTime:   r.waitTime,
+	}
+	entries, meta, err := r.health.ServiceMultipleTags(r.service, r.tags, true, opts.WithContext(ctx))
+	if err != nil {
+		r.telemetry.recordResolution(ctx, "consul", false)
+		return nil, err
+	}
+	r.telemetry.recordResolution(ctx, "consul", true)
+
+	backends := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		address := entry.Service.Address
+		if address == "" {
+			// the instance is registered with the address of its node
+			address = entry.Node.Address
+		}
+		backends = append(backends, net.JoinHostPort(address, strconv.Itoa(entry.Service.Port)))
+	}
+
+	// keep it always in the same order
+	sort.Strings(backends)
+
+	r.updateLock.Lock()
+	if meta.LastIndex < r.lastIndex {
+		// the index went backwards, such as after a restore of the Consul servers, start over
+		r.lastIndex = 0
+	} else {
+		r.lastIndex = meta.LastIndex
+	}
+	if equalStringSlice(r.endpoints, backends) {
+		r.updateLock.Unlock()
+		return backends, nil
+	}
+
+	// the list has changed!
+	r.endpoints = backends
+	r.updateLock.Unlock()
+	r.telemetry.recordBackends(ctx, "consul", len(backends))
+
+	// propagate the change
+	r.changeCallbackLock.RLock()
+	for _, callback := range r.onChangeCallbacks {
+		callback(backends)
+	}
+	r.changeCallbackLock.RUnlock()
+
+	return backends, nil
+}
+
+func (r *consulResolver) onChange(f func([]string)) {
+	r.changeCallbackLock.Lock()
+	defer r.changeCallbackLock.Unlock()
+	r.onChangeCallbacks = append(r.onChangeCallbacks, f)
+}
@@ -0,0 +1,196 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+)
+
+// mockConsul serves the health endpoint of the Consul HTTP API for a single service, answering the blocking
+// queries once the instances changed past the requested index.
+type mockConsul struct {
+	lock      sync.Mutex
+	changed   chan struct{}
+	index     uint64
+	instances []*api.ServiceEntry
+	failures  int
+	requests  []*http.Request
+}
+
+func startMockConsul(t *testing.T, instances ...*api.ServiceEntry) (*mockConsul, string) {
+	m := &mockConsul{changed: make(chan struct{}), index: 1, instances: instances}
+	srv := httptest.NewServer(http.HandlerFunc(m.handle))
+	t.Cleanup(srv.Close)
+	return m, strings.TrimPrefix(srv.URL, "http://")
+}
+
+func (m *mockConsul) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/health/service/collectors" {
+		http.NotFound(w, r)
+		return
+	}
+
+	m.lock.Lock()
+	m.requests = append(m.requests, r)
+	if m.failures > 0 {
+		m.failures--
+		m.lock.Unlock()
+		http.Error(w, "no cluster leader", http.StatusInternalServerError)
+		return
+	}
+	waitIndex, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	for waitIndex >= m.index {
+		changed := m.changed
+		m.lock.Unlock()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		m.lock.Lock()
+	}
+	index, instances := m.index, m.instances
+	m.lock.Unlock()
+
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(instances)
+}
+
+// update replaces the instances, waking up the blocking queries.
+func (m *mockConsul) update(instances ...*api.ServiceEntry) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.index++
+	m.instances = instances
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+func (m *mockConsul) firstRequest() *http.Request {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.requests[0]
+}
+
+func consulInstance(nodeAddress string, address string, port int) *api.ServiceEntry {
+	return &api.ServiceEntry{
+		Node:    &api.Node{Address: nodeAddress},
+		Service: &api.AgentService{Service: "collectors", Address: address, Port: port},
+	}
+}
+
+func newTestConsulResolver(t *testing.T, address string) *consulResolver {
+	res, err := newConsulResolver(zap.NewNop(), &ConsulResolver{
+		Address:       address,
+		Token:         "secret",
+		Service:       "collectors",
+		Datacenter:    "dc-2",
+		Tags:          []string{"tail-sampling", "v2"},
+		WaitTime:      time.Minute,
+		RetryInterval: 10 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+	return res
+}
+
+func TestConsulResolverTracksInstances(t *testing.T) {
+	// prepare
+	consul, address := startMockConsul(t,
+		consulInstance("10.0.1.1", "10.0.0.2", 4317),
+		consulInstance("10.0.1.1", "10.0.0.1", 4317),
+	)
+	res := newTestConsulResolver(t, address)
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	})
+	require.NoError(t, err)
+	lb.res = res
+
+	// test
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	assertRingEndpoints(t, lb, []string{"10.0.0.1:4317", "10.0.0.2:4317"})
+	query := consul.firstRequest()
+	assert.Equal(t, "dc-2", query.URL.Query().Get("dc"))
+	assert.Equal(t, []string{"tail-sampling", "v2"}, query.URL.Query()["tag"])
+	assert.True(t, query.URL.Query().Has("passing"))
+	assert.Equal(t, "secret", query.Header.Get("X-Consul-Token"))
+
+	// the blocking query returns as soon as the instances change
+	consul.update(
+		consulInstance("10.0.1.1", "10.0.0.2", 4317),
+		consulInstance("10.0.1.3", "", 14317),
+	)
+	assertRingEndpoints(t, lb, []string{"10.0.0.2:4317", "10.0.1.3:14317"})
+}
+
+func TestConsulResolverRetriesAfterFailure(t *testing.T) {
+	// prepare
+	consul, address := startMockConsul(t, consulInstance("10.0.1.1", "10.0.0.1", 4317))
+	consul.failures = 2
+	res := newTestConsulResolver(t, address)
+	resolved := make(chan []string, 10)
+	res.onChange(func(endpoints []string) {
+		resolved <- endpoints
+	})
+
+	// test
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	select {
+	case endpoints := <-resolved:
+		assert.Equal(t, []string{"10.0.0.1:4317"}, endpoints)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the instances weren't resolved after the failures")
+	}
+}
+
+func TestConsulResolverResolve(t *testing.T) {
+	// prepare
+	_, address := startMockConsul(t, consulInstance("10.0.1.1", "::1", 4317))
+	res := newTestConsulResolver(t, address)
+
+	// test
+	endpoints, err := res.resolve(context.Background())
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[::1]:4317"}, endpoints)
+}
+
+func TestConsulResolverInvalidConfig(t *testing.T) {
+	res, err := newConsulResolver(zap.NewNop(), &ConsulResolver{Datacenter: "dc-1"}, nil)
+	assert.Equal(t, errNoConsulService, err)
+	assert.Nil(t, res)
+
+	cfg := simpleConfig()
+	cfg.Resolver.Consul = &ConsulResolver{Service: "collectors"}
+	_, err = newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errMultipleResolversProvided, err)
+}
@@ -0,0 +1,93 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"sync"
+	"time"
+)
+
+// resolverDebouncer coalesces the changes of the endpoints made by the resolver within a window, such as during a
+// rolling update, so that the ring is rebuilt once with the latest endpoints instead of after each change. The first
+// change is applied right away, so that the ring is populated as soon as the exporter starts.
+type resolverDebouncer struct {
+	window time.Duration
+	apply  func([]string)
+
+	// lock is held while applying the changes, so that stopping waits for the change being applied
+	lock    sync.Mutex
+	timer   *time.Timer
+	pending []string
+	changed bool
+	applied bool
+	stopped bool
+}
+
+// newResolverDebouncer returns the debouncer applying the changes with the given function once the window is over,
+// or nil when the window is zero, the changes being then applied right away.
+func newResolverDebouncer(window time.Duration, apply func([]string)) *resolverDebouncer {
+	if window <= 0 {
+		return nil
+	}
+	return &resolverDebouncer{window: window, apply: apply}
+}
+
+// onChange registers the latest endpoints, starting the window when none is in progress.
+func (d *resolverDebouncer) onChange(endpoints []string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.stopped {
+		return
+	}
+	if !d.applied {
+		d.applied = true
+		d.apply(endpoints)
+		return
+	}
+	d.pending = endpoints
+	d.changed = true
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.window, d.flush)
+	}
+}
+
+// flush applies the latest endpoints registered during the window, if any, without waiting for its end. A nil
+// debouncer has nothing to apply.
+func (d *resolverDebouncer) flush() {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if d.stopped || !d.changed {
+		return
+	}
+	endpoints := d.pending
+	d.pending = nil
+	d.changed = false
+	d.apply(endpoints)
+}
+
+// stop discards the changes not applied yet, waiting for the change being applied, if any. A nil debouncer has
+// nothing to stop.
+func (d *resolverDebouncer) stop() {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.stopped = true
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.pending = nil
+}
@@ -0,0 +1,131 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+)
+
+// recordedChanges records the endpoints applied by a debouncer.
+type recordedChanges struct {
+	lock    sync.Mutex
+	applied [][]string
+}
+
+func (r *recordedChanges) apply(endpoints []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.applied = append(r.applied, endpoints)
+}
+
+func (r *recordedChanges) get() [][]string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([][]string(nil), r.applied...)
+}
+
+func TestResolverDebouncerCoalescesChanges(t *testing.T) {
+	// prepare
+	changes := &recordedChanges{}
+	d := newResolverDebouncer(20*time.Millisecond, changes.apply)
+
+	// test
+	d.onChange([]string{"endpoint-1"})
+	d.onChange([]string{"endpoint-1", "endpoint-2"})
+	d.onChange([]string{"endpoint-1", "endpoint-2", "endpoint-3"})
+
+	// verify
+	// the first change is applied right away, and the following ones once the window is over
+	assert.Equal(t, [][]string{{"endpoint-1"}}, changes.get())
+	assert.Eventually(t, func() bool {
+		return len(changes.get()) == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, [][]string{{"endpoint-1"}, {"endpoint-1", "endpoint-2", "endpoint-3"}}, changes.get())
+}
+
+func TestResolverDebouncerStop(t *testing.T) {
+	// prepare
+	changes := &recordedChanges{}
+	d := newResolverDebouncer(10*time.Millisecond, changes.apply)
+	d.onChange([]string{"endpoint-1"})
+	d.onChange([]string{"endpoint-2"})
+
+	// test
+	d.stop()
+	d.onChange([]string{"endpoint-3"})
+	d.flush()
+
+	// verify
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, [][]string{{"endpoint-1"}}, changes.get())
+}
+
+func TestResolverDebouncerDisabled(t *testing.T) {
+	// test
+	d := newResolverDebouncer(0, func([]string) {})
+
+	// verify
+	assert.Nil(t, d)
+	assert.NotPanics(t, func() {
+		d.flush()
+		d.stop()
+	})
+}
+
+func TestLoadBalancerDebouncesResolverChanges(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Debounce = time.Hour
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	}
+	p, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+
+	var lock sync.Mutex
+	endpoints := []string{"endpoint-1"}
+	res := &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return endpoints, nil
+		},
+	}
+	p.res = res
+
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+	assert.Len(t, p.exporters, 1)
+
+	// test
+	lock.Lock()
+	endpoints = []string{"endpoint-1", "endpoint-2"}
+	lock.Unlock()
+	_, err = res.resolve(context.Background())
+	require.NoError(t, err)
+
+	// verify
+	// the change waits for the end of the window...
+	p.updateLock.RLock()
+	assert.Len(t, p.exporters, 1)
+	p.updateLock.RUnlock()
+
+	// ...unless a rebalance is requested
+	require.NoError(t, p.rebalance(context.Background()))
+	p.updateLock.RLock()
+	assert.Len(t, p.exporters, 2)
+	p.updateLock.RUnlock()
+}
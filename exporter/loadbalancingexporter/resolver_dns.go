@@ -7,13 +7,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"go.opencensus.io/stats"
-	"go.opencensus.io/tag"
+	"github.com/cenkalti/backoff/v4"
 	"go.uber.org/zap"
 )
 
@@ -22,19 +24,28 @@ var _ resolver = (*dnsResolver)(nil)
 const (
 	defaultResInterval = 5 * time.Second
 	defaultResTimeout  = time.Second
-)
 
-var (
-	errNoHostname = errors.New("no hostname specified to resolve the backends")
+	ipFamilyIPv4 = "ipv4"
+	ipFamilyIPv6 = "ipv6"
+	ipFamilyDual = "dual"
+
+	defaultSRVService = "otlp"
+	defaultSRVProto   = "tcp"
 
-	resolverMutator = tag.Upsert(tag.MustNewKey("resolver"), "dns")
+	defaultBackoffMaxInterval         = time.Minute
+	defaultBackoffMultiplier          = 2
+	defaultBackoffRandomizationFactor = 0.5
+)
 
-	resolverSuccessTrueMutators  = []tag.Mutator{resolverMutator, successTrueMutator}
-	resolverSuccessFalseMutators = []tag.Mutator{resolverMutator, successFalseMutator}
+var (
+	errNoHostname      = errors.New("no hostname specified to resolve the backends")
+	errPortWithSRV     = errors.New("no port can be specified along with the SRV records, which hold the ports")
+	errIPFamilyWithSRV = errors.New("no IP family can be specified along with the SRV records, whose targets are hostnames")
 )
 
 type dnsResolver struct {
-	logger *zap.Logger
+	logger    *zap.Logger
+	telemetry *telemetry
 
 	hostname    string
 	port        string
@@ -42,6 +53,19 @@ type dnsResolver struct {
 	resInterval time.Duration
 	resTimeout  time.Duration
 
+	// ipFamily is the family of the addresses kept among the resolved ones
+	ipFamily string
+
+	// srv holds the SRV records to resolve instead of the addresses of the hostname, when set
+	srv *DNSSRVSettings
+
+	// backoff gives the time until the next resolution after a failure, when set
+	backoff *backoff.ExponentialBackOff
+
+	// maxStaleness is how long the endpoints are kept while the resolutions fail, forever when zero
+	maxStaleness time.Duration
+	lastSuccess  time.Time
+
 	endpoints         []string
 	onChangeCallbacks []func([]string)
 
@@ -53,9 +77,10 @@ type dnsResolver struct {
 
 type netResolver interface {
 	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
 }
 
-func newDNSResolver(logger *zap.Logger, hostname string, port string, interval time.Duration, timeout time.Duration) (*dnsResolver, error) {
+func newDNSResolver(logger *zap.Logger, hostname string, port string, interval time.Duration, timeout time.Duration, tb *telemetry) (*dnsResolver, error) {
 	if len(hostname) == 0 {
 		return nil, errNoHostname
 	}
@@ -68,15 +93,74 @@ func newDNSResolver(logger *zap.Logger, hostname string, port string, interval t
 
 	return &dnsResolver{
 		logger:      logger,
+		telemetry:   tb,
 		hostname:    hostname,
 		port:        port,
 		resolver:    &net.Resolver{},
 		resInterval: interval,
 		resTimeout:  timeout,
+		ipFamily:    ipFamilyDual,
 		stopCh:      make(chan struct{}),
 	}, nil
 }
 
+// useIPFamily keeps only the resolved addresses of the given family, one of ipv4, ipv6 or dual.
+func (r *dnsResolver) useIPFamily(family string) error {
+	switch family {
+	case ipFamilyIPv4, ipFamilyIPv6, ipFamilyDual:
+		r.ipFamily = family
+		return nil
+	default:
+		return fmt.Errorf("invalid IP family %q, expected one of %q, %q or %q", family, ipFamilyIPv4, ipFamilyIPv6, ipFamilyDual)
+	}
+}
+
+// useSRV makes the resolver resolve the given SRV records of the hostname instead of its addresses.
+func (r *dnsResolver) useSRV(srv *DNSSRVSettings) error {
+	if r.port != "" {
+		return errPortWithSRV
+	}
+	if r.ipFamily != ipFamilyDual {
+		return errIPFamilyWithSRV
+	}
+
+	settings := *srv
+	if settings.Service == "" {
+		settings.Service = defaultSRVService
+	}
+	if settings.Proto == "" {
+		settings.Proto = defaultSRVProto
+	}
+	r.srv = &settings
+	return nil
+}
+
+// useBackoff makes the resolver wait exponentially longer, with some jitter, between the resolutions while they keep
+// failing, starting from the resolution interval.
+func (r *dnsResolver) useBackoff(settings *DNSBackoffSettings) {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = r.resInterval
+	b.MaxInterval = settings.MaxInterval
+	if b.MaxInterval == 0 {
+		b.MaxInterval = defaultBackoffMaxInterval
+	}
+	if b.MaxInterval < b.InitialInterval {
+		b.MaxInterval = b.InitialInterval
+	}
+	b.Multiplier = settings.Multiplier
+	if b.Multiplier == 0 {
+		b.Multiplier = defaultBackoffMultiplier
+	}
+	b.RandomizationFactor = settings.RandomizationFactor
+	if b.RandomizationFactor == 0 {
+		b.RandomizationFactor = defaultBackoffRandomizationFactor
+	}
+	// the resolutions never stop
+	b.MaxElapsedTime = 0
+	b.Reset()
+	r.backoff = b
+}
+
 func (r *dnsResolver) start(ctx context.Context) error {
 	if _, err := r.resolve(ctx); err != nil {
 		r.logger.Warn("failed to resolve", zap.Error(err))
@@ -85,7 +169,7 @@ func (r *dnsResolver) start(ctx context.Context) error {
 	go r.periodicallyResolve()
 
 	r.logger.Debug("DNS resolver started",
-		zap.String("hostname", r.hostname), zap.String("port", r.port),
+		zap.String("hostname", r.hostname), zap.String("port", r.port), zap.String("ip_family", r.ipFamily),
 		zap.Duration("interval", r.resInterval), zap.Duration("timeout", r.resTimeout))
 	return nil
 }
@@ -101,53 +185,58 @@ func (r *dnsResolver) shutdown(_ context.Context) error {
 }
 
 func (r *dnsResolver) periodicallyResolve() {
-	ticker := time.NewTicker(r.resInterval)
+	timer := time.NewTimer(r.resInterval)
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
 			ctx, cancel := context.WithTimeout(context.Background(), r.resTimeout)
-			if _, err := r.resolve(ctx); err != nil {
+			_, err := r.resolve(ctx)
+			if err != nil {
 				r.logger.Warn("failed to resolve", zap.Error(err))
 			} else {
 				r.logger.Debug("resolved successfully")
 			}
 			cancel()
+			timer.Reset(r.nextResolution(err))
 		case <-r.stopCh:
 			return
 		}
 	}
 }
 
+// nextResolution returns the time until the next resolution, given the error of the last one.
+func (r *dnsResolver) nextResolution(err error) time.Duration {
+	if r.backoff == nil {
+		return r.resInterval
+	}
+	if err == nil {
+		r.backoff.Reset()
+		return r.resInterval
+	}
+	return r.backoff.NextBackOff()
+}
+
 func (r *dnsResolver) resolve(ctx context.Context) ([]string, error) {
 	r.shutdownWg.Add(1)
 	defer r.shutdownWg.Done()
 
-	addrs, err := r.resolver.LookupIPAddr(ctx, r.hostname)
+	var backends []string
+	var err error
+	if r.srv != nil {
+		backends, err = r.lookupSRV(ctx)
+	} else {
+		backends, err = r.lookupAddresses(ctx)
+	}
 	if err != nil {
-		_ = stats.RecordWithTags(ctx, resolverSuccessFalseMutators, mNumResolutions.M(1))
+		r.telemetry.recordResolution(ctx, "dns", false)
+		r.dropStaleEndpoints(ctx)
 		return nil, err
 	}
 
-	_ = stats.RecordWithTags(ctx, resolverSuccessTrueMutators, mNumResolutions.M(1))
-
-	backends := make([]string, len(addrs))
-	for i, ip := range addrs {
-		var backend string
-		if ip.IP.To4() != nil {
-			backend = ip.String()
-		} else {
-			// it's an IPv6 address
-			backend = fmt.Sprintf("[%s]", ip.String())
-		}
-
-		// if a port is specified in the configuration, add it
-		if r.port != "" {
-			backend = fmt.Sprintf("%s:%s", backend, r.port)
-		}
-
-		backends[i] = backend
-	}
+	r.telemetry.recordResolution(ctx, "dns", true)
+	r.lastSuccess = time.Now()
 
 	// keep it always in the same order
 	sort.Strings(backends)
@@ -160,7 +249,7 @@ func (r *dnsResolver) resolve(ctx context.Context) ([]string, error) {
 	r.updateLock.Lock()
 	r.endpoints = backends
 	r.updateLock.Unlock()
-	_ = stats.RecordWithTags(ctx, resolverSuccessTrueMutators, mNumBackends.M(int64(len(backends))))
+	r.telemetry.recordBackends(ctx, "dns", len(backends))
 
 	// propagate the change
 	r.changeCallbackLock.RLock()
@@ -172,6 +261,87 @@ func (r *dnsResolver) resolve(ctx context.Context) ([]string, error) {
 	return r.endpoints, nil
 }
 
+// dropStaleEndpoints removes the last resolved endpoints once the resolutions have been failing for longer than the
+// maximum staleness. Until then, the last known good endpoints are kept.
+func (r *dnsResolver) dropStaleEndpoints(ctx context.Context) {
+	if r.maxStaleness == 0 || len(r.endpoints) == 0 || time.Since(r.lastSuccess) < r.maxStaleness {
+		return
+	}
+
+	r.logger.Warn("the endpoints are stale, dropping them until a resolution succeeds",
+		zap.Time("last_success", r.lastSuccess), zap.Duration("max_staleness", r.maxStaleness))
+
+	r.updateLock.Lock()
+	r.endpoints = []string{}
+	r.updateLock.Unlock()
+	r.telemetry.recordBackends(ctx, "dns", 0)
+
+	// propagate the change
+	r.changeCallbackLock.RLock()
+	for _, callback := range r.onChangeCallbacks {
+		callback(r.endpoints)
+	}
+	r.changeCallbackLock.RUnlock()
+}
+
+// lookupAddresses returns the addresses of the hostname, along with the configured port, if any.
+func (r *dnsResolver) lookupAddresses(ctx context.Context) ([]string, error) {
+	addrs, err := r.resolver.LookupIPAddr(ctx, r.hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	backends := make([]string, 0, len(addrs))
+	for _, ip := range addrs {
+		isIPv4 := ip.IP.To4() != nil
+		if (r.ipFamily == ipFamilyIPv4 && !isIPv4) || (r.ipFamily == ipFamilyIPv6 && isIPv4) {
+			continue
+		}
+
+		var backend string
+		if isIPv4 {
+			backend = ip.String()
+		} else {
+			// it's an IPv6 address
+			backend = fmt.Sprintf("[%s]", ip.String())
+		}
+
+		// if a port is specified in the configuration, add it
+		if r.port != "" {
+			backend = fmt.Sprintf("%s:%s", backend, r.port)
+		}
+
+		backends = append(backends, backend)
+	}
+	return backends, nil
+}
+
+// lookupSRV returns the targets of the SRV records of the hostname, along with their ports. The weights of the
+// records aren't used, as the share of each backend is given by the ring.
+func (r *dnsResolver) lookupSRV(ctx context.Context) ([]string, error) {
+	_, records, err := r.resolver.LookupSRV(ctx, r.srv.Service, r.srv.Proto, r.hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	lowestPriority := uint16(math.MaxUint16)
+	for _, record := range records {
+		if record.Priority < lowestPriority {
+			lowestPriority = record.Priority
+		}
+	}
+
+	backends := make([]string, 0, len(records))
+	for _, record := range records {
+		if r.srv.LowestPriorityOnly && record.Priority != lowestPriority {
+			continue
+		}
+		target := strings.TrimSuffix(record.Target, ".")
+		backends = append(backends, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+	}
+	return backends, nil
+}
+
 func (r *dnsResolver) onChange(f func([]string)) {
 	r.changeCallbackLock.Lock()
 	defer r.changeCallbackLock.Unlock()
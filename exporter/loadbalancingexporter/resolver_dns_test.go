@@ -6,6 +6,7 @@ package loadbalancingexporter
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -14,12 +15,13 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/exporter/exportertest"
 	"go.uber.org/zap"
 )
 
 func TestInitialDNSResolution(t *testing.T) {
 	// prepare
-	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second)
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second, nil)
 	require.NoError(t, err)
 
 	res.resolver = &mockDNSResolver{
@@ -51,7 +53,7 @@ func TestInitialDNSResolution(t *testing.T) {
 
 func TestInitialDNSResolutionWithPort(t *testing.T) {
 	// prepare
-	res, err := newDNSResolver(zap.NewNop(), "service-1", "55690", 5*time.Second, 1*time.Second)
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "55690", 5*time.Second, 1*time.Second, nil)
 	require.NoError(t, err)
 
 	res.resolver = &mockDNSResolver{
@@ -83,7 +85,7 @@ func TestInitialDNSResolutionWithPort(t *testing.T) {
 
 func TestErrNoHostname(t *testing.T) {
 	// test
-	res, err := newDNSResolver(zap.NewNop(), "", "", 5*time.Second, 1*time.Second)
+	res, err := newDNSResolver(zap.NewNop(), "", "", 5*time.Second, 1*time.Second, nil)
 
 	// verify
 	assert.Nil(t, res)
@@ -92,7 +94,7 @@ func TestErrNoHostname(t *testing.T) {
 
 func TestCantResolve(t *testing.T) {
 	// prepare
-	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second)
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second, nil)
 	require.NoError(t, err)
 
 	expectedErr := errors.New("some expected error")
@@ -104,6 +106,9 @@ func TestCantResolve(t *testing.T) {
 
 	// test
 	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
 
 	// verify
 	assert.NoError(t, err)
@@ -111,7 +116,7 @@ func TestCantResolve(t *testing.T) {
 
 func TestOnChange(t *testing.T) {
 	// prepare
-	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second)
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second, nil)
 	require.NoError(t, err)
 
 	resolve := []net.IPAddr{
@@ -178,7 +183,7 @@ func TestEqualStringSlice(t *testing.T) {
 
 func TestPeriodicallyResolve(t *testing.T) {
 	// prepare
-	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 10*time.Millisecond, 1*time.Second)
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 10*time.Millisecond, 1*time.Second, nil)
 	require.NoError(t, err)
 
 	counter := &atomic.Int64{}
@@ -236,7 +241,7 @@ func TestPeriodicallyResolve(t *testing.T) {
 
 func TestPeriodicallyResolveFailure(t *testing.T) {
 	// prepare
-	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 10*time.Millisecond, 1*time.Second)
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 10*time.Millisecond, 1*time.Second, nil)
 	require.NoError(t, err)
 
 	expectedErr := errors.New("some expected error")
@@ -277,9 +282,259 @@ func TestPeriodicallyResolveFailure(t *testing.T) {
 	assert.Len(t, res.endpoints, 1) // no change to the list of endpoints
 }
 
+func TestDNSResolutionWithIPFamily(t *testing.T) {
+	for _, tt := range []struct {
+		family   string
+		expected []string
+	}{
+		{
+			family:   "ipv4",
+			expected: []string{"127.0.0.1:4317", "127.0.0.2:4317"},
+		},
+		{
+			family:   "ipv6",
+			expected: []string{"[2001:db8::1]:4317", "[::1]:4317"},
+		},
+		{
+			family:   "dual",
+			expected: []string{"127.0.0.1:4317", "127.0.0.2:4317", "[2001:db8::1]:4317", "[::1]:4317"},
+		},
+	} {
+		t.Run(tt.family, func(t *testing.T) {
+			// prepare
+			res, err := newDNSResolver(zap.NewNop(), "service-1", "4317", 5*time.Second, 1*time.Second, nil)
+			require.NoError(t, err)
+			require.NoError(t, res.useIPFamily(tt.family))
+
+			res.resolver = &mockDNSResolver{
+				onLookupIPAddr: func(context.Context, string) ([]net.IPAddr, error) {
+					return []net.IPAddr{
+						{IP: net.IPv4(127, 0, 0, 1)},
+						{IP: net.IPv6loopback},
+						{IP: net.IPv4(127, 0, 0, 2)},
+						{IP: net.ParseIP("2001:db8::1")},
+					}, nil
+				},
+			}
+
+			// test
+			resolved, err := res.resolve(context.Background())
+
+			// verify
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, resolved)
+		})
+	}
+}
+
+func TestDNSInvalidIPFamily(t *testing.T) {
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second, nil)
+	require.NoError(t, err)
+	assert.ErrorContains(t, res.useIPFamily("ipv5"), `invalid IP family "ipv5"`)
+
+	cfg := simpleConfig()
+	cfg.Resolver = ResolverSettings{DNS: &DNSResolver{
+		Hostname: "collectors.example.com",
+		IPFamily: "ipv6",
+		SRV:      &DNSSRVSettings{},
+	}}
+	_, err = newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errIPFamilyWithSRV, err)
+}
+
+func TestInitialDNSSRVResolution(t *testing.T) {
+	// prepare
+	res, err := newDNSResolver(zap.NewNop(), "collectors.example.com", "", 5*time.Second, 1*time.Second, nil)
+	require.NoError(t, err)
+	require.NoError(t, res.useSRV(&DNSSRVSettings{}))
+
+	var lookedUp string
+	res.resolver = &mockDNSResolver{
+		onLookupSRV: func(_ context.Context, service, proto, name string) ([]*net.SRV, error) {
+			lookedUp = fmt.Sprintf("_%s._%s.%s", service, proto, name)
+			return []*net.SRV{
+				{Target: "collector-2.example.com.", Port: 4317, Priority: 10, Weight: 5},
+				{Target: "collector-1.example.com.", Port: 24317, Priority: 10, Weight: 5},
+				{Target: "collector-3.example.com.", Port: 4317, Priority: 20, Weight: 5},
+			}, nil
+		},
+	}
+
+	// test
+	var resolved []string
+	res.onChange(func(endpoints []string) {
+		resolved = endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, "_otlp._tcp.collectors.example.com", lookedUp)
+	assert.Equal(t, []string{
+		"collector-1.example.com:24317",
+		"collector-2.example.com:4317",
+		"collector-3.example.com:4317",
+	}, resolved)
+}
+
+func TestDNSSRVResolutionLowestPriorityOnly(t *testing.T) {
+	// prepare
+	res, err := newDNSResolver(zap.NewNop(), "collectors.example.com", "", 5*time.Second, 1*time.Second, nil)
+	require.NoError(t, err)
+	require.NoError(t, res.useSRV(&DNSSRVSettings{Service: "grpc", Proto: "udp", LowestPriorityOnly: true}))
+
+	res.resolver = &mockDNSResolver{
+		onLookupSRV: func(_ context.Context, service, proto, _ string) ([]*net.SRV, error) {
+			assert.Equal(t, "grpc", service)
+			assert.Equal(t, "udp", proto)
+			return []*net.SRV{
+				{Target: "collector-3.example.com.", Port: 4317, Priority: 20},
+				{Target: "collector-1.example.com.", Port: 4317, Priority: 10},
+				{Target: "collector-2.example.com.", Port: 4317, Priority: 10},
+			}, nil
+		},
+	}
+
+	// test
+	resolved, err := res.resolve(context.Background())
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, []string{"collector-1.example.com:4317", "collector-2.example.com:4317"}, resolved)
+}
+
+func TestCantResolveDNSSRV(t *testing.T) {
+	// prepare
+	res, err := newDNSResolver(zap.NewNop(), "collectors.example.com", "", 5*time.Second, 1*time.Second, nil)
+	require.NoError(t, err)
+	require.NoError(t, res.useSRV(&DNSSRVSettings{}))
+
+	expectedErr := &net.DNSError{Err: "no such host", IsNotFound: true}
+	res.resolver = &mockDNSResolver{
+		onLookupSRV: func(context.Context, string, string, string) ([]*net.SRV, error) {
+			return nil, expectedErr
+		},
+	}
+
+	// test
+	resolved, err := res.resolve(context.Background())
+
+	// verify
+	assert.Equal(t, expectedErr, err)
+	assert.Nil(t, resolved)
+}
+
+func TestDNSSRVWithPort(t *testing.T) {
+	res, err := newDNSResolver(zap.NewNop(), "collectors.example.com", "4317", 5*time.Second, 1*time.Second, nil)
+	require.NoError(t, err)
+	assert.Equal(t, errPortWithSRV, res.useSRV(&DNSSRVSettings{}))
+
+	cfg := simpleConfig()
+	cfg.Resolver = ResolverSettings{DNS: &DNSResolver{
+		Hostname: "collectors.example.com",
+		Port:     "4317",
+		SRV:      &DNSSRVSettings{},
+	}}
+	_, err = newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errPortWithSRV, err)
+}
+
+func TestDNSBackoffOnConsecutiveFailures(t *testing.T) {
+	// prepare
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 10*time.Millisecond, 1*time.Second, nil)
+	require.NoError(t, err)
+	res.useBackoff(&DNSBackoffSettings{MaxInterval: 50 * time.Millisecond, Multiplier: 2, RandomizationFactor: 0.5})
+	failure := errors.New("some expected error")
+
+	// test and verify
+	for _, expected := range []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		40 * time.Millisecond,
+		50 * time.Millisecond,
+		50 * time.Millisecond,
+	} {
+		delay := res.nextResolution(failure)
+		assert.GreaterOrEqual(t, delay, expected/2)
+		assert.LessOrEqual(t, delay, expected*3/2)
+	}
+
+	// a success resets the backoff
+	assert.Equal(t, 10*time.Millisecond, res.nextResolution(nil))
+	assert.LessOrEqual(t, res.nextResolution(failure), 15*time.Millisecond)
+}
+
+func TestDNSWithoutBackoff(t *testing.T) {
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 10*time.Millisecond, 1*time.Second, nil)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 10*time.Millisecond, res.nextResolution(errors.New("some expected error")))
+	}
+}
+
+func TestDNSKeepsLastKnownGoodEndpoints(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		maxStaleness time.Duration
+		expected     []string
+	}{
+		{
+			name:     "without max staleness",
+			expected: []string{"127.0.0.1"},
+		},
+		{
+			name:         "within max staleness",
+			maxStaleness: time.Hour,
+			expected:     []string{"127.0.0.1"},
+		},
+		{
+			name:         "past max staleness",
+			maxStaleness: time.Nanosecond,
+			expected:     []string{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// prepare
+			res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second, nil)
+			require.NoError(t, err)
+			res.maxStaleness = tt.maxStaleness
+
+			var lookupErr error
+			res.resolver = &mockDNSResolver{
+				onLookupIPAddr: func(context.Context, string) ([]net.IPAddr, error) {
+					if lookupErr != nil {
+						return nil, lookupErr
+					}
+					return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, nil
+				},
+			}
+			var resolved []string
+			res.onChange(func(endpoints []string) {
+				resolved = endpoints
+			})
+			_, err = res.resolve(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, []string{"127.0.0.1"}, resolved)
+
+			// test
+			lookupErr = errors.New("some expected error")
+			time.Sleep(time.Millisecond)
+			_, err = res.resolve(context.Background())
+
+			// verify
+			assert.Equal(t, lookupErr, err)
+			assert.Equal(t, tt.expected, resolved)
+			assert.Equal(t, tt.expected, res.endpoints)
+		})
+	}
+}
+
 func TestShutdownClearsCallbacks(t *testing.T) {
 	// prepare
-	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second)
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second, nil)
 	require.NoError(t, err)
 
 	res.resolver = &mockDNSResolver{}
@@ -306,6 +561,7 @@ var _ netResolver = (*mockDNSResolver)(nil)
 type mockDNSResolver struct {
 	net.Resolver
 	onLookupIPAddr func(context.Context, string) ([]net.IPAddr, error)
+	onLookupSRV    func(context.Context, string, string, string) ([]*net.SRV, error)
 }
 
 func (m *mockDNSResolver) LookupIPAddr(ctx context.Context, hostname string) ([]net.IPAddr, error) {
@@ -314,3 +570,11 @@ func (m *mockDNSResolver) LookupIPAddr(ctx context.Context, hostname string) ([]
 	}
 	return nil, nil
 }
+
+func (m *mockDNSResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if m.onLookupSRV != nil {
+		records, err := m.onLookupSRV(ctx, service, proto, name)
+		return fmt.Sprintf("_%s._%s.%s", service, proto, name), records, err
+	}
+	return "", nil, nil
+}
@@ -0,0 +1,296 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+var _ resolver = (*etcdResolver)(nil)
+
+const (
+	defaultEtcdDialTimeout   = 5 * time.Second
+	defaultEtcdRetryInterval = 5 * time.Second
+)
+
+var (
+	errNoEtcdEndpoints = errors.New("no endpoints specified for the etcd resolver")
+	errNoEtcdPrefix    = errors.New("no prefix specified for the etcd resolver")
+	errEtcdWatchClosed = errors.New("the watch was closed by the etcd cluster")
+)
+
+// etcdClient is the part of the etcd client used by the resolver.
+type etcdClient interface {
+	Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error)
+	Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan
+	Close() error
+}
+
+// etcdResolver watches the keys under a prefix in etcd, each of them being a backend. The backends are expected to
+// register themselves with a lease, so that their keys are deleted, and the ring updated right away, once they are
+// gone. The value of a key is the endpoint of the backend, or the key itself, without the prefix, when empty.
+type etcdResolver struct {
+	logger    *zap.Logger
+	telemetry *telemetry
+
+	prefix        string
+	retryInterval time.Duration
+	clientCfg     clientv3.Config
+	newClient     func(clientv3.Config) (etcdClient, error)
+	client        etcdClient
+
+	// members holds the endpoint of each key under the prefix, as of the revision
+	members  map[string]string
+	revision int64
+
+	endpoints         []string
+	onChangeCallbacks []func([]string)
+
+	stopCh             chan struct{}
+	updateLock         sync.Mutex
+	shutdownWg         sync.WaitGroup
+	changeCallbackLock sync.RWMutex
+}
+
+func newEtcdResolver(logger *zap.Logger, cfg *EtcdResolver, tb *telemetry) (*etcdResolver, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, errNoEtcdEndpoints
+	}
+	if cfg.Prefix == "" {
+		return nil, errNoEtcdPrefix
+	}
+	tlsCfg, err := cfg.TLS.LoadTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dialTimeout := cfg.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = defaultEtcdDialTimeout
+	}
+	retryInterval := cfg.RetryInterval
+	if retryInterval == 0 {
+		retryInterval = defaultEtcdRetryInterval
+	}
+
+	return &etcdResolver{
+		logger:        logger,
+		telemetry:     tb,
+		prefix:        cfg.Prefix,
+		retryInterval: retryInterval,
+		clientCfg: clientv3.Config{
+			Endpoints:   cfg.Endpoints,
+			Username:    cfg.Username,
+			Password:    string(cfg.Password),
+			DialTimeout: dialTimeout,
+			TLS:         tlsCfg,
+			Logger:      logger,
+		},
+		newClient: func(clientCfg clientv3.Config) (etcdClient, error) {
+			return clientv3.New(clientCfg)
+		},
+		stopCh: make(chan struct{}),
+	}, nil
+}
+
+func (r *etcdResolver) start(ctx context.Context) error {
+	client, err := r.newClient(r.clientCfg)
+	if err != nil {
+		return err
+	}
+	r.client = client
+
+	if _, err := r.resolve(ctx); err != nil {
+		r.logger.Warn("failed to resolve", zap.Error(err))
+	}
+
+	r.shutdownWg.Add(1)
+	go r.watch()
+
+	r.logger.Debug("etcd resolver started",
+		zap.Strings("endpoints", r.clientCfg.Endpoints), zap.String("prefix", r.prefix))
+	return nil
+}
+
+func (r *etcdResolver) shutdown(_ context.Context) error {
+	r.changeCallbackLock.Lock()
+	r.onChangeCallbacks = nil
+	r.changeCallbackLock.Unlock()
+
+	close(r.stopCh)
+	r.shutdownWg.Wait()
+	if r.client != nil {
+		return r.client.Close()
+	}
+	return nil
+}
+
+// watch applies the changes of the keys under the prefix as they happen, starting right after the revision of the
+// latest resolution. When the watch fails, such as when the revision was compacted, the keys are fetched again
+// after the retry interval and a new watch started from there.
+func (r *etcdResolver) watch() {
+	defer r.shutdownWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-r.stopCh
+		cancel()
+	}()
+
+	for {
+		r.updateLock.Lock()
+		revision := r.revision
+		r.updateLock.Unlock()
+
+		var err error
+		if revision == 0 {
+			_, err = r.resolve(ctx)
+		} else {
+			err = r.watchFrom(ctx, revision+1)
+		}
+		select {
+		case <-r.stopCh:
+			return
+		default:
+		}
+		if err == nil {
+			continue
+		}
+
+		r.logger.Warn("failed to resolve", zap.Error(err))
+		select {
+		case <-r.stopCh:
+			return
+		case <-time.After(r.retryInterval):
+		}
+	}
+}
+
+// watchFrom applies the changes from the given revision on, until the watch fails.
+func (r *etcdResolver) watchFrom(ctx context.Context, revision int64) error {
+	// fail the watch when the member it's connected to loses the leader, instead of waiting for it forever
+	ctx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+
+	for response := range r.client.Watch(ctx, r.prefix, clientv3.WithPrefix(), clientv3.WithRev(revision)) {
+		if err := response.Err(); err != nil {
+			r.reset()
+			r.telemetry.recordResolution(ctx, "etcd", false)
+			return err
+		}
+		if len(response.Events) == 0 {
+			continue
+		}
+
+		r.updateLock.Lock()
+		for _, event := range response.Events {
+			if event.Type == mvccpb.DELETE {
+				delete(r.members, string(event.Kv.Key))
+				continue
+			}
+			r.members[string(event.Kv.Key)] = r.endpointFor(event.Kv)
+		}
+		r.revision = response.Header.Revision
+		backends := backendsOf(r.members)
+		r.updateLock.Unlock()
+
+		r.telemetry.recordResolution(ctx, "etcd", true)
+		r.update(ctx, backends)
+	}
+
+	r.reset()
+	return errEtcdWatchClosed
+}
+
+// reset makes the next watch start over from the current keys.
+func (r *etcdResolver) reset() {
+	r.updateLock.Lock()
+	r.revision = 0
+	r.updateLock.Unlock()
+}
+
+func (r *etcdResolver) resolve(ctx context.Context) ([]string, error) {
+	response, err := r.client.Get(ctx, r.prefix, clientv3.WithPrefix())
+	if err != nil {
+		r.telemetry.recordResolution(ctx, "etcd", false)
+		return nil, err
+	}
+	r.telemetry.recordResolution(ctx, "etcd", true)
+
+	members := make(map[string]string, len(response.Kvs))
+	for _, kv := range response.Kvs {
+		members[string(kv.Key)] = r.endpointFor(kv)
+	}
+	backends := backendsOf(members)
+
+	r.updateLock.Lock()
+	r.members = members
+	r.revision = response.Header.Revision
+	r.updateLock.Unlock()
+
+	r.update(ctx, backends)
+	return backends, nil
+}
+
+// endpointFor returns the endpoint of the backend registered with the given key.
+func (r *etcdResolver) endpointFor(kv *mvccpb.KeyValue) string {
+	if endpoint := strings.TrimSpace(string(kv.Value)); endpoint != "" {
+		return endpoint
+	}
+	return strings.TrimPrefix(string(kv.Key), r.prefix)
+}
+
+// backendsOf returns the sorted endpoints of the members, without duplicates.
+func backendsOf(members map[string]string) []string {
+	backends := make([]string, 0, len(members))
+	seen := make(map[string]bool, len(members))
+	for _, endpoint := range members {
+		if endpoint == "" || seen[endpoint] {
+			continue
+		}
+		seen[endpoint] = true
+		backends = append(backends, endpoint)
+	}
+
+	// keep it always in the same order
+	sort.Strings(backends)
+	return backends
+}
+
+// update propagates the endpoints, if they changed.
+func (r *etcdResolver) update(ctx context.Context, backends []string) {
+	r.updateLock.Lock()
+	if equalStringSlice(r.endpoints, backends) {
+		r.updateLock.Unlock()
+		return
+	}
+
+	// the list has changed!
+	r.endpoints = backends
+	r.updateLock.Unlock()
+	r.telemetry.recordBackends(ctx, "etcd", len(backends))
+
+	// propagate the change
+	r.changeCallbackLock.RLock()
+	for _, callback := range r.onChangeCallbacks {
+		callback(backends)
+	}
+	r.changeCallbackLock.RUnlock()
+}
+
+func (r *etcdResolver) onChange(f func([]string)) {
+	r.changeCallbackLock.Lock()
+	defer r.changeCallbackLock.Unlock()
+	r.onChangeCallbacks = append(r.onChangeCallbacks, f)
+}
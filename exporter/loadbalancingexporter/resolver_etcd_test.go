@@ -0,0 +1,250 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+)
+
+func newTestEtcdResolver(t *testing.T, client *mockEtcdClient) *etcdResolver {
+	res, err := newEtcdResolver(zap.NewNop(), &EtcdResolver{
+		Endpoints:     []string{"etcd:2379"},
+		Prefix:        "/otel/gateways/",
+		RetryInterval: 10 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+	res.newClient = func(clientv3.Config) (etcdClient, error) {
+		return client, nil
+	}
+	return res
+}
+
+func TestInitialEtcdResolution(t *testing.T) {
+	// prepare
+	client := newMockEtcdClient(10, map[string]string{
+		"/otel/gateways/gateway-2":       "10.0.0.2:4317",
+		"/otel/gateways/gateway-1":       "10.0.0.1:4317",
+		"/otel/gateways/10.0.0.3:4317":   "",
+		"/otel/gateways/gateway-1-again": "10.0.0.1:4317",
+	})
+	res := newTestEtcdResolver(t, client)
+
+	// test
+	var resolved []string
+	res.onChange(func(endpoints []string) {
+		resolved = endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, []string{"10.0.0.1:4317", "10.0.0.2:4317", "10.0.0.3:4317"}, resolved)
+	assert.Equal(t, int64(11), <-client.watchRevisions)
+}
+
+func TestEtcdWatchAppliesChanges(t *testing.T) {
+	// prepare
+	client := newMockEtcdClient(10, map[string]string{
+		"/otel/gateways/gateway-1": "10.0.0.1:4317",
+		"/otel/gateways/gateway-2": "10.0.0.2:4317",
+	})
+	res := newTestEtcdResolver(t, client)
+	resolved := make(chan []string, 10)
+	res.onChange(func(endpoints []string) {
+		resolved <- endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+	assert.Equal(t, []string{"10.0.0.1:4317", "10.0.0.2:4317"}, <-resolved)
+	<-client.watchRevisions
+
+	// test
+	client.events <- clientv3.WatchResponse{
+		Header: etcdserverpb.ResponseHeader{Revision: 12},
+		Events: []*clientv3.Event{
+			{Type: mvccpb.PUT, Kv: &mvccpb.KeyValue{Key: []byte("/otel/gateways/gateway-3"), Value: []byte("10.0.0.3:4317")}},
+			// the lease of the second gateway expired
+			{Type: mvccpb.DELETE, Kv: &mvccpb.KeyValue{Key: []byte("/otel/gateways/gateway-2")}},
+		},
+	}
+
+	// verify
+	select {
+	case endpoints := <-resolved:
+		assert.Equal(t, []string{"10.0.0.1:4317", "10.0.0.3:4317"}, endpoints)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the changes weren't applied")
+	}
+}
+
+func TestEtcdWatchStartsOverAfterFailure(t *testing.T) {
+	// prepare
+	client := newMockEtcdClient(10, map[string]string{
+		"/otel/gateways/gateway-1": "10.0.0.1:4317",
+	})
+	res := newTestEtcdResolver(t, client)
+	resolved := make(chan []string, 10)
+	res.onChange(func(endpoints []string) {
+		resolved <- endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+	assert.Equal(t, []string{"10.0.0.1:4317"}, <-resolved)
+	<-client.watchRevisions
+
+	// test
+	client.set(20, map[string]string{
+		"/otel/gateways/gateway-2": "10.0.0.2:4317",
+	})
+	client.events <- clientv3.WatchResponse{CompactRevision: 15}
+
+	// verify
+	select {
+	case endpoints := <-resolved:
+		assert.Equal(t, []string{"10.0.0.2:4317"}, endpoints)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the keys weren't fetched again")
+	}
+	assert.Equal(t, int64(21), <-client.watchRevisions)
+}
+
+func TestEtcdCantResolve(t *testing.T) {
+	// prepare
+	expectedErr := errors.New("some expected error")
+	client := newMockEtcdClient(10, nil)
+	client.getErr = expectedErr
+	res := newTestEtcdResolver(t, client)
+	res.client = client
+
+	// test
+	_, err := res.resolve(context.Background())
+
+	// verify
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestEtcdInvalidConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cfg  *EtcdResolver
+		err  error
+	}{
+		{
+			name: "no endpoints",
+			cfg:  &EtcdResolver{Prefix: "/otel/gateways/"},
+			err:  errNoEtcdEndpoints,
+		},
+		{
+			name: "no prefix",
+			cfg:  &EtcdResolver{Endpoints: []string{"etcd:2379"}},
+			err:  errNoEtcdPrefix,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := newEtcdResolver(zap.NewNop(), tt.cfg, nil)
+			assert.Equal(t, tt.err, err)
+			assert.Nil(t, res)
+		})
+	}
+}
+
+func TestEtcdResolverExclusive(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.Resolver.Etcd = &EtcdResolver{Endpoints: []string{"etcd:2379"}, Prefix: "/otel/gateways/"}
+
+	_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errMultipleResolversProvided, err)
+}
+
+// mockEtcdClient returns the keys it holds, and forwards the events sent by the test to the current watch.
+type mockEtcdClient struct {
+	lock     sync.Mutex
+	revision int64
+	kvs      map[string]string
+	getErr   error
+
+	events         chan clientv3.WatchResponse
+	watchRevisions chan int64
+}
+
+func newMockEtcdClient(revision int64, kvs map[string]string) *mockEtcdClient {
+	return &mockEtcdClient{
+		revision:       revision,
+		kvs:            kvs,
+		events:         make(chan clientv3.WatchResponse),
+		watchRevisions: make(chan int64, 10),
+	}
+}
+
+func (m *mockEtcdClient) set(revision int64, kvs map[string]string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.revision = revision
+	m.kvs = kvs
+}
+
+func (m *mockEtcdClient) Get(_ context.Context, _ string, _ ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+
+	response := &etcdserverpb.RangeResponse{Header: &etcdserverpb.ResponseHeader{Revision: m.revision}}
+	for key, value := range m.kvs {
+		response.Kvs = append(response.Kvs, &mvccpb.KeyValue{Key: []byte(key), Value: []byte(value)})
+	}
+	sort.Slice(response.Kvs, func(i, j int) bool {
+		return string(response.Kvs[i].Key) < string(response.Kvs[j].Key)
+	})
+	return (*clientv3.GetResponse)(response), nil
+}
+
+func (m *mockEtcdClient) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	m.watchRevisions <- clientv3.OpGet(key, opts...).Rev()
+
+	ch := make(chan clientv3.WatchResponse)
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case response := <-m.events:
+				select {
+				case ch <- response:
+				case <-ctx.Done():
+					return
+				}
+				if response.Err() != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (m *mockEtcdClient) Close() error {
+	return nil
+}
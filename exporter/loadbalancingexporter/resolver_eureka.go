@@ -0,0 +1,264 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var _ resolver = (*eurekaResolver)(nil)
+
+// eurekaStatusUp is the status of the instances ready to receive traffic
+const eurekaStatusUp = "UP"
+
+var (
+	errNoEurekaServerURL       = errors.New("no server URL specified for the eureka resolver")
+	errNoEurekaApplication     = errors.New("either an application or a VIP address has to be specified for the eureka resolver")
+	errEurekaApplicationAndVIP = errors.New("only one of an application or a VIP address can be specified for the eureka resolver")
+)
+
+// eurekaResolver periodically polls the instances of an application, or of a VIP address, from Eureka, using the
+// ones whose status is UP.
+type eurekaResolver struct {
+	logger    *zap.Logger
+	telemetry *telemetry
+
+	url         string
+	port        *uint16
+	client      *http.Client
+	resInterval time.Duration
+	resTimeout  time.Duration
+
+	endpoints         []string
+	onChangeCallbacks []func([]string)
+
+	stopCh             chan struct{}
+	updateLock         sync.Mutex
+	shutdownWg         sync.WaitGroup
+	changeCallbackLock sync.RWMutex
+}
+
+func newEurekaResolver(logger *zap.Logger, cfg *EurekaResolver, tb *telemetry) (*eurekaResolver, error) {
+	if cfg.ServerURL == "" {
+		return nil, errNoEurekaServerURL
+	}
+	if cfg.Application == "" && cfg.VIPAddress == "" {
+		return nil, errNoEurekaApplication
+	}
+	if cfg.Application != "" && cfg.VIPAddress != "" {
+		return nil, errEurekaApplicationAndVIP
+	}
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = defaultResInterval
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultResTimeout
+	}
+
+	resourceURL := strings.TrimSuffix(cfg.ServerURL, "/") + "/apps/" + url.PathEscape(cfg.Application)
+	if cfg.VIPAddress != "" {
+		resourceURL = strings.TrimSuffix(cfg.ServerURL, "/") + "/vips/" + url.PathEscape(cfg.VIPAddress)
+	}
+
+	return &eurekaResolver{
+		logger:      logger,
+		telemetry:   tb,
+		url:         resourceURL,
+		port:        cfg.Port,
+		client:      &http.Client{},
+		resInterval: interval,
+		resTimeout:  timeout,
+		stopCh:      make(chan struct{}),
+	}, nil
+}
+
+func (r *eurekaResolver) start(ctx context.Context) error {
+	if _, err := r.resolve(ctx); err != nil {
+		r.logger.Warn("failed to resolve", zap.Error(err))
+	}
+
+	go r.periodicallyResolve()
+
+	r.logger.Debug("Eureka resolver started",
+		zap.String("url", r.url), zap.Duration("interval", r.resInterval), zap.Duration("timeout", r.resTimeout))
+	return nil
+}
+
+func (r *eurekaResolver) shutdown(_ context.Context) error {
+	r.changeCallbackLock.Lock()
+	r.onChangeCallbacks = nil
+	r.changeCallbackLock.Unlock()
+
+	close(r.stopCh)
+	r.shutdownWg.Wait()
+	return nil
+}
+
+func (r *eurekaResolver) periodicallyResolve() {
+	ticker := time.NewTicker(r.resInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), r.resTimeout)
+			if _, err := r.resolve(ctx); err != nil {
+				r.logger.Warn("failed to resolve", zap.Error(err))
+			} else {
+				r.logger.Debug("resolved successfully")
+			}
+			cancel()
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+func (r *eurekaResolver) resolve(ctx context.Context) ([]string, error) {
+	r.shutdownWg.Add(1)
+	defer r.shutdownWg.Done()
+
+	instances, err := r.instances(ctx)
+	if err != nil {
+		r.telemetry.recordResolution(ctx, "eureka", false)
+		return nil, err
+	}
+
+	r.telemetry.recordResolution(ctx, "eureka", true)
+
+	backends := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		if instance.Status != eurekaStatusUp {
+			continue
+		}
+		port := strconv.Itoa(instance.Port.Number)
+		if r.port != nil {
+			port = strconv.Itoa(int(*r.port))
+		}
+		backends = append(backends, net.JoinHostPort(instance.HostName, port))
+	}
+
+	// keep it always in the same order
+	sort.Strings(backends)
+
+	if equalStringSlice(r.endpoints, backends) {
+		return r.endpoints, nil
+	}
+
+	// the list has changed!
+	r.updateLock.Lock()
+	r.endpoints = backends
+	r.updateLock.Unlock()
+	r.telemetry.recordBackends(ctx, "eureka", len(backends))
+
+	// propagate the change
+	r.changeCallbackLock.RLock()
+	for _, callback := range r.onChangeCallbacks {
+		callback(r.endpoints)
+	}
+	r.changeCallbackLock.RUnlock()
+
+	return r.endpoints, nil
+}
+
+// instances fetches the instances of the application, or of all the applications with the VIP address.
+func (r *eurekaResolver) instances(ctx context.Context) ([]eurekaInstance, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		// Eureka forgets about the applications once their last instance is gone
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from Eureka: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Application  *eurekaApplication `json:"application"`
+		Applications *struct {
+			Application eurekaList[eurekaApplication] `json:"application"`
+		} `json:"applications"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	var instances []eurekaInstance
+	if body.Application != nil {
+		instances = append(instances, body.Application.Instance...)
+	}
+	if body.Applications != nil {
+		for _, application := range body.Applications.Application {
+			instances = append(instances, application.Instance...)
+		}
+	}
+	return instances, nil
+}
+
+func (r *eurekaResolver) onChange(f func([]string)) {
+	r.changeCallbackLock.Lock()
+	defer r.changeCallbackLock.Unlock()
+	r.onChangeCallbacks = append(r.onChangeCallbacks, f)
+}
+
+type eurekaApplication struct {
+	Name     string                     `json:"name"`
+	Instance eurekaList[eurekaInstance] `json:"instance"`
+}
+
+type eurekaInstance struct {
+	HostName string `json:"hostName"`
+	Status   string `json:"status"`
+	Port     struct {
+		Number int `json:"$"`
+	} `json:"port"`
+}
+
+// eurekaList is a list in the JSON representation of Eureka, which holds a single object instead of a list when
+// there's only one item.
+type eurekaList[T any] []T
+
+func (l *eurekaList[T]) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var item T
+		if err := json.Unmarshal(data, &item); err != nil {
+			return err
+		}
+		*l = eurekaList[T]{item}
+		return nil
+	}
+
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+)
+
+// newMockEurekaServer serves the given bodies for the given paths, and 404 for any other.
+func newMockEurekaServer(t *testing.T, bodies func() map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		body, ok := bodies()[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+const eurekaGatewaysApplication = `{"application": {"name": "GATEWAYS", "instance": [
+	{"instanceId": "gateway-2", "hostName": "10.0.0.2", "status": "UP", "port": {"$": 4317, "@enabled": "true"}},
+	{"instanceId": "gateway-1", "hostName": "10.0.0.1", "status": "UP", "port": {"$": 4317, "@enabled": "true"}},
+	{"instanceId": "gateway-3", "hostName": "10.0.0.3", "status": "DOWN", "port": {"$": 4317, "@enabled": "true"}},
+	{"instanceId": "gateway-4", "hostName": "10.0.0.4", "status": "STARTING", "port": {"$": 4317, "@enabled": "true"}}
+]}}`
+
+func TestInitialEurekaResolution(t *testing.T) {
+	// prepare
+	server := newMockEurekaServer(t, func() map[string]string {
+		return map[string]string{"/eureka/apps/GATEWAYS": eurekaGatewaysApplication}
+	})
+	res, err := newEurekaResolver(zap.NewNop(), &EurekaResolver{ServerURL: server.URL + "/eureka/", Application: "GATEWAYS"}, nil)
+	require.NoError(t, err)
+
+	// test
+	var resolved []string
+	res.onChange(func(endpoints []string) {
+		resolved = endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, []string{"10.0.0.1:4317", "10.0.0.2:4317"}, resolved)
+}
+
+func TestEurekaResolutionByVIPAddress(t *testing.T) {
+	// prepare
+	server := newMockEurekaServer(t, func() map[string]string {
+		// a single application and a single instance are objects instead of lists
+		return map[string]string{"/eureka/vips/otel-gateways": `{"applications": {"application": [
+			{"name": "GATEWAYS-EU", "instance": {"hostName": "gateway-eu", "status": "UP", "port": {"$": 55690}}},
+			{"name": "GATEWAYS-US", "instance": [
+				{"hostName": "gateway-us-1", "status": "UP", "port": {"$": 55690}},
+				{"hostName": "gateway-us-2", "status": "OUT_OF_SERVICE", "port": {"$": 55690}}
+			]}
+		]}}`}
+	})
+	port := uint16(4318)
+	res, err := newEurekaResolver(zap.NewNop(), &EurekaResolver{ServerURL: server.URL + "/eureka", VIPAddress: "otel-gateways", Port: &port}, nil)
+	require.NoError(t, err)
+
+	// test
+	resolved, err := res.resolve(context.Background())
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gateway-eu:4318", "gateway-us-1:4318"}, resolved)
+}
+
+func TestEurekaPeriodicallyResolve(t *testing.T) {
+	// prepare
+	var lock sync.Mutex
+	bodies := map[string]string{"/eureka/apps/GATEWAYS": eurekaGatewaysApplication}
+	server := newMockEurekaServer(t, func() map[string]string {
+		lock.Lock()
+		defer lock.Unlock()
+		return bodies
+	})
+	res, err := newEurekaResolver(zap.NewNop(), &EurekaResolver{
+		ServerURL:   server.URL + "/eureka",
+		Application: "GATEWAYS",
+		Interval:    10 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+	resolved := make(chan []string, 10)
+	res.onChange(func(endpoints []string) {
+		resolved <- endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+	assert.Equal(t, []string{"10.0.0.1:4317", "10.0.0.2:4317"}, <-resolved)
+
+	// test
+	// the last instance is gone, along with the application
+	lock.Lock()
+	bodies = map[string]string{}
+	lock.Unlock()
+
+	// verify
+	select {
+	case endpoints := <-resolved:
+		assert.Empty(t, endpoints)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the instances weren't polled again")
+	}
+}
+
+func TestEurekaCantResolve(t *testing.T) {
+	// prepare
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	res, err := newEurekaResolver(zap.NewNop(), &EurekaResolver{ServerURL: server.URL, Application: "GATEWAYS"}, nil)
+	require.NoError(t, err)
+
+	// test
+	_, err = res.resolve(context.Background())
+
+	// verify
+	assert.EqualError(t, err, "unexpected status code from Eureka: 503")
+}
+
+func TestEurekaInvalidConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cfg  *EurekaResolver
+		err  error
+	}{
+		{
+			name: "no server URL",
+			cfg:  &EurekaResolver{Application: "GATEWAYS"},
+			err:  errNoEurekaServerURL,
+		},
+		{
+			name: "no application",
+			cfg:  &EurekaResolver{ServerURL: "http://eureka:8761/eureka"},
+			err:  errNoEurekaApplication,
+		},
+		{
+			name: "both application and VIP address",
+			cfg:  &EurekaResolver{ServerURL: "http://eureka:8761/eureka", Application: "GATEWAYS", VIPAddress: "otel-gateways"},
+			err:  errEurekaApplicationAndVIP,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := newEurekaResolver(zap.NewNop(), tt.cfg, nil)
+			assert.Equal(t, tt.err, err)
+			assert.Nil(t, res)
+		})
+	}
+}
+
+func TestEurekaResolverExclusive(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.Resolver.Eureka = &EurekaResolver{ServerURL: "http://eureka:8761/eureka", Application: "GATEWAYS"}
+
+	_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errMultipleResolversProvided, err)
+}
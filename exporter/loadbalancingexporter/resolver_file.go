@@ -0,0 +1,211 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+var _ resolver = (*fileResolver)(nil)
+
+var (
+	errNoFilePath = errors.New("no path specified for the file resolver")
+)
+
+// fileResolver reads the endpoints from a local file, and reads it again whenever it changes. The directory of the
+// file is watched rather than the file itself, so that the files replaced with a rename, such as the ones mounted
+// from a Kubernetes ConfigMap, keep being watched.
+type fileResolver struct {
+	logger    *zap.Logger
+	telemetry *telemetry
+
+	path    string
+	watcher *fsnotify.Watcher
+
+	endpoints         []string
+	onChangeCallbacks []func([]string)
+
+	stopCh             chan struct{}
+	updateLock         sync.Mutex
+	shutdownWg         sync.WaitGroup
+	changeCallbackLock sync.RWMutex
+}
+
+func newFileResolver(logger *zap.Logger, cfg *FileResolver, tb *telemetry) (*fileResolver, error) {
+	if cfg.Path == "" {
+		return nil, errNoFilePath
+	}
+	path, err := filepath.Abs(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileResolver{
+		logger:    logger,
+		telemetry: tb,
+		path:      path,
+		stopCh:    make(chan struct{}),
+	}, nil
+}
+
+func (r *fileResolver) start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err = watcher.Add(filepath.Dir(r.path)); err != nil {
+		_ = watcher.Close()
+		return err
+	}
+	r.watcher = watcher
+
+	if _, err := r.resolve(ctx); err != nil {
+		r.logger.Warn("failed to resolve", zap.Error(err))
+	}
+
+	r.shutdownWg.Add(1)
+	go r.watch()
+
+	r.logger.Debug("file resolver started", zap.String("path", r.path))
+	return nil
+}
+
+func (r *fileResolver) shutdown(_ context.Context) error {
+	r.changeCallbackLock.Lock()
+	r.onChangeCallbacks = nil
+	r.changeCallbackLock.Unlock()
+
+	close(r.stopCh)
+	r.shutdownWg.Wait()
+	if r.watcher != nil {
+		return r.watcher.Close()
+	}
+	return nil
+}
+
+// watch reads the file again on every change in its directory, the endpoints being propagated only when they
+// changed. When the file can't be read or parsed, the current endpoints are kept.
+func (r *fileResolver) watch() {
+	defer r.shutdownWg.Done()
+
+	for {
+		select {
+		case <-r.stopCh:
+			return
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			r.logger.Debug("the directory of the file changed", zap.String("event", event.String()))
+			if _, err := r.resolve(context.Background()); err != nil {
+				r.logger.Warn("failed to resolve", zap.Error(err))
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			r.logger.Warn("failed to watch the file", zap.Error(err))
+		}
+	}
+}
+
+func (r *fileResolver) resolve(ctx context.Context) ([]string, error) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		r.telemetry.recordResolution(ctx, "file", false)
+		return nil, err
+	}
+	backends, err := parseEndpointsFile(data)
+	if err != nil {
+		r.telemetry.recordResolution(ctx, "file", false)
+		return nil, fmt.Errorf("invalid endpoints file %q: %w", r.path, err)
+	}
+	r.telemetry.recordResolution(ctx, "file", true)
+
+	r.updateLock.Lock()
+	if equalStringSlice(r.endpoints, backends) {
+		r.updateLock.Unlock()
+		return backends, nil
+	}
+
+	// the list has changed!
+	r.endpoints = backends
+	r.updateLock.Unlock()
+	r.telemetry.recordBackends(ctx, "file", len(backends))
+
+	// propagate the change
+	r.changeCallbackLock.RLock()
+	for _, callback := range r.onChangeCallbacks {
+		callback(backends)
+	}
+	r.changeCallbackLock.RUnlock()
+
+	return backends, nil
+}
+
+// parseEndpointsFile returns the normalized endpoints from either a JSON array of strings or one endpoint per line,
+// where the empty lines and the ones starting with # are skipped.
+func parseEndpointsFile(data []byte) ([]string, error) {
+	var endpoints []string
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := json.Unmarshal(data, &endpoints); err != nil {
+			return nil, err
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			endpoints = append(endpoints, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return normalizeEndpoints(endpoints)
+}
+
+// normalizeEndpoints returns the sorted endpoints without duplicates, failing when one of them is empty or holds
+// whitespace, as when several endpoints were given as one.
+func normalizeEndpoints(endpoints []string) ([]string, error) {
+	backends := make([]string, 0, len(endpoints))
+	seen := make(map[string]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		endpoint = strings.TrimSpace(endpoint)
+		if endpoint == "" || strings.ContainsAny(endpoint, " \t") {
+			return nil, fmt.Errorf("invalid endpoint %q", endpoint)
+		}
+		if seen[endpoint] {
+			continue
+		}
+		seen[endpoint] = true
+		backends = append(backends, endpoint)
+	}
+
+	// keep it always in the same order
+	sort.Strings(backends)
+	return backends, nil
+}
+
+func (r *fileResolver) onChange(f func([]string)) {
+	r.changeCallbackLock.Lock()
+	defer r.changeCallbackLock.Unlock()
+	r.onChangeCallbacks = append(r.onChangeCallbacks, f)
+}
@@ -0,0 +1,176 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+)
+
+func TestInitialFileResolution(t *testing.T) {
+	// prepare
+	path := filepath.Join(t.TempDir(), "endpoints.json")
+	require.NoError(t, os.WriteFile(path, []byte(`["endpoint-2:4317", "endpoint-1:4317"]`), 0600))
+	res, err := newFileResolver(zap.NewNop(), &FileResolver{Path: path}, nil)
+	require.NoError(t, err)
+
+	// test
+	var resolved []string
+	res.onChange(func(endpoints []string) {
+		resolved = endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, []string{"endpoint-1:4317", "endpoint-2:4317"}, resolved)
+}
+
+func TestFileResolverReloads(t *testing.T) {
+	// prepare
+	dir := t.TempDir()
+	path := filepath.Join(dir, "endpoints")
+	require.NoError(t, os.WriteFile(path, []byte("endpoint-1:4317\n"), 0600))
+	res, err := newFileResolver(zap.NewNop(), &FileResolver{Path: path}, nil)
+	require.NoError(t, err)
+	resolved := make(chan []string, 10)
+	res.onChange(func(endpoints []string) {
+		resolved <- endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+	assert.Equal(t, []string{"endpoint-1:4317"}, <-resolved)
+
+	for _, tt := range []struct {
+		name     string
+		write    func()
+		expected []string
+	}{
+		{
+			name: "written in place",
+			write: func() {
+				require.NoError(t, os.WriteFile(path, []byte("endpoint-1:4317\nendpoint-2:4317\n"), 0600))
+			},
+			expected: []string{"endpoint-1:4317", "endpoint-2:4317"},
+		},
+		{
+			name: "replaced with a rename",
+			write: func() {
+				tmp := filepath.Join(dir, "endpoints.tmp")
+				require.NoError(t, os.WriteFile(tmp, []byte("endpoint-3:4317\n"), 0600))
+				require.NoError(t, os.Rename(tmp, path))
+			},
+			expected: []string{"endpoint-3:4317"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// test
+			tt.write()
+
+			// verify
+			timeout := time.After(5 * time.Second)
+			for {
+				select {
+				case endpoints := <-resolved:
+					if assert.ObjectsAreEqual(tt.expected, endpoints) {
+						return
+					}
+				case <-timeout:
+					t.Fatal("the file wasn't read again")
+				}
+			}
+		})
+	}
+}
+
+func TestFileResolverKeepsEndpointsOnInvalidFile(t *testing.T) {
+	// prepare
+	path := filepath.Join(t.TempDir(), "endpoints")
+	require.NoError(t, os.WriteFile(path, []byte("endpoint-1:4317\n"), 0600))
+	res, err := newFileResolver(zap.NewNop(), &FileResolver{Path: path}, nil)
+	require.NoError(t, err)
+	_, err = res.resolve(context.Background())
+	require.NoError(t, err)
+
+	// test
+	require.NoError(t, os.WriteFile(path, []byte(`["endpoint-1:4317", 42]`), 0600))
+	_, err = res.resolve(context.Background())
+
+	// verify
+	assert.Error(t, err)
+	assert.Equal(t, []string{"endpoint-1:4317"}, res.endpoints)
+}
+
+func TestParseEndpointsFile(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		data     string
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "json",
+			data:     ` ["endpoint-2:4317", "endpoint-1:4317", "endpoint-2:4317"]`,
+			expected: []string{"endpoint-1:4317", "endpoint-2:4317"},
+		},
+		{
+			name:     "newline-delimited",
+			data:     "# the gateways\nendpoint-2:4317\n\n  endpoint-1:4317  \r\n",
+			expected: []string{"endpoint-1:4317", "endpoint-2:4317"},
+		},
+		{
+			name:     "empty",
+			data:     "",
+			expected: []string{},
+		},
+		{
+			name: "invalid json",
+			data: `["endpoint-1:4317"`,
+			err:  true,
+		},
+		{
+			name: "empty endpoint",
+			data: `["endpoint-1:4317", ""]`,
+			err:  true,
+		},
+		{
+			name: "several endpoints on a line",
+			data: "endpoint-1:4317 endpoint-2:4317",
+			err:  true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoints, err := parseEndpointsFile([]byte(tt.data))
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, endpoints)
+		})
+	}
+}
+
+func TestFileResolverInvalidConfig(t *testing.T) {
+	res, err := newFileResolver(zap.NewNop(), &FileResolver{}, nil)
+	assert.Equal(t, errNoFilePath, err)
+	assert.Nil(t, res)
+
+	cfg := simpleConfig()
+	cfg.Resolver.File = &FileResolver{Path: "endpoints.json"}
+	_, err = newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errMultipleResolversProvided, err)
+}
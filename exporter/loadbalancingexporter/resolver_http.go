@@ -0,0 +1,206 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.uber.org/zap"
+)
+
+var (
+	_ resolver          = (*httpResolver)(nil)
+	_ hostAwareResolver = (*httpResolver)(nil)
+)
+
+// maxHTTPResolverResponseSize is the maximum size of the endpoint list returned by the HTTP API
+const maxHTTPResolverResponseSize = 1 << 20
+
+var (
+	errNoHTTPEndpoint = errors.New("no endpoint specified for the http resolver")
+)
+
+// httpResolver periodically fetches the endpoints from an HTTP API returning them as a JSON array of strings. When
+// the response is invalid, the current endpoints are kept.
+type httpResolver struct {
+	logger    *zap.Logger
+	telemetry *telemetry
+	settings  component.TelemetrySettings
+
+	clientCfg   confighttp.ClientConfig
+	host        component.Host
+	client      *http.Client
+	resInterval time.Duration
+	resTimeout  time.Duration
+
+	endpoints         []string
+	onChangeCallbacks []func([]string)
+
+	stopCh             chan struct{}
+	updateLock         sync.Mutex
+	shutdownWg         sync.WaitGroup
+	changeCallbackLock sync.RWMutex
+}
+
+func newHTTPResolver(logger *zap.Logger, settings component.TelemetrySettings, cfg *HTTPResolver, tb *telemetry) (*httpResolver, error) {
+	if cfg.Endpoint == "" {
+		return nil, errNoHTTPEndpoint
+	}
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = defaultResInterval
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultResTimeout
+	}
+
+	return &httpResolver{
+		logger:      logger,
+		telemetry:   tb,
+		settings:    settings,
+		clientCfg:   cfg.ClientConfig,
+		resInterval: interval,
+		resTimeout:  timeout,
+		stopCh:      make(chan struct{}),
+	}, nil
+}
+
+// setHost keeps the host, used to find the authenticator of the client.
+func (r *httpResolver) setHost(host component.Host) {
+	r.host = host
+}
+
+func (r *httpResolver) start(ctx context.Context) error {
+	client, err := r.clientCfg.ToClient(r.host, r.settings)
+	if err != nil {
+		return err
+	}
+	r.client = client
+
+	if _, err := r.resolve(ctx); err != nil {
+		r.logger.Warn("failed to resolve", zap.Error(err))
+	}
+
+	go r.periodicallyResolve()
+
+	r.logger.Debug("HTTP resolver started",
+		zap.String("endpoint", r.clientCfg.Endpoint), zap.Duration("interval", r.resInterval), zap.Duration("timeout", r.resTimeout))
+	return nil
+}
+
+func (r *httpResolver) shutdown(_ context.Context) error {
+	r.changeCallbackLock.Lock()
+	r.onChangeCallbacks = nil
+	r.changeCallbackLock.Unlock()
+
+	close(r.stopCh)
+	r.shutdownWg.Wait()
+	return nil
+}
+
+func (r *httpResolver) periodicallyResolve() {
+	ticker := time.NewTicker(r.resInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), r.resTimeout)
+			if _, err := r.resolve(ctx); err != nil {
+				r.logger.Warn("failed to resolve", zap.Error(err))
+			} else {
+				r.logger.Debug("resolved successfully")
+			}
+			cancel()
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+func (r *httpResolver) resolve(ctx context.Context) ([]string, error) {
+	r.shutdownWg.Add(1)
+	defer r.shutdownWg.Done()
+
+	backends, err := r.fetch(ctx)
+	if err != nil {
+		r.telemetry.recordResolution(ctx, "http", false)
+		return nil, err
+	}
+
+	r.telemetry.recordResolution(ctx, "http", true)
+
+	r.updateLock.Lock()
+	if equalStringSlice(r.endpoints, backends) {
+		r.updateLock.Unlock()
+		return backends, nil
+	}
+
+	// the list has changed!
+	r.endpoints = backends
+	r.updateLock.Unlock()
+	r.telemetry.recordBackends(ctx, "http", len(backends))
+
+	// propagate the change
+	r.changeCallbackLock.RLock()
+	for _, callback := range r.onChangeCallbacks {
+		callback(backends)
+	}
+	r.changeCallbackLock.RUnlock()
+
+	return backends, nil
+}
+
+// fetch gets the endpoints from the HTTP API, validating them.
+func (r *httpResolver) fetch(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.clientCfg.Endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from %s: %d", r.clientCfg.Endpoint, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResolverResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxHTTPResolverResponseSize {
+		return nil, fmt.Errorf("the response from %s is larger than %d bytes", r.clientCfg.Endpoint, maxHTTPResolverResponseSize)
+	}
+
+	var endpoints []string
+	if err := json.Unmarshal(body, &endpoints); err != nil {
+		return nil, fmt.Errorf("the response from %s isn't a JSON array of endpoints: %w", r.clientCfg.Endpoint, err)
+	}
+	if endpoints == nil {
+		// a null response is most likely a bug of the API rather than the intent to remove all the backends
+		return nil, fmt.Errorf("the response from %s isn't a JSON array of endpoints", r.clientCfg.Endpoint)
+	}
+	return normalizeEndpoints(endpoints)
+}
+
+func (r *httpResolver) onChange(f func([]string)) {
+	r.changeCallbackLock.Lock()
+	defer r.changeCallbackLock.Unlock()
+	r.onChangeCallbacks = append(r.onChangeCallbacks, f)
+}
@@ -0,0 +1,241 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configauth"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/extension/auth"
+	"go.uber.org/zap"
+)
+
+// authHost is a host holding a client authenticator setting a bearer token.
+type authHost struct {
+	component.Host
+	id component.ID
+}
+
+func (h *authHost) GetExtensions() map[component.ID]component.Component {
+	return map[component.ID]component.Component{
+		h.id: auth.NewClient(auth.WithClientRoundTripper(func(base http.RoundTripper) (http.RoundTripper, error) {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Set("Authorization", "Bearer control-plane")
+				return base.RoundTrip(req)
+			}), nil
+		})),
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func newMockControlPlane(t *testing.T, body func() string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer control-plane" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body()))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestHTTPResolver(t *testing.T, endpoint string, interval time.Duration) *httpResolver {
+	authID := component.MustNewID("bearertokenauth")
+	res, err := newHTTPResolver(zap.NewNop(), componenttest.NewNopTelemetrySettings(), &HTTPResolver{
+		ClientConfig: confighttp.ClientConfig{
+			Endpoint: endpoint,
+			Auth:     &configauth.Authentication{AuthenticatorID: authID},
+		},
+		Interval: interval,
+	}, nil)
+	require.NoError(t, err)
+	res.setHost(&authHost{Host: componenttest.NewNopHost(), id: authID})
+	return res
+}
+
+func TestInitialHTTPResolution(t *testing.T) {
+	// prepare
+	server := newMockControlPlane(t, func() string {
+		return `["endpoint-2:4317", "endpoint-1:4317"]`
+	})
+	res := newTestHTTPResolver(t, server.URL+"/v1/backends", 0)
+
+	// test
+	var resolved []string
+	res.onChange(func(endpoints []string) {
+		resolved = endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, []string{"endpoint-1:4317", "endpoint-2:4317"}, resolved)
+}
+
+func TestHTTPResolverGetsHostFromLoadBalancer(t *testing.T) {
+	// prepare
+	server := newMockControlPlane(t, func() string {
+		return `["endpoint-1:4317"]`
+	})
+	authID := component.MustNewID("bearertokenauth")
+	cfg := simpleConfig()
+	cfg.Resolver = ResolverSettings{HTTP: &HTTPResolver{
+		ClientConfig: confighttp.ClientConfig{
+			Endpoint: server.URL,
+			Auth:     &configauth.Authentication{AuthenticatorID: authID},
+		},
+	}}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	})
+	require.NoError(t, err)
+
+	// test
+	require.NoError(t, lb.Start(context.Background(), &authHost{Host: componenttest.NewNopHost(), id: authID}))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	assertRingEndpoints(t, lb, []string{"endpoint-1:4317"})
+}
+
+func TestHTTPPeriodicallyResolve(t *testing.T) {
+	// prepare
+	var lock sync.Mutex
+	body := `["endpoint-1:4317"]`
+	server := newMockControlPlane(t, func() string {
+		lock.Lock()
+		defer lock.Unlock()
+		return body
+	})
+	res := newTestHTTPResolver(t, server.URL, 10*time.Millisecond)
+	resolved := make(chan []string, 10)
+	res.onChange(func(endpoints []string) {
+		resolved <- endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+	assert.Equal(t, []string{"endpoint-1:4317"}, <-resolved)
+
+	// test
+	lock.Lock()
+	body = `["endpoint-1:4317", "endpoint-2:4317"]`
+	lock.Unlock()
+
+	// verify
+	select {
+	case endpoints := <-resolved:
+		assert.Equal(t, []string{"endpoint-1:4317", "endpoint-2:4317"}, endpoints)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the endpoints weren't fetched again")
+	}
+}
+
+func TestHTTPResolverRejectsInvalidPayloads(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		body string
+		err  string
+	}{
+		{
+			name: "not an array",
+			body: `{"endpoints": ["endpoint-1:4317"]}`,
+			err:  "isn't a JSON array of endpoints",
+		},
+		{
+			name: "null",
+			body: `null`,
+			err:  "isn't a JSON array of endpoints",
+		},
+		{
+			name: "empty endpoint",
+			body: `["endpoint-1:4317", " "]`,
+			err:  `invalid endpoint ""`,
+		},
+		{
+			name: "too large",
+			body: `["` + strings.Repeat("a", maxHTTPResolverResponseSize) + `"]`,
+			err:  "is larger than",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// prepare
+			body := `["endpoint-1:4317"]`
+			server := newMockControlPlane(t, func() string {
+				return body
+			})
+			res := newTestHTTPResolver(t, server.URL, 0)
+			require.NoError(t, res.start(context.Background()))
+			defer func() {
+				require.NoError(t, res.shutdown(context.Background()))
+			}()
+
+			// test
+			body = tt.body
+			_, err := res.resolve(context.Background())
+
+			// verify
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+			assert.Equal(t, []string{"endpoint-1:4317"}, res.endpoints)
+		})
+	}
+}
+
+func TestHTTPResolverUnauthorized(t *testing.T) {
+	// prepare
+	server := newMockControlPlane(t, func() string {
+		return `["endpoint-1:4317"]`
+	})
+	res, err := newHTTPResolver(zap.NewNop(), componenttest.NewNopTelemetrySettings(), &HTTPResolver{
+		ClientConfig: confighttp.ClientConfig{Endpoint: server.URL},
+	}, nil)
+	require.NoError(t, err)
+	res.setHost(componenttest.NewNopHost())
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// test
+	_, err = res.resolve(context.Background())
+
+	// verify
+	assert.ErrorContains(t, err, "unexpected status code")
+}
+
+func TestHTTPResolverInvalidConfig(t *testing.T) {
+	res, err := newHTTPResolver(zap.NewNop(), componenttest.NewNopTelemetrySettings(), &HTTPResolver{}, nil)
+	assert.Equal(t, errNoHTTPEndpoint, err)
+	assert.Nil(t, res)
+
+	cfg := simpleConfig()
+	cfg.Resolver.HTTP = &HTTPResolver{ClientConfig: confighttp.ClientConfig{Endpoint: "http://control-plane/v1/backends"}}
+	_, err = newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errMultipleResolversProvided, err)
+}
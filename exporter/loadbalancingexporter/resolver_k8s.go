@@ -7,22 +7,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
-	"go.opencensus.io/stats"
-	"go.opencensus.io/tag"
+	"go.opentelemetry.io/collector/component"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/utils/ptr"
 	"k8s.io/utils/strings/slices"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
@@ -30,27 +36,45 @@ import (
 
 var _ resolver = (*k8sResolver)(nil)
 
+const (
+	k8sAuthTypeServiceAccount = "serviceAccount"
+	k8sAuthTypeKubeConfig     = "kubeConfig"
+)
+
 var (
-	errNoSvc                        = errors.New("no service specified to resolve the backends")
-	k8sResolverMutator              = tag.Upsert(tag.MustNewKey("resolver"), "k8s")
-	k8sResolverSuccessTrueMutators  = []tag.Mutator{k8sResolverMutator, successTrueMutator}
-	k8sResolverSuccessFalseMutators = []tag.Mutator{k8sResolverMutator, successFalseMutator}
+	errNoSvc                      = errors.New("no service specified to resolve the backends")
+	errZonesWithoutEndpointSlices = errors.New("the endpoints can only be filtered by zone when using the EndpointSlices")
 )
 
 type k8sResolver struct {
-	logger  *zap.Logger
-	svcName string
-	svcNs   string
-	port    []int32
+	logger    *zap.Logger
+	telemetry *telemetry
+	svcName   string
+	svcNs     string
+	port      []int32
 
-	handler        *handler
+	handler        cache.ResourceEventHandler
 	once           *sync.Once
 	epsListWatcher cache.ListerWatcher
+	epsType        runtime.Object
 	endpointsStore *sync.Map
 
+	// podListWatcher lists the pods matching the pod selector, only their addresses being used when set
+	podListWatcher cache.ListerWatcher
+	pods           *podFilter
+
+	// conversion selects the endpoints kept from the Endpoints or the EndpointSlices
+	conversion *conversionOptions
+	// clusterDomain is appended to the hostnames of the endpoints, when returning them
+	clusterDomain string
+
 	endpoints         []string
 	onChangeCallbacks []func([]string)
 
+	// reportStatus reports the degraded status while the connection to the API server is lost
+	reportStatus func(*component.StatusEvent)
+	disconnected atomic.Bool
+
 	stopCh             chan struct{}
 	updateLock         sync.RWMutex
 	shutdownWg         sync.WaitGroup
@@ -60,7 +84,8 @@ type k8sResolver struct {
 func newK8sResolver(clt kubernetes.Interface,
 	logger *zap.Logger,
 	service string,
-	ports []int32) (*k8sResolver, error) {
+	ports []int32,
+	endpointSlices bool, tb *telemetry) (*k8sResolver, error) {
 
 	if len(service) == 0 {
 		return nil, errNoSvc
@@ -80,8 +105,41 @@ func newK8sResolver(clt kubernetes.Interface,
 		}
 	}
 
+	epsStore := &sync.Map{}
+	r := &k8sResolver{
+		logger:         logger,
+		telemetry:      tb,
+		svcName:        name,
+		svcNs:          namespace,
+		port:           ports,
+		once:           &sync.Once{},
+		endpointsStore: epsStore,
+		conversion:     &conversionOptions{},
+		stopCh:         make(chan struct{}),
+	}
+
+	if endpointSlices {
+		// a service has as many slices as needed to hold its endpoints, all of them labeled with its name
+		slicesSelector := fmt.Sprintf("%s=%s", discoveryv1.LabelServiceName, name)
+		r.epsListWatcher = &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = slicesSelector
+				options.TimeoutSeconds = ptr.To[int64](1)
+				return clt.DiscoveryV1().EndpointSlices(namespace).List(context.Background(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = slicesSelector
+				options.TimeoutSeconds = ptr.To[int64](1)
+				return clt.DiscoveryV1().EndpointSlices(namespace).Watch(context.Background(), options)
+			},
+		}
+		r.epsType = &discoveryv1.EndpointSlice{}
+		r.handler = &sliceHandler{endpoints: epsStore, logger: logger, telemetry: tb, callback: r.resolve, opts: r.conversion, slices: map[string][]string{}}
+		return r, nil
+	}
+
 	epsSelector := fmt.Sprintf("metadata.name=%s", name)
-	epsListWatcher := &cache.ListWatch{
+	r.epsListWatcher = &cache.ListWatch{
 		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
 			options.FieldSelector = epsSelector
 			options.TimeoutSeconds = ptr.To[int64](1)
@@ -93,34 +151,107 @@ func newK8sResolver(clt kubernetes.Interface,
 			return clt.CoreV1().Endpoints(namespace).Watch(context.Background(), options)
 		},
 	}
+	r.epsType = &corev1.Endpoints{}
+	r.handler = &handler{endpoints: epsStore, logger: logger, telemetry: tb, callback: r.resolve, opts: r.conversion}
 
-	epsStore := &sync.Map{}
-	h := &handler{endpoints: epsStore, logger: logger}
-	r := &k8sResolver{
-		logger:         logger,
-		svcName:        name,
-		svcNs:          namespace,
-		port:           ports,
-		once:           &sync.Once{},
-		endpointsStore: epsStore,
-		epsListWatcher: epsListWatcher,
-		handler:        h,
-		stopCh:         make(chan struct{}),
-	}
-	h.callback = r.resolve
+	return r, nil
+}
 
+// newConfiguredK8sResolver returns a resolver for one of the services of the configuration, applying its options.
+func newConfiguredK8sResolver(clt kubernetes.Interface, logger *zap.Logger, service string, cfg *K8sSvcResolver, tb *telemetry) (*k8sResolver, error) {
+	r, err := newK8sResolver(clt, logger, service, cfg.Ports, cfg.UseEndpointSlices, tb)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.IncludeNotReady {
+		r.includeNotReady()
+	}
+	if cfg.ReturnHostnames {
+		r.returnHostnames(cfg.ClusterDomain)
+	}
+	if cfg.PodSelector != "" {
+		if err = r.filterPods(clt, cfg.PodSelector); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.Zones) > 0 {
+		if err = r.filterZones(cfg.Zones); err != nil {
+			return nil, err
+		}
+	}
 	return r, nil
 }
 
+// filterPods keeps only the addresses of the pods matching the given label selector.
+func (r *k8sResolver) filterPods(clt kubernetes.Interface, selector string) error {
+	if _, err := labels.Parse(selector); err != nil {
+		return fmt.Errorf("invalid pod selector for the k8s resolver: %w", err)
+	}
+
+	r.podListWatcher = &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = selector
+			options.TimeoutSeconds = ptr.To[int64](1)
+			return clt.CoreV1().Pods(r.svcNs).List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = selector
+			options.TimeoutSeconds = ptr.To[int64](1)
+			return clt.CoreV1().Pods(r.svcNs).Watch(context.Background(), options)
+		},
+	}
+	r.pods = &podFilter{logger: r.logger, callback: r.resolve, pods: map[string][]string{}, addresses: map[string]bool{}}
+	return nil
+}
+
+// filterZones keeps only the endpoints in the given zones, which are only known from the EndpointSlices.
+func (r *k8sResolver) filterZones(zones []string) error {
+	if _, ok := r.handler.(*sliceHandler); !ok {
+		return errZonesWithoutEndpointSlices
+	}
+	r.conversion.zones = zones
+	return nil
+}
+
+// includeNotReady also uses the addresses of the endpoints that aren't ready yet, so that the new pods are added to
+// the ring once, before they pass their readiness checks.
+func (r *k8sResolver) includeNotReady() {
+	r.conversion.includeNotReady = true
+}
+
+// returnHostnames uses the DNS names of the pods, <hostname>.<service>.<namespace>, instead of their addresses, such
+// as for the TLS certificates of the pods to be verified. Only the pods with a hostname, such as the ones of a
+// StatefulSet, are used. When a cluster domain is given, the names are fully qualified.
+func (r *k8sResolver) returnHostnames(clusterDomain string) {
+	r.conversion.returnHostnames = true
+	r.clusterDomain = clusterDomain
+}
+
 func (r *k8sResolver) start(_ context.Context) error {
 	var initErr error
 	r.once.Do(func() {
+		if r.podListWatcher != nil {
+			r.logger.Debug("creating and starting pods informer")
+			podInformer := cache.NewSharedInformer(r.podListWatcher, &corev1.Pod{}, 0)
+			if _, err := podInformer.AddEventHandler(r.pods); err != nil {
+				r.logger.Error("unable to start watching for changes to the selected pods", zap.Error(err))
+			}
+			go podInformer.Run(r.stopCh)
+			if !cache.WaitForCacheSync(r.stopCh, podInformer.HasSynced) {
+				initErr = errors.New("pods informer not sync")
+				return
+			}
+		}
 		if r.epsListWatcher != nil {
 			r.logger.Debug("creating and starting endpoints informer")
-			epsInformer := cache.NewSharedInformer(r.epsListWatcher, &corev1.Endpoints{}, 0)
+			lw := &connectionTrackingListWatcher{ListerWatcher: r.epsListWatcher, onConnected: r.onConnected}
+			epsInformer := cache.NewSharedInformer(lw, r.epsType, 0)
 			if _, err := epsInformer.AddEventHandler(r.handler); err != nil {
 				r.logger.Error("unable to start watching for changes to the specified service names", zap.Error(err))
 			}
+			if err := epsInformer.SetWatchErrorHandler(r.onWatchError); err != nil {
+				r.logger.Error("unable to watch for the errors from the API server", zap.Error(err))
+			}
 			go epsInformer.Run(r.stopCh)
 			if !cache.WaitForCacheSync(r.stopCh, epsInformer.HasSynced) {
 				initErr = errors.New("endpoints informer not sync")
@@ -147,12 +278,88 @@ func (r *k8sResolver) shutdown(_ context.Context) error {
 	r.shutdownWg.Wait()
 	return nil
 }
-func newInClusterClient() (kubernetes.Interface, error) {
-	cfg, err := config.GetConfig()
+
+// onWatchError is called by the informer when listing or watching the endpoints fails. The informer keeps retrying
+// with a backoff, while the endpoints known so far are kept in use.
+func (r *k8sResolver) onWatchError(_ *cache.Reflector, err error) {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+		// the watch was closed or has to start over, which the informer handles without losing the connection
+		return
+	}
+
+	r.telemetry.recordResolution(context.Background(), "k8s", false)
+	if r.disconnected.CompareAndSwap(false, true) {
+		r.logger.Warn("lost the connection to the Kubernetes API server, keeping the current endpoints until it's restored", zap.Error(err))
+		r.reportStatusEvent(component.NewRecoverableErrorEvent(err))
+	}
+}
+
+// onConnected is called whenever listing or watching the endpoints succeeds.
+func (r *k8sResolver) onConnected() {
+	if r.disconnected.CompareAndSwap(true, false) {
+		r.logger.Info("connection to the Kubernetes API server restored, re-syncing the endpoints")
+		r.reportStatusEvent(component.NewStatusEvent(component.StatusOK))
+	}
+}
+
+func (r *k8sResolver) reportStatusEvent(ev *component.StatusEvent) {
+	if r.reportStatus != nil {
+		r.reportStatus(ev)
+	}
+}
+
+// connectionTrackingListWatcher notifies about the successful calls to the API server, signaling that the
+// connection is established.
+type connectionTrackingListWatcher struct {
+	cache.ListerWatcher
+	onConnected func()
+}
+
+func (lw *connectionTrackingListWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	obj, err := lw.ListerWatcher.List(options)
+	if err == nil {
+		lw.onConnected()
+	}
+	return obj, err
+}
+
+func (lw *connectionTrackingListWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w, err := lw.ListerWatcher.Watch(options)
+	if err == nil {
+		lw.onConnected()
+	}
+	return w, err
+}
+
+// newK8sClient returns a client for the cluster selected by the authentication type: the in-cluster service account,
+// or a kubeconfig file along with an optional context. When no authentication type is set, the usual lookup is made,
+// trying the kubeconfig set by the environment before the in-cluster service account.
+func newK8sClient(cfg *K8sSvcResolver) (kubernetes.Interface, error) {
+	restCfg, err := newK8sRestConfig(cfg)
 	if err != nil {
 		return nil, err
 	}
-	return kubernetes.NewForConfig(cfg)
+	return kubernetes.NewForConfig(restCfg)
+}
+
+func newK8sRestConfig(cfg *K8sSvcResolver) (*rest.Config, error) {
+	switch cfg.AuthType {
+	case "":
+		return config.GetConfig()
+	case k8sAuthTypeServiceAccount:
+		return rest.InClusterConfig()
+	case k8sAuthTypeKubeConfig:
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		loadingRules.ExplicitPath = cfg.KubeConfig
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: cfg.Context}
+		restCfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the kubeconfig: %w", err)
+		}
+		return restCfg, nil
+	default:
+		return nil, fmt.Errorf("invalid auth_type %q for the k8s resolver, expected %q or %q", cfg.AuthType, k8sAuthTypeServiceAccount, k8sAuthTypeKubeConfig)
+	}
 }
 
 func (r *k8sResolver) resolve(ctx context.Context) ([]string, error) {
@@ -162,6 +369,15 @@ func (r *k8sResolver) resolve(ctx context.Context) ([]string, error) {
 	var backends []string
 	r.endpointsStore.Range(func(address, value any) bool {
 		addr := address.(string)
+		if r.pods != nil && !r.pods.matches(addr) {
+			return true
+		}
+		if r.conversion.returnHostnames {
+			addr = fmt.Sprintf("%s.%s.%s", addr, r.svcName, r.svcNs)
+			if r.clusterDomain != "" {
+				addr = fmt.Sprintf("%s.svc.%s", addr, r.clusterDomain)
+			}
+		}
 		if len(r.port) == 0 {
 			backends = append(backends, addr)
 		} else {
@@ -171,7 +387,7 @@ func (r *k8sResolver) resolve(ctx context.Context) ([]string, error) {
 		}
 		return true
 	})
-	_ = stats.RecordWithTags(ctx, k8sResolverSuccessTrueMutators, mNumResolutions.M(1))
+	r.telemetry.recordResolution(ctx, "k8s", true)
 
 	// keep it always in the same order
 	sort.Strings(backends)
@@ -184,7 +400,7 @@ func (r *k8sResolver) resolve(ctx context.Context) ([]string, error) {
 	r.updateLock.Lock()
 	r.endpoints = backends
 	r.updateLock.Unlock()
-	_ = stats.RecordWithTags(ctx, k8sResolverSuccessTrueMutators, mNumBackends.M(int64(len(backends))))
+	r.telemetry.recordBackends(ctx, "k8s", len(backends))
 
 	// propagate the change
 	r.changeCallbackLock.RLock()
@@ -7,18 +7,44 @@ import (
 	"context"
 	"sync"
 
-	"go.opencensus.io/stats"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/strings/slices"
 )
 
-var _ cache.ResourceEventHandler = (*handler)(nil)
+var (
+	_ cache.ResourceEventHandler = (*handler)(nil)
+	_ cache.ResourceEventHandler = (*sliceHandler)(nil)
+	_ cache.ResourceEventHandler = (*podFilter)(nil)
+)
 
 type handler struct {
 	endpoints *sync.Map
 	callback  func(ctx context.Context) ([]string, error)
 	logger    *zap.Logger
+	telemetry *telemetry
+	opts      *conversionOptions
+}
+
+// conversionOptions selects the endpoints kept from the Endpoints or the EndpointSlices, and how they're identified.
+type conversionOptions struct {
+	// zones holds the zones of the endpoints to keep, all of them being kept when empty
+	zones []string
+	// includeNotReady also keeps the endpoints that aren't ready yet, but not the terminating ones when known
+	includeNotReady bool
+	// returnHostnames keeps the hostnames of the endpoints instead of their addresses, skipping the endpoints
+	// without a hostname
+	returnHostnames bool
+}
+
+// endpointID returns the address of an endpoint, or its hostname if requested, empty when it has none.
+func (o *conversionOptions) endpointID(ip string, hostname string) string {
+	if o.returnHostnames {
+		return hostname
+	}
+	return ip
 }
 
 func (h handler) OnAdd(obj any, _ bool) {
@@ -26,10 +52,10 @@ func (h handler) OnAdd(obj any, _ bool) {
 
 	switch object := obj.(type) {
 	case *corev1.Endpoints:
-		endpoints = convertToEndpoints(object)
+		endpoints = convertToEndpoints(h.opts, object)
 	default: // unsupported
 		h.logger.Warn("Got an unexpected Kubernetes data type during the inclusion of a new pods for the service", zap.Any("obj", obj))
-		_ = stats.RecordWithTags(context.Background(), k8sResolverSuccessFalseMutators, mNumResolutions.M(1))
+		h.telemetry.recordResolution(context.Background(), "k8s", false)
 		return
 	}
 	changed := false
@@ -46,22 +72,27 @@ func (h handler) OnAdd(obj any, _ bool) {
 func (h handler) OnUpdate(oldObj, newObj any) {
 	switch oldEps := oldObj.(type) {
 	case *corev1.Endpoints:
-		epRemove := convertToEndpoints(oldEps)
-		for _, ep := range epRemove {
-			h.endpoints.Delete(ep)
-		}
-		if len(epRemove) > 0 {
-			_, _ = h.callback(context.Background())
-		}
-
 		newEps, ok := newObj.(*corev1.Endpoints)
 		if !ok {
 			h.logger.Warn("Got an unexpected Kubernetes data type during the update of the pods for a service", zap.Any("obj", newObj))
-			_ = stats.RecordWithTags(context.Background(), k8sResolverSuccessFalseMutators, mNumResolutions.M(1))
+			h.telemetry.recordResolution(context.Background(), "k8s", false)
 			return
 		}
+
+		// only the endpoints that are gone are removed, so that the endpoints still in use are kept all along,
+		// such as when the informer re-syncs after a lost connection
+		current := map[string]bool{}
+		for _, ep := range convertToEndpoints(h.opts, newEps) {
+			current[ep] = true
+		}
 		changed := false
-		for _, ep := range convertToEndpoints(newEps) {
+		for _, ep := range convertToEndpoints(h.opts, oldEps) {
+			if !current[ep] {
+				h.endpoints.Delete(ep)
+				changed = true
+			}
+		}
+		for ep := range current {
 			if _, loaded := h.endpoints.LoadOrStore(ep, true); !loaded {
 				changed = true
 			}
@@ -71,7 +102,7 @@ func (h handler) OnUpdate(oldObj, newObj any) {
 		}
 	default: // unsupported
 		h.logger.Warn("Got an unexpected Kubernetes data type during the update of the pods for a service", zap.Any("obj", oldObj))
-		_ = stats.RecordWithTags(context.Background(), k8sResolverSuccessFalseMutators, mNumResolutions.M(1))
+		h.telemetry.recordResolution(context.Background(), "k8s", false)
 		return
 	}
 }
@@ -84,11 +115,11 @@ func (h handler) OnDelete(obj any) {
 		return
 	case *corev1.Endpoints:
 		if object != nil {
-			endpoints = convertToEndpoints(object)
+			endpoints = convertToEndpoints(h.opts, object)
 		}
 	default: // unsupported
 		h.logger.Warn("Got an unexpected Kubernetes data type during the removal of the pods for a service", zap.Any("obj", obj))
-		_ = stats.RecordWithTags(context.Background(), k8sResolverSuccessFalseMutators, mNumResolutions.M(1))
+		h.telemetry.recordResolution(context.Background(), "k8s", false)
 		return
 	}
 	if len(endpoints) != 0 {
@@ -99,14 +130,244 @@ func (h handler) OnDelete(obj any) {
 	}
 }
 
-func convertToEndpoints(eps ...*corev1.Endpoints) []string {
+func convertToEndpoints(opts *conversionOptions, eps ...*corev1.Endpoints) []string {
 	var ipAddress []string
 	for _, ep := range eps {
 		for _, subsets := range ep.Subsets {
-			for _, addr := range subsets.Addresses {
-				ipAddress = append(ipAddress, addr.IP)
+			addresses := subsets.Addresses
+			if opts.includeNotReady {
+				addresses = append(addresses[:len(addresses):len(addresses)], subsets.NotReadyAddresses...)
+			}
+			for _, addr := range addresses {
+				if id := opts.endpointID(addr.IP, addr.Hostname); id != "" {
+					ipAddress = append(ipAddress, id)
+				}
 			}
 		}
 	}
 	return ipAddress
 }
+
+// sliceHandler keeps the endpoints of all the EndpointSlices of a service. As an address may be listed by more than
+// one slice, such as while it moves from a slice to another, the endpoints of each slice are tracked and merged, an
+// address being removed only once no slice lists it anymore.
+type sliceHandler struct {
+	endpoints *sync.Map
+	callback  func(ctx context.Context) ([]string, error)
+	logger    *zap.Logger
+	telemetry *telemetry
+	opts      *conversionOptions
+
+	lock   sync.Mutex
+	slices map[string][]string
+}
+
+func (h *sliceHandler) OnAdd(obj any, _ bool) {
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		h.logger.Warn("Got an unexpected Kubernetes data type during the inclusion of a new pods for the service", zap.Any("obj", obj))
+		h.telemetry.recordResolution(context.Background(), "k8s", false)
+		return
+	}
+	h.update(sliceKey(slice), convertSliceToEndpoints(slice, h.opts))
+}
+
+func (h *sliceHandler) OnUpdate(_, newObj any) {
+	slice, ok := newObj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		h.logger.Warn("Got an unexpected Kubernetes data type during the update of the pods for a service", zap.Any("obj", newObj))
+		h.telemetry.recordResolution(context.Background(), "k8s", false)
+		return
+	}
+	h.update(sliceKey(slice), convertSliceToEndpoints(slice, h.opts))
+}
+
+func (h *sliceHandler) OnDelete(obj any) {
+	switch object := obj.(type) {
+	case cache.DeletedFinalStateUnknown:
+		h.update(object.Key, nil)
+	case *cache.DeletedFinalStateUnknown:
+		h.update(object.Key, nil)
+	case *discoveryv1.EndpointSlice:
+		h.update(sliceKey(object), nil)
+	default: // unsupported
+		h.logger.Warn("Got an unexpected Kubernetes data type during the removal of the pods for a service", zap.Any("obj", obj))
+		h.telemetry.recordResolution(context.Background(), "k8s", false)
+	}
+}
+
+// update replaces the endpoints of a slice, removing it when it has none, and stores the merged endpoints of all the
+// slices. Only the endpoints that are gone are removed from the store, so that the ones still in use are kept all
+// along.
+func (h *sliceHandler) update(key string, endpoints []string) {
+	h.lock.Lock()
+	if len(endpoints) == 0 {
+		delete(h.slices, key)
+	} else {
+		h.slices[key] = endpoints
+	}
+
+	current := map[string]bool{}
+	for _, eps := range h.slices {
+		for _, ep := range eps {
+			current[ep] = true
+		}
+	}
+	changed := false
+	h.endpoints.Range(func(ep, _ any) bool {
+		if !current[ep.(string)] {
+			h.endpoints.Delete(ep)
+			changed = true
+		}
+		return true
+	})
+	for ep := range current {
+		if _, loaded := h.endpoints.LoadOrStore(ep, true); !loaded {
+			changed = true
+		}
+	}
+	h.lock.Unlock()
+
+	if changed {
+		_, _ = h.callback(context.Background())
+	}
+}
+
+func sliceKey(slice *discoveryv1.EndpointSlice) string {
+	return slice.Namespace + "/" + slice.Name
+}
+
+// convertSliceToEndpoints returns the addresses of the ready endpoints of a slice, an unknown readiness meaning that
+// the endpoint is ready. The slices of FQDNs are skipped, as the Endpoints API only ever held IP addresses. When zones
+// are given, only the endpoints in one of them are kept. When the endpoints that aren't ready are included, the
+// terminating ones are still skipped, as they're about to go away.
+func convertSliceToEndpoints(slice *discoveryv1.EndpointSlice, opts *conversionOptions) []string {
+	if slice.AddressType == discoveryv1.AddressTypeFQDN {
+		return nil
+	}
+
+	var ipAddress []string
+	for _, ep := range slice.Endpoints {
+		if opts.includeNotReady {
+			if ep.Conditions.Terminating != nil && *ep.Conditions.Terminating {
+				continue
+			}
+		} else if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+			continue
+		}
+		if len(opts.zones) > 0 && (ep.Zone == nil || !slices.Contains(opts.zones, *ep.Zone)) {
+			continue
+		}
+		if opts.returnHostnames {
+			if ep.Hostname != nil && *ep.Hostname != "" {
+				ipAddress = append(ipAddress, *ep.Hostname)
+			}
+			continue
+		}
+		ipAddress = append(ipAddress, ep.Addresses...)
+	}
+	return ipAddress
+}
+
+// podFilter keeps the addresses of the pods matching the pod selector, so that only the endpoints backed by one of
+// them are used. The endpoints are resolved again whenever the addresses change, such as when a pod gets or loses
+// one of the selected labels.
+type podFilter struct {
+	logger   *zap.Logger
+	callback func(ctx context.Context) ([]string, error)
+
+	lock      sync.RWMutex
+	pods      map[string][]string
+	addresses map[string]bool
+}
+
+func (f *podFilter) OnAdd(obj any, _ bool) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		f.logger.Warn("Got an unexpected Kubernetes data type during the inclusion of a new selected pod", zap.Any("obj", obj))
+		return
+	}
+	f.update(pod.Namespace+"/"+pod.Name, podAddresses(pod))
+}
+
+func (f *podFilter) OnUpdate(_, newObj any) {
+	pod, ok := newObj.(*corev1.Pod)
+	if !ok {
+		f.logger.Warn("Got an unexpected Kubernetes data type during the update of a selected pod", zap.Any("obj", newObj))
+		return
+	}
+	f.update(pod.Namespace+"/"+pod.Name, podAddresses(pod))
+}
+
+func (f *podFilter) OnDelete(obj any) {
+	switch object := obj.(type) {
+	case cache.DeletedFinalStateUnknown:
+		f.update(object.Key, nil)
+	case *cache.DeletedFinalStateUnknown:
+		f.update(object.Key, nil)
+	case *corev1.Pod:
+		f.update(object.Namespace+"/"+object.Name, nil)
+	default: // unsupported
+		f.logger.Warn("Got an unexpected Kubernetes data type during the removal of a selected pod", zap.Any("obj", obj))
+	}
+}
+
+// update replaces the addresses of a pod, resolving the endpoints again when the addresses of the selected pods
+// changed.
+func (f *podFilter) update(key string, addresses []string) {
+	f.lock.Lock()
+	if len(addresses) == 0 {
+		delete(f.pods, key)
+	} else {
+		f.pods[key] = addresses
+	}
+
+	// pods sharing the address of their node may come and go, so the addresses are always computed from all pods
+	current := map[string]bool{}
+	for _, addrs := range f.pods {
+		for _, addr := range addrs {
+			current[addr] = true
+		}
+	}
+	changed := len(current) != len(f.addresses)
+	for addr := range current {
+		if !f.addresses[addr] {
+			changed = true
+		}
+	}
+	f.addresses = current
+	f.lock.Unlock()
+
+	if changed {
+		_, _ = f.callback(context.Background())
+	}
+}
+
+// matches tells whether the address is the one of a selected pod.
+func (f *podFilter) matches(address string) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.addresses[address]
+}
+
+// podAddresses returns the addresses of a pod along with its hostname, matching the endpoints whether they're
+// identified by their addresses or hostnames.
+func podAddresses(pod *corev1.Pod) []string {
+	var addresses []string
+	for _, ip := range pod.Status.PodIPs {
+		addresses = append(addresses, ip.IP)
+	}
+	if len(addresses) == 0 && pod.Status.PodIP != "" {
+		addresses = append(addresses, pod.Status.PodIP)
+	}
+	if len(addresses) == 0 {
+		// the pod isn't running yet
+		return nil
+	}
+
+	hostname := pod.Spec.Hostname
+	if hostname == "" {
+		hostname = pod.Name
+	}
+	return append(addresses, hostname)
+}
@@ -5,17 +5,28 @@ package loadbalancingexporter
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -56,7 +67,7 @@ func TestK8sResolve(t *testing.T) {
 		}
 
 		cl := fake.NewSimpleClientset(endpoint)
-		res, err := newK8sResolver(cl, zap.NewNop(), service, ports)
+		res, err := newK8sResolver(cl, zap.NewNop(), service, ports, false, nil)
 		require.NoError(t, err)
 
 		require.NoError(t, res.start(context.Background()))
@@ -241,7 +252,7 @@ func Test_newK8sResolver(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := newK8sResolver(fake.NewSimpleClientset(), tt.args.logger, tt.args.service, tt.args.ports)
+			got, err := newK8sResolver(fake.NewSimpleClientset(), tt.args.logger, tt.args.service, tt.args.ports, false, nil)
 			if tt.wantErr != nil {
 				require.Error(t, err, tt.wantErr)
 			} else {
@@ -255,3 +266,447 @@ func Test_newK8sResolver(t *testing.T) {
 		})
 	}
 }
+
+// flakyListWatcher fails to list and watch while the connection to the API server is lost.
+type flakyListWatcher struct {
+	cache.ListerWatcher
+
+	lock         sync.Mutex
+	disconnected bool
+	watches      []watch.Interface
+}
+
+func (lw *flakyListWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	if lw.disconnected {
+		return nil, errors.New("connection refused")
+	}
+	return lw.ListerWatcher.List(options)
+}
+
+func (lw *flakyListWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	if lw.disconnected {
+		return nil, errors.New("connection refused")
+	}
+	w, err := lw.ListerWatcher.Watch(options)
+	if err == nil {
+		lw.watches = append(lw.watches, w)
+	}
+	return w, err
+}
+
+// disconnect drops the ongoing watches and fails the following calls.
+func (lw *flakyListWatcher) disconnect() {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	lw.disconnected = true
+	for _, w := range lw.watches {
+		w.Stop()
+	}
+	lw.watches = nil
+}
+
+func (lw *flakyListWatcher) reconnect() {
+	lw.lock.Lock()
+	defer lw.lock.Unlock()
+	lw.disconnected = false
+}
+
+func TestK8sResolveLostConnection(t *testing.T) {
+	// prepare
+	endpoint := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "lb", Namespace: "default"},
+		Subsets: []corev1.EndpointSubset{
+			{Addresses: []corev1.EndpointAddress{{IP: "192.168.10.100"}}},
+		},
+	}
+	cl := fake.NewSimpleClientset(endpoint)
+	res, err := newK8sResolver(cl, zap.NewNop(), "lb.default", []int32{4317}, false, nil)
+	require.NoError(t, err)
+
+	lw := &flakyListWatcher{ListerWatcher: res.epsListWatcher}
+	res.epsListWatcher = lw
+
+	var statusLock sync.Mutex
+	var statuses []component.Status
+	res.reportStatus = func(ev *component.StatusEvent) {
+		statusLock.Lock()
+		defer statusLock.Unlock()
+		statuses = append(statuses, ev.Status())
+	}
+	lastStatus := func() component.Status {
+		statusLock.Lock()
+		defer statusLock.Unlock()
+		if len(statuses) == 0 {
+			return component.StatusNone
+		}
+		return statuses[len(statuses)-1]
+	}
+
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+	require.Equal(t, []string{"192.168.10.100:4317"}, res.Endpoints())
+
+	// test
+	lw.disconnect()
+
+	// verify
+	require.Eventually(t, func() bool {
+		return lastStatus() == component.StatusRecoverableError
+	}, 10*time.Second, 20*time.Millisecond)
+	assert.Equal(t, []string{"192.168.10.100:4317"}, res.Endpoints(), "the endpoints must be kept during the outage")
+
+	// the endpoints change during the outage, and are re-synced once the connection is restored
+	updated := endpoint.DeepCopy()
+	updated.Subsets = []corev1.EndpointSubset{
+		{Addresses: []corev1.EndpointAddress{{IP: "192.168.10.100"}, {IP: "10.10.0.11"}}},
+	}
+	_, err = cl.CoreV1().Endpoints("default").Update(context.Background(), updated, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.168.10.100:4317"}, res.Endpoints())
+
+	lw.reconnect()
+	require.Eventually(t, func() bool {
+		return lastStatus() == component.StatusOK
+	}, 10*time.Second, 20*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"10.10.0.11:4317", "192.168.10.100:4317"}, res.Endpoints())
+	}, 10*time.Second, 20*time.Millisecond)
+}
+
+func TestK8sHandlerUpdateKeepsRemainingEndpoints(t *testing.T) {
+	// prepare
+	store := &sync.Map{}
+	var resolved [][]string
+	h := handler{endpoints: store, logger: zap.NewNop(), opts: &conversionOptions{}, callback: func(ctx context.Context) ([]string, error) {
+		var current []string
+		store.Range(func(key, _ any) bool {
+			current = append(current, key.(string))
+			return true
+		})
+		sort.Strings(current)
+		resolved = append(resolved, current)
+		return current, nil
+	}}
+	eps := func(ips ...string) *corev1.Endpoints {
+		var addresses []corev1.EndpointAddress
+		for _, ip := range ips {
+			addresses = append(addresses, corev1.EndpointAddress{IP: ip})
+		}
+		return &corev1.Endpoints{Subsets: []corev1.EndpointSubset{{Addresses: addresses}}}
+	}
+	h.OnAdd(eps("10.0.0.1", "10.0.0.2"), false)
+	resolved = nil
+
+	// test
+	h.OnUpdate(eps("10.0.0.1", "10.0.0.2"), eps("10.0.0.1", "10.0.0.2"))
+	h.OnUpdate(eps("10.0.0.1", "10.0.0.2"), eps("10.0.0.2", "10.0.0.3"))
+
+	// verify
+	assert.Equal(t, [][]string{{"10.0.0.2", "10.0.0.3"}}, resolved)
+}
+
+func newEndpointSlice(name string, ips ...string) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "lb"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+	}
+	for _, ip := range ips {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{Addresses: []string{ip}})
+	}
+	return slice
+}
+
+func TestK8sResolveEndpointSlices(t *testing.T) {
+	// prepare
+	other := newEndpointSlice("other-abcde", "10.10.0.99")
+	other.Labels[discoveryv1.LabelServiceName] = "other"
+	cl := fake.NewSimpleClientset(
+		newEndpointSlice("lb-abcde", "192.168.10.100", "192.168.10.101"),
+		newEndpointSlice("lb-fghij", "192.168.10.101", "192.168.10.102"),
+		other,
+	)
+	res, err := newK8sResolver(cl, zap.NewNop(), "lb.default", []int32{4317}, true, nil)
+	require.NoError(t, err)
+
+	// test
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, []string{"192.168.10.100:4317", "192.168.10.101:4317", "192.168.10.102:4317"}, res.Endpoints())
+
+	// the address still listed by another slice is kept
+	_, err = cl.DiscoveryV1().EndpointSlices("default").Update(context.Background(), newEndpointSlice("lb-abcde", "192.168.10.100"), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, cl.DiscoveryV1().EndpointSlices("default").Delete(context.Background(), "lb-fghij", metav1.DeleteOptions{}))
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"192.168.10.100:4317"}, res.Endpoints())
+	}, time.Second, 20*time.Millisecond)
+}
+
+func TestK8sSliceHandlerMergesSlices(t *testing.T) {
+	// prepare
+	store := &sync.Map{}
+	var resolved [][]string
+	h := &sliceHandler{endpoints: store, logger: zap.NewNop(), opts: &conversionOptions{}, slices: map[string][]string{}, callback: func(ctx context.Context) ([]string, error) {
+		var current []string
+		store.Range(func(key, _ any) bool {
+			current = append(current, key.(string))
+			return true
+		})
+		sort.Strings(current)
+		resolved = append(resolved, current)
+		return current, nil
+	}}
+	notReady := newEndpointSlice("lb-klmno", "10.0.0.4")
+	notReady.Endpoints[0].Conditions.Ready = ptr.To(false)
+	fqdn := newEndpointSlice("lb-pqrst", "collector.example.com")
+	fqdn.AddressType = discoveryv1.AddressTypeFQDN
+
+	// test
+	h.OnAdd(newEndpointSlice("lb-abcde", "10.0.0.1", "10.0.0.2"), false)
+	h.OnAdd(newEndpointSlice("lb-fghij", "10.0.0.2", "10.0.0.3"), false)
+	h.OnAdd(notReady, false)
+	h.OnAdd(fqdn, false)
+	h.OnUpdate(nil, newEndpointSlice("lb-abcde", "10.0.0.1"))
+	h.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/lb-fghij", Obj: newEndpointSlice("lb-fghij")})
+
+	// verify
+	assert.Equal(t, [][]string{
+		{"10.0.0.1", "10.0.0.2"},
+		{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+		{"10.0.0.1"},
+	}, resolved)
+}
+
+func TestK8sRestConfigFromKubeConfig(t *testing.T) {
+	// prepare
+	kubeConfig := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeConfig, []byte(`apiVersion: v1
+kind: Config
+current-context: local
+clusters:
+- name: local
+  cluster:
+    server: https://local.example.com:6443
+- name: remote
+  cluster:
+    server: https://remote.example.com:6443
+contexts:
+- name: local
+  context:
+    cluster: local
+    user: collector
+- name: remote
+  context:
+    cluster: remote
+    user: collector
+users:
+- name: collector
+  user:
+    token: secret
+`), 0600))
+
+	for _, tt := range []struct {
+		name     string
+		context  string
+		expected string
+	}{
+		{
+			name:     "current context",
+			expected: "https://local.example.com:6443",
+		},
+		{
+			name:     "explicit context",
+			context:  "remote",
+			expected: "https://remote.example.com:6443",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// test
+			cfg, err := newK8sRestConfig(&K8sSvcResolver{AuthType: "kubeConfig", KubeConfig: kubeConfig, Context: tt.context})
+
+			// verify
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, cfg.Host)
+			assert.Equal(t, "secret", cfg.BearerToken)
+		})
+	}
+}
+
+func TestK8sRestConfigInvalid(t *testing.T) {
+	_, err := newK8sRestConfig(&K8sSvcResolver{AuthType: "kubeConfig", KubeConfig: filepath.Join(t.TempDir(), "missing")})
+	assert.ErrorContains(t, err, "failed to load the kubeconfig")
+
+	_, err = newK8sRestConfig(&K8sSvcResolver{AuthType: "tls"})
+	assert.ErrorContains(t, err, `invalid auth_type "tls"`)
+}
+
+func newPod(name, ip string, podLabels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: podLabels},
+		Status:     corev1.PodStatus{PodIP: ip, PodIPs: []corev1.PodIP{{IP: ip}}},
+	}
+}
+
+func TestK8sResolvePodSelector(t *testing.T) {
+	// prepare
+	endpoint := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "lb", Namespace: "default"},
+		Subsets: []corev1.EndpointSubset{
+			{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}}},
+		},
+	}
+	cl := fake.NewSimpleClientset(
+		endpoint,
+		newPod("sampler", "10.0.0.1", map[string]string{"role": "sampling-tier"}),
+		newPod("ingester", "10.0.0.2", map[string]string{"role": "ingest-tier"}),
+	)
+	res, err := newK8sResolver(cl, zap.NewNop(), "lb.default", []int32{4317}, false, nil)
+	require.NoError(t, err)
+	require.NoError(t, res.filterPods(cl, "role=sampling-tier"))
+
+	// test
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, []string{"10.0.0.1:4317"}, res.Endpoints())
+
+	// the pod getting the selected label is used as well
+	_, err = cl.CoreV1().Pods("default").Update(context.Background(),
+		newPod("ingester", "10.0.0.2", map[string]string{"role": "sampling-tier"}), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"10.0.0.1:4317", "10.0.0.2:4317"}, res.Endpoints())
+	}, time.Second, 20*time.Millisecond)
+}
+
+func TestK8sResolveZones(t *testing.T) {
+	// prepare
+	slice := newEndpointSlice("lb-abcde", "10.0.0.1", "10.0.0.2", "10.0.0.3")
+	slice.Endpoints[0].Zone = ptr.To("eu-west-1a")
+	slice.Endpoints[1].Zone = ptr.To("eu-west-1b")
+	cl := fake.NewSimpleClientset(slice)
+	res, err := newK8sResolver(cl, zap.NewNop(), "lb.default", []int32{4317}, true, nil)
+	require.NoError(t, err)
+	require.NoError(t, res.filterZones([]string{"eu-west-1a"}))
+
+	// test
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, []string{"10.0.0.1:4317"}, res.Endpoints())
+}
+
+func TestK8sResolverInvalidFilters(t *testing.T) {
+	cl := fake.NewSimpleClientset()
+	res, err := newK8sResolver(cl, zap.NewNop(), "lb.default", []int32{4317}, false, nil)
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, res.filterPods(cl, "role in sampling-tier"), "invalid pod selector")
+	assert.Equal(t, errZonesWithoutEndpointSlices, res.filterZones([]string{"eu-west-1a"}))
+}
+
+func TestK8sResolveIncludeNotReady(t *testing.T) {
+	for _, endpointSlices := range []bool{false, true} {
+		t.Run(fmt.Sprintf("endpoint slices: %t", endpointSlices), func(t *testing.T) {
+			// prepare
+			endpoint := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: "lb", Namespace: "default"},
+				Subsets: []corev1.EndpointSubset{{
+					Addresses:         []corev1.EndpointAddress{{IP: "10.0.0.1"}},
+					NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}},
+				}},
+			}
+			slice := newEndpointSlice("lb-abcde", "10.0.0.1", "10.0.0.2", "10.0.0.3")
+			slice.Endpoints[1].Conditions.Ready = ptr.To(false)
+			slice.Endpoints[2].Conditions.Ready = ptr.To(false)
+			slice.Endpoints[2].Conditions.Terminating = ptr.To(true)
+			cl := fake.NewSimpleClientset(endpoint, slice)
+			res, err := newK8sResolver(cl, zap.NewNop(), "lb.default", []int32{4317}, endpointSlices, nil)
+			require.NoError(t, err)
+			res.includeNotReady()
+
+			// test
+			require.NoError(t, res.start(context.Background()))
+			defer func() {
+				require.NoError(t, res.shutdown(context.Background()))
+			}()
+
+			// verify
+			assert.Equal(t, []string{"10.0.0.1:4317", "10.0.0.2:4317"}, res.Endpoints())
+		})
+	}
+}
+
+func TestK8sResolveReturnHostnames(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		endpointSlices bool
+		clusterDomain  string
+		expected       []string
+	}{
+		{
+			name:     "endpoints",
+			expected: []string{"lb-0.lb.default:4317", "lb-1.lb.default:4317"},
+		},
+		{
+			name:           "endpoint slices",
+			endpointSlices: true,
+			expected:       []string{"lb-0.lb.default:4317", "lb-1.lb.default:4317"},
+		},
+		{
+			name:          "cluster domain",
+			clusterDomain: "cluster.local",
+			expected:      []string{"lb-0.lb.default.svc.cluster.local:4317", "lb-1.lb.default.svc.cluster.local:4317"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// prepare
+			endpoint := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{Name: "lb", Namespace: "default"},
+				Subsets: []corev1.EndpointSubset{{
+					Addresses: []corev1.EndpointAddress{
+						{IP: "10.0.0.1", Hostname: "lb-0"},
+						{IP: "10.0.0.2", Hostname: "lb-1"},
+						{IP: "10.0.0.3"},
+					},
+				}},
+			}
+			slice := newEndpointSlice("lb-abcde", "10.0.0.1", "10.0.0.2", "10.0.0.3")
+			slice.Endpoints[0].Hostname = ptr.To("lb-0")
+			slice.Endpoints[1].Hostname = ptr.To("lb-1")
+			cl := fake.NewSimpleClientset(endpoint, slice)
+			res, err := newK8sResolver(cl, zap.NewNop(), "lb.default", []int32{4317}, tt.endpointSlices, nil)
+			require.NoError(t, err)
+			res.returnHostnames(tt.clusterDomain)
+
+			// test
+			require.NoError(t, res.start(context.Background()))
+			defer func() {
+				require.NoError(t, res.shutdown(context.Background()))
+			}()
+
+			// verify
+			assert.Equal(t, tt.expected, res.Endpoints())
+		})
+	}
+}
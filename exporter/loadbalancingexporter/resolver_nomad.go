@@ -0,0 +1,273 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var _ resolver = (*nomadResolver)(nil)
+
+const (
+	defaultNomadAddress       = "http://127.0.0.1:4646"
+	defaultNomadWaitTime      = 5 * time.Minute
+	defaultNomadRetryInterval = 5 * time.Second
+)
+
+var (
+	errNoNomadService = errors.New("no service specified for the nomad resolver")
+)
+
+// nomadServiceRegistration is the part of a service registration of the Nomad HTTP API used by the resolver.
+type nomadServiceRegistration struct {
+	Address string `json:"Address"`
+	Port    int    `json:"Port"`
+}
+
+// nomadResolver watches the instances of a service registered with the Nomad native service discovery, with
+// blocking queries.
+type nomadResolver struct {
+	logger    *zap.Logger
+	telemetry *telemetry
+
+	url           string
+	token         string
+	waitTime      time.Duration
+	retryInterval time.Duration
+	client        *http.Client
+
+	// lastIndex is the Nomad index of the latest result, the next blocking query waits for a change past it
+	lastIndex uint64
+
+	endpoints         []string
+	onChangeCallbacks []func([]string)
+
+	stopCh             chan struct{}
+	updateLock         sync.Mutex
+	shutdownWg         sync.WaitGroup
+	changeCallbackLock sync.RWMutex
+}
+
+func newNomadResolver(logger *zap.Logger, cfg *NomadResolver, tb *telemetry) (*nomadResolver, error) {
+	if cfg.Service == "" {
+		return nil, errNoNomadService
+	}
+
+	// the same environment variables as the ones of the Nomad CLI are honored
+	address := cfg.Address
+	if address == "" {
+		address = os.Getenv("NOMAD_ADDR")
+	}
+	if address == "" {
+		address = defaultNomadAddress
+	}
+	token := string(cfg.Token)
+	if token == "" {
+		token = os.Getenv("NOMAD_TOKEN")
+	}
+
+	query := url.Values{}
+	if cfg.Namespace != "" {
+		query.Set("namespace", cfg.Namespace)
+	}
+	if cfg.Region != "" {
+		query.Set("region", cfg.Region)
+	}
+	serviceURL := strings.TrimSuffix(address, "/") + "/v1/service/" + url.PathEscape(cfg.Service)
+	if len(query) > 0 {
+		serviceURL += "?" + query.Encode()
+	}
+
+	waitTime := cfg.WaitTime
+	if waitTime == 0 {
+		waitTime = defaultNomadWaitTime
+	}
+	retryInterval := cfg.RetryInterval
+	if retryInterval == 0 {
+		retryInterval = defaultNomadRetryInterval
+	}
+
+	return &nomadResolver{
+		logger:        logger,
+		telemetry:     tb,
+		url:           serviceURL,
+		token:         token,
+		waitTime:      waitTime,
+		retryInterval: retryInterval,
+		client:        &http.Client{},
+		stopCh:        make(chan struct{}),
+	}, nil
+}
+
+func (r *nomadResolver) start(ctx context.Context) error {
+	if _, err := r.resolve(ctx); err != nil {
+		r.logger.Warn("failed to resolve", zap.Error(err))
+	}
+
+	r.shutdownWg.Add(1)
+	go r.watch()
+
+	r.logger.Debug("Nomad resolver started", zap.String("url", r.url))
+	return nil
+}
+
+func (r *nomadResolver) shutdown(_ context.Context) error {
+	r.changeCallbackLock.Lock()
+	r.onChangeCallbacks = nil
+	r.changeCallbackLock.Unlock()
+
+	close(r.stopCh)
+	r.shutdownWg.Wait()
+	return nil
+}
+
+// watch runs blocking queries one after the other, each of them returning once the instances changed or the wait
+// time is over. After a failure, the next query is run after the retry interval.
+func (r *nomadResolver) watch() {
+	defer r.shutdownWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-r.stopCh
+		cancel()
+	}()
+
+	for {
+		r.updateLock.Lock()
+		index := r.lastIndex
+		r.updateLock.Unlock()
+
+		_, err := r.query(ctx, index)
+		select {
+		case <-r.stopCh:
+			return
+		default:
+		}
+		if err == nil {
+			continue
+		}
+
+		r.logger.Warn("failed to resolve", zap.Error(err))
+		select {
+		case <-r.stopCh:
+			return
+		case <-time.After(r.retryInterval):
+		}
+	}
+}
+
+func (r *nomadResolver) resolve(ctx context.Context) ([]string, error) {
+	return r.query(ctx, 0)
+}
+
+// query fetches the instances of the service, waiting for a change past the given index, if any.
+func (r *nomadResolver) query(ctx context.Context, index uint64) ([]string, error) {
+	r.shutdownWg.Add(1)
+	defer r.shutdownWg.Done()
+
+	registrations, lastIndex, err := r.fetch(ctx, index)
+	if err != nil {
+		r.telemetry.recordResolution(ctx, "nomad", false)
+		return nil, err
+	}
+	r.telemetry.recordResolution(ctx, "nomad", true)
+
+	backends := make([]string, 0, len(registrations))
+	for _, registration := range registrations {
+		backends = append(backends, net.JoinHostPort(registration.Address, strconv.Itoa(registration.Port)))
+	}
+
+	// keep it always in the same order
+	sort.Strings(backends)
+
+	r.updateLock.Lock()
+	if lastIndex < r.lastIndex {
+		// the index went backwards, such as after a restore of the Nomad servers, start over
+		r.lastIndex = 0
+	} else {
+		r.lastIndex = lastIndex
+	}
+	if equalStringSlice(r.endpoints, backends) {
+		r.updateLock.Unlock()
+		return backends, nil
+	}
+
+	// the list has changed!
+	r.endpoints = backends
+	r.updateLock.Unlock()
+	r.telemetry.recordBackends(ctx, "nomad", len(backends))
+
+	// propagate the change
+	r.changeCallbackLock.RLock()
+	for _, callback := range r.onChangeCallbacks {
+		callback(backends)
+	}
+	r.changeCallbackLock.RUnlock()
+
+	return backends, nil
+}
+
+// fetch runs the query against the Nomad HTTP API, returning the registrations along with the index of the result.
+func (r *nomadResolver) fetch(ctx context.Context, index uint64) ([]nomadServiceRegistration, uint64, error) {
+	queryURL := r.url
+	if index > 0 {
+		separator := "?"
+		if strings.Contains(queryURL, "?") {
+			separator = "&"
+		}
+		queryURL += separator + url.Values{
+			"index": {strconv.FormatUint(index, 10)},
+			"wait":  {r.waitTime.String()},
+		}.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, queryURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if r.token != "" {
+		req.Header.Set("X-Nomad-Token", r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected status code from Nomad: %d", resp.StatusCode)
+	}
+
+	var registrations []nomadServiceRegistration
+	if err := json.NewDecoder(resp.Body).Decode(&registrations); err != nil {
+		return nil, 0, err
+	}
+	lastIndex, err := strconv.ParseUint(resp.Header.Get("X-Nomad-Index"), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid index from Nomad: %w", err)
+	}
+	return registrations, lastIndex, nil
+}
+
+func (r *nomadResolver) onChange(f func([]string)) {
+	r.changeCallbackLock.Lock()
+	defer r.changeCallbackLock.Unlock()
+	r.onChangeCallbacks = append(r.onChangeCallbacks, f)
+}
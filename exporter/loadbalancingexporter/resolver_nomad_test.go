@@ -0,0 +1,200 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+)
+
+// mockNomad serves the service endpoint of the Nomad HTTP API for a single service, answering the blocking
+// queries once the instances changed past the requested index.
+type mockNomad struct {
+	lock      sync.Mutex
+	changed   chan struct{}
+	index     uint64
+	instances []nomadServiceRegistration
+	failures  int
+	requests  []*http.Request
+}
+
+func startMockNomad(t *testing.T, instances ...nomadServiceRegistration) (*mockNomad, string) {
+	m := &mockNomad{changed: make(chan struct{}), index: 1, instances: instances}
+	srv := httptest.NewServer(http.HandlerFunc(m.handle))
+	t.Cleanup(srv.Close)
+	return m, srv.URL
+}
+
+func (m *mockNomad) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/service/collectors" {
+		http.NotFound(w, r)
+		return
+	}
+
+	m.lock.Lock()
+	m.requests = append(m.requests, r)
+	if m.failures > 0 {
+		m.failures--
+		m.lock.Unlock()
+		http.Error(w, "No cluster leader", http.StatusInternalServerError)
+		return
+	}
+	waitIndex, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	for waitIndex >= m.index {
+		changed := m.changed
+		m.lock.Unlock()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		m.lock.Lock()
+	}
+	index, instances := m.index, m.instances
+	m.lock.Unlock()
+
+	w.Header().Set("X-Nomad-Index", strconv.FormatUint(index, 10))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(instances)
+}
+
+// update replaces the instances, waking up the blocking queries.
+func (m *mockNomad) update(instances ...nomadServiceRegistration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.index++
+	m.instances = instances
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+func (m *mockNomad) request(i int) *http.Request {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.requests[i]
+}
+
+func newTestNomadResolver(t *testing.T, address string) *nomadResolver {
+	res, err := newNomadResolver(zap.NewNop(), &NomadResolver{
+		Address:       address,
+		Token:         "secret",
+		Service:       "collectors",
+		Namespace:     "observability",
+		Region:        "eu",
+		WaitTime:      time.Minute,
+		RetryInterval: 10 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+	return res
+}
+
+func TestNomadResolverTracksInstances(t *testing.T) {
+	// prepare
+	nomad, address := startMockNomad(t,
+		nomadServiceRegistration{Address: "10.0.0.2", Port: 4317},
+		nomadServiceRegistration{Address: "10.0.0.1", Port: 4317},
+	)
+	res := newTestNomadResolver(t, address)
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	})
+	require.NoError(t, err)
+	lb.res = res
+
+	// test
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	assertRingEndpoints(t, lb, []string{"10.0.0.1:4317", "10.0.0.2:4317"})
+
+	// the blocking query returns as soon as the instances change
+	nomad.update(
+		nomadServiceRegistration{Address: "10.0.0.2", Port: 4317},
+		nomadServiceRegistration{Address: "10.0.0.3", Port: 24317},
+	)
+	assertRingEndpoints(t, lb, []string{"10.0.0.2:4317", "10.0.0.3:24317"})
+
+	// the first blocking query is the one returning the change
+	query := nomad.request(1)
+	assert.Equal(t, "observability", query.URL.Query().Get("namespace"))
+	assert.Equal(t, "eu", query.URL.Query().Get("region"))
+	assert.Equal(t, "1", query.URL.Query().Get("index"))
+	assert.Equal(t, "1m0s", query.URL.Query().Get("wait"))
+	assert.Equal(t, "secret", query.Header.Get("X-Nomad-Token"))
+}
+
+func TestNomadResolverRetriesAfterFailure(t *testing.T) {
+	// prepare
+	nomad, address := startMockNomad(t, nomadServiceRegistration{Address: "10.0.0.1", Port: 4317})
+	nomad.failures = 2
+	res := newTestNomadResolver(t, address)
+	resolved := make(chan []string, 10)
+	res.onChange(func(endpoints []string) {
+		resolved <- endpoints
+	})
+
+	// test
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	select {
+	case endpoints := <-resolved:
+		assert.Equal(t, []string{"10.0.0.1:4317"}, endpoints)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the instances weren't resolved after the failures")
+	}
+}
+
+func TestNomadResolverResolve(t *testing.T) {
+	// prepare
+	_, address := startMockNomad(t, nomadServiceRegistration{Address: "::1", Port: 4317})
+	res := newTestNomadResolver(t, address)
+
+	// test
+	endpoints, err := res.resolve(context.Background())
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[::1]:4317"}, endpoints)
+}
+
+func TestNomadResolverDefaultsFromEnvironment(t *testing.T) {
+	t.Setenv("NOMAD_ADDR", "https://nomad.example.com:4646")
+	t.Setenv("NOMAD_TOKEN", "from-env")
+
+	res, err := newNomadResolver(zap.NewNop(), &NomadResolver{Service: "collectors"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://nomad.example.com:4646/v1/service/collectors", res.url)
+	assert.Equal(t, "from-env", res.token)
+}
+
+func TestNomadResolverInvalidConfig(t *testing.T) {
+	res, err := newNomadResolver(zap.NewNop(), &NomadResolver{Namespace: "observability"}, nil)
+	assert.Equal(t, errNoNomadService, err)
+	assert.Nil(t, res)
+
+	cfg := simpleConfig()
+	cfg.Resolver.Nomad = &NomadResolver{Service: "collectors"}
+	_, err = newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errMultipleResolversProvided, err)
+}
@@ -0,0 +1,134 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+var _ resolver = (*sharedResolver)(nil)
+
+// sharedResolvers holds the resolvers shared by the exporters for the different signals of the same component,
+// keyed by the component's configuration.
+var sharedResolvers = newSharedResolverRegistry()
+
+type sharedResolverRegistry struct {
+	lock      sync.Mutex
+	resolvers map[*Config]*sharedResolver
+}
+
+func newSharedResolverRegistry() *sharedResolverRegistry {
+	return &sharedResolverRegistry{resolvers: map[*Config]*sharedResolver{}}
+}
+
+// getOrCreate returns the resolver shared for the given configuration, creating it when it doesn't exist yet.
+func (r *sharedResolverRegistry) getOrCreate(cfg *Config, create func() (resolver, error)) (*sharedResolver, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if res, ok := r.resolvers[cfg]; ok {
+		return res, nil
+	}
+
+	res, err := create()
+	if err != nil {
+		return nil, err
+	}
+	shared := newSharedResolver(res, func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		delete(r.resolvers, cfg)
+	})
+	r.resolvers[cfg] = shared
+	return shared, nil
+}
+
+// sharedResolver lets several load balancers use the same resolver, so that they all build their rings from the
+// same resolutions. The underlying resolver is started by the first load balancer starting, and shut down once
+// all the load balancers that started it are shut down.
+type sharedResolver struct {
+	resolver
+	remove func()
+
+	lock      sync.Mutex
+	callbacks []func([]string)
+	last      []string
+	resolved  bool
+	users     int
+}
+
+func newSharedResolver(res resolver, remove func()) *sharedResolver {
+	shared := &sharedResolver{resolver: res, remove: remove}
+	res.onChange(shared.notify)
+	return shared
+}
+
+// notify passes the endpoints resolved by the underlying resolver to all the registered callbacks.
+func (s *sharedResolver) notify(endpoints []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.last = endpoints
+	s.resolved = true
+	for _, callback := range s.callbacks {
+		callback(endpoints)
+	}
+}
+
+// onChange registers the callback. Callbacks registered after the first resolution are immediately called with
+// the latest endpoints, so that late load balancers don't miss the resolutions already made.
+func (s *sharedResolver) onChange(f func([]string)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.callbacks = append(s.callbacks, f)
+	if s.resolved {
+		f(s.last)
+	}
+}
+
+// setHost passes the host to the underlying resolver, when it needs it.
+func (s *sharedResolver) setHost(host component.Host) {
+	if res, ok := s.resolver.(hostAwareResolver); ok {
+		res.setHost(host)
+	}
+}
+
+func (s *sharedResolver) start(ctx context.Context) error {
+	s.lock.Lock()
+	s.users++
+	first := s.users == 1
+	s.lock.Unlock()
+
+	if !first {
+		return nil
+	}
+	if err := s.resolver.start(ctx); err != nil {
+		s.lock.Lock()
+		s.users--
+		s.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *sharedResolver) shutdown(ctx context.Context) error {
+	s.lock.Lock()
+	if s.users == 0 {
+		s.lock.Unlock()
+		return nil
+	}
+	s.users--
+	last := s.users == 0
+	s.lock.Unlock()
+
+	if !last {
+		return nil
+	}
+	s.remove()
+	return s.resolver.shutdown(ctx)
+}
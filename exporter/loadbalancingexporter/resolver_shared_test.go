@@ -0,0 +1,107 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+)
+
+func TestSharedRingAcrossSignals(t *testing.T) {
+	// prepare
+	cfg := createDefaultConfig().(*Config)
+	cfg.Resolver.Static = &StaticResolver{Hostnames: []string{"endpoint-1", "endpoint-2", "endpoint-3"}}
+	cfg.ShareRing = true
+
+	te, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	me, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	le, err := newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+
+	// test
+	require.NoError(t, te.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, me.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, le.Start(context.Background(), componenttest.NewNopHost()))
+
+	// verify
+	assert.Same(t, te.loadBalancer.res, me.loadBalancer.res)
+	assert.Same(t, te.loadBalancer.res, le.loadBalancer.res)
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		endpoint := te.loadBalancer.ring.endpointFor(key)
+		assert.Equal(t, endpoint, me.loadBalancer.ring.endpointFor(key))
+		assert.Equal(t, endpoint, le.loadBalancer.ring.endpointFor(key))
+	}
+
+	require.NoError(t, te.Shutdown(context.Background()))
+	require.NoError(t, me.Shutdown(context.Background()))
+	assert.Contains(t, sharedResolvers.resolvers, cfg)
+	require.NoError(t, le.Shutdown(context.Background()))
+	assert.NotContains(t, sharedResolvers.resolvers, cfg)
+}
+
+func TestSharedRingPerComponent(t *testing.T) {
+	// prepare
+	cfg1 := simpleConfig()
+	cfg1.ShareRing = true
+	cfg2 := simpleConfig()
+	cfg2.ShareRing = true
+
+	// test
+	lb1, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg1, nil)
+	require.NoError(t, err)
+	lb2, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg2, nil)
+	require.NoError(t, err)
+
+	// verify
+	assert.NotSame(t, lb1.res, lb2.res)
+	require.NoError(t, lb1.Shutdown(context.Background()))
+	require.NoError(t, lb2.Shutdown(context.Background()))
+}
+
+func TestSharedResolverChanges(t *testing.T) {
+	// prepare
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	shared := newSharedResolver(&mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return endpoints, nil
+		},
+	}, func() {})
+
+	var lbs []*loadBalancer
+	for i := 0; i < 3; i++ {
+		lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), func(ctx context.Context, endpoint string) (component.Component, error) {
+			return newNopMockExporter(), nil
+		})
+		require.NoError(t, err)
+		lb.res = shared
+		lbs = append(lbs, lb)
+	}
+
+	// the first load balancer starts the resolver, the others are registered after the first resolution
+	for _, lb := range lbs {
+		require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	}
+
+	// test
+	endpoints = []string{"endpoint-1", "endpoint-3", "endpoint-4"}
+	_, err := shared.resolve(context.Background())
+	require.NoError(t, err)
+
+	// verify
+	for _, lb := range lbs {
+		assert.Equal(t, newHashRing(endpoints), lb.ring)
+		assert.Len(t, lb.exporters, 3)
+	}
+}
@@ -6,40 +6,49 @@ package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
-
-	"go.opencensus.io/stats"
-	"go.opencensus.io/tag"
 )
 
 var _ resolver = (*staticResolver)(nil)
 
 var (
 	errNoEndpoints = errors.New("no endpoints specified for the static resolver")
-
-	staticResolverMutators = []tag.Mutator{tag.Upsert(tag.MustNewKey("resolver"), "static"), successTrueMutator}
 )
 
+// staticWeightPrefix prefixes the optional weight following the endpoint in a hostname of the static resolver
+const staticWeightPrefix = "weight="
+
 type staticResolver struct {
+	telemetry         *telemetry
 	endpoints         []string
 	onChangeCallbacks []func([]string)
 	once              sync.Once // we trigger the onChange only once
 }
 
-func newStaticResolver(endpoints []string) (*staticResolver, error) {
+func newStaticResolver(endpoints []string, tb *telemetry) (*staticResolver, error) {
 	if len(endpoints) == 0 {
 		return nil, errNoEndpoints
 	}
 
-	// make sure we won't change the provided slice
+	// make sure we won't change the provided slice, the weights being left to the ring
 	endpointsCopy := make([]string, len(endpoints))
-	copy(endpointsCopy, endpoints)
+	for i, hostname := range endpoints {
+		endpoint, _, err := parseStaticHostname(hostname)
+		if err != nil {
+			return nil, err
+		}
+		endpointsCopy[i] = endpoint
+	}
 
 	// sort is a guarantee that the order of endpoints doesn't matter
 	sort.Strings(endpointsCopy)
 
 	return &staticResolver{
+		telemetry: tb,
 		endpoints: endpointsCopy,
 	}, nil
 }
@@ -54,10 +63,10 @@ func (r *staticResolver) shutdown(_ context.Context) error {
 }
 
 func (r *staticResolver) resolve(ctx context.Context) ([]string, error) {
-	_ = stats.RecordWithTags(ctx, staticResolverMutators, mNumResolutions.M(1))
+	r.telemetry.recordResolution(ctx, "static", true)
 
 	r.once.Do(func() {
-		_ = stats.RecordWithTags(ctx, staticResolverMutators, mNumBackends.M(int64(len(r.endpoints))))
+		r.telemetry.recordBackends(ctx, "static", len(r.endpoints))
 
 		for _, callback := range r.onChangeCallbacks {
 			callback(r.endpoints)
@@ -69,3 +78,35 @@ func (r *staticResolver) resolve(ctx context.Context) ([]string, error) {
 func (r *staticResolver) onChange(f func([]string)) {
 	r.onChangeCallbacks = append(r.onChangeCallbacks, f)
 }
+
+// parseStaticHostname splits a hostname of the static resolver into its endpoint and its optional weight, such as
+// "host-a:4317 weight=3". The weight is 0 when the hostname has none.
+func parseStaticHostname(hostname string) (string, int, error) {
+	fields := strings.Fields(hostname)
+	switch {
+	case len(fields) <= 1:
+		return strings.TrimSpace(hostname), 0, nil
+	case len(fields) == 2 && strings.HasPrefix(fields[1], staticWeightPrefix):
+		weight, err := strconv.Atoi(strings.TrimPrefix(fields[1], staticWeightPrefix))
+		if err != nil || weight <= 0 {
+			return "", 0, fmt.Errorf("invalid weight for the static hostname %q, it must be a positive integer", hostname)
+		}
+		return fields[0], weight, nil
+	default:
+		return "", 0, fmt.Errorf("invalid static hostname %q, expected an endpoint optionally followed by %s<weight>", hostname, staticWeightPrefix)
+	}
+}
+
+// staticEndpointWeights returns the weights set in the hostnames of the static resolver, by endpoint.
+func staticEndpointWeights(cfg *StaticResolver) map[string]int {
+	weights := map[string]int{}
+	if cfg == nil {
+		return weights
+	}
+	for _, hostname := range cfg.Hostnames {
+		if endpoint, weight, err := parseStaticHostname(hostname); err == nil && weight > 0 {
+			weights[endpoint] = weight
+		}
+	}
+	return weights
+}
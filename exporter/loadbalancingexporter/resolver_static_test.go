@@ -14,7 +14,7 @@ import (
 func TestInitialResolution(t *testing.T) {
 	// prepare
 	provided := []string{"endpoint-2", "endpoint-1"}
-	res, err := newStaticResolver(provided)
+	res, err := newStaticResolver(provided, nil)
 	require.NoError(t, err)
 
 	// test
@@ -35,7 +35,7 @@ func TestInitialResolution(t *testing.T) {
 func TestResolvedOnlyOnce(t *testing.T) {
 	// prepare
 	expected := []string{"endpoint-1", "endpoint-2"}
-	res, err := newStaticResolver(expected)
+	res, err := newStaticResolver(expected, nil)
 	require.NoError(t, err)
 
 	counter := 0
@@ -61,9 +61,41 @@ func TestFailOnMissingEndpoints(t *testing.T) {
 	var expected []string
 
 	// test
-	res, err := newStaticResolver(expected)
+	res, err := newStaticResolver(expected, nil)
 
 	// verify
 	assert.Equal(t, errNoEndpoints, err)
 	assert.Nil(t, res)
 }
+
+func TestStaticResolverStripsWeights(t *testing.T) {
+	// prepare
+	res, err := newStaticResolver([]string{"endpoint-2:4317 weight=3", "endpoint-1:4317"}, nil)
+	require.NoError(t, err)
+
+	// test
+	resolved, err := res.resolve(context.Background())
+
+	// verify
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"endpoint-1:4317", "endpoint-2:4317"}, resolved)
+	assert.Equal(t, map[string]int{"endpoint-2:4317": 3}, staticEndpointWeights(&StaticResolver{
+		Hostnames: []string{"endpoint-2:4317 weight=3", "endpoint-1:4317"},
+	}))
+}
+
+func TestStaticResolverInvalidWeights(t *testing.T) {
+	for _, hostname := range []string{
+		"endpoint-1:4317 weight=0",
+		"endpoint-1:4317 weight=-1",
+		"endpoint-1:4317 weight=three",
+		"endpoint-1:4317 priority=3",
+		"endpoint-1:4317 weight=3 weight=4",
+	} {
+		t.Run(hostname, func(t *testing.T) {
+			res, err := newStaticResolver([]string{hostname}, nil)
+			assert.ErrorContains(t, err, hostname)
+			assert.Nil(t, res)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+)
+
+var (
+	_ resolver          = (*unionResolver)(nil)
+	_ hostAwareResolver = (*unionResolver)(nil)
+)
+
+// unionResolver merges the endpoints of several resolvers into a single list without duplicates, such as to build one
+// ring out of several services. The endpoints of each resolver are kept until it resolves them again, so that a
+// resolver failing doesn't remove the endpoints of the other ones.
+type unionResolver struct {
+	logger    *zap.Logger
+	resolvers []resolver
+
+	// resolved holds the latest endpoints of each resolver
+	resolved  [][]string
+	endpoints []string
+
+	onChangeCallbacks []func([]string)
+
+	updateLock         sync.Mutex
+	changeCallbackLock sync.RWMutex
+}
+
+func newUnionResolver(logger *zap.Logger, resolvers []resolver) *unionResolver {
+	return &unionResolver{
+		logger:    logger,
+		resolvers: resolvers,
+		resolved:  make([][]string, len(resolvers)),
+	}
+}
+
+// setHost passes the host to the resolvers needing it.
+func (r *unionResolver) setHost(host component.Host) {
+	for _, res := range r.resolvers {
+		if hostAware, ok := res.(hostAwareResolver); ok {
+			hostAware.setHost(host)
+		}
+	}
+}
+
+func (r *unionResolver) start(ctx context.Context) error {
+	for i, res := range r.resolvers {
+		i := i
+		res.onChange(func(endpoints []string) {
+			r.update(i, endpoints)
+		})
+	}
+
+	for i, res := range r.resolvers {
+		if err := res.start(ctx); err != nil {
+			// the resolvers already started are stopped, as the caller won't shut down a resolver failing to start
+			for _, started := range r.resolvers[:i] {
+				_ = started.shutdown(ctx)
+			}
+			return err
+		}
+	}
+
+	r.logger.Debug("union resolver started", zap.Int("resolvers", len(r.resolvers)))
+	return nil
+}
+
+func (r *unionResolver) shutdown(ctx context.Context) error {
+	r.changeCallbackLock.Lock()
+	r.onChangeCallbacks = nil
+	r.changeCallbackLock.Unlock()
+
+	var errs error
+	for _, res := range r.resolvers {
+		errs = errors.Join(errs, res.shutdown(ctx))
+	}
+	return errs
+}
+
+// resolve resolves the endpoints of all the resolvers, returning the merged endpoints. The endpoints of the
+// resolvers failing to resolve are the ones they resolved last, an error being returned only when all of them fail.
+func (r *unionResolver) resolve(ctx context.Context) ([]string, error) {
+	var errs error
+	failed := 0
+	for i, res := range r.resolvers {
+		endpoints, err := res.resolve(ctx)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			failed++
+			continue
+		}
+		r.update(i, endpoints)
+	}
+	if failed == len(r.resolvers) {
+		return nil, errs
+	}
+	if errs != nil {
+		r.logger.Warn("failed to resolve the endpoints of some of the resolvers", zap.Error(errs))
+	}
+
+	r.updateLock.Lock()
+	defer r.updateLock.Unlock()
+	return r.endpoints, nil
+}
+
+// update replaces the endpoints of a resolver, propagating the merged endpoints when they changed.
+func (r *unionResolver) update(i int, endpoints []string) {
+	r.updateLock.Lock()
+	r.resolved[i] = endpoints
+
+	seen := map[string]bool{}
+	backends := []string{}
+	for _, resolved := range r.resolved {
+		for _, endpoint := range resolved {
+			if !seen[endpoint] {
+				seen[endpoint] = true
+				backends = append(backends, endpoint)
+			}
+		}
+	}
+
+	// keep it always in the same order
+	sort.Strings(backends)
+
+	if equalStringSlice(r.endpoints, backends) {
+		r.updateLock.Unlock()
+		return
+	}
+
+	// the list has changed!
+	r.endpoints = backends
+	r.updateLock.Unlock()
+
+	// propagate the change
+	r.changeCallbackLock.RLock()
+	for _, callback := range r.onChangeCallbacks {
+		callback(backends)
+	}
+	r.changeCallbackLock.RUnlock()
+}
+
+func (r *unionResolver) onChange(f func([]string)) {
+	r.changeCallbackLock.Lock()
+	defer r.changeCallbackLock.Unlock()
+	r.onChangeCallbacks = append(r.onChangeCallbacks, f)
+}
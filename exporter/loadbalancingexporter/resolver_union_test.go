@@ -0,0 +1,204 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUnionResolverMergesEndpoints(t *testing.T) {
+	// prepare
+	first := &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(context.Context) ([]string, error) {
+			return []string{"endpoint-2", "endpoint-1"}, nil
+		},
+	}
+	second := &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(context.Context) ([]string, error) {
+			return []string{"endpoint-3", "endpoint-2"}, nil
+		},
+	}
+	res := newUnionResolver(zap.NewNop(), []resolver{first, second})
+
+	var resolved []string
+	res.onChange(func(endpoints []string) {
+		resolved = endpoints
+	})
+
+	// test
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, []string{"endpoint-1", "endpoint-2", "endpoint-3"}, resolved)
+}
+
+func TestUnionResolverKeepsEndpointsOfFailingResolver(t *testing.T) {
+	// prepare
+	var failure error
+	failing := &mockResolver{
+		onResolve: func(context.Context) ([]string, error) {
+			if failure != nil {
+				return nil, failure
+			}
+			return []string{"endpoint-1"}, nil
+		},
+	}
+	healthy := &mockResolver{
+		onResolve: func(context.Context) ([]string, error) {
+			return []string{"endpoint-2"}, nil
+		},
+	}
+	res := newUnionResolver(zap.NewNop(), []resolver{failing, healthy})
+	endpoints, err := res.resolve(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"endpoint-1", "endpoint-2"}, endpoints)
+
+	// test
+	failure = errors.New("some expected error")
+	endpoints, err = res.resolve(context.Background())
+
+	// verify
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"endpoint-1", "endpoint-2"}, endpoints)
+
+	// all the resolvers failing is an error
+	res = newUnionResolver(zap.NewNop(), []resolver{failing})
+	endpoints, err = res.resolve(context.Background())
+	assert.ErrorIs(t, err, failure)
+	assert.Nil(t, endpoints)
+}
+
+func TestUnionResolverStopsStartedResolversOnFailure(t *testing.T) {
+	// prepare
+	stopped := false
+	started := &mockResolver{
+		onShutdown: func(context.Context) error {
+			stopped = true
+			return nil
+		},
+	}
+	expectedErr := errors.New("some expected error")
+	failing := &mockResolver{
+		onStart: func(context.Context) error {
+			return expectedErr
+		},
+	}
+	res := newUnionResolver(zap.NewNop(), []resolver{started, failing})
+
+	// test
+	err := res.start(context.Background())
+
+	// verify
+	assert.Equal(t, expectedErr, err)
+	assert.True(t, stopped)
+}
+
+func TestMergedResolvers(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver = ResolverSettings{
+		Static: &StaticResolver{Hostnames: []string{"endpoint-1:4317", "endpoint-2:4317"}},
+		DNS:    &DNSResolver{Hostname: "collectors.example.com", Port: "4317"},
+		Merge:  true,
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	})
+	require.NoError(t, err)
+
+	res, ok := lb.res.(*unionResolver)
+	require.True(t, ok)
+	require.Len(t, res.resolvers, 2)
+	res.resolvers[1].(*dnsResolver).resolver = &mockDNSResolver{
+		onLookupIPAddr: func(context.Context, string) ([]net.IPAddr, error) {
+			return []net.IPAddr{{IP: net.IPv4(10, 0, 0, 1)}}, nil
+		},
+	}
+
+	// test
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	assertRingEndpoints(t, lb, []string{"10.0.0.1:4317", "endpoint-1:4317", "endpoint-2:4317"})
+}
+
+func TestMergeAllowsExclusiveResolvers(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.Resolver.File = &FileResolver{Path: "endpoints.json"}
+
+	_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errMultipleResolversProvided, err)
+
+	cfg.Resolver.Merge = true
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &unionResolver{}, lb.res)
+}
+
+func TestK8sResolveMultipleServices(t *testing.T) {
+	// prepare
+	cl := fake.NewSimpleClientset(
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "lb-blue", Namespace: "observability"},
+			Subsets: []corev1.EndpointSubset{
+				{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}}},
+			},
+		},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "lb-green", Namespace: "observability-next"},
+			Subsets: []corev1.EndpointSubset{
+				{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}, {IP: "10.0.1.1"}}},
+			},
+		},
+	)
+	cfg := &K8sSvcResolver{Ports: []int32{4317}}
+	var resolvers []resolver
+	for _, service := range []string{"lb-blue.observability", "lb-green.observability-next"} {
+		k8sRes, err := newConfiguredK8sResolver(cl, zap.NewNop(), service, cfg, nil)
+		require.NoError(t, err)
+		resolvers = append(resolvers, k8sRes)
+	}
+	res := newUnionResolver(zap.NewNop(), resolvers)
+
+	// test
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Eventually(t, func() bool {
+		endpoints, err := res.resolve(context.Background())
+		return err == nil && assert.ObjectsAreEqual([]string{"10.0.0.1:4317", "10.0.0.2:4317", "10.0.1.1:4317"}, endpoints)
+	}, time.Second, 20*time.Millisecond)
+
+	// the endpoints of a service being drained are removed, while the ones shared with the other service are kept
+	require.NoError(t, cl.CoreV1().Endpoints("observability").Delete(context.Background(), "lb-blue", metav1.DeleteOptions{}))
+	assert.Eventually(t, func() bool {
+		endpoints, err := res.resolve(context.Background())
+		return err == nil && assert.ObjectsAreEqual([]string{"10.0.0.2:4317", "10.0.1.1:4317"}, endpoints)
+	}, time.Second, 20*time.Millisecond)
+}
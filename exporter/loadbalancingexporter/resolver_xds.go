@@ -0,0 +1,272 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"go.opentelemetry.io/collector/config/configtls"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var _ resolver = (*xdsResolver)(nil)
+
+const (
+	// clusterLoadAssignmentTypeURL is the type of the EDS resources, holding the endpoints of a cluster
+	clusterLoadAssignmentTypeURL = "type.googleapis.com/envoy.config.endpoint.v3.ClusterLoadAssignment"
+
+	defaultXDSRetryInterval = 5 * time.Second
+	defaultXDSNodeID        = "otelcol-loadbalancing"
+)
+
+var (
+	errNoXDSServer       = errors.New("no management server specified for the xds resolver")
+	errNoXDSResourceName = errors.New("no resource name specified for the xds resolver")
+)
+
+// xdsResolver subscribes to the endpoints of a cluster on an xDS management server, through the aggregated
+// discovery service. Only the endpoints whose health status is healthy or unknown are used, as Envoy does.
+type xdsResolver struct {
+	logger    *zap.Logger
+	telemetry *telemetry
+
+	server        string
+	resourceName  string
+	nodeID        string
+	tls           configtls.ClientConfig
+	retryInterval time.Duration
+
+	conn *grpc.ClientConn
+
+	endpoints         []string
+	onChangeCallbacks []func([]string)
+
+	stopCh             chan struct{}
+	updateLock         sync.RWMutex
+	shutdownWg         sync.WaitGroup
+	changeCallbackLock sync.RWMutex
+}
+
+func newXDSResolver(logger *zap.Logger, cfg *XDSResolver, tb *telemetry) (*xdsResolver, error) {
+	if cfg.Server == "" {
+		return nil, errNoXDSServer
+	}
+	if cfg.ResourceName == "" {
+		return nil, errNoXDSResourceName
+	}
+
+	nodeID := cfg.NodeID
+	if nodeID == "" {
+		nodeID = defaultXDSNodeID
+	}
+	retryInterval := cfg.RetryInterval
+	if retryInterval == 0 {
+		retryInterval = defaultXDSRetryInterval
+	}
+
+	return &xdsResolver{
+		logger:        logger,
+		telemetry:     tb,
+		server:        cfg.Server,
+		resourceName:  cfg.ResourceName,
+		nodeID:        nodeID,
+		tls:           cfg.TLS,
+		retryInterval: retryInterval,
+		stopCh:        make(chan struct{}),
+	}, nil
+}
+
+func (r *xdsResolver) start(ctx context.Context) error {
+	tlsCfg, err := r.tls.LoadTLSConfig()
+	if err != nil {
+		return err
+	}
+	creds := insecure.NewCredentials()
+	if tlsCfg != nil {
+		creds = credentials.NewTLS(tlsCfg)
+	}
+	r.conn, err = grpc.DialContext(ctx, r.server, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+
+	r.shutdownWg.Add(1)
+	go r.watch()
+
+	r.logger.Debug("xDS resolver started",
+		zap.String("server", r.server), zap.String("resource_name", r.resourceName), zap.String("node_id", r.nodeID))
+	return nil
+}
+
+func (r *xdsResolver) shutdown(_ context.Context) error {
+	r.changeCallbackLock.Lock()
+	r.onChangeCallbacks = nil
+	r.changeCallbackLock.Unlock()
+
+	close(r.stopCh)
+	var err error
+	if r.conn != nil {
+		err = r.conn.Close()
+	}
+	r.shutdownWg.Wait()
+	return err
+}
+
+// watch keeps a stream open to the management server, opening a new one after the retry interval whenever it
+// fails. The endpoints known so far are kept in use while the stream is down.
+func (r *xdsResolver) watch() {
+	defer r.shutdownWg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-r.stopCh
+		cancel()
+	}()
+
+	for {
+		err := r.subscribe(ctx)
+		select {
+		case <-r.stopCh:
+			return
+		default:
+		}
+
+		r.telemetry.recordResolution(ctx, "xds", false)
+		r.logger.Warn("the stream to the xDS management server failed, keeping the current endpoints", zap.Error(err))
+		select {
+		case <-r.stopCh:
+			return
+		case <-time.After(r.retryInterval):
+		}
+	}
+}
+
+// subscribe subscribes to the endpoints of the cluster, applying the updates until the stream fails. Every
+// response is acknowledged, or rejected when it can't be used, in which case the current endpoints are kept.
+func (r *xdsResolver) subscribe(ctx context.Context) error {
+	stream, err := discoveryv3.NewAggregatedDiscoveryServiceClient(r.conn).StreamAggregatedResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	request := &discoveryv3.DiscoveryRequest{
+		Node:          &corev3.Node{Id: r.nodeID},
+		TypeUrl:       clusterLoadAssignmentTypeURL,
+		ResourceNames: []string{r.resourceName},
+	}
+	var version string
+	for {
+		if err = stream.Send(request); err != nil {
+			return err
+		}
+
+		response, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		request = &discoveryv3.DiscoveryRequest{
+			Node:          &corev3.Node{Id: r.nodeID},
+			TypeUrl:       clusterLoadAssignmentTypeURL,
+			ResourceNames: []string{r.resourceName},
+			ResponseNonce: response.GetNonce(),
+		}
+		endpoints, err := r.endpointsFrom(response)
+		if err != nil {
+			r.logger.Warn("rejecting the update from the xDS management server", zap.String("version", response.GetVersionInfo()), zap.Error(err))
+			request.VersionInfo = version
+			request.ErrorDetail = &status.Status{Code: int32(codes.InvalidArgument), Message: err.Error()}
+			continue
+		}
+
+		version = response.GetVersionInfo()
+		request.VersionInfo = version
+		r.update(ctx, endpoints)
+	}
+}
+
+// endpointsFrom returns the sorted endpoints of the cluster from the response.
+func (r *xdsResolver) endpointsFrom(response *discoveryv3.DiscoveryResponse) ([]string, error) {
+	if response.GetTypeUrl() != clusterLoadAssignmentTypeURL {
+		return nil, fmt.Errorf("unexpected resource type %q", response.GetTypeUrl())
+	}
+
+	endpoints := []string{}
+	for _, resource := range response.GetResources() {
+		assignment := &endpointv3.ClusterLoadAssignment{}
+		if err := resource.UnmarshalTo(assignment); err != nil {
+			return nil, err
+		}
+		if assignment.GetClusterName() != r.resourceName {
+			continue
+		}
+
+		for _, locality := range assignment.GetEndpoints() {
+			for _, lbEndpoint := range locality.GetLbEndpoints() {
+				if health := lbEndpoint.GetHealthStatus(); health != corev3.HealthStatus_HEALTHY && health != corev3.HealthStatus_UNKNOWN {
+					continue
+				}
+				address := lbEndpoint.GetEndpoint().GetAddress().GetSocketAddress()
+				if address == nil {
+					return nil, fmt.Errorf("an endpoint of the cluster %q has no socket address", r.resourceName)
+				}
+				endpoints = append(endpoints, net.JoinHostPort(address.GetAddress(), strconv.FormatUint(uint64(address.GetPortValue()), 10)))
+			}
+		}
+	}
+
+	// keep it always in the same order
+	sort.Strings(endpoints)
+	return endpoints, nil
+}
+
+// update propagates the endpoints, if they changed.
+func (r *xdsResolver) update(ctx context.Context, endpoints []string) {
+	r.telemetry.recordResolution(ctx, "xds", true)
+
+	r.updateLock.Lock()
+	if equalStringSlice(r.endpoints, endpoints) {
+		r.updateLock.Unlock()
+		return
+	}
+	r.endpoints = endpoints
+	r.updateLock.Unlock()
+	r.telemetry.recordBackends(ctx, "xds", len(endpoints))
+
+	// propagate the change
+	r.changeCallbackLock.RLock()
+	for _, callback := range r.onChangeCallbacks {
+		callback(endpoints)
+	}
+	r.changeCallbackLock.RUnlock()
+}
+
+// resolve returns the endpoints from the latest update, as they are pushed by the management server.
+func (r *xdsResolver) resolve(_ context.Context) ([]string, error) {
+	r.updateLock.RLock()
+	defer r.updateLock.RUnlock()
+	return r.endpoints, nil
+}
+
+func (r *xdsResolver) onChange(f func([]string)) {
+	r.changeCallbackLock.Lock()
+	defer r.changeCallbackLock.Unlock()
+	r.onChangeCallbacks = append(r.onChangeCallbacks, f)
+}
@@ -0,0 +1,279 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configtls"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// mockXDSServer is an aggregated discovery service sending the queued responses on the open stream, and
+// recording the requests received.
+type mockXDSServer struct {
+	discoveryv3.UnimplementedAggregatedDiscoveryServiceServer
+
+	responses chan *discoveryv3.DiscoveryResponse
+	failures  chan error
+	requests  chan *discoveryv3.DiscoveryRequest
+}
+
+func startMockXDSServer(t *testing.T) (*mockXDSServer, string) {
+	m := &mockXDSServer{
+		responses: make(chan *discoveryv3.DiscoveryResponse, 10),
+		failures:  make(chan error, 1),
+		requests:  make(chan *discoveryv3.DiscoveryRequest, 100),
+	}
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	discoveryv3.RegisterAggregatedDiscoveryServiceServer(srv, m)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	t.Cleanup(srv.Stop)
+	return m, listener.Addr().String()
+}
+
+func (m *mockXDSServer) StreamAggregatedResources(stream discoveryv3.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	go func() {
+		for {
+			request, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			m.requests <- request
+		}
+	}()
+
+	for {
+		select {
+		case response := <-m.responses:
+			if err := stream.Send(response); err != nil {
+				return err
+			}
+		case err := <-m.failures:
+			return err
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// nextRequest returns the next request received, skipping none.
+func (m *mockXDSServer) nextRequest(t *testing.T) *discoveryv3.DiscoveryRequest {
+	select {
+	case request := <-m.requests:
+		return request
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request received by the xDS server")
+		return nil
+	}
+}
+
+func lbEndpoint(address string, port uint32, health corev3.HealthStatus) *endpointv3.LbEndpoint {
+	return &endpointv3.LbEndpoint{
+		HealthStatus: health,
+		HostIdentifier: &endpointv3.LbEndpoint_Endpoint{Endpoint: &endpointv3.Endpoint{
+			Address: &corev3.Address{Address: &corev3.Address_SocketAddress{SocketAddress: &corev3.SocketAddress{
+				Address:       address,
+				PortSpecifier: &corev3.SocketAddress_PortValue{PortValue: port},
+			}}},
+		}},
+	}
+}
+
+func edsResponse(t *testing.T, version string, cluster string, endpoints ...*endpointv3.LbEndpoint) *discoveryv3.DiscoveryResponse {
+	resource, err := anypb.New(&endpointv3.ClusterLoadAssignment{
+		ClusterName: cluster,
+		Endpoints:   []*endpointv3.LocalityLbEndpoints{{LbEndpoints: endpoints}},
+	})
+	require.NoError(t, err)
+	return &discoveryv3.DiscoveryResponse{
+		VersionInfo: version,
+		Nonce:       "nonce-" + version,
+		TypeUrl:     clusterLoadAssignmentTypeURL,
+		Resources:   []*anypb.Any{resource},
+	}
+}
+
+func newTestXDSResolver(t *testing.T, server string) *xdsResolver {
+	res, err := newXDSResolver(zap.NewNop(), &XDSResolver{
+		Server:        server,
+		ResourceName:  "collectors",
+		NodeID:        "node-1",
+		RetryInterval: 10 * time.Millisecond,
+		TLS:           configtls.ClientConfig{Insecure: true},
+	}, nil)
+	require.NoError(t, err)
+	return res
+}
+
+func TestXDSResolverTracksEndpoints(t *testing.T) {
+	// prepare
+	server, address := startMockXDSServer(t)
+	res := newTestXDSResolver(t, address)
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockExporter(), nil
+	})
+	require.NoError(t, err)
+	lb.res = res
+
+	// test
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	subscription := server.nextRequest(t)
+	assert.Equal(t, "node-1", subscription.GetNode().GetId())
+	assert.Equal(t, clusterLoadAssignmentTypeURL, subscription.GetTypeUrl())
+	assert.Equal(t, []string{"collectors"}, subscription.GetResourceNames())
+
+	server.responses <- edsResponse(t, "1", "collectors",
+		lbEndpoint("10.0.0.2", 4317, corev3.HealthStatus_HEALTHY),
+		lbEndpoint("10.0.0.1", 4317, corev3.HealthStatus_UNKNOWN),
+		lbEndpoint("10.0.0.3", 4317, corev3.HealthStatus_UNHEALTHY),
+		lbEndpoint("10.0.0.4", 4317, corev3.HealthStatus_DRAINING),
+	)
+	ack := server.nextRequest(t)
+	assert.Equal(t, "1", ack.GetVersionInfo())
+	assert.Equal(t, "nonce-1", ack.GetResponseNonce())
+	assert.Nil(t, ack.GetErrorDetail())
+	assertRingEndpoints(t, lb, []string{"10.0.0.1:4317", "10.0.0.2:4317"})
+
+	server.responses <- edsResponse(t, "2", "collectors",
+		lbEndpoint("10.0.0.2", 4317, corev3.HealthStatus_HEALTHY),
+		lbEndpoint("10.0.0.3", 4317, corev3.HealthStatus_HEALTHY),
+	)
+	assert.Equal(t, "2", server.nextRequest(t).GetVersionInfo())
+	assertRingEndpoints(t, lb, []string{"10.0.0.2:4317", "10.0.0.3:4317"})
+
+	endpoints, err := res.resolve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.2:4317", "10.0.0.3:4317"}, endpoints)
+}
+
+func TestXDSResolverRejectsInvalidUpdate(t *testing.T) {
+	// prepare
+	server, address := startMockXDSServer(t)
+	res := newTestXDSResolver(t, address)
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+	server.nextRequest(t)
+	server.responses <- edsResponse(t, "1", "collectors", lbEndpoint("10.0.0.1", 4317, corev3.HealthStatus_HEALTHY))
+	server.nextRequest(t)
+
+	// test
+	invalid := edsResponse(t, "2", "collectors")
+	invalid.TypeUrl = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+	server.responses <- invalid
+
+	// verify
+	nack := server.nextRequest(t)
+	assert.Equal(t, "1", nack.GetVersionInfo())
+	assert.Equal(t, "nonce-2", nack.GetResponseNonce())
+	require.NotNil(t, nack.GetErrorDetail())
+	assert.Contains(t, nack.GetErrorDetail().GetMessage(), "unexpected resource type")
+
+	endpoints, err := res.resolve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:4317"}, endpoints)
+}
+
+func TestXDSResolverReconnects(t *testing.T) {
+	// prepare
+	server, address := startMockXDSServer(t)
+	res := newTestXDSResolver(t, address)
+	var resolved []string
+	changed := make(chan struct{}, 10)
+	res.onChange(func(endpoints []string) {
+		resolved = endpoints
+		changed <- struct{}{}
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+	server.nextRequest(t)
+	server.responses <- edsResponse(t, "1", "collectors", lbEndpoint("10.0.0.1", 4317, corev3.HealthStatus_HEALTHY))
+	server.nextRequest(t)
+	<-changed
+
+	// test
+	server.failures <- errors.New("management server restarting")
+
+	// verify
+	subscription := server.nextRequest(t)
+	assert.Empty(t, subscription.GetVersionInfo())
+	assert.Equal(t, []string{"10.0.0.1:4317"}, resolved)
+
+	server.responses <- edsResponse(t, "1", "collectors", lbEndpoint("::1", 4317, corev3.HealthStatus_HEALTHY))
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the endpoints weren't updated after reconnecting")
+	}
+	assert.Equal(t, []string{"[::1]:4317"}, resolved)
+}
+
+func TestXDSResolverInvalidConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cfg  *XDSResolver
+		err  error
+	}{
+		{
+			name: "no server",
+			cfg:  &XDSResolver{ResourceName: "collectors"},
+			err:  errNoXDSServer,
+		},
+		{
+			name: "no resource name",
+			cfg:  &XDSResolver{Server: "localhost:15010"},
+			err:  errNoXDSResourceName,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := newXDSResolver(zap.NewNop(), tt.cfg, nil)
+			assert.Equal(t, tt.err, err)
+			assert.Nil(t, res)
+		})
+	}
+}
+
+func TestXDSResolverExclusive(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.Resolver.XDS = &XDSResolver{Server: "localhost:15010", ResourceName: "collectors"}
+
+	_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errMultipleResolversProvided, err)
+}
+
+// assertRingEndpoints waits for the ring of the load balancer to have the given endpoints.
+func assertRingEndpoints(t *testing.T, lb *loadBalancer, expected []string) {
+	assert.Eventually(t, func() bool {
+		lb.updateLock.RLock()
+		defer lb.updateLock.RUnlock()
+		return lb.ring != nil && assert.ObjectsAreEqual(expected, lb.ring.endpoints())
+	}, 5*time.Second, 10*time.Millisecond)
+}
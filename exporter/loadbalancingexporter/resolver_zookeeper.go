@@ -0,0 +1,227 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"go.uber.org/zap"
+)
+
+var _ resolver = (*zooKeeperResolver)(nil)
+
+const (
+	defaultZooKeeperSessionTimeout = 10 * time.Second
+	defaultZooKeeperRetryInterval  = 5 * time.Second
+)
+
+var (
+	errNoZooKeeperServers = errors.New("no servers specified for the zookeeper resolver")
+	errNoZooKeeperPath    = errors.New("no path specified for the zookeeper resolver")
+)
+
+// zooKeeperConn is the part of the ZooKeeper connection used by the resolver.
+type zooKeeperConn interface {
+	Children(path string) ([]string, *zk.Stat, error)
+	ChildrenW(path string) ([]string, *zk.Stat, <-chan zk.Event, error)
+	Get(path string) ([]byte, *zk.Stat, error)
+	Close()
+}
+
+// zooKeeperResolver watches the children of a ZooKeeper node, each of them being a backend. The backends are expected
+// to register themselves with ephemeral nodes, so that they're removed from the ring once their session expires. The
+// data of a child is the endpoint of the backend, or the name of the child itself when empty.
+type zooKeeperResolver struct {
+	logger    *zap.Logger
+	telemetry *telemetry
+
+	servers        []string
+	path           string
+	sessionTimeout time.Duration
+	retryInterval  time.Duration
+	newConn        func(servers []string, sessionTimeout time.Duration) (zooKeeperConn, error)
+	conn           zooKeeperConn
+
+	endpoints         []string
+	onChangeCallbacks []func([]string)
+
+	stopCh             chan struct{}
+	updateLock         sync.Mutex
+	shutdownWg         sync.WaitGroup
+	changeCallbackLock sync.RWMutex
+}
+
+func newZooKeeperResolver(logger *zap.Logger, cfg *ZooKeeperResolver, tb *telemetry) (*zooKeeperResolver, error) {
+	if len(cfg.Servers) == 0 {
+		return nil, errNoZooKeeperServers
+	}
+	if cfg.Path == "" {
+		return nil, errNoZooKeeperPath
+	}
+
+	sessionTimeout := cfg.SessionTimeout
+	if sessionTimeout == 0 {
+		sessionTimeout = defaultZooKeeperSessionTimeout
+	}
+	retryInterval := cfg.RetryInterval
+	if retryInterval == 0 {
+		retryInterval = defaultZooKeeperRetryInterval
+	}
+
+	return &zooKeeperResolver{
+		logger:         logger,
+		telemetry:      tb,
+		servers:        cfg.Servers,
+		path:           cfg.Path,
+		sessionTimeout: sessionTimeout,
+		retryInterval:  retryInterval,
+		newConn: func(servers []string, sessionTimeout time.Duration) (zooKeeperConn, error) {
+			// the connection is established in the background, the session events aren't needed as the watches
+			// fire on the session changes as well
+			conn, _, err := zk.Connect(servers, sessionTimeout, zk.WithLogger(&zooKeeperLogger{logger: logger}))
+			return conn, err
+		},
+		stopCh: make(chan struct{}),
+	}, nil
+}
+
+func (r *zooKeeperResolver) start(ctx context.Context) error {
+	conn, err := r.newConn(r.servers, r.sessionTimeout)
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+
+	if _, err := r.resolve(ctx); err != nil {
+		r.logger.Warn("failed to resolve", zap.Error(err))
+	}
+
+	r.shutdownWg.Add(1)
+	go r.watch()
+
+	r.logger.Debug("ZooKeeper resolver started",
+		zap.Strings("servers", r.servers), zap.String("path", r.path), zap.Duration("session_timeout", r.sessionTimeout))
+	return nil
+}
+
+func (r *zooKeeperResolver) shutdown(_ context.Context) error {
+	r.changeCallbackLock.Lock()
+	r.onChangeCallbacks = nil
+	r.changeCallbackLock.Unlock()
+
+	close(r.stopCh)
+	r.shutdownWg.Wait()
+	if r.conn != nil {
+		r.conn.Close()
+	}
+	return nil
+}
+
+// watch fetches the children along with a watch on them, and fetches them again once the watch fired. After a
+// failure, they're fetched again after the retry interval.
+func (r *zooKeeperResolver) watch() {
+	defer r.shutdownWg.Done()
+
+	for {
+		children, _, events, err := r.conn.ChildrenW(r.path)
+		if err == nil {
+			err = r.apply(context.Background(), children)
+		}
+		if err != nil {
+			r.telemetry.recordResolution(context.Background(), "zookeeper", false)
+			r.logger.Warn("failed to resolve", zap.Error(err))
+			select {
+			case <-r.stopCh:
+				return
+			case <-time.After(r.retryInterval):
+			}
+			continue
+		}
+
+		select {
+		case <-r.stopCh:
+			return
+		case event := <-events:
+			r.logger.Debug("the children changed", zap.Stringer("type", event.Type), zap.Stringer("state", event.State))
+		}
+	}
+}
+
+func (r *zooKeeperResolver) resolve(ctx context.Context) ([]string, error) {
+	children, _, err := r.conn.Children(r.path)
+	if err == nil {
+		err = r.apply(ctx, children)
+	}
+	if err != nil {
+		r.telemetry.recordResolution(ctx, "zookeeper", false)
+		return nil, err
+	}
+
+	r.updateLock.Lock()
+	defer r.updateLock.Unlock()
+	return r.endpoints, nil
+}
+
+// apply fetches the data of the children and propagates their endpoints, if they changed.
+func (r *zooKeeperResolver) apply(ctx context.Context, children []string) error {
+	members := make(map[string]string, len(children))
+	for _, child := range children {
+		data, _, err := r.conn.Get(path.Join(r.path, child))
+		if errors.Is(err, zk.ErrNoNode) {
+			// the backend is gone already
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		members[child] = strings.TrimSpace(string(data))
+		if members[child] == "" {
+			members[child] = child
+		}
+	}
+	backends := backendsOf(members)
+	r.telemetry.recordResolution(ctx, "zookeeper", true)
+
+	r.updateLock.Lock()
+	if equalStringSlice(r.endpoints, backends) {
+		r.updateLock.Unlock()
+		return nil
+	}
+
+	// the list has changed!
+	r.endpoints = backends
+	r.updateLock.Unlock()
+	r.telemetry.recordBackends(ctx, "zookeeper", len(backends))
+
+	// propagate the change
+	r.changeCallbackLock.RLock()
+	for _, callback := range r.onChangeCallbacks {
+		callback(backends)
+	}
+	r.changeCallbackLock.RUnlock()
+	return nil
+}
+
+func (r *zooKeeperResolver) onChange(f func([]string)) {
+	r.changeCallbackLock.Lock()
+	defer r.changeCallbackLock.Unlock()
+	r.onChangeCallbacks = append(r.onChangeCallbacks, f)
+}
+
+// zooKeeperLogger sends the logs of the ZooKeeper client to the logger of the resolver.
+type zooKeeperLogger struct {
+	logger *zap.Logger
+}
+
+func (l *zooKeeperLogger) Printf(format string, args ...any) {
+	l.logger.Debug(fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,235 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+)
+
+func newTestZooKeeperResolver(t *testing.T, conn *mockZooKeeperConn) *zooKeeperResolver {
+	res, err := newZooKeeperResolver(zap.NewNop(), &ZooKeeperResolver{
+		Servers:       []string{"zookeeper:2181"},
+		Path:          "/otel/gateways",
+		RetryInterval: 10 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+	res.newConn = func([]string, time.Duration) (zooKeeperConn, error) {
+		return conn, nil
+	}
+	return res
+}
+
+func TestInitialZooKeeperResolution(t *testing.T) {
+	// prepare
+	conn := newMockZooKeeperConn(map[string]string{
+		"gateway-2":     "10.0.0.2:4317",
+		"gateway-1":     "10.0.0.1:4317",
+		"10.0.0.3:4317": "",
+	})
+	res := newTestZooKeeperResolver(t, conn)
+
+	// test
+	var resolved []string
+	res.onChange(func(endpoints []string) {
+		resolved = endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Equal(t, []string{"10.0.0.1:4317", "10.0.0.2:4317", "10.0.0.3:4317"}, resolved)
+	assert.Equal(t, "/otel/gateways", conn.paths()[0])
+}
+
+func TestZooKeeperWatch(t *testing.T) {
+	// prepare
+	conn := newMockZooKeeperConn(map[string]string{
+		"gateway-1": "10.0.0.1:4317",
+		"gateway-2": "10.0.0.2:4317",
+	})
+	res := newTestZooKeeperResolver(t, conn)
+	resolved := make(chan []string, 10)
+	res.onChange(func(endpoints []string) {
+		resolved <- endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+	assert.Equal(t, []string{"10.0.0.1:4317", "10.0.0.2:4317"}, <-resolved)
+
+	// test
+	// the session of the second gateway expired, and a third one registered
+	conn.setChildren(map[string]string{
+		"gateway-1": "10.0.0.1:4317",
+		"gateway-3": "10.0.0.3:4317",
+	})
+
+	// verify
+	select {
+	case endpoints := <-resolved:
+		assert.Equal(t, []string{"10.0.0.1:4317", "10.0.0.3:4317"}, endpoints)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the children weren't fetched again")
+	}
+}
+
+func TestZooKeeperWatchRetries(t *testing.T) {
+	// prepare
+	conn := newMockZooKeeperConn(nil)
+	conn.err = zk.ErrNoServer
+	res := newTestZooKeeperResolver(t, conn)
+	resolved := make(chan []string, 10)
+	res.onChange(func(endpoints []string) {
+		resolved <- endpoints
+	})
+	require.NoError(t, res.start(context.Background()))
+	defer func() {
+		require.NoError(t, res.shutdown(context.Background()))
+	}()
+
+	// test
+	conn.setChildren(map[string]string{
+		"gateway-1": "10.0.0.1:4317",
+	})
+
+	// verify
+	select {
+	case endpoints := <-resolved:
+		assert.Equal(t, []string{"10.0.0.1:4317"}, endpoints)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the children weren't fetched again")
+	}
+}
+
+func TestZooKeeperCantResolve(t *testing.T) {
+	// prepare
+	conn := newMockZooKeeperConn(nil)
+	conn.err = zk.ErrNoServer
+	res := newTestZooKeeperResolver(t, conn)
+	res.conn = conn
+
+	// test
+	_, err := res.resolve(context.Background())
+
+	// verify
+	assert.True(t, errors.Is(err, zk.ErrNoServer))
+}
+
+func TestZooKeeperInvalidConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cfg  *ZooKeeperResolver
+		err  error
+	}{
+		{
+			name: "no servers",
+			cfg:  &ZooKeeperResolver{Path: "/otel/gateways"},
+			err:  errNoZooKeeperServers,
+		},
+		{
+			name: "no path",
+			cfg:  &ZooKeeperResolver{Servers: []string{"zookeeper:2181"}},
+			err:  errNoZooKeeperPath,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := newZooKeeperResolver(zap.NewNop(), tt.cfg, nil)
+			assert.Equal(t, tt.err, err)
+			assert.Nil(t, res)
+		})
+	}
+}
+
+func TestZooKeeperResolverExclusive(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.Resolver.ZooKeeper = &ZooKeeperResolver{Servers: []string{"zookeeper:2181"}, Path: "/otel/gateways"}
+
+	_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.Equal(t, errMultipleResolversProvided, err)
+}
+
+// mockZooKeeperConn holds the children of a single node, firing the watches when they're changed.
+type mockZooKeeperConn struct {
+	lock      sync.Mutex
+	children  map[string]string
+	err       error
+	requested []string
+	watches   []chan zk.Event
+}
+
+func newMockZooKeeperConn(children map[string]string) *mockZooKeeperConn {
+	return &mockZooKeeperConn{children: children}
+}
+
+func (m *mockZooKeeperConn) setChildren(children map[string]string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.children = children
+	m.err = nil
+	for _, watch := range m.watches {
+		watch <- zk.Event{Type: zk.EventNodeChildrenChanged, State: zk.StateHasSession}
+	}
+	m.watches = nil
+}
+
+func (m *mockZooKeeperConn) paths() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.requested
+}
+
+func (m *mockZooKeeperConn) Children(p string) ([]string, *zk.Stat, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.requested = append(m.requested, p)
+	if m.err != nil {
+		return nil, nil, m.err
+	}
+
+	children := make([]string, 0, len(m.children))
+	for child := range m.children {
+		children = append(children, child)
+	}
+	return children, &zk.Stat{}, nil
+}
+
+func (m *mockZooKeeperConn) ChildrenW(p string) ([]string, *zk.Stat, <-chan zk.Event, error) {
+	children, stat, err := m.Children(p)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	watch := make(chan zk.Event, 1)
+	m.watches = append(m.watches, watch)
+	return children, stat, watch, nil
+}
+
+func (m *mockZooKeeperConn) Get(p string) ([]byte, *zk.Stat, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for child, data := range m.children {
+		if path.Join("/otel/gateways", child) == p {
+			return []byte(data), &zk.Stat{}, nil
+		}
+	}
+	return nil, nil, zk.ErrNoNode
+}
+
+func (m *mockZooKeeperConn) Close() {}
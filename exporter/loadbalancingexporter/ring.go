@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import "fmt"
+
+const (
+	consistentHashStrategy     = "consistent"
+	rendezvousHashStrategy     = "rendezvous"
+	maglevHashStrategy         = "maglev"
+	weightedRoundRobinStrategy = "weighted_round_robin"
+)
+
+// ring maps the routing identifiers to the endpoints. Implementations are immutable, apart from the turn of the
+// round-robin ring, and are rebuilt whenever the endpoints change.
+type ring interface {
+	// endpointFor returns the endpoint for the given identifier, or an empty string when there are no endpoints.
+	endpointFor(identifier []byte) string
+
+	// endpointForKnown returns the endpoint for an identifier previously routed to the given endpoint: while
+	// that endpoint is draining, the identifier keeps being routed to it.
+	endpointForKnown(identifier []byte, previous string) string
+
+	// equal returns whether the candidate routes all identifiers in the same way as this ring.
+	equal(candidate ring) bool
+
+	// endpoints returns the sorted list of distinct endpoints in the ring, without the draining ones.
+	endpoints() []string
+
+	// fingerprint returns a hash identifying how the ring routes the identifiers.
+	fingerprint() string
+}
+
+// ringLayout is implemented by the rings assigning fixed positions to the endpoints, describing how the
+// identifiers are spread over them.
+type ringLayout interface {
+	// layout returns the number of positions held by each endpoint, and the share of the identifiers it gets.
+	layout() map[string]endpointLayout
+}
+
+// endpointLayout describes the part of a ring held by an endpoint.
+type endpointLayout struct {
+	positions int
+	share     float64
+}
+
+// ringBuilder builds a ring for the given endpoints, keeping the draining endpoints available only for the
+// identifiers that were already routed to them.
+type ringBuilder func(endpoints []string, draining []string) ring
+
+// newRingBuilder returns the builder for the rings of the configured hash strategy.
+func newRingBuilder(cfg *Config) (ringBuilder, error) {
+	weight := endpointWeights(cfg)
+	switch strategy := cfg.HashStrategy; strategy {
+	case consistentHashStrategy, "":
+		if cfg.VirtualNodes < 0 {
+			return nil, fmt.Errorf("invalid virtual_nodes %d, it must be positive", cfg.VirtualNodes)
+		}
+		opts := hashRingOptions{virtualNodes: cfg.VirtualNodes, weight: weight}
+		if cfg.HashFunction != "" && cfg.HashFunction != crc32HashFunction {
+			// the default hash function is left unset, keeping the rings comparable with the default ones
+			hash, err := newRingHash(cfg.HashFunction)
+			if err != nil {
+				return nil, err
+			}
+			opts.hash = hash
+		}
+		return func(endpoints []string, draining []string) ring {
+			return newHashRingWithOptions(endpoints, draining, opts)
+		}, nil
+	case rendezvousHashStrategy:
+		return func(endpoints []string, draining []string) ring {
+			return newRendezvousRing(endpoints, draining)
+		}, nil
+	case maglevHashStrategy:
+		return func(endpoints []string, draining []string) ring {
+			return newMaglevRing(endpoints, draining)
+		}, nil
+	case weightedRoundRobinStrategy:
+		return func(endpoints []string, _ []string) ring {
+			return newWeightedRoundRobinRing(endpoints, weight)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported hash_strategy: %q", strategy)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+var (
+	errNoRoutingAttributes     = errors.New("no routing_attributes specified for the attributes routing key")
+	errMissingRoutingAttribute = errors.New("unable to get routing attribute")
+)
+
+var (
+	_ resourceIdentifier = (*attributesRouting)(nil)
+	_ resourceIdentifier = (*expressionRouting)(nil)
+	_ resourceIdentifier = (*serviceRouting)(nil)
+)
+
+// resourceIdentifier derives the routing identifier of the data from its resource.
+type resourceIdentifier interface {
+	identifier(resource pcommon.Resource) (string, error)
+}
+
+// attributesRouting derives the routing identifier from an ordered list of resource attributes.
+type attributesRouting struct {
+	attributes []string
+
+	// placeholder is used in place of the missing attributes. Missing attributes are an error when empty.
+	placeholder string
+
+	// delimiter joins the values of the attributes, which are length-prefixed when empty
+	delimiter string
+}
+
+func newAttributesRouting(cfg *Config) (*attributesRouting, error) {
+	if len(cfg.RoutingAttributes) == 0 {
+		return nil, errNoRoutingAttributes
+	}
+	return &attributesRouting{
+		attributes:  cfg.RoutingAttributes,
+		placeholder: cfg.MissingAttributePlaceholder,
+		delimiter:   cfg.RoutingAttributesDelimiter,
+	}, nil
+}
+
+// identifier returns the routing identifier for the given resource.
+func (r *attributesRouting) identifier(resource pcommon.Resource) (string, error) {
+	values := make([]string, len(r.attributes))
+	for i, name := range r.attributes {
+		value, ok := resource.Attributes().Get(name)
+		switch {
+		case ok:
+			values[i] = value.AsString()
+		case r.placeholder != "":
+			values[i] = r.placeholder
+		default:
+			return "", fmt.Errorf("%w: %q", errMissingRoutingAttribute, name)
+		}
+	}
+	if r.delimiter != "" {
+		return strings.Join(values, r.delimiter), nil
+	}
+	return encodeRoutingFields(values), nil
+}
+
+// encodeRoutingFields combines the fields into a single routing identifier. Each field is prefixed with its length,
+// so that different lists of fields never result in the same identifier, whatever characters the fields contain.
+func encodeRoutingFields(fields []string) string {
+	var sb strings.Builder
+	for _, field := range fields {
+		sb.WriteString(strconv.Itoa(len(field)))
+		sb.WriteByte(':')
+		sb.WriteString(field)
+	}
+	return sb.String()
+}
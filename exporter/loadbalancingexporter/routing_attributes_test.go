@@ -0,0 +1,245 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	conventions "go.opentelemetry.io/collector/semconv/v1.9.0"
+)
+
+func TestEncodeRoutingFields(t *testing.T) {
+	assert.Equal(t, "9:service-10:", encodeRoutingFields([]string{"service-1", ""}))
+	assert.NotEqual(t, encodeRoutingFields([]string{"a:b", "c"}), encodeRoutingFields([]string{"a", "b:c"}))
+	assert.NotEqual(t, encodeRoutingFields([]string{"ab", "c"}), encodeRoutingFields([]string{"a", "bc"}))
+}
+
+func TestAttributesRoutingIdentifier(t *testing.T) {
+	resource := pcommon.NewResource()
+	resource.Attributes().PutStr(conventions.AttributeServiceName, "service-1")
+
+	for _, tt := range []struct {
+		desc        string
+		placeholder string
+		delimiter   string
+		expected    string
+		err         error
+	}{
+		{"missing attribute", "", "", "", errMissingRoutingAttribute},
+		{"missing attribute with placeholder", "unknown", "", "9:service-17:unknown", nil},
+		{"delimiter", "unknown", "/", "service-1/unknown", nil},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			r, err := newAttributesRouting(&Config{
+				RoutingAttributes:           []string{conventions.AttributeServiceName, conventions.AttributeDeploymentEnvironment},
+				MissingAttributePlaceholder: tt.placeholder,
+				RoutingAttributesDelimiter:  tt.delimiter,
+			})
+			require.NoError(t, err)
+
+			// test
+			rid, err := r.identifier(resource)
+
+			// verify
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.expected, rid)
+		})
+	}
+}
+
+func TestNewAttributesRoutingWithoutAttributes(t *testing.T) {
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "attributes"
+
+	_, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorIs(t, err, errNoRoutingAttributes)
+
+	_, err = newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorIs(t, err, errNoRoutingAttributes)
+
+	_, err = newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorIs(t, err, errNoRoutingAttributes)
+}
+
+func TestConsumeTracesAttributesBased(t *testing.T) {
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "attributes"
+	cfg.RoutingAttributes = []string{conventions.AttributeK8SNamespaceName}
+	sinks := map[string]*consumertest.TracesSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks[endpoint] = sink
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// the services of the same namespace, such as a renamed service, stay on the same backend
+	td := ptrace.NewTraces()
+	for i, svc := range []string{"checkout", "checkout-v2", "payments"} {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr(conventions.AttributeServiceName, svc)
+		rs.Resource().Attributes().PutStr(conventions.AttributeK8SNamespaceName, "shop")
+		rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetTraceID([16]byte{byte(i)})
+	}
+
+	// test
+	require.NoError(t, p.ConsumeTraces(context.Background(), td))
+
+	// verify
+	_, endpoint, err := lb.exporterAndEndpoint([]byte("4:shop"))
+	require.NoError(t, err)
+	for name, sink := range sinks {
+		if name != endpointWithPort(endpoint) {
+			assert.Empty(t, sink.AllTraces())
+			continue
+		}
+		spans := 0
+		for _, td := range sink.AllTraces() {
+			spans += td.SpanCount()
+		}
+		assert.Equal(t, 3, spans)
+	}
+
+	// the traces missing the attribute are rejected
+	assert.ErrorIs(t, p.ConsumeTraces(context.Background(), simpleTraces()), errMissingRoutingAttribute)
+}
+
+func TestConsumeLogsAttributesBased(t *testing.T) {
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "attributes"
+	cfg.RoutingAttributes = []string{conventions.AttributeServiceName, conventions.AttributeDeploymentEnvironment}
+	sinks := map[string]*consumertest.LogsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.LogsSink)
+		sinks[endpoint] = sink
+		return newMockLogsExporter(sink.ConsumeLogs), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	ld := plog.NewLogs()
+	for i, env := range []string{"production", "staging"} {
+		rl := ld.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+		rl.Resource().Attributes().PutStr(conventions.AttributeDeploymentEnvironment, env)
+		// the trace IDs don't matter
+		rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().SetTraceID([16]byte{byte(i)})
+		rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().SetTraceID([16]byte{byte(i + 2)})
+	}
+
+	// test
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.ConsumeLogs(context.Background(), ld))
+	}
+
+	// verify
+	require.Len(t, sinks, 2)
+	environment := func(ld plog.Logs) string {
+		require.Equal(t, 1, ld.ResourceLogs().Len())
+		env, _ := ld.ResourceLogs().At(0).Resource().Attributes().Get(conventions.AttributeDeploymentEnvironment)
+		return env.Str()
+	}
+	require.Len(t, sinks["endpoint-1:4317"].AllLogs(), 3)
+	require.Len(t, sinks["endpoint-2:4317"].AllLogs(), 3)
+	for _, ld := range sinks["endpoint-1:4317"].AllLogs() {
+		assert.Equal(t, "staging", environment(ld))
+		assert.Equal(t, 2, ld.LogRecordCount())
+	}
+	for _, ld := range sinks["endpoint-2:4317"].AllLogs() {
+		assert.Equal(t, "production", environment(ld))
+	}
+
+	// the logs missing an attribute are rejected
+	missing := plog.NewLogs()
+	missing.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	assert.ErrorIs(t, p.ConsumeLogs(context.Background(), missing), errMissingRoutingAttribute)
+}
+
+func TestConsumeMetricsAttributesBased(t *testing.T) {
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "attributes"
+	cfg.RoutingAttributes = []string{conventions.AttributeServiceName, conventions.AttributeDeploymentEnvironment}
+	sinks := map[string]*consumertest.MetricsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.MetricsSink)
+		sinks[endpoint] = sink
+		return newMockMetricsExporter(sink.ConsumeMetrics), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	md := pmetric.NewMetrics()
+	for _, env := range []string{"production", "staging"} {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+		rm.Resource().Attributes().PutStr(conventions.AttributeDeploymentEnvironment, env)
+		appendSimpleMetricWithID(rm, env)
+	}
+
+	// test
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.ConsumeMetrics(context.Background(), md))
+	}
+
+	// verify
+	require.Len(t, sinks, 2)
+	require.Contains(t, sinks, "endpoint-1:4317")
+	require.Contains(t, sinks, "endpoint-2:4317")
+	for _, md := range sinks["endpoint-1:4317"].AllMetrics() {
+		assert.Equal(t, []string{"staging"}, metricNames(md))
+	}
+	for _, md := range sinks["endpoint-2:4317"].AllMetrics() {
+		assert.Equal(t, []string{"production"}, metricNames(md))
+	}
+	assert.Len(t, sinks["endpoint-1:4317"].AllMetrics(), 3)
+	assert.Len(t, sinks["endpoint-2:4317"].AllMetrics(), 3)
+}
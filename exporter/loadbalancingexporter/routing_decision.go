@@ -0,0 +1,89 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"errors"
+	"math/rand"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// defaultRoutingDecisionSamplingRate is the share of the spans and log records stamped with the routing decision
+// when no rate is configured.
+const defaultRoutingDecisionSamplingRate = 0.01
+
+var errInvalidRoutingDecisionSamplingRate = errors.New("routing_decision_sampling_rate must be greater than 0 and at most 1")
+
+// routingDecisionStamper records the endpoint the spans and log records are routed to in one of their attributes,
+// for a sample of them. Metrics aren't stamped, as an additional attribute would make them different time series.
+type routingDecisionStamper struct {
+	attribute string
+	rate      float64
+
+	// sample returns a pseudo-random number in [0,1), the items are stamped when it is below the rate
+	sample func() float64
+}
+
+// newRoutingDecisionStamper returns the stamper for the configured attribute, or nil when none is configured.
+func newRoutingDecisionStamper(cfg *Config) (*routingDecisionStamper, error) {
+	if cfg.RoutingDecisionAttribute == "" {
+		return nil, nil
+	}
+	if cfg.RoutingDecisionSamplingRate <= 0 || cfg.RoutingDecisionSamplingRate > 1 {
+		return nil, errInvalidRoutingDecisionSamplingRate
+	}
+	return &routingDecisionStamper{
+		attribute: cfg.RoutingDecisionAttribute,
+		rate:      cfg.RoutingDecisionSamplingRate,
+		sample:    rand.Float64,
+	}, nil
+}
+
+// stampTraces sets the routing decision attribute to the endpoint on a sample of the spans.
+func (s *routingDecisionStamper) stampTraces(td ptrace.Traces, endpoint string) {
+	if s == nil {
+		return
+	}
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		sss := rss.At(i).ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			spans := sss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				s.stamp(spans.At(k).Attributes(), endpoint)
+			}
+		}
+	}
+}
+
+// stampLogs sets the routing decision attribute to the endpoint on a sample of the log records.
+func (s *routingDecisionStamper) stampLogs(ld plog.Logs, endpoint string) {
+	if s == nil {
+		return
+	}
+	rls := ld.ResourceLogs()
+	for i := 0; i < rls.Len(); i++ {
+		sls := rls.At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			records := sls.At(j).LogRecords()
+			for k := 0; k < records.Len(); k++ {
+				s.stamp(records.At(k).Attributes(), endpoint)
+			}
+		}
+	}
+}
+
+func (s *routingDecisionStamper) stamp(attrs pcommon.Map, endpoint string) {
+	if s.sample() < s.rate {
+		attrs.PutStr(s.attribute, endpoint)
+	}
+}
+
+// mutatesData returns whether the data is modified before being sent, for the signals that are stamped.
+func (s *routingDecisionStamper) mutatesData() bool {
+	return s != nil
+}
@@ -0,0 +1,179 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const routingDecisionAttribute = "loadbalancing.endpoint"
+
+func TestNewRoutingDecisionStamper(t *testing.T) {
+	for _, tt := range []struct {
+		desc      string
+		attribute string
+		rate      float64
+		enabled   bool
+		err       error
+	}{
+		{"disabled", "", 0, false, nil},
+		{"all the data", routingDecisionAttribute, 1, true, nil},
+		{"sampled", routingDecisionAttribute, 0.1, true, nil},
+		{"no rate", routingDecisionAttribute, 0, false, errInvalidRoutingDecisionSamplingRate},
+		{"rate above 1", routingDecisionAttribute, 1.5, false, errInvalidRoutingDecisionSamplingRate},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := simpleConfig()
+			cfg.RoutingDecisionAttribute = tt.attribute
+			cfg.RoutingDecisionSamplingRate = tt.rate
+
+			// test
+			s, err := newRoutingDecisionStamper(cfg)
+
+			// verify
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.enabled, s.mutatesData())
+		})
+	}
+}
+
+func TestRoutingDecisionSamplingRate(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.RoutingDecisionAttribute = routingDecisionAttribute
+	cfg.RoutingDecisionSamplingRate = 0.25
+	s, err := newRoutingDecisionStamper(cfg)
+	require.NoError(t, err)
+	s.sample = rand.New(rand.NewSource(1)).Float64
+
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	for i := 0; i < 10000; i++ {
+		spans.AppendEmpty()
+	}
+
+	// test
+	s.stampTraces(td, "endpoint-1")
+
+	// verify
+	stamped := 0
+	for i := 0; i < spans.Len(); i++ {
+		if value, ok := spans.At(i).Attributes().Get(routingDecisionAttribute); ok {
+			assert.Equal(t, "endpoint-1", value.Str())
+			stamped++
+		}
+	}
+	assert.InDelta(t, 2500, stamped, 200)
+}
+
+func TestConsumeTracesRoutingDecision(t *testing.T) {
+	for _, tt := range []struct {
+		desc      string
+		attribute string
+	}{
+		{"enabled", routingDecisionAttribute},
+		{"disabled", ""},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			// prepare
+			cfg := serviceBasedRoutingConfig()
+			cfg.RoutingDecisionAttribute = tt.attribute
+			cfg.RoutingDecisionSamplingRate = 1
+			sinks := map[string]*consumertest.TracesSink{}
+			componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+				sink := new(consumertest.TracesSink)
+				sinks[endpoint] = sink
+				return newMockTracesExporter(sink.ConsumeTraces), nil
+			}
+			lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+			require.NoError(t, err)
+
+			p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+			require.NoError(t, err)
+			p.loadBalancer = lb
+			require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+			defer func() {
+				require.NoError(t, p.Shutdown(context.Background()))
+			}()
+
+			// test
+			require.NoError(t, p.ConsumeTraces(context.Background(), simpleTracesWithServiceName()))
+
+			// verify
+			assert.Equal(t, tt.attribute != "", p.Capabilities().MutatesData)
+			require.NotEmpty(t, sinks)
+			for endpoint, sink := range sinks {
+				for _, td := range sink.AllTraces() {
+					rss := td.ResourceSpans()
+					for i := 0; i < rss.Len(); i++ {
+						span := rss.At(i).ScopeSpans().At(0).Spans().At(0)
+						value, ok := span.Attributes().Get(routingDecisionAttribute)
+						if tt.attribute == "" {
+							assert.False(t, ok)
+							continue
+						}
+						require.True(t, ok)
+						assert.Equal(t, endpoint, endpointWithPort(value.Str()))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestConsumeLogsRoutingDecision(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.RoutingDecisionAttribute = routingDecisionAttribute
+	cfg.RoutingDecisionSamplingRate = 1
+	sink := new(consumertest.LogsSink)
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockLogsExporter(sink.ConsumeLogs), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+
+	p, err := newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	ld := plog.NewLogs()
+	ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+
+	// test
+	require.NoError(t, p.ConsumeLogs(context.Background(), ld))
+
+	// verify
+	assert.True(t, p.Capabilities().MutatesData)
+	require.Len(t, sink.AllLogs(), 1)
+	record := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	value, ok := record.Attributes().Get(routingDecisionAttribute)
+	require.True(t, ok)
+	assert.Equal(t, "endpoint-1", value.Str())
+}
+
+func TestMetricsNotStampedWithRoutingDecision(t *testing.T) {
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingDecisionAttribute = routingDecisionAttribute
+	cfg.RoutingDecisionSamplingRate = 1
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	assert.False(t, p.Capabilities().MutatesData)
+}
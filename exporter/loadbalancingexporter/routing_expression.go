@@ -0,0 +1,91 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/contexts/ottlresource"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl/ottlfuncs"
+)
+
+var (
+	errNoRoutingExpression    = errors.New("no routing_expression specified for the expression routing key")
+	errEmptyRoutingExpression = errors.New("the routing expression evaluated to an empty value")
+)
+
+// expressionRouting derives the routing identifier from an OTTL value expression evaluated against the resource,
+// such as `Concat([attributes["region"], attributes["service.name"]], ":")`.
+type expressionRouting struct {
+	statement *ottl.Statement[ottlresource.TransformContext]
+}
+
+func newExpressionRouting(cfg *Config, settings component.TelemetrySettings) (*expressionRouting, error) {
+	if cfg.RoutingExpression == "" {
+		return nil, errNoRoutingExpression
+	}
+
+	functions := ottlfuncs.StandardConverters[ottlresource.TransformContext]()
+	route := newRouteFactory[ottlresource.TransformContext]()
+	functions[route.Name()] = route
+
+	parser, err := ottlresource.NewParser(functions, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// the expression is evaluated as the argument of the route editor, returning its value
+	statement, err := parser.ParseStatement(fmt.Sprintf("%s(%s)", route.Name(), cfg.RoutingExpression))
+	if err != nil {
+		return nil, fmt.Errorf("invalid routing_expression: %w", err)
+	}
+	return &expressionRouting{statement: statement}, nil
+}
+
+// identifier returns the routing identifier for the given resource.
+func (r *expressionRouting) identifier(resource pcommon.Resource) (string, error) {
+	value, _, err := r.statement.Execute(context.Background(), ottlresource.NewTransformContext(resource))
+	if err != nil {
+		return "", err
+	}
+
+	var id string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		id = v
+	case pcommon.Value:
+		id = v.AsString()
+	default:
+		id = fmt.Sprint(v)
+	}
+	if id == "" {
+		return "", errEmptyRoutingExpression
+	}
+	return id, nil
+}
+
+type routeArguments[K any] struct {
+	Value ottl.Getter[K]
+}
+
+// newRouteFactory creates the editor returning the value of its argument, which is how the routing expression
+// gets evaluated, as a statement has to call an editor.
+func newRouteFactory[K any]() ottl.Factory[K] {
+	return ottl.NewFactory("route", &routeArguments[K]{}, createRouteFunction[K])
+}
+
+func createRouteFunction[K any](_ ottl.FunctionContext, oArgs ottl.Arguments) (ottl.ExprFunc[K], error) {
+	args, ok := oArgs.(*routeArguments[K])
+	if !ok {
+		return nil, fmt.Errorf("routeFactory args must be of type *routeArguments[K]")
+	}
+	return args.Value.Get, nil
+}
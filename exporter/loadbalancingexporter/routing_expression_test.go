@@ -0,0 +1,124 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	conventions "go.opentelemetry.io/collector/semconv/v1.9.0"
+)
+
+func TestExpressionRoutingIdentifier(t *testing.T) {
+	resource := pcommon.NewResource()
+	resource.Attributes().PutStr(conventions.AttributeServiceName, "service-1")
+	resource.Attributes().PutStr("region", "eu-west-1")
+	resource.Attributes().PutInt("shard", 3)
+
+	for _, tt := range []struct {
+		desc       string
+		expression string
+		expected   string
+		err        error
+	}{
+		{"attribute", `attributes["service.name"]`, "service-1", nil},
+		{"non-string attribute", `attributes["shard"]`, "3", nil},
+		{"converter", `Concat([attributes["region"], attributes["service.name"]], ":")`, "eu-west-1:service-1", nil},
+		{"missing attribute", `attributes["tenant.id"]`, "", errEmptyRoutingExpression},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			r, err := newExpressionRouting(&Config{RoutingExpression: tt.expression}, componenttest.NewNopTelemetrySettings())
+			require.NoError(t, err)
+
+			// test
+			rid, err := r.identifier(resource)
+
+			// verify
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.expected, rid)
+		})
+	}
+}
+
+func TestNewExpressionRoutingInvalidConfig(t *testing.T) {
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "expression"
+
+	_, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorIs(t, err, errNoRoutingExpression)
+
+	_, err = newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorIs(t, err, errNoRoutingExpression)
+
+	_, err = newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorIs(t, err, errNoRoutingExpression)
+
+	cfg.RoutingExpression = `Concat([attributes["region"]`
+	_, err = newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorContains(t, err, "invalid routing_expression")
+
+	cfg.RoutingExpression = `UnknownFunction(attributes["region"])`
+	_, err = newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorContains(t, err, "invalid routing_expression")
+}
+
+func TestConsumeTracesExpressionBased(t *testing.T) {
+	// prepare
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "expression"
+	cfg.RoutingExpression = `Concat([attributes["region"], attributes["service.name"]], ":")`
+	sinks := map[string]*consumertest.TracesSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks[endpoint] = sink
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	td := ptrace.NewTraces()
+	for i := 0; i < 3; i++ {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("region", "eu-west-1")
+		rs.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+		// the trace IDs don't matter
+		rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetTraceID([16]byte{byte(i)})
+	}
+
+	// test
+	require.NoError(t, p.ConsumeTraces(context.Background(), td))
+
+	// verify
+	_, endpoint, err := lb.exporterAndEndpoint([]byte("eu-west-1:" + serviceName1))
+	require.NoError(t, err)
+	for name, sink := range sinks {
+		switch name {
+		case endpointWithPort(endpoint):
+			require.Len(t, sink.AllTraces(), 1)
+			assert.Equal(t, 3, sink.AllTraces()[0].SpanCount())
+		default:
+			assert.Empty(t, sink.AllTraces())
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	conventions "go.opentelemetry.io/collector/semconv/v1.6.1"
+)
+
+var (
+	errMissingServiceName = errors.New("unable to get service name")
+	errMissingRoutingID   = errors.New("unable to get routing id")
+)
+
+// RoutingIDAttribute is the resource attribute holding a precomputed routing identifier. It is used when
+// metrics are routed with the "routingID" routing key.
+const RoutingIDAttribute = "loadbalancing.routing_id"
+
+// TraceIDRoutingID returns the routing identifier for the given trace ID, in the form expected by the
+// RoutingIDAttribute. Metrics carrying this value are routed to the same backend as the spans for the trace
+// when the traces are routed with the "traceID" routing key, as long as both exporters see the same backends.
+func TraceIDRoutingID(traceID pcommon.TraceID) string {
+	return hex.EncodeToString(traceID[:])
+}
+
+// traceIDRoutingIdentifier is the identifier used in the ring for the given trace ID.
+func traceIDRoutingIdentifier(traceID pcommon.TraceID) string {
+	return string(traceID[:])
+}
+
+// routingIdentifierFromRoutingID converts the value of the RoutingIDAttribute into the identifier used in the
+// ring. Values produced by TraceIDRoutingID map to the same identifier as the trace ID itself.
+func routingIdentifierFromRoutingID(routingID string) string {
+	if len(routingID) == hex.EncodedLen(len(pcommon.TraceID{})) {
+		if decoded, err := hex.DecodeString(routingID); err == nil {
+			return string(decoded)
+		}
+	}
+	return routingID
+}
+
+// resourceRoutingIdentifier returns the routing identifier for the routing keys that depend only on the resource.
+func resourceRoutingIdentifier(resource pcommon.Resource, key routingKey) (string, error) {
+	switch key {
+	case routingIDRouting:
+		rid, ok := resource.Attributes().Get(RoutingIDAttribute)
+		if !ok {
+			return "", errMissingRoutingID
+		}
+		return routingIdentifierFromRoutingID(rid.AsString()), nil
+	case resourceAttrsRouting:
+		// unlike resourceRouting, the metric name isn't part of the key, keeping all the metrics
+		// from the same resource together
+		return strings.Join(sortedMapAttrs(resource.Attributes()), ""), nil
+	default:
+		svc, ok := resource.Attributes().Get(conventions.AttributeServiceName)
+		if !ok {
+			return "", errMissingServiceName
+		}
+		return svc.Str(), nil
+	}
+}
+
+// schemaURLRoutingIdentifier returns the routing identifier for a resource with the given schema URL, falling back
+// to the service name of the resource when the schema URL is empty.
+func schemaURLRoutingIdentifier(schemaURL string, resource pcommon.Resource) (string, error) {
+	if schemaURL != "" {
+		return schemaURL, nil
+	}
+	return resourceRoutingIdentifier(resource, svcRouting)
+}
+
+// isUnroutable returns whether the error means that the routing identifier couldn't be derived from the data.
+func isUnroutable(err error) bool {
+	return errors.Is(err, errMissingServiceName) || errors.Is(err, errMissingRoutingID) ||
+		errors.Is(err, errMissingRoutingAttribute) || errors.Is(err, errMissingRoutingMetadata) ||
+		errors.Is(err, errEmptyRoutingExpression)
+}
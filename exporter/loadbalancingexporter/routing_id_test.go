@@ -0,0 +1,76 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+func TestTraceIDRoutingID(t *testing.T) {
+	// prepare
+	traceID := pcommon.TraceID([16]byte{1, 2, 3, 4})
+
+	// test
+	rid := TraceIDRoutingID(traceID)
+
+	// verify
+	assert.Equal(t, "01020304000000000000000000000000", rid)
+	assert.Equal(t, traceIDRoutingIdentifier(traceID), routingIdentifierFromRoutingID(rid))
+}
+
+func TestRoutingIdentifierFromRoutingID(t *testing.T) {
+	for _, tt := range []struct {
+		desc     string
+		input    string
+		expected string
+	}{
+		{"arbitrary value", "tenant-1", "tenant-1"},
+		{"hex value with the wrong length", "0102", "0102"},
+		{"not a hex value", "zz020304000000000000000000000000", "zz020304000000000000000000000000"},
+		{"trace ID", "01020304000000000000000000000000", string([]byte{1, 2, 3, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.expected, routingIdentifierFromRoutingID(tt.input))
+		})
+	}
+}
+
+func TestResourceRoutingIdentifier(t *testing.T) {
+	resource := pcommon.NewResource()
+
+	_, err := resourceRoutingIdentifier(resource, routingIDRouting)
+	assert.EqualError(t, err, "unable to get routing id")
+	_, err = resourceRoutingIdentifier(resource, svcRouting)
+	assert.EqualError(t, err, "unable to get service name")
+
+	resource.Attributes().PutStr(RoutingIDAttribute, "tenant-1")
+	resource.Attributes().PutStr("service.name", "service-1")
+
+	rid, err := resourceRoutingIdentifier(resource, routingIDRouting)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1", rid)
+	rid, err = resourceRoutingIdentifier(resource, svcRouting)
+	require.NoError(t, err)
+	assert.Equal(t, "service-1", rid)
+}
+
+func TestSchemaURLRoutingIdentifier(t *testing.T) {
+	resource := pcommon.NewResource()
+
+	_, err := schemaURLRoutingIdentifier("", resource)
+	assert.ErrorIs(t, err, errMissingServiceName)
+
+	resource.Attributes().PutStr("service.name", "service-1")
+
+	rid, err := schemaURLRoutingIdentifier("https://opentelemetry.io/schemas/1.9.0", resource)
+	require.NoError(t, err)
+	assert.Equal(t, "https://opentelemetry.io/schemas/1.9.0", rid)
+	rid, err = schemaURLRoutingIdentifier("", resource)
+	require.NoError(t, err)
+	assert.Equal(t, "service-1", rid)
+}
@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/client"
+)
+
+var (
+	errNoRoutingMetadataKey   = errors.New("no routing_metadata_key specified for the metadata routing key")
+	errMissingRoutingMetadata = errors.New("unable to get routing metadata")
+)
+
+// metadataRouting derives the routing identifier from the metadata of the client sending the data, such as a tenant
+// header propagated by a receiver with include_metadata. All the data of a request shares the same identifier.
+type metadataRouting struct {
+	key string
+}
+
+func newMetadataRouting(cfg *Config) (*metadataRouting, error) {
+	if cfg.RoutingMetadataKey == "" {
+		return nil, errNoRoutingMetadataKey
+	}
+	return &metadataRouting{key: cfg.RoutingMetadataKey}, nil
+}
+
+// identifier returns the routing identifier for the request in the given context, from the first value of the
+// metadata key.
+func (r *metadataRouting) identifier(ctx context.Context) (string, error) {
+	values := client.FromContext(ctx).Metadata.Get(r.key)
+	if len(values) == 0 || values[0] == "" {
+		return "", fmt.Errorf("%w: %q", errMissingRoutingMetadata, r.key)
+	}
+	return values[0], nil
+}
+
+// failoverIdentifier returns the routing identifier the request in the given context fails over for, nil when it
+// has none and went to the catch-all endpoint.
+func (r *metadataRouting) failoverIdentifier(ctx context.Context) []byte {
+	rid, err := r.identifier(ctx)
+	if err != nil {
+		return nil
+	}
+	return []byte(rid)
+}
+
+// exporterAndEndpointForMetadata returns the exporter and the endpoint for the request in the given context, routed
+// by its client metadata. The requests without the metadata go to the catch-all endpoint, when one is configured.
+func (lb *loadBalancer) exporterAndEndpointForMetadata(ctx context.Context, r *metadataRouting) (*wrappedExporter, string, error) {
+	rid, err := r.identifier(ctx)
+	if err != nil {
+		if isUnroutable(err) && lb.hasCatchAll() {
+			return lb.catchAllExporterAndEndpoint()
+		}
+		return nil, "", err
+	}
+	return lb.exporterAndEndpoint([]byte(rid))
+}
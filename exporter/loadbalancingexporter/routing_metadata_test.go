@@ -0,0 +1,144 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+)
+
+func contextWithTenant(tenant string) context.Context {
+	return client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{"X-Tenant-ID": {tenant}}),
+	})
+}
+
+func TestMetadataRoutingIdentifier(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.RoutingMetadataKey = "x-tenant-id"
+	r, err := newMetadataRouting(cfg)
+	require.NoError(t, err)
+
+	// test and verify
+	rid, err := r.identifier(contextWithTenant("tenant-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant-1", rid)
+
+	_, err = r.identifier(context.Background())
+	assert.ErrorIs(t, err, errMissingRoutingMetadata)
+	assert.True(t, isUnroutable(err))
+
+	_, err = r.identifier(contextWithTenant(""))
+	assert.ErrorIs(t, err, errMissingRoutingMetadata)
+}
+
+func TestNewMetadataRoutingWithoutKey(t *testing.T) {
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "metadata"
+
+	_, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorIs(t, err, errNoRoutingMetadataKey)
+
+	_, err = newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorIs(t, err, errNoRoutingMetadataKey)
+
+	_, err = newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorIs(t, err, errNoRoutingMetadataKey)
+}
+
+func TestConsumeTracesMetadataBased(t *testing.T) {
+	// prepare
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "metadata"
+	cfg.RoutingMetadataKey = "X-Tenant-ID"
+	cfg.CatchAllEndpoint = "catch-all"
+	sinks := map[string]*consumertest.TracesSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks[endpoint] = sink
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test, the traces of different services from the same tenant
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.ConsumeTraces(contextWithTenant("tenant-1"), simpleTracesWithServiceName()))
+		require.NoError(t, p.ConsumeTraces(contextWithTenant("tenant-1"), simpleTraces()))
+	}
+	require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+
+	// verify
+	_, tenantEndpoint, err := lb.exporterAndEndpoint([]byte("tenant-1"))
+	require.NoError(t, err)
+	for endpoint, sink := range sinks {
+		switch endpoint {
+		case endpointWithPort(tenantEndpoint):
+			assert.Len(t, sink.AllTraces(), 6)
+		case "catch-all:4317":
+			assert.Len(t, sink.AllTraces(), 1)
+		default:
+			assert.Empty(t, sink.AllTraces())
+		}
+	}
+}
+
+func TestConsumeLogsMetadataBased(t *testing.T) {
+	// prepare
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "metadata"
+	cfg.RoutingMetadataKey = "X-Tenant-ID"
+	sinks := map[string]*consumertest.LogsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.LogsSink)
+		sinks[endpoint] = sink
+		return newMockLogsExporter(sink.ConsumeLogs), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	require.NoError(t, p.ConsumeLogs(contextWithTenant("tenant-1"), randomLogs()))
+	require.NoError(t, p.ConsumeLogs(contextWithTenant("tenant-1"), simpleLogs()))
+	err = p.ConsumeLogs(context.Background(), simpleLogs())
+
+	// verify
+	assert.ErrorIs(t, err, errMissingRoutingMetadata)
+	_, tenantEndpoint, err := lb.exporterAndEndpoint([]byte("tenant-1"))
+	require.NoError(t, err)
+	assert.Len(t, sinks[endpointWithPort(tenantEndpoint)].AllLogs(), 2)
+}
@@ -0,0 +1,72 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+const (
+	missingServiceNameReject             = "reject"
+	missingServiceNameDefaultKey         = "default_key"
+	missingServiceNameResourceAttributes = "resource_attributes"
+	missingServiceNameDrop               = "drop"
+)
+
+var (
+	errNoMissingServiceNameKey = errors.New("no missing_service_name_key specified for the default_key fallback")
+
+	// errDroppedWithoutServiceName is returned for the resources without a service name to be dropped, the rest of
+	// the data being routed as usual
+	errDroppedWithoutServiceName = errors.New("dropped the data without service name")
+)
+
+// serviceRouting derives the routing identifier from the service name of the resource, falling back to another
+// identifier for the resources without a service name, instead of failing the whole batch.
+type serviceRouting struct {
+	fallback string
+
+	// key is the routing identifier of the resources without a service name for the default_key fallback
+	key string
+}
+
+// newServiceRouting returns the routing by service name with the fallback from the configuration, or nil when the
+// data without a service name is rejected.
+func newServiceRouting(cfg *Config) (*serviceRouting, error) {
+	switch cfg.MissingServiceName {
+	case "", missingServiceNameReject:
+		return nil, nil
+	case missingServiceNameDefaultKey:
+		if cfg.MissingServiceNameKey == "" {
+			return nil, errNoMissingServiceNameKey
+		}
+	case missingServiceNameResourceAttributes, missingServiceNameDrop:
+	default:
+		return nil, fmt.Errorf("unsupported missing_service_name: %q", cfg.MissingServiceName)
+	}
+	return &serviceRouting{
+		fallback: cfg.MissingServiceName,
+		key:      cfg.MissingServiceNameKey,
+	}, nil
+}
+
+// identifier returns the routing identifier for the given resource.
+func (r *serviceRouting) identifier(resource pcommon.Resource) (string, error) {
+	rid, err := resourceRoutingIdentifier(resource, svcRouting)
+	if !errors.Is(err, errMissingServiceName) {
+		return rid, err
+	}
+
+	switch r.fallback {
+	case missingServiceNameDefaultKey:
+		return r.key, nil
+	case missingServiceNameResourceAttributes:
+		return resourceRoutingIdentifier(resource, resourceAttrsRouting)
+	default:
+		return "", errDroppedWithoutServiceName
+	}
+}
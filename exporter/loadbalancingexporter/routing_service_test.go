@@ -0,0 +1,125 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	conventions "go.opentelemetry.io/collector/semconv/v1.9.0"
+)
+
+func TestServiceRoutingIdentifier(t *testing.T) {
+	named := pcommon.NewResource()
+	named.Attributes().PutStr(conventions.AttributeServiceName, "service-1")
+	unnamed := pcommon.NewResource()
+	unnamed.Attributes().PutStr(conventions.AttributeHostName, "host-1")
+
+	for _, tt := range []struct {
+		fallback string
+		expected string
+		err      error
+	}{
+		{missingServiceNameDefaultKey, "unknown-service", nil},
+		{missingServiceNameResourceAttributes, "host.namehost-1", nil},
+		{missingServiceNameDrop, "", errDroppedWithoutServiceName},
+	} {
+		t.Run(tt.fallback, func(t *testing.T) {
+			r, err := newServiceRouting(&Config{MissingServiceName: tt.fallback, MissingServiceNameKey: "unknown-service"})
+			require.NoError(t, err)
+			require.NotNil(t, r)
+
+			// test
+			rid, err := r.identifier(unnamed)
+
+			// verify
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.expected, rid)
+
+			// the resources with a service name are routed by it
+			rid, err = r.identifier(named)
+			assert.NoError(t, err)
+			assert.Equal(t, "service-1", rid)
+		})
+	}
+}
+
+func TestNewServiceRoutingInvalidConfig(t *testing.T) {
+	r, err := newServiceRouting(&Config{MissingServiceName: missingServiceNameReject})
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+
+	cfg := serviceBasedRoutingConfig()
+	cfg.MissingServiceName = missingServiceNameDefaultKey
+	_, err = newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorIs(t, err, errNoMissingServiceNameKey)
+
+	cfg.MissingServiceName = "unknown"
+	_, err = newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorContains(t, err, "unsupported missing_service_name")
+
+	_, err = newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	assert.ErrorContains(t, err, "unsupported missing_service_name")
+}
+
+func TestConsumeMetricsWithoutServiceNameFallback(t *testing.T) {
+	for _, tt := range []struct {
+		fallback string
+		routed   []string
+	}{
+		{missingServiceNameDefaultKey, []string{signal1Name, signal2Name}},
+		{missingServiceNameDrop, []string{signal1Name}},
+	} {
+		t.Run(tt.fallback, func(t *testing.T) {
+			// prepare
+			cfg := serviceBasedRoutingConfig()
+			cfg.MissingServiceName = tt.fallback
+			// the same backend as the named service
+			cfg.MissingServiceNameKey = serviceName1
+			sinks := map[string]*consumertest.MetricsSink{}
+			componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+				sink := new(consumertest.MetricsSink)
+				sinks[endpoint] = sink
+				return newMockMetricsExporter(sink.ConsumeMetrics), nil
+			}
+			lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+			require.NotNil(t, lb)
+			require.NoError(t, err)
+
+			p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+			require.NotNil(t, p)
+			require.NoError(t, err)
+
+			p.loadBalancer = lb
+			err = p.Start(context.Background(), componenttest.NewNopHost())
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, p.Shutdown(context.Background()))
+			}()
+
+			md := pmetric.NewMetrics()
+			named := md.ResourceMetrics().AppendEmpty()
+			named.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+			appendSimpleMetricWithID(named, signal1Name)
+			appendSimpleMetricWithID(md.ResourceMetrics().AppendEmpty(), signal2Name)
+
+			// test
+			err = p.ConsumeMetrics(context.Background(), md)
+
+			// verify
+			require.NoError(t, err)
+			backend := endpointWithPort(lb.ring.endpointFor([]byte(serviceName1)))
+			require.Len(t, sinks[backend].AllMetrics(), 1)
+			assert.ElementsMatch(t, tt.routed, metricNames(sinks[backend].AllMetrics()[0]))
+		})
+	}
+}
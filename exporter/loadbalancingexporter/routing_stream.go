@@ -0,0 +1,126 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/pdatautil"
+)
+
+// splitMetricsByStream groups the data points of the metrics from the batch by the identifier of their stream, made
+// of the resource attributes, the scope, the metric name and the data point attributes. Each of the resulting
+// pmetric.Metrics holds the data points of a single stream, so that the whole stream reaches the same endpoint.
+func splitMetricsByStream(md pmetric.Metrics) map[string]pmetric.Metrics {
+	result := make(map[string]pmetric.Metrics)
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		resourceHash := pdatautil.MapHash(rm.Resource().Attributes())
+
+		sms := rm.ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			sm := sms.At(j)
+			scopeHash := pdatautil.MapHash(sm.Scope().Attributes())
+
+			metrics := sm.Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+
+				// the metric holding the data points of each stream of this metric
+				streams := make(map[string]pmetric.Metric)
+				dest := func(attrs pcommon.Map) pmetric.Metric {
+					attrsHash := pdatautil.MapHash(attrs)
+					sid := encodeRoutingFields([]string{
+						string(resourceHash[:]),
+						sm.Scope().Name(),
+						sm.Scope().Version(),
+						string(scopeHash[:]),
+						metric.Name(),
+						string(attrsHash[:]),
+					})
+					stream, ok := streams[sid]
+					if !ok {
+						stream = appendStreamMetric(result, sid, rm, sm, metric)
+						streams[sid] = stream
+					}
+					return stream
+				}
+
+				switch metric.Type() {
+				case pmetric.MetricTypeGauge:
+					dps := metric.Gauge().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dps.At(l).CopyTo(dest(dps.At(l).Attributes()).Gauge().DataPoints().AppendEmpty())
+					}
+				case pmetric.MetricTypeSum:
+					dps := metric.Sum().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dps.At(l).CopyTo(dest(dps.At(l).Attributes()).Sum().DataPoints().AppendEmpty())
+					}
+				case pmetric.MetricTypeHistogram:
+					dps := metric.Histogram().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dps.At(l).CopyTo(dest(dps.At(l).Attributes()).Histogram().DataPoints().AppendEmpty())
+					}
+				case pmetric.MetricTypeExponentialHistogram:
+					dps := metric.ExponentialHistogram().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dps.At(l).CopyTo(dest(dps.At(l).Attributes()).ExponentialHistogram().DataPoints().AppendEmpty())
+					}
+				case pmetric.MetricTypeSummary:
+					dps := metric.Summary().DataPoints()
+					for l := 0; l < dps.Len(); l++ {
+						dps.At(l).CopyTo(dest(dps.At(l).Attributes()).Summary().DataPoints().AppendEmpty())
+					}
+				}
+
+				if len(streams) == 0 {
+					// the metric without data points is kept along, as the stream without attributes
+					dest(pcommon.NewMap())
+				}
+			}
+		}
+	}
+	return result
+}
+
+// appendStreamMetric appends a metric without data points to the batch of the stream, copying the resource, the scope
+// and the description of the metric the data points come from.
+func appendStreamMetric(result map[string]pmetric.Metrics, sid string, rm pmetric.ResourceMetrics, sm pmetric.ScopeMetrics, metric pmetric.Metric) pmetric.Metric {
+	batch, ok := result[sid]
+	if !ok {
+		batch = pmetric.NewMetrics()
+		result[sid] = batch
+	}
+
+	newRM := batch.ResourceMetrics().AppendEmpty()
+	rm.Resource().CopyTo(newRM.Resource())
+	newRM.SetSchemaUrl(rm.SchemaUrl())
+
+	newSM := newRM.ScopeMetrics().AppendEmpty()
+	sm.Scope().CopyTo(newSM.Scope())
+	newSM.SetSchemaUrl(sm.SchemaUrl())
+
+	stream := newSM.Metrics().AppendEmpty()
+	stream.SetName(metric.Name())
+	stream.SetDescription(metric.Description())
+	stream.SetUnit(metric.Unit())
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		stream.SetEmptyGauge()
+	case pmetric.MetricTypeSum:
+		sum := stream.SetEmptySum()
+		sum.SetAggregationTemporality(metric.Sum().AggregationTemporality())
+		sum.SetIsMonotonic(metric.Sum().IsMonotonic())
+	case pmetric.MetricTypeHistogram:
+		stream.SetEmptyHistogram().SetAggregationTemporality(metric.Histogram().AggregationTemporality())
+	case pmetric.MetricTypeExponentialHistogram:
+		stream.SetEmptyExponentialHistogram().SetAggregationTemporality(metric.ExponentialHistogram().AggregationTemporality())
+	case pmetric.MetricTypeSummary:
+		stream.SetEmptySummary()
+	}
+	return stream
+}
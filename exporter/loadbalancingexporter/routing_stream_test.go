@@ -0,0 +1,134 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	conventions "go.opentelemetry.io/collector/semconv/v1.9.0"
+)
+
+// streamMetrics returns a delta sum with a data point for each of the given pods, from the given scope.
+func streamMetrics(scope string, pods ...string) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName(scope)
+	metric := sm.Metrics().AppendEmpty()
+	metric.SetName("http.server.requests")
+	metric.SetUnit("1")
+	sum := metric.SetEmptySum()
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	sum.SetIsMonotonic(true)
+	for _, pod := range pods {
+		dp := sum.DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("pod", pod)
+		dp.SetIntValue(1)
+	}
+	return md
+}
+
+func TestSplitMetricsByStream(t *testing.T) {
+	// test
+	streams := splitMetricsByStream(streamMetrics("scope-1", "pod-1", "pod-2", "pod-1"))
+
+	// verify
+	require.Len(t, streams, 2)
+	points := map[string]int{}
+	for _, md := range streams {
+		require.Equal(t, 1, md.MetricCount())
+		metric := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+		assert.Equal(t, "http.server.requests", metric.Name())
+		assert.Equal(t, "1", metric.Unit())
+		assert.Equal(t, pmetric.AggregationTemporalityDelta, metric.Sum().AggregationTemporality())
+		assert.True(t, metric.Sum().IsMonotonic())
+
+		pod, _ := metric.Sum().DataPoints().At(0).Attributes().Get("pod")
+		points[pod.Str()] = metric.Sum().DataPoints().Len()
+	}
+	assert.Equal(t, map[string]int{"pod-1": 2, "pod-2": 1}, points)
+
+	// the same attributes from another scope are another stream
+	for sid := range splitMetricsByStream(streamMetrics("scope-2", "pod-1")) {
+		assert.NotContains(t, streams, sid)
+	}
+
+	// the metrics without data points are kept
+	md := pmetric.NewMetrics()
+	md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetName("empty")
+	streams = splitMetricsByStream(md)
+	require.Len(t, streams, 1)
+	for _, md := range streams {
+		assert.Equal(t, 1, md.MetricCount())
+	}
+}
+
+func TestConsumeMetricsStreamIDBased(t *testing.T) {
+	// prepare
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "streamID"
+	sinks := map[string]*consumertest.MetricsSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.MetricsSink)
+		sinks[endpoint] = sink
+		return newMockMetricsExporter(sink.ConsumeMetrics), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	var pods []string
+	for i := 0; i < 20; i++ {
+		pods = append(pods, fmt.Sprintf("pod-%d", i))
+	}
+
+	// test
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.ConsumeMetrics(context.Background(), streamMetrics("scope-1", pods...)))
+	}
+
+	// verify
+	require.Len(t, sinks, 2)
+	endpoints := map[string]string{}
+	for endpoint, sink := range sinks {
+		// the data points of the same metric are spread across the backends
+		require.NotEmpty(t, sink.AllMetrics())
+		for _, md := range sink.AllMetrics() {
+			rms := md.ResourceMetrics()
+			for i := 0; i < rms.Len(); i++ {
+				dps := rms.At(i).ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints()
+				for j := 0; j < dps.Len(); j++ {
+					pod, _ := dps.At(j).Attributes().Get("pod")
+					if previous, ok := endpoints[pod.Str()]; ok {
+						// each stream always reaches the same backend
+						assert.Equal(t, previous, endpoint)
+					}
+					endpoints[pod.Str()] = endpoint
+				}
+			}
+		}
+	}
+	assert.Len(t, endpoints, len(pods))
+}
@@ -0,0 +1,66 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// maxVerifiedIdentifiers bounds the memory used by the routing verification: the identifiers seen are forgotten
+// once there are more of them.
+const maxVerifiedIdentifiers = 10000
+
+// routingVerifier checks that the ring keeps routing each identifier to the same endpoint, as long as the ring
+// doesn't change, warning about any identifier moving to another endpoint, which would be a bug.
+type routingVerifier struct {
+	logger *zap.Logger
+
+	lock sync.Mutex
+	ring ring
+	seen map[string]string
+}
+
+// newRoutingVerifier returns the verifier for the routing decisions, or nil when the verification is disabled or
+// the hash strategy doesn't route the identifiers consistently by design.
+func newRoutingVerifier(logger *zap.Logger, cfg *Config) *routingVerifier {
+	if !cfg.VerifyRouting {
+		return nil
+	}
+	if cfg.HashStrategy == weightedRoundRobinStrategy {
+		logger.Info("the routing isn't verified with the weighted_round_robin hash strategy, which has no affinity")
+		return nil
+	}
+	return &routingVerifier{logger: logger, seen: map[string]string{}}
+}
+
+// verify records the endpoint the ring routed the identifier to, warning when the same ring routed it to another
+// endpoint before.
+func (v *routingVerifier) verify(r ring, identifier []byte, endpoint string) {
+	if v == nil {
+		return
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if r != v.ring || len(v.seen) >= maxVerifiedIdentifiers {
+		v.ring = r
+		v.seen = map[string]string{}
+	}
+	previous, known := v.seen[string(identifier)]
+	if !known {
+		v.seen[string(identifier)] = endpoint
+		return
+	}
+	if previous != endpoint {
+		v.logger.Warn("inconsistent routing: the same identifier was routed to different endpoints without any change of the ring",
+			zap.ByteString("identifier", identifier),
+			zap.String("previous", previous),
+			zap.String("endpoint", endpoint),
+			zap.String("fingerprint", r.fingerprint()))
+		v.seen[string(identifier)] = endpoint
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const inconsistentRoutingMessage = "inconsistent routing: the same identifier was routed to different endpoints without any change of the ring"
+
+// flippingRing is a faulty ring, routing the identifiers to its endpoints in turn.
+type flippingRing struct {
+	ring
+	calls int
+}
+
+func (r *flippingRing) endpointFor(_ []byte) string {
+	endpoints := r.ring.endpoints()
+	r.calls++
+	return endpoints[r.calls%len(endpoints)]
+}
+
+func newVerifiedTracesExporter(t *testing.T, cfg *Config, endpoints *[]string) (*traceExporterImp, *observer.ObservedLogs) {
+	cfg.VerifyRouting = true
+	core, logs := observer.New(zap.WarnLevel)
+	params := exportertest.NewNopCreateSettings()
+	params.Logger = zap.New(core)
+	lb, err := newLoadBalancer(params, cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockTracesExporter(), nil
+	})
+	require.NoError(t, err)
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return *endpoints, nil
+		},
+	}
+
+	p, err := newTracesExporter(params, cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	})
+	return p, logs
+}
+
+func TestRoutingVerifierSilent(t *testing.T) {
+	// prepare
+	endpoints := []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+	p, logs := newVerifiedTracesExporter(t, simpleConfig(), &endpoints)
+
+	// test
+	for i := 0; i < 10; i++ {
+		require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+		require.NoError(t, p.ConsumeTraces(context.Background(), randomTraces()))
+	}
+
+	// identifiers moving along with a change of the ring are expected
+	endpoints = []string{"endpoint-1", "endpoint-4"}
+	_, err := p.loadBalancer.res.resolve(context.Background())
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+	}
+
+	// verify
+	assert.Zero(t, logs.FilterMessage(inconsistentRoutingMessage).Len())
+}
+
+func TestRoutingVerifierWarns(t *testing.T) {
+	// prepare
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	p, logs := newVerifiedTracesExporter(t, simpleConfig(), &endpoints)
+	p.loadBalancer.updateLock.Lock()
+	p.loadBalancer.ring = &flippingRing{ring: p.loadBalancer.ring}
+	p.loadBalancer.updateLock.Unlock()
+
+	// test
+	require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+	require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+
+	// verify
+	warnings := logs.FilterMessage(inconsistentRoutingMessage).All()
+	require.Len(t, warnings, 1)
+	fields := warnings[0].ContextMap()
+	assert.NotEqual(t, fields["previous"], fields["endpoint"])
+	assert.Contains(t, endpoints, fields["previous"])
+	assert.Contains(t, endpoints, fields["endpoint"])
+}
+
+func TestRoutingVerifierDisabled(t *testing.T) {
+	cfg := simpleConfig()
+	assert.Nil(t, newRoutingVerifier(zap.NewNop(), cfg))
+
+	cfg.VerifyRouting = true
+	cfg.HashStrategy = weightedRoundRobinStrategy
+	assert.Nil(t, newRoutingVerifier(zap.NewNop(), cfg))
+}
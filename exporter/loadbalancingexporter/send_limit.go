@@ -0,0 +1,53 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"sync"
+)
+
+// acquireSendSlot waits until a send to a backend can start, when the number of concurrent sends is limited, and
+// returns the function releasing the slot once the send is over. An error is returned when the context is done
+// before a slot is available.
+func (lb *loadBalancer) acquireSendSlot(ctx context.Context) (func(), error) {
+	if lb.sendSlots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case lb.sendSlots <- struct{}{}:
+		return func() { <-lb.sendSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// dispatch calls the send function for each of the given number of parts of a batch, for up to the configured
+// dispatch concurrency of them at the same time, and returns the errors in the order of the parts.
+func (lb *loadBalancer) dispatch(parts int, send func(i int) error) []error {
+	errs := make([]error, parts)
+	if lb.cfg.DispatchConcurrency <= 1 || parts <= 1 {
+		for i := range errs {
+			errs[i] = send(i)
+		}
+		return errs
+	}
+
+	workers := make(chan struct{}, lb.cfg.DispatchConcurrency)
+	var wg sync.WaitGroup
+	for i := range errs {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			errs[i] = send(i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,216 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func newLimitedTracesExporter(t *testing.T, maxInFlightSends int, consume func(context.Context, ptrace.Traces) error) *traceExporterImp {
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+	cfg.MaxInFlightSends = maxInFlightSends
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer.componentFactory = func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(consume), nil
+	}
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	return p
+}
+
+func TestMaxInFlightSends(t *testing.T) {
+	// prepare
+	var inFlight, maxInFlight atomic.Int32
+	p := newLimitedTracesExporter(t, 3, func(context.Context, ptrace.Traces) error {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			observed := maxInFlight.Load()
+			if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+
+	// test
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			td := ptrace.NewTraces()
+			for j := 0; j < 5; j++ {
+				td.ResourceSpans().AppendEmpty()
+				randomTraces().ResourceSpans().At(0).CopyTo(td.ResourceSpans().At(j))
+			}
+			assert.NoError(t, p.ConsumeTraces(context.Background(), td))
+		}()
+	}
+	wg.Wait()
+
+	// verify
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(3))
+	assert.Positive(t, maxInFlight.Load())
+	assert.Empty(t, p.loadBalancer.sendSlots)
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestMaxInFlightSendsContextDone(t *testing.T) {
+	// prepare
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	var once sync.Once
+	p := newLimitedTracesExporter(t, 1, func(context.Context, ptrace.Traces) error {
+		once.Do(func() {
+			close(started)
+			<-unblock
+		})
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+	}()
+	<-started
+
+	// test
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := p.ConsumeTraces(ctx, simpleTraces())
+
+	// verify
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the slot is released once the blocked send completes, and the exporters aren't waiting for the send given up
+	close(unblock)
+	<-done
+	assert.Empty(t, p.loadBalancer.sendSlots)
+	for _, exp := range p.loadBalancer.exporters {
+		waited := make(chan struct{})
+		go func(exp *wrappedExporter) {
+			exp.consumeWG.Wait()
+			close(waited)
+		}(exp)
+		select {
+		case <-waited:
+		case <-time.After(time.Second):
+			t.Fatal("an exporter is still waiting for a send")
+		}
+	}
+	require.NoError(t, p.Shutdown(context.Background()))
+}
+
+func TestDispatch(t *testing.T) {
+	for _, concurrency := range []int{0, 1, 3} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			// prepare
+			lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), nil)
+			require.NoError(t, err)
+			lb.cfg.DispatchConcurrency = concurrency
+			var inFlight, maxInFlight atomic.Int32
+
+			// test
+			errs := lb.dispatch(10, func(i int) error {
+				current := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					observed := maxInFlight.Load()
+					if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				if i%2 == 1 {
+					return fmt.Errorf("part %d failed", i)
+				}
+				return nil
+			})
+
+			// verify
+			// the errors are returned in the order of the parts
+			require.Len(t, errs, 10)
+			for i, err := range errs {
+				if i%2 == 1 {
+					assert.EqualError(t, err, fmt.Sprintf("part %d failed", i))
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+			expected := int32(concurrency)
+			if expected < 1 {
+				expected = 1
+			}
+			assert.LessOrEqual(t, maxInFlight.Load(), expected)
+			assert.Positive(t, maxInFlight.Load())
+		})
+	}
+}
+
+func TestDispatchConcurrencySendsToBackendsInParallel(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+	cfg.DispatchConcurrency = 3
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+
+	// the sends only complete once all the backends got their data
+	var started sync.WaitGroup
+	p.loadBalancer.componentFactory = func(ctx context.Context, endpoint string) (component.Component, error) {
+		started.Add(1)
+		return newMockTracesExporter(func(context.Context, ptrace.Traces) error {
+			started.Done()
+			done := make(chan struct{})
+			go func() {
+				started.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-time.After(time.Second):
+				return errors.New("the sends to the other backends didn't start")
+			}
+		}), nil
+	}
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	td := ptrace.NewTraces()
+	for _, id := range [][16]byte{{1}, {2}, {3}, {4}, {5}, {6}, {7}, {8}} {
+		appendSimpleTraceWithID(td.ResourceSpans().AppendEmpty(), id)
+	}
+	endpoints := map[string]bool{}
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		id := td.ResourceSpans().At(i).ScopeSpans().At(0).Spans().At(0).TraceID()
+		endpoints[p.loadBalancer.ring.endpointFor(id[:])] = true
+	}
+	require.Len(t, endpoints, 3, "the traces must be spread over all the backends")
+
+	// test
+	err = p.ConsumeTraces(context.Background(), td)
+
+	// verify
+	assert.NoError(t, err)
+}
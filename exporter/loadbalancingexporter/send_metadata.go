@@ -0,0 +1,177 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"google.golang.org/grpc/metadata"
+)
+
+// withTracesMetadata adds the forwarded client metadata, the headers of the exporter and the outgoing metadata derived
+// from the resources of the traces to the context of a send.
+func (lb *loadBalancer) withTracesMetadata(ctx context.Context, exp *wrappedExporter, td ptrace.Traces) context.Context {
+	ctx = withOutgoingMetadata(lb.withClientMetadata(ctx), exp.headers)
+	if len(lb.cfg.AttributesAsMetadata) == 0 {
+		return ctx
+	}
+	rss := td.ResourceSpans()
+	resources := make([]pcommon.Resource, rss.Len())
+	for i := 0; i < rss.Len(); i++ {
+		resources[i] = rss.At(i).Resource()
+	}
+	return lb.withAttributesMetadata(ctx, resources)
+}
+
+// withMetricsMetadata adds the forwarded client metadata, the headers of the exporter and the outgoing metadata derived
+// from the resources of the metrics to the context of a send.
+func (lb *loadBalancer) withMetricsMetadata(ctx context.Context, exp *wrappedExporter, md pmetric.Metrics) context.Context {
+	ctx = withOutgoingMetadata(lb.withClientMetadata(ctx), exp.headers)
+	if len(lb.cfg.AttributesAsMetadata) == 0 {
+		return ctx
+	}
+	rms := md.ResourceMetrics()
+	resources := make([]pcommon.Resource, rms.Len())
+	for i := 0; i < rms.Len(); i++ {
+		resources[i] = rms.At(i).Resource()
+	}
+	return lb.withAttributesMetadata(ctx, resources)
+}
+
+// withLogsMetadata adds the forwarded client metadata, the headers of the exporter and the outgoing metadata derived
+// from the resources of the logs to the context of a send.
+func (lb *loadBalancer) withLogsMetadata(ctx context.Context, exp *wrappedExporter, ld plog.Logs) context.Context {
+	ctx = withOutgoingMetadata(lb.withClientMetadata(ctx), exp.headers)
+	if len(lb.cfg.AttributesAsMetadata) == 0 {
+		return ctx
+	}
+	rls := ld.ResourceLogs()
+	resources := make([]pcommon.Resource, rls.Len())
+	for i := 0; i < rls.Len(); i++ {
+		resources[i] = rls.At(i).Resource()
+	}
+	return lb.withAttributesMetadata(ctx, resources)
+}
+
+// withAttributesMetadata sets each of the headers mapped from a resource attribute to the values of the attribute
+// in the given resources, in order of appearance and without duplicates. Resources without the attribute add no
+// value to its header.
+func (lb *loadBalancer) withAttributesMetadata(ctx context.Context, resources []pcommon.Resource) context.Context {
+	attributes := make([]string, 0, len(lb.cfg.AttributesAsMetadata))
+	for attribute := range lb.cfg.AttributesAsMetadata {
+		attributes = append(attributes, attribute)
+	}
+	sort.Strings(attributes)
+
+	md := metadata.MD{}
+	for _, attribute := range attributes {
+		header := lb.cfg.AttributesAsMetadata[attribute]
+		seen := map[string]bool{}
+		for _, resource := range resources {
+			value, ok := resource.Attributes().Get(attribute)
+			if !ok || seen[value.AsString()] {
+				continue
+			}
+			seen[value.AsString()] = true
+			md.Append(header, value.AsString())
+		}
+	}
+	return withOutgoingMetadata(ctx, md)
+}
+
+// withOutgoingMetadata adds the given metadata to the outgoing metadata of the context, merging the values of the
+// keys already set.
+func withOutgoingMetadata(ctx context.Context, md metadata.MD) context.Context {
+	if md.Len() == 0 {
+		return ctx
+	}
+	if existing, ok := metadata.FromOutgoingContext(ctx); ok {
+		md = metadata.Join(existing, md)
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// forwardsMetadata returns whether metadata is added to the sends to the backends, in which case the headers of the
+// OTLP exporters are sent along with it by the load balancer, as the exporters replace the outgoing metadata with
+// their headers.
+func (lb *loadBalancer) forwardsMetadata() bool {
+	return len(lb.cfg.AttributesAsMetadata) > 0 || len(lb.cfg.ForwardMetadataKeys) > 0
+}
+
+// outgoingHeaders returns the headers of the OTLP exporter of the endpoint to be sent by the load balancer, nil
+// when the exporter sends them itself.
+func (lb *loadBalancer) outgoingHeaders(endpoint string) metadata.MD {
+	if !lb.forwardsMetadata() {
+		return nil
+	}
+	oCfg, ok := lb.endpointExporterConfig(endpoint).(*otlpexporter.Config)
+	if !ok || len(oCfg.Headers) == 0 {
+		return nil
+	}
+	md := metadata.MD{}
+	for name, value := range oCfg.Headers {
+		md.Append(name, string(value))
+	}
+	return md
+}
+
+// withClientMetadata sets the outgoing metadata for each of the forwarded keys to its values in the metadata of the
+// client that sent the data. The keys missing from the client metadata are left out.
+func (lb *loadBalancer) withClientMetadata(ctx context.Context) context.Context {
+	if len(lb.cfg.ForwardMetadataKeys) == 0 {
+		return ctx
+	}
+	info := client.FromContext(ctx)
+	md := metadata.MD{}
+	for _, key := range lb.cfg.ForwardMetadataKeys {
+		md.Append(key, info.Metadata.Get(key)...)
+	}
+	return withOutgoingMetadata(ctx, md)
+}
+
+// clientMetadataRoundTripper sets the headers of the HTTP requests to the backends from the forwarded keys of the
+// client metadata, as the OTLP/HTTP exporters don't send the gRPC metadata.
+type clientMetadataRoundTripper struct {
+	next http.RoundTripper
+	keys []string
+}
+
+// forwardMetadataRoundTripper returns the function wrapping the transport of the OTLP/HTTP exporters so that they
+// forward the given keys of the client metadata.
+func forwardMetadataRoundTripper(keys []string) func(next http.RoundTripper) (http.RoundTripper, error) {
+	return func(next http.RoundTripper) (http.RoundTripper, error) {
+		return &clientMetadataRoundTripper{next: next, keys: keys}, nil
+	}
+}
+
+func (rt *clientMetadataRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	info := client.FromContext(req.Context())
+	var forwarded *http.Request
+	for _, key := range rt.keys {
+		values := info.Metadata.Get(key)
+		if len(values) == 0 {
+			continue
+		}
+		if forwarded == nil {
+			// a round tripper must not modify the request it's given
+			forwarded = req.Clone(req.Context())
+		}
+		forwarded.Header.Del(key)
+		for _, value := range values {
+			forwarded.Header.Add(key, value)
+		}
+	}
+	if forwarded == nil {
+		return rt.next.RoundTrip(req)
+	}
+	return rt.next.RoundTrip(forwarded)
+}
@@ -0,0 +1,313 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	conventions "go.opentelemetry.io/collector/semconv/v1.9.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// metadataTestServer records the metadata of the trace requests it receives.
+type metadataTestServer struct {
+	ptraceotlp.UnimplementedGRPCServer
+	lock     sync.Mutex
+	received []metadata.MD
+}
+
+func (s *metadataTestServer) Export(ctx context.Context, _ ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.received = append(s.received, md)
+	return ptraceotlp.NewExportResponse(), nil
+}
+
+func (s *metadataTestServer) get() []metadata.MD {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]metadata.MD(nil), s.received...)
+}
+
+// startMetadataTestServer starts a gRPC server recording the metadata of the trace requests, returning its address.
+func startMetadataTestServer(t *testing.T) (*metadataTestServer, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	server := &metadataTestServer{}
+	ptraceotlp.RegisterGRPCServer(srv, server)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	t.Cleanup(srv.Stop)
+	return server, listener.Addr().String()
+}
+
+// metadataTestConfig returns a configuration sending the traces to the given address with the OTLP exporter,
+// without a sending queue so that the metadata of the sends reaches the backend.
+func metadataTestConfig(address string) *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Resolver.Static = &StaticResolver{Hostnames: []string{address}}
+	cfg.Protocol.OTLP.TLSSetting.Insecure = true
+	cfg.Protocol.OTLP.QueueConfig.Enabled = false
+	cfg.Protocol.OTLP.RetryConfig.Enabled = false
+	return cfg
+}
+
+func TestConsumeMetricsAttributesAsMetadata(t *testing.T) {
+	// prepare
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "attributes"
+	cfg.RoutingAttributes = []string{"tenant.id"}
+	cfg.AttributesAsMetadata = map[string]string{"tenant.id": "X-Tenant"}
+
+	var lock sync.Mutex
+	received := map[string][]string{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockMetricsExporter(func(ctx context.Context, md pmetric.Metrics) error {
+			lock.Lock()
+			defer lock.Unlock()
+			md2, _ := metadata.FromOutgoingContext(ctx)
+			tenant, _ := md.ResourceMetrics().At(0).Resource().Attributes().Get("tenant.id")
+			received[tenant.AsString()] = md2.Get("x-tenant")
+			return nil
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	for _, tenant := range []string{"tenant-1", "tenant-2", "tenant-3"} {
+		md := pmetric.NewMetrics()
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr("tenant.id", tenant)
+		appendSimpleMetricWithID(rm, tenant)
+
+		// test
+		require.NoError(t, p.ConsumeMetrics(context.Background(), md))
+	}
+
+	// verify
+	assert.Equal(t, map[string][]string{
+		"tenant-1": {"tenant-1"},
+		"tenant-2": {"tenant-2"},
+		"tenant-3": {"tenant-3"},
+	}, received)
+}
+
+func TestConsumeTracesAttributesAsMetadata(t *testing.T) {
+	// prepare
+	cfg := serviceBasedRoutingConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1"}
+	cfg.AttributesAsMetadata = map[string]string{
+		conventions.AttributeServiceName: "x-service",
+		"tenant.id":                      "x-tenant",
+	}
+
+	var received metadata.MD
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(func(ctx context.Context, td ptrace.Traces) error {
+			received, _ = metadata.FromOutgoingContext(ctx)
+			return nil
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	td := ptrace.NewTraces()
+	for _, service := range []string{"service-a", "service-b", "service-a"} {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr(conventions.AttributeServiceName, service)
+		rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetTraceID(pcommon.TraceID([16]byte{1, 2, 3, 4}))
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-existing", "value")
+
+	// test
+	err = p.ConsumeTraces(ctx, td)
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, []string{"service-a", "service-b"}, received.Get("x-service"))
+	assert.Empty(t, received.Get("x-tenant"))
+	assert.Equal(t, []string{"value"}, received.Get("x-existing"))
+}
+
+func TestAttributesAsMetadataWithHeaders(t *testing.T) {
+	for _, shareConnections := range []bool{false, true} {
+		t.Run(fmt.Sprintf("share_connections=%t", shareConnections), func(t *testing.T) {
+			// prepare
+			server, address := startMetadataTestServer(t)
+			cfg := metadataTestConfig(address)
+			cfg.ShareConnections = shareConnections
+			cfg.Protocol.OTLP.Headers = map[string]configopaque.String{"Authorization": "Bearer secret"}
+			cfg.AttributesAsMetadata = map[string]string{"tenant.id": "X-Tenant"}
+
+			p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+			require.NoError(t, err)
+			require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+			defer func() {
+				require.NoError(t, p.Shutdown(context.Background()))
+			}()
+
+			td := simpleTraces()
+			td.ResourceSpans().At(0).Resource().Attributes().PutStr("tenant.id", "tenant-1")
+
+			// test
+			require.NoError(t, p.ConsumeTraces(context.Background(), td))
+
+			// verify
+			received := server.get()
+			require.Len(t, received, 1)
+			assert.Equal(t, []string{"Bearer secret"}, received[0].Get("authorization"))
+			assert.Equal(t, []string{"tenant-1"}, received[0].Get("x-tenant"))
+		})
+	}
+}
+
+func TestConsumeTracesForwardMetadataKeys(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.ForwardMetadataKeys = []string{"X-Tenant", "Authorization"}
+
+	var received metadata.MD
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(func(ctx context.Context, td ptrace.Traces) error {
+			received, _ = metadata.FromOutgoingContext(ctx)
+			return nil
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	ctx := client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{
+			"x-tenant": {"tenant-1"},
+			"x-other":  {"not forwarded"},
+		}),
+	})
+
+	// test
+	require.NoError(t, p.ConsumeTraces(ctx, simpleTraces()))
+
+	// verify
+	assert.Equal(t, metadata.MD{"x-tenant": {"tenant-1"}}, received)
+}
+
+func TestForwardMetadataKeysWithHeaders(t *testing.T) {
+	for _, shareConnections := range []bool{false, true} {
+		t.Run(fmt.Sprintf("share_connections=%t", shareConnections), func(t *testing.T) {
+			// prepare
+			server, address := startMetadataTestServer(t)
+			cfg := metadataTestConfig(address)
+			cfg.ShareConnections = shareConnections
+			cfg.Protocol.OTLP.Headers = map[string]configopaque.String{"Authorization": "Bearer secret"}
+			cfg.EndpointOverrides = []EndpointOverride{{
+				Endpoint: address,
+				Headers:  map[string]configopaque.String{"X-Cluster": "cluster-1"},
+			}}
+			cfg.ForwardMetadataKeys = []string{"X-Tenant"}
+
+			p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+			require.NoError(t, err)
+			require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+			defer func() {
+				require.NoError(t, p.Shutdown(context.Background()))
+			}()
+
+			ctx := client.NewContext(context.Background(), client.Info{
+				Metadata: client.NewMetadata(map[string][]string{"X-Tenant": {"tenant-1"}}),
+			})
+
+			// test
+			require.NoError(t, p.ConsumeTraces(ctx, simpleTraces()))
+
+			// verify
+			received := server.get()
+			require.Len(t, received, 1)
+			assert.Equal(t, []string{"Bearer secret"}, received[0].Get("authorization"))
+			assert.Equal(t, []string{"cluster-1"}, received[0].Get("x-cluster"))
+			assert.Equal(t, []string{"tenant-1"}, received[0].Get("x-tenant"))
+		})
+	}
+}
+
+func TestForwardMetadataRoundTripper(t *testing.T) {
+	// prepare
+	var received http.Header
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		received = req.Header
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+	rt, err := forwardMetadataRoundTripper([]string{"X-Tenant", "X-Missing"})(next)
+	require.NoError(t, err)
+
+	ctx := client.NewContext(context.Background(), client.Info{
+		Metadata: client.NewMetadata(map[string][]string{"x-tenant": {"tenant-1", "tenant-2"}}),
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://endpoint-1:4318/v1/traces", nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	// test
+	_, err = rt.RoundTrip(req)
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tenant-1", "tenant-2"}, received.Values("X-Tenant"))
+	assert.Equal(t, "application/x-protobuf", received.Get("Content-Type"))
+	assert.NotContains(t, received, "X-Missing")
+
+	// the original request is left untouched
+	assert.Empty(t, req.Header.Values("X-Tenant"))
+}
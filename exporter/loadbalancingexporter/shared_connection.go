@@ -0,0 +1,273 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// sharedConnections holds the gRPC connections shared by the exporters for the different signals of the same
+// component, keyed by the component's configuration and the endpoint.
+var sharedConnections = newSharedConnectionRegistry()
+
+// validateSharedConnections checks that the connections can be shared, which the connection pool precludes.
+func validateSharedConnections(cfg *Config) error {
+	if cfg.ShareConnections && cfg.ConnectionPoolSize > 1 {
+		return errors.New("share_connections can't be used along with connection_pool_size")
+	}
+	return nil
+}
+
+type sharedConnectionKey struct {
+	cfg      *Config
+	endpoint string
+}
+
+// sharedConnection is a gRPC connection to an endpoint used by the exporters for several signals.
+type sharedConnection struct {
+	clientConn *grpc.ClientConn
+	users      int
+}
+
+type sharedConnectionRegistry struct {
+	lock        sync.Mutex
+	connections map[sharedConnectionKey]*sharedConnection
+}
+
+func newSharedConnectionRegistry() *sharedConnectionRegistry {
+	return &sharedConnectionRegistry{connections: map[sharedConnectionKey]*sharedConnection{}}
+}
+
+// acquire returns the connection shared for the given configuration and endpoint, dialing it when the first
+// exporter starts.
+func (r *sharedConnectionRegistry) acquire(cfg *Config, endpoint string, dial func() (*grpc.ClientConn, error)) (*grpc.ClientConn, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := sharedConnectionKey{cfg: cfg, endpoint: endpoint}
+	if conn, ok := r.connections[key]; ok {
+		conn.users++
+		return conn.clientConn, nil
+	}
+	clientConn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	r.connections[key] = &sharedConnection{clientConn: clientConn, users: 1}
+	return clientConn, nil
+}
+
+// release closes the connection shared for the given configuration and endpoint once all the exporters that
+// acquired it are shut down.
+func (r *sharedConnectionRegistry) release(cfg *Config, endpoint string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := sharedConnectionKey{cfg: cfg, endpoint: endpoint}
+	conn, ok := r.connections[key]
+	if !ok {
+		return nil
+	}
+	conn.users--
+	if conn.users > 0 {
+		return nil
+	}
+	delete(r.connections, key)
+	return conn.clientConn.Close()
+}
+
+// newSharedConnectionFactory returns a factory of OTLP exporters sending the data for all the signals to an
+// endpoint over the same gRPC connection, instead of one connection per signal.
+func newSharedConnectionFactory(cfg *Config) exporter.Factory {
+	base := exporterFactories[otlpProtocol]
+	f := &sharedConnectionFactory{cfg: cfg}
+	return exporter.NewFactory(
+		base.Type(),
+		base.CreateDefaultConfig,
+		exporter.WithTraces(f.createTracesExporter, base.TracesExporterStability()),
+		exporter.WithMetrics(f.createMetricsExporter, base.MetricsExporterStability()),
+		exporter.WithLogs(f.createLogsExporter, base.LogsExporterStability()),
+	)
+}
+
+type sharedConnectionFactory struct {
+	cfg *Config
+}
+
+func (f *sharedConnectionFactory) newExporter(set exporter.CreateSettings, cfg component.Config) *sharedConnectionExporter {
+	oCfg := cfg.(*otlpexporter.Config)
+	return &sharedConnectionExporter{
+		cfg:       f.cfg,
+		config:    oCfg,
+		settings:  set.TelemetrySettings,
+		userAgent: fmt.Sprintf("%s/%s (%s/%s)", set.BuildInfo.Description, set.BuildInfo.Version, runtime.GOOS, runtime.GOARCH),
+	}
+}
+
+func (f *sharedConnectionFactory) createTracesExporter(ctx context.Context, set exporter.CreateSettings, cfg component.Config) (exporter.Traces, error) {
+	e := f.newExporter(set, cfg)
+	return exporterhelper.NewTracesExporter(ctx, set, cfg, e.pushTraces, e.options()...)
+}
+
+func (f *sharedConnectionFactory) createMetricsExporter(ctx context.Context, set exporter.CreateSettings, cfg component.Config) (exporter.Metrics, error) {
+	e := f.newExporter(set, cfg)
+	return exporterhelper.NewMetricsExporter(ctx, set, cfg, e.pushMetrics, e.options()...)
+}
+
+func (f *sharedConnectionFactory) createLogsExporter(ctx context.Context, set exporter.CreateSettings, cfg component.Config) (exporter.Logs, error) {
+	e := f.newExporter(set, cfg)
+	return exporterhelper.NewLogsExporter(ctx, set, cfg, e.pushLogs, e.options()...)
+}
+
+// sharedConnectionExporter sends the data of a signal as the OTLP exporter does, over the connection shared with
+// the exporters for the other signals.
+type sharedConnectionExporter struct {
+	cfg       *Config
+	config    *otlpexporter.Config
+	settings  component.TelemetrySettings
+	userAgent string
+
+	clientConn  *grpc.ClientConn
+	metadata    metadata.MD
+	callOptions []grpc.CallOption
+}
+
+func (e *sharedConnectionExporter) options() []exporterhelper.Option {
+	return []exporterhelper.Option{
+		exporterhelper.WithCapabilities(consumer.Capabilities{MutatesData: false}),
+		exporterhelper.WithTimeout(e.config.TimeoutSettings),
+		exporterhelper.WithRetry(e.config.RetryConfig),
+		exporterhelper.WithQueue(e.config.QueueConfig),
+		exporterhelper.WithStart(e.start),
+		exporterhelper.WithShutdown(e.shutdown),
+	}
+}
+
+func (e *sharedConnectionExporter) start(ctx context.Context, host component.Host) error {
+	clientConn, err := sharedConnections.acquire(e.cfg, e.config.Endpoint, func() (*grpc.ClientConn, error) {
+		return e.config.ClientConfig.ToClientConn(ctx, host, e.settings, grpc.WithUserAgent(e.userAgent))
+	})
+	if err != nil {
+		return err
+	}
+	e.clientConn = clientConn
+	headers := map[string]string{}
+	for k, v := range e.config.ClientConfig.Headers {
+		headers[k] = string(v)
+	}
+	e.metadata = metadata.New(headers)
+	e.callOptions = []grpc.CallOption{
+		grpc.WaitForReady(e.config.ClientConfig.WaitForReady),
+	}
+	return nil
+}
+
+func (e *sharedConnectionExporter) shutdown(context.Context) error {
+	if e.clientConn == nil {
+		// never started
+		return nil
+	}
+	return sharedConnections.release(e.cfg, e.config.Endpoint)
+}
+
+func (e *sharedConnectionExporter) pushTraces(ctx context.Context, td ptrace.Traces) error {
+	resp, err := ptraceotlp.NewGRPCClient(e.clientConn).Export(e.enhanceContext(ctx), ptraceotlp.NewExportRequestFromTraces(td), e.callOptions...)
+	if err = processGRPCError(err); err != nil {
+		return err
+	}
+	e.logPartialSuccess(resp.PartialSuccess().ErrorMessage(), "dropped_spans", resp.PartialSuccess().RejectedSpans())
+	return nil
+}
+
+func (e *sharedConnectionExporter) pushMetrics(ctx context.Context, md pmetric.Metrics) error {
+	resp, err := pmetricotlp.NewGRPCClient(e.clientConn).Export(e.enhanceContext(ctx), pmetricotlp.NewExportRequestFromMetrics(md), e.callOptions...)
+	if err = processGRPCError(err); err != nil {
+		return err
+	}
+	e.logPartialSuccess(resp.PartialSuccess().ErrorMessage(), "dropped_data_points", resp.PartialSuccess().RejectedDataPoints())
+	return nil
+}
+
+func (e *sharedConnectionExporter) pushLogs(ctx context.Context, ld plog.Logs) error {
+	resp, err := plogotlp.NewGRPCClient(e.clientConn).Export(e.enhanceContext(ctx), plogotlp.NewExportRequestFromLogs(ld), e.callOptions...)
+	if err = processGRPCError(err); err != nil {
+		return err
+	}
+	e.logPartialSuccess(resp.PartialSuccess().ErrorMessage(), "dropped_log_records", resp.PartialSuccess().RejectedLogRecords())
+	return nil
+}
+
+// logPartialSuccess warns about the data rejected by the backend, as the OTLP exporter does.
+func (e *sharedConnectionExporter) logPartialSuccess(message string, droppedKey string, dropped int64) {
+	if message != "" || dropped != 0 {
+		e.settings.Logger.Warn("Partial success response", zap.String("message", message), zap.Int64(droppedKey, dropped))
+	}
+}
+
+// enhanceContext adds the headers to the outgoing metadata, merged with the metadata forwarded by the load balancer
+// instead of replacing it as the OTLP exporter does.
+func (e *sharedConnectionExporter) enhanceContext(ctx context.Context) context.Context {
+	return withOutgoingMetadata(ctx, e.metadata)
+}
+
+// processGRPCError turns the error of an export into a permanent error unless its status code is retryable, as the
+// OTLP exporter does, honoring the delay requested by the backend before retrying.
+func processGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	st := status.Convert(err)
+	if st.Code() == codes.OK {
+		return nil
+	}
+
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retryInfo = info
+			break
+		}
+	}
+
+	switch st.Code() {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+	case codes.ResourceExhausted:
+		// the backend can only recover when it says when to retry
+		if retryInfo == nil {
+			return consumererror.NewPermanent(err)
+		}
+	default:
+		return consumererror.NewPermanent(err)
+	}
+
+	if retryInfo != nil && retryInfo.RetryDelay != nil {
+		if delay := retryInfo.RetryDelay.AsDuration(); delay > 0 {
+			return exporterhelper.NewThrottleRetry(err, delay)
+		}
+	}
+	return err
+}
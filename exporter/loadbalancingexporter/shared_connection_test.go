@@ -0,0 +1,258 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type otlpTestServer struct {
+	ptraceotlp.UnimplementedGRPCServer
+	lock  sync.Mutex
+	spans int
+}
+
+func (s *otlpTestServer) Export(_ context.Context, req ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.spans += req.Traces().SpanCount()
+	return ptraceotlp.NewExportResponse(), nil
+}
+
+type otlpLogsTestServer struct {
+	plogotlp.UnimplementedGRPCServer
+	lock sync.Mutex
+	logs int
+}
+
+func (s *otlpLogsTestServer) Export(_ context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.logs += req.Logs().LogRecordCount()
+	return plogotlp.NewExportResponse(), nil
+}
+
+func TestShareConnectionsAcrossSignals(t *testing.T) {
+	// prepare
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	traces := &otlpTestServer{}
+	logs := &otlpLogsTestServer{}
+	ptraceotlp.RegisterGRPCServer(srv, traces)
+	plogotlp.RegisterGRPCServer(srv, logs)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	defer srv.Stop()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Resolver.Static = &StaticResolver{Hostnames: []string{listener.Addr().String()}}
+	cfg.Protocol.OTLP.TLSSetting.Insecure = true
+	cfg.Protocol.OTLP.QueueConfig.Enabled = false
+	cfg.ShareConnections = true
+
+	te, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	le, err := newLogsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+
+	// test
+	require.NoError(t, te.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, le.Start(context.Background(), componenttest.NewNopHost()))
+
+	// verify
+	key := sharedConnectionKey{cfg: cfg, endpoint: listener.Addr().String()}
+	sharedConnections.lock.Lock()
+	require.Contains(t, sharedConnections.connections, key)
+	assert.Equal(t, 2, sharedConnections.connections[key].users)
+	sharedConnections.lock.Unlock()
+
+	require.NoError(t, te.ConsumeTraces(context.Background(), simpleTraces()))
+	require.NoError(t, le.ConsumeLogs(context.Background(), simpleLogs()))
+	traces.lock.Lock()
+	assert.Equal(t, 1, traces.spans)
+	traces.lock.Unlock()
+	logs.lock.Lock()
+	assert.Equal(t, 1, logs.logs)
+	logs.lock.Unlock()
+
+	// the connection is closed once the endpoint is removed from both signals
+	te.loadBalancer.removeExtraExporters(context.Background(), nil)
+	sharedConnections.lock.Lock()
+	assert.Contains(t, sharedConnections.connections, key)
+	sharedConnections.lock.Unlock()
+
+	le.loadBalancer.removeExtraExporters(context.Background(), nil)
+	require.NoError(t, te.Shutdown(context.Background()))
+	require.NoError(t, le.Shutdown(context.Background()))
+	sharedConnections.lock.Lock()
+	assert.NotContains(t, sharedConnections.connections, key)
+	sharedConnections.lock.Unlock()
+}
+
+func TestValidateSharedConnections(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.ShareConnections = true
+
+	// test and verify
+	assert.NoError(t, validateSharedConnections(cfg))
+
+	cfg.ConnectionPoolSize = 2
+	assert.ErrorContains(t, validateSharedConnections(cfg), "connection_pool_size")
+}
+
+func TestSharedConnectionFactoryOnlyForOTLP(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.ShareConnections = true
+	cfg.EndpointSettings = map[string]EndpointSettings{"endpoint-2": {Protocol: otlpHTTPProtocol}}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	require.NoError(t, err)
+
+	// test and verify
+	assert.NotSame(t, exporterFactories[otlpProtocol], lb.exporterFactory("endpoint-1"))
+	assert.Same(t, exporterFactories[otlpHTTPProtocol], lb.exporterFactory("endpoint-2"))
+}
+
+func TestProcessGRPCError(t *testing.T) {
+	throttled, err := status.New(codes.ResourceExhausted, "slow down").WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(time.Second),
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		desc      string
+		err       error
+		permanent bool
+	}{
+		{"unavailable", status.Error(codes.Unavailable, "unavailable"), false},
+		{"invalid argument", status.Error(codes.InvalidArgument, "bad data"), true},
+		{"exhausted without retry info", status.Error(codes.ResourceExhausted, "exhausted"), true},
+		{"exhausted with retry info", throttled.Err(), false},
+		{"not a status", errors.New("failed"), true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			// test
+			err := processGRPCError(tt.err)
+
+			// verify
+			require.Error(t, err)
+			assert.Equal(t, tt.permanent, consumererror.IsPermanent(err))
+		})
+	}
+
+	assert.NoError(t, processGRPCError(nil))
+}
+
+type statusTestServer struct {
+	ptraceotlp.UnimplementedGRPCServer
+	resp ptraceotlp.ExportResponse
+	err  error
+}
+
+func (s *statusTestServer) Export(context.Context, ptraceotlp.ExportRequest) (ptraceotlp.ExportResponse, error) {
+	return s.resp, s.err
+}
+
+// TestSharedConnectionExporterMatchesOTLPExporter pins the behavior of the exporter sharing the connections to the
+// one of the OTLP exporter it copies, for the responses of a backend.
+func TestSharedConnectionExporterMatchesOTLPExporter(t *testing.T) {
+	throttled, err := status.New(codes.Unavailable, "slow down").WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(time.Second),
+	})
+	require.NoError(t, err)
+	exhausted, err := status.New(codes.ResourceExhausted, "exhausted").WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(time.Second),
+	})
+	require.NoError(t, err)
+	partial := ptraceotlp.NewExportResponse()
+	partial.PartialSuccess().SetRejectedSpans(1)
+	partial.PartialSuccess().SetErrorMessage("rejected")
+
+	for _, tt := range []struct {
+		desc string
+		resp ptraceotlp.ExportResponse
+		err  error
+	}{
+		{"ok", ptraceotlp.NewExportResponse(), nil},
+		{"partial success", partial, nil},
+		{"unavailable", ptraceotlp.NewExportResponse(), status.Error(codes.Unavailable, "unavailable")},
+		{"throttled", ptraceotlp.NewExportResponse(), throttled.Err()},
+		{"invalid argument", ptraceotlp.NewExportResponse(), status.Error(codes.InvalidArgument, "bad data")},
+		{"exhausted without retry info", ptraceotlp.NewExportResponse(), status.Error(codes.ResourceExhausted, "exhausted")},
+		{"exhausted with retry info", ptraceotlp.NewExportResponse(), exhausted.Err()},
+		{"unauthenticated", ptraceotlp.NewExportResponse(), status.Error(codes.Unauthenticated, "who are you")},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			// prepare
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			srv := grpc.NewServer()
+			ptraceotlp.RegisterGRPCServer(srv, &statusTestServer{resp: tt.resp, err: tt.err})
+			go func() {
+				_ = srv.Serve(listener)
+			}()
+			defer srv.Stop()
+
+			otlpFactory := otlpexporter.NewFactory()
+			oCfg := otlpFactory.CreateDefaultConfig().(*otlpexporter.Config)
+			oCfg.Endpoint = listener.Addr().String()
+			oCfg.TLSSetting.Insecure = true
+			oCfg.QueueConfig.Enabled = false
+			oCfg.RetryConfig.Enabled = false
+
+			// send returns the error and the logs of a send of the exporter created by the factory
+			send := func(factory exporter.Factory) (error, []observer.LoggedEntry) {
+				core, logs := observer.New(zap.WarnLevel)
+				set := exportertest.NewNopCreateSettings()
+				set.Logger = zap.New(core)
+				exp, err := factory.CreateTracesExporter(context.Background(), set, oCfg)
+				require.NoError(t, err)
+				require.NoError(t, exp.Start(context.Background(), componenttest.NewNopHost()))
+				defer func() {
+					require.NoError(t, exp.Shutdown(context.Background()))
+				}()
+				return exp.ConsumeTraces(context.Background(), simpleTraces()), logs.AllUntimed()
+			}
+
+			// test
+			expectedErr, expectedLogs := send(otlpFactory)
+			actualErr, actualLogs := send(newSharedConnectionFactory(createDefaultConfig().(*Config)))
+
+			// verify
+			if expectedErr == nil {
+				assert.NoError(t, actualErr)
+			} else {
+				require.Error(t, actualErr)
+				assert.Equal(t, expectedErr.Error(), actualErr.Error())
+				assert.Equal(t, consumererror.IsPermanent(expectedErr), consumererror.IsPermanent(actualErr))
+			}
+			assert.Equal(t, expectedLogs, actualLogs)
+		})
+	}
+}
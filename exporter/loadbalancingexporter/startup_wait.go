@@ -0,0 +1,68 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// startupWait holds back the data consumed right after the start, until the first resolution populated the ring
+// or the timeout is over, whichever comes first.
+type startupWait struct {
+	timeout time.Duration
+	done    chan struct{}
+	once    sync.Once
+
+	timerLock sync.Mutex
+	timer     *time.Timer
+}
+
+func newStartupWait(timeout time.Duration) *startupWait {
+	if timeout <= 0 {
+		return nil
+	}
+	return &startupWait{timeout: timeout, done: make(chan struct{})}
+}
+
+// start starts the timeout, ending the startup window once it is over.
+func (w *startupWait) start() {
+	if w == nil {
+		return
+	}
+	w.timerLock.Lock()
+	defer w.timerLock.Unlock()
+	w.timer = time.AfterFunc(w.timeout, w.end)
+}
+
+// end ends the startup window, letting the data through.
+func (w *startupWait) end() {
+	if w == nil {
+		return
+	}
+	w.once.Do(func() {
+		close(w.done)
+	})
+
+	w.timerLock.Lock()
+	defer w.timerLock.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+}
+
+// wait blocks until the end of the startup window, returning an error when the context is done first.
+func (w *startupWait) wait(ctx context.Context) error {
+	if w == nil {
+		return nil
+	}
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
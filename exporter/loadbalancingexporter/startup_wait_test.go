@@ -0,0 +1,128 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+)
+
+// newStartupWaitMetricsExporter returns a started metrics exporter whose resolver yields no endpoints until the
+// given ones are stored.
+func newStartupWaitMetricsExporter(t *testing.T, timeout time.Duration, endpoints *atomic.Pointer[[]string], sink *consumertest.MetricsSink) *metricExporterImp {
+	cfg := serviceBasedRoutingConfig()
+	cfg.StartupWaitTimeout = timeout
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockMetricsExporter(sink.ConsumeMetrics), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			if resolved := endpoints.Load(); resolved != nil {
+				return *resolved, nil
+			}
+			return nil, nil
+		},
+	}
+
+	p, err := newMetricsExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	})
+	return p
+}
+
+func TestStartupWaitForFirstResolution(t *testing.T) {
+	// prepare
+	var endpoints atomic.Pointer[[]string]
+	sink := new(consumertest.MetricsSink)
+	p := newStartupWaitMetricsExporter(t, time.Minute, &endpoints, sink)
+
+	// test
+	consumed := make(chan error, 1)
+	go func() {
+		consumed <- p.ConsumeMetrics(context.Background(), simpleMetricsWithServiceName())
+	}()
+
+	// verify
+	select {
+	case err := <-consumed:
+		t.Fatalf("the metrics were consumed before the first resolution: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	endpoints.Store(&[]string{"endpoint-1"})
+	_, err := p.loadBalancer.res.resolve(context.Background())
+	require.NoError(t, err)
+
+	select {
+	case err := <-consumed:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the metrics are still waiting after the first resolution")
+	}
+	assert.Len(t, sink.AllMetrics(), 1)
+}
+
+func TestStartupWaitTimeout(t *testing.T) {
+	// prepare
+	var endpoints atomic.Pointer[[]string]
+	p := newStartupWaitMetricsExporter(t, 50*time.Millisecond, &endpoints, new(consumertest.MetricsSink))
+
+	// test
+	start := time.Now()
+	err := p.ConsumeMetrics(context.Background(), simpleMetricsWithServiceName())
+
+	// verify
+	assert.ErrorContains(t, err, "couldn't find the exporter")
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// once the window is over, the data no longer waits
+	start = time.Now()
+	assert.Error(t, p.ConsumeMetrics(context.Background(), simpleMetricsWithServiceName()))
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestStartupWaitContextDone(t *testing.T) {
+	// prepare
+	var endpoints atomic.Pointer[[]string]
+	p := newStartupWaitMetricsExporter(t, time.Minute, &endpoints, new(consumertest.MetricsSink))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// test
+	err := p.ConsumeMetrics(ctx, simpleMetricsWithServiceName())
+
+	// verify
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestStartupWaitNotNeededAfterResolution(t *testing.T) {
+	// prepare
+	var endpoints atomic.Pointer[[]string]
+	endpoints.Store(&[]string{"endpoint-1"})
+	sink := new(consumertest.MetricsSink)
+	p := newStartupWaitMetricsExporter(t, time.Minute, &endpoints, sink)
+
+	// test
+	err := p.ConsumeMetrics(context.Background(), simpleMetricsWithServiceName())
+
+	// verify
+	require.NoError(t, err)
+	assert.Len(t, sink.AllMetrics(), 1)
+}
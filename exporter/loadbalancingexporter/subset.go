@@ -0,0 +1,70 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+)
+
+// subset selects the endpoints this collector connects to among all the endpoints, so that each collector of a
+// tier only connects to some of the backends. Each endpoint is ranked by the hash of the subset key of the
+// collector along with the endpoint, the highest ranked ones being selected: the collectors with different keys
+// select different subsets, spreading the connections evenly across the backends, and adding or removing an
+// endpoint changes at most one of the selected endpoints.
+type subset struct {
+	key  string
+	size int
+}
+
+func newSubset(cfg *SubsetSettings) (*subset, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if cfg.Size <= 0 {
+		return nil, fmt.Errorf("invalid subset size %d, it must be positive", cfg.Size)
+	}
+
+	key := cfg.Key
+	if key == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("no subset key specified, and the hostname can't be used instead: %w", err)
+		}
+		key = hostname
+	}
+	return &subset{key: key, size: cfg.Size}, nil
+}
+
+// apply returns the sorted endpoints of the subset.
+func (s *subset) apply(endpoints []string) []string {
+	if len(endpoints) <= s.size {
+		return endpoints
+	}
+
+	ranks := make(map[string]uint64, len(endpoints))
+	for _, endpoint := range endpoints {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(s.key))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(endpoint))
+		ranks[endpoint] = h.Sum64()
+	}
+
+	candidates := make([]string, len(endpoints))
+	copy(candidates, endpoints)
+	sort.Slice(candidates, func(i, j int) bool {
+		ri, rj := ranks[candidates[i]], ranks[candidates[j]]
+		if ri != rj {
+			return ri > rj
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	selected := candidates[:s.size]
+	sort.Strings(selected)
+	return selected
+}
@@ -0,0 +1,94 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+)
+
+func TestSubsetApply(t *testing.T) {
+	var endpoints []string
+	for i := 0; i < 60; i++ {
+		endpoints = append(endpoints, fmt.Sprintf("backend-%d:4317", i))
+	}
+
+	// test
+	connections := map[string]int{}
+	for i := 0; i < 300; i++ {
+		s, err := newSubset(&SubsetSettings{Size: 6, Key: fmt.Sprintf("agent-%d", i)})
+		require.NoError(t, err)
+		selected := s.apply(endpoints)
+		require.Len(t, selected, 6)
+		assert.IsNonDecreasing(t, selected)
+		for _, endpoint := range selected {
+			connections[endpoint]++
+		}
+	}
+
+	// verify
+	// the connections are spread across all the backends, 30 of them for each one on average
+	assert.Len(t, connections, len(endpoints))
+	for endpoint, count := range connections {
+		assert.Greater(t, count, 10, endpoint)
+		assert.Less(t, count, 60, endpoint)
+	}
+
+	// the same key selects the same subset, and adding an endpoint changes at most one of the selected endpoints
+	s, err := newSubset(&SubsetSettings{Size: 6, Key: "agent-1"})
+	require.NoError(t, err)
+	selected := s.apply(endpoints)
+	assert.Equal(t, selected, s.apply(endpoints))
+	grown := s.apply(append(endpoints[:len(endpoints):len(endpoints)], "backend-60:4317"))
+	kept := 0
+	for _, endpoint := range grown {
+		if endpointFound(endpoint, selected) {
+			kept++
+		}
+	}
+	assert.GreaterOrEqual(t, kept, 5)
+
+	// all the endpoints are used when there are fewer of them than the size of the subset
+	assert.Equal(t, endpoints[:3], s.apply(endpoints[:3]))
+}
+
+func TestSubsetRing(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.Resolver.Static.Hostnames = []string{"endpoint-1", "endpoint-2", "endpoint-3", "endpoint-4"}
+	cfg.Subset = &SubsetSettings{Size: 2, Key: "agent-1"}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockTracesExporter(), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+
+	// test
+	require.NoError(t, lb.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, lb.Shutdown(context.Background()))
+	}()
+
+	// verify
+	assert.Len(t, lb.ring.endpoints(), 2)
+	assert.Len(t, lb.exporters, 2)
+}
+
+func TestSubsetInvalidSize(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.Subset = &SubsetSettings{}
+
+	// test
+	_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+
+	// verify
+	assert.ErrorContains(t, err, "invalid subset size 0")
+}
@@ -10,10 +10,9 @@ import (
 	"sync"
 	"time"
 
-	"go.opencensus.io/stats"
-	"go.opencensus.io/tag"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/exporter/otlpexporter"
 	"go.opentelemetry.io/collector/pdata/ptrace"
@@ -24,37 +23,103 @@ import (
 
 var _ exporter.Traces = (*traceExporterImp)(nil)
 
+// errEmptyTraces is returned for the batches without any span, whatever the routing key
+var errEmptyTraces = errors.New("empty spans")
+
 type exporterTraces map[*wrappedExporter]ptrace.Traces
 
 type traceExporterImp struct {
 	loadBalancer *loadBalancer
 	routingKey   routingKey
 
+	// attributesRouting derives the routing identifiers when routing by attributes or by expression
+	attributesRouting resourceIdentifier
+
+	// metadataRouting derives the routing identifiers when routing by client metadata
+	metadataRouting *metadataRouting
+
+	// partialFailures makes the returned errors carry only the data sent to the failed backends
+	partialFailures bool
+
+	// mirror sends a copy of a share of the data to other backends, when configured
+	mirror *mirror
+
+	// persistentQueue writes the traces to a storage extension while they're being sent, when configured
+	persistentQueue *persistentQueue
+
 	stopped    bool
 	shutdownWg sync.WaitGroup
 }
 
 // Create new traces exporter
 func newTracesExporter(params exporter.CreateSettings, cfg component.Config) (*traceExporterImp, error) {
-	exporterFactory := otlpexporter.NewFactory()
-
+	var lb *loadBalancer
 	lb, err := newLoadBalancer(params, cfg, func(ctx context.Context, endpoint string) (component.Component, error) {
-		oCfg := buildExporterConfig(cfg.(*Config), endpoint)
-		return exporterFactory.CreateTracesExporter(ctx, params, &oCfg)
+		return lb.exporterFactory(endpoint).CreateTracesExporter(ctx, params, lb.exporterConfig(endpoint))
 	})
 	if err != nil {
 		return nil, err
 	}
+	lb.signal = component.DataTypeTraces
 
-	traceExporter := traceExporterImp{loadBalancer: lb, routingKey: traceIDRouting}
+	traceExporter := traceExporterImp{
+		loadBalancer:    lb,
+		routingKey:      traceIDRouting,
+		partialFailures: cfg.(*Config).PartialFailures,
+		persistentQueue: newPersistentQueue(params, cfg.(*Config).PersistentQueue, component.DataTypeTraces),
+	}
 
 	switch cfg.(*Config).RoutingKey {
 	case "service":
 		traceExporter.routingKey = svcRouting
+		serviceRouting, err := newServiceRouting(cfg.(*Config))
+		if err != nil {
+			return nil, err
+		}
+		if serviceRouting != nil {
+			traceExporter.routingKey = attrsRouting
+			traceExporter.attributesRouting = serviceRouting
+		}
+	case "schemaURL":
+		traceExporter.routingKey = schemaURLRouting
+	case "attributes":
+		traceExporter.routingKey = attrsRouting
+		traceExporter.attributesRouting, err = newAttributesRouting(cfg.(*Config))
+		if err != nil {
+			return nil, err
+		}
+	case "expression":
+		traceExporter.routingKey = attrsRouting
+		traceExporter.attributesRouting, err = newExpressionRouting(cfg.(*Config), params.TelemetrySettings)
+		if err != nil {
+			return nil, err
+		}
+	case "none":
+		traceExporter.routingKey = noRouting
+	case "leastOutstanding":
+		traceExporter.routingKey = leastOutstandingRouting
+	case "metadata":
+		traceExporter.routingKey = clientMetadataRouting
+		traceExporter.metadataRouting, err = newMetadataRouting(cfg.(*Config))
+		if err != nil {
+			return nil, err
+		}
 	case "traceID", "":
 	default:
 		return nil, fmt.Errorf("unsupported routing_key: %s", cfg.(*Config).RoutingKey)
 	}
+
+	if cfg.(*Config).Mirror != nil {
+		mirrorCfg, err := mirrorConfig(cfg.(*Config))
+		if err != nil {
+			return nil, err
+		}
+		mirrored, err := newTracesExporter(params, mirrorCfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mirror: %w", err)
+		}
+		traceExporter.mirror = newMirror(params.Logger, cfg.(*Config).Mirror, mirrored)
+	}
 	return &traceExporter, nil
 }
 
@@ -65,100 +130,347 @@ func buildExporterConfig(cfg *Config, endpoint string) otlpexporter.Config {
 }
 
 func (e *traceExporterImp) Capabilities() consumer.Capabilities {
-	return consumer.Capabilities{MutatesData: false}
+	return consumer.Capabilities{MutatesData: e.loadBalancer.routingDecision.mutatesData()}
 }
 
 func (e *traceExporterImp) Start(ctx context.Context, host component.Host) error {
-	return e.loadBalancer.Start(ctx, host)
+	if err := e.loadBalancer.Start(ctx, host); err != nil {
+		return err
+	}
+	if err := e.mirror.Start(ctx, host); err != nil {
+		return err
+	}
+	return e.persistentQueue.start(ctx, host, func(ctx context.Context, data []byte) error {
+		td, err := tracesUnmarshaler.UnmarshalTraces(data)
+		if err != nil {
+			return err
+		}
+		if err = e.loadBalancer.startupWait.wait(ctx); err != nil {
+			return err
+		}
+		return e.consumeTraces(ctx, td, 0)
+	})
 }
 
-func (e *traceExporterImp) Shutdown(context.Context) error {
+func (e *traceExporterImp) Shutdown(ctx context.Context) error {
 	e.stopped = true
 	e.shutdownWg.Wait()
-	return nil
+	return multierr.Combine(e.persistentQueue.shutdown(ctx), e.mirror.Shutdown(ctx), e.loadBalancer.Shutdown(ctx))
 }
 
 func (e *traceExporterImp) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	if err := e.loadBalancer.startupWait.wait(ctx); err != nil {
+		return err
+	}
+	if e.mirror.sampled() {
+		mirrored := ptrace.NewTraces()
+		td.CopyTo(mirrored)
+		e.mirror.send(ctx, func(ctx context.Context) error {
+			return e.mirror.exporter.(consumer.Traces).ConsumeTraces(ctx, mirrored)
+		})
+	}
+	seq, err := e.persistentQueue.add(ctx, func() ([]byte, error) {
+		return tracesMarshaler.MarshalTraces(td)
+	})
+	if err != nil {
+		return err
+	}
+	defer e.persistentQueue.remove(ctx, seq)
+	return e.consumeTraces(ctx, td, 0)
+}
+
+// consumeTraces routes the traces to their backends. The reroutes indicate how many times the data
+// has already been routed again because its backend left the ring while the data was being sent.
+func (e *traceExporterImp) consumeTraces(ctx context.Context, td ptrace.Traces, reroutes int) error {
+	if withoutAffinity(e.routingKey) {
+		// the spans go to any backend, without being split
+		exp, endpoint, err := e.loadBalancer.exporterAndEndpointWithoutAffinity(e.routingKey)
+		if err != nil {
+			return err
+		}
+		return e.sendAll(ctx, exp, endpoint, nil, td, reroutes)
+	}
+	if e.routingKey == clientMetadataRouting {
+		// all the spans of the request share the routing identifier from its metadata
+		exp, endpoint, err := e.loadBalancer.exporterAndEndpointForMetadata(ctx, e.metadataRouting)
+		if err != nil {
+			return err
+		}
+		return e.sendAll(ctx, exp, endpoint, e.metadataRouting.failoverIdentifier(ctx), td, reroutes)
+	}
+	if exp, endpoint, ok := e.singleExporter(td); ok {
+		// all the spans go to the same backend, no need to split them
+		return e.sendAll(ctx, exp, endpoint, nil, td, reroutes)
+	}
+
+	start := time.Now()
 	batches := batchpersignal.SplitTraces(td)
 
 	exporterSegregatedTraces := make(exporterTraces)
 	endpoints := make(map[*wrappedExporter]string)
+	// the first routing identifier of the data segregated for each exporter, which it fails over for
+	identifiers := make(map[*wrappedExporter][]byte)
+	segregate := func(exp *wrappedExporter, endpoint string, identifier []byte, td ptrace.Traces) {
+		_, ok := exporterSegregatedTraces[exp]
+		if !ok {
+			exp.consumeWG.Add(1)
+			exporterSegregatedTraces[exp] = ptrace.NewTraces()
+			identifiers[exp] = identifier
+		}
+		exporterSegregatedTraces[exp] = mergeTraces(exporterSegregatedTraces[exp], td)
+
+		endpoints[exp] = endpoint
+	}
+	// release the exporters the data was segregated for when giving up before sending it
+	release := func() {
+		for exp := range exporterSegregatedTraces {
+			exp.consumeWG.Done()
+		}
+	}
+
 	for _, batch := range batches {
-		routingID, err := routingIdentifiersFromTraces(batch, e.routingKey)
+		routingID, err := e.routingIdentifiers(batch)
+		if errors.Is(err, errDroppedWithoutServiceName) {
+			continue
+		}
 		if err != nil {
-			return err
+			if !isUnroutable(err) || !e.loadBalancer.hasCatchAll() {
+				release()
+				return err
+			}
+
+			// the data without a routing identifier goes to the catch-all endpoint
+			exp, endpoint, err := e.loadBalancer.catchAllExporterAndEndpoint()
+			if err != nil {
+				release()
+				return err
+			}
+			segregate(exp, endpoint, nil, batch)
+			continue
 		}
 
 		for rid := range routingID {
 			exp, endpoint, err := e.loadBalancer.exporterAndEndpoint([]byte(rid))
 			if err != nil {
+				release()
 				return err
 			}
+			segregate(exp, endpoint, []byte(rid), batch)
+		}
+	}
+	e.loadBalancer.telemetry.recordSplit(ctx, start)
 
-			_, ok := exporterSegregatedTraces[exp]
-			if !ok {
-				exp.consumeWG.Add(1)
-				exporterSegregatedTraces[exp] = ptrace.NewTraces()
-			}
-			exporterSegregatedTraces[exp] = mergeTraces(exporterSegregatedTraces[exp], batch)
+	var errs error
+	failed := ptrace.NewTraces()
 
-			endpoints[exp] = endpoint
+	exporters := sortedByEndpoint(endpoints)
+	results := e.loadBalancer.dispatch(len(exporters), func(i int) error {
+		exp := exporters[i]
+		return e.send(ctx, exp, endpoints[exp], identifiers[exp], exporterSegregatedTraces[exp], reroutes)
+	})
+	for i, err := range results {
+		errs = multierr.Append(errs, err)
+		if err != nil && e.partialFailures {
+			appendFailedTraces(failed, exporterSegregatedTraces[exporters[i]], err)
 		}
 	}
 
-	var errs error
+	if errs != nil && e.partialFailures {
+		return consumererror.NewTraces(errs, failed)
+	}
+	return errs
+}
+
+// sendAll sends all the traces to the exporter, without splitting them.
+func (e *traceExporterImp) sendAll(ctx context.Context, exp *wrappedExporter, endpoint string, identifier []byte, td ptrace.Traces, reroutes int) error {
+	exp.consumeWG.Add(1)
+	err := e.send(ctx, exp, endpoint, identifier, td, reroutes)
+	if err != nil && e.partialFailures {
+		failed := ptrace.NewTraces()
+		appendFailedTraces(failed, td, err)
+		return consumererror.NewTraces(err, failed)
+	}
+	return err
+}
 
-	for exp, td := range exporterSegregatedTraces {
-		start := time.Now()
-		err := exp.ConsumeTraces(ctx, td)
+// send sends the traces to the exporter, whose consumeWG must have been incremented for them. The traces are
+// routed again when the endpoint left the ring in the meantime, and fail over to the next endpoints when the send
+// fails, if configured, following the routing identifier of the traces, which may be nil.
+func (e *traceExporterImp) send(ctx context.Context, exp *wrappedExporter, endpoint string, identifier []byte, td ptrace.Traces, reroutes int) error {
+	if exp.isRemoved() && reroutes < maxReroutes {
+		// the endpoint left the ring after the data was routed to it, route it again to the new owner
 		exp.consumeWG.Done()
-		errs = multierr.Append(errs, err)
-		duration := time.Since(start)
+		return e.consumeTraces(ctx, td, reroutes+1)
+	}
+
+	err := e.export(ctx, exp, endpoint, td)
+	return e.loadBalancer.failover(identifier, endpoint, err, func(exp *wrappedExporter, endpoint string, err error) error {
+		td = failedTraces(td, err)
+		return e.export(ctx, exp, endpoint, td)
+	})
+}
+
+// export sends the traces to the exporter, whose consumeWG must have been incremented for them.
+func (e *traceExporterImp) export(ctx context.Context, exp *wrappedExporter, endpoint string, td ptrace.Traces) error {
+	e.loadBalancer.routingDecision.stampTraces(td, endpoint)
+
+	if err := exp.limiter.wait(ctx, td.SpanCount()); err != nil {
+		exp.consumeWG.Done()
+		return err
+	}
+
+	release, err := e.loadBalancer.acquireSendSlot(ctx)
+	if err != nil {
+		exp.consumeWG.Done()
+		return err
+	}
 
-		if err == nil {
-			_ = stats.RecordWithTags(
-				ctx,
-				[]tag.Mutator{tag.Upsert(endpointTagKey, endpoints[exp]), successTrueMutator},
-				mBackendLatency.M(duration.Milliseconds()))
-		} else {
-			_ = stats.RecordWithTags(
-				ctx,
-				[]tag.Mutator{tag.Upsert(endpointTagKey, endpoints[exp]), successFalseMutator},
-				mBackendLatency.M(duration.Milliseconds()))
+	start := time.Now()
+	spanCtx, span := e.loadBalancer.startSendSpan(ctx, endpoint, td.SpanCount())
+	err = e.consume(spanCtx, exp, td)
+	endSendSpan(span, err)
+	release()
+	exp.consumeWG.Done()
+	duration := time.Since(start)
+
+	e.loadBalancer.telemetry.recordBackendSend(ctx, endpoint, err == nil, duration, tracesMarshaler.TracesSize(td))
+	return err
+}
+
+// consume sends the traces to the exporter, in several requests when they're larger than the maximum batch size.
+// The traces of the failed requests are returned along with the error.
+func (e *traceExporterImp) consume(ctx context.Context, exp *wrappedExporter, td ptrace.Traces) error {
+	parts := splitTracesBySize(td, e.loadBalancer.cfg.MaxBatchSizeBytes)
+	if len(parts) == 1 {
+		return exp.ConsumeTraces(e.loadBalancer.withTracesMetadata(ctx, exp, td), td)
+	}
+
+	var errs error
+	failed := ptrace.NewTraces()
+	for _, part := range parts {
+		if err := exp.ConsumeTraces(e.loadBalancer.withTracesMetadata(ctx, exp, part), part); err != nil {
+			errs = multierr.Append(errs, err)
+			appendFailedTraces(failed, part, err)
 		}
 	}
+	if errs != nil {
+		return consumererror.NewTraces(errs, failed)
+	}
+	return nil
+}
 
-	return errs
+// singleExporter returns the exporter for the only backend, when all the spans are known to be routed to it.
+// The routing identifiers then don't have to be computed, unless they could fail to be derived.
+func (e *traceExporterImp) singleExporter(td ptrace.Traces) (*wrappedExporter, string, bool) {
+	if td.SpanCount() == 0 {
+		return nil, "", false
+	}
+	exp, endpoint, ok := e.loadBalancer.singleExporter()
+	if !ok {
+		return nil, "", false
+	}
+
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		if !hasSpans(rss.At(i)) {
+			continue
+		}
+		var err error
+		switch e.routingKey {
+		case traceIDRouting:
+			// the identifiers can always be derived
+		case attrsRouting:
+			_, err = e.attributesRouting.identifier(rss.At(i).Resource())
+		case schemaURLRouting:
+			_, err = schemaURLRoutingIdentifier(rss.At(i).SchemaUrl(), rss.At(i).Resource())
+		default:
+			_, err = resourceRoutingIdentifier(rss.At(i).Resource(), e.routingKey)
+		}
+		if err != nil {
+			return nil, "", false
+		}
+	}
+	return exp, endpoint, true
 }
 
-func routingIdentifiersFromTraces(td ptrace.Traces, key routingKey) (map[string]bool, error) {
-	ids := make(map[string]bool)
-	rs := td.ResourceSpans()
-	if rs.Len() == 0 {
-		return nil, errors.New("empty resource spans")
+// routingIdentifiers returns the routing identifiers for the batch, holding a single resource.
+func (e *traceExporterImp) routingIdentifiers(batch ptrace.Traces) (map[string]bool, error) {
+	if e.routingKey != attrsRouting {
+		return routingIdentifiersFromTraces(batch, e.routingKey)
+	}
+	if batch.SpanCount() == 0 {
+		return nil, errEmptyTraces
 	}
 
-	ils := rs.At(0).ScopeSpans()
-	if ils.Len() == 0 {
-		return nil, errors.New("empty scope spans")
+	rid, err := e.attributesRouting.identifier(batch.ResourceSpans().At(0).Resource())
+	if err != nil {
+		return nil, err
 	}
+	return map[string]bool{rid: true}, nil
+}
 
-	spans := ils.At(0).Spans()
-	if spans.Len() == 0 {
-		return nil, errors.New("empty spans")
+// routingIdentifiersFromTraces returns the routing identifiers for the spans in the batch. The same rule applies
+// to all the routing keys: a batch without any span, be it without resources, with resources without scopes or
+// with scopes without spans, fails with errEmptyTraces, while the resources and scopes without spans are ignored
+// in the other batches.
+func routingIdentifiersFromTraces(td ptrace.Traces, key routingKey) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	if td.SpanCount() == 0 {
+		return nil, errEmptyTraces
 	}
 
+	rs := td.ResourceSpans()
+	if key == schemaURLRouting {
+		for i := 0; i < rs.Len(); i++ {
+			if !hasSpans(rs.At(i)) {
+				continue
+			}
+			rid, err := schemaURLRoutingIdentifier(rs.At(i).SchemaUrl(), rs.At(i).Resource())
+			if err != nil {
+				return nil, err
+			}
+			ids[rid] = true
+		}
+		return ids, nil
+	}
 	if key == svcRouting {
 		for i := 0; i < rs.Len(); i++ {
+			if !hasSpans(rs.At(i)) {
+				continue
+			}
 			svc, ok := rs.At(i).Resource().Attributes().Get("service.name")
 			if !ok {
-				return nil, errors.New("unable to get service name")
+				return nil, errMissingServiceName
 			}
 			ids[svc.Str()] = true
 		}
 		return ids, nil
 	}
-	tid := spans.At(0).TraceID()
-	ids[string(tid[:])] = true
+	ids[traceIDRoutingIdentifier(firstSpan(td).TraceID())] = true
 	return ids, nil
 }
+
+// hasSpans returns whether any of the scopes of the resource holds spans.
+func hasSpans(rs ptrace.ResourceSpans) bool {
+	ss := rs.ScopeSpans()
+	for i := 0; i < ss.Len(); i++ {
+		if ss.At(i).Spans().Len() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// firstSpan returns the first span of the traces, which must hold at least one span.
+func firstSpan(td ptrace.Traces) ptrace.Span {
+	rs := td.ResourceSpans()
+	for i := 0; i < rs.Len(); i++ {
+		ss := rs.At(i).ScopeSpans()
+		for j := 0; j < ss.Len(); j++ {
+			if ss.At(j).Spans().Len() > 0 {
+				return ss.At(j).Spans().At(0)
+			}
+		}
+	}
+	return ptrace.NewSpan()
+}
@@ -20,6 +20,7 @@ import (
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/exporter/exportertest"
@@ -245,6 +246,254 @@ func TestConsumeTracesServiceBased(t *testing.T) {
 	assert.Nil(t, res)
 }
 
+func TestConsumeTracesSingleBackend(t *testing.T) {
+	// prepare
+	endpoints := []string{"endpoint-1"}
+	sinks := map[string]*consumertest.TracesSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks[endpoint] = sink
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), serviceBasedRoutingConfig(), componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return endpoints, nil
+		},
+	}
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), serviceBasedRoutingConfig())
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	td := simpleTracesWithServiceName()
+
+	// test
+	require.NoError(t, p.ConsumeTraces(context.Background(), td))
+
+	// verify
+	require.Len(t, sinks["endpoint-1:4317"].AllTraces(), 1)
+	assert.Equal(t, td, sinks["endpoint-1:4317"].AllTraces()[0])
+
+	// the data without a service name is still rejected
+	err = p.ConsumeTraces(context.Background(), simpleTraces())
+	assert.ErrorIs(t, err, errMissingServiceName)
+	assert.Len(t, sinks["endpoint-1:4317"].AllTraces(), 1)
+
+	// the data is routed again once the ring grows
+	endpoints = []string{"endpoint-1", "endpoint-2"}
+	_, err = lb.res.resolve(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, p.ConsumeTraces(context.Background(), td))
+	require.Contains(t, sinks, "endpoint-2:4317")
+	assert.Greater(t, len(sinks["endpoint-1:4317"].AllTraces()), 1)
+	assert.NotEmpty(t, sinks["endpoint-2:4317"].AllTraces())
+	assert.Equal(t, 2*td.SpanCount(), sinks["endpoint-1:4317"].SpanCount()+sinks["endpoint-2:4317"].SpanCount())
+}
+
+func TestConsumeTracesRoundRobin(t *testing.T) {
+	// prepare
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "none"
+	sinks := map[string]*consumertest.TracesSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks[endpoint] = sink
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+	assert.Equal(t, noRouting, p.routingKey)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test, the spans without a service name being sent as well
+	td := simpleTracesWithServiceName()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, p.ConsumeTraces(context.Background(), td))
+	}
+	require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+
+	// verify
+	require.Len(t, sinks, 2)
+	assert.Len(t, sinks["endpoint-1:4317"].AllTraces(), 3)
+	assert.Len(t, sinks["endpoint-2:4317"].AllTraces(), 2)
+	for _, sink := range sinks {
+		// each batch is sent as a whole
+		assert.Equal(t, td, sink.AllTraces()[0])
+	}
+}
+
+func TestConsumeTracesLeastOutstanding(t *testing.T) {
+	// prepare
+	cfg := serviceBasedRoutingConfig()
+	cfg.RoutingKey = "leastOutstanding"
+	sending := make(chan struct{})
+	release := make(chan struct{})
+	sinks := map[string]*consumertest.TracesSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks[endpoint] = sink
+		if endpoint == "endpoint-1:4317" {
+			// the sends to the first endpoint hang until released
+			return newMockTracesExporter(func(ctx context.Context, td ptrace.Traces) error {
+				sending <- struct{}{}
+				<-release
+				return sink.ConsumeTraces(ctx, td)
+			}), nil
+		}
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+	assert.Equal(t, leastOutstandingRouting, p.routingKey)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// the first send goes to the first endpoint, and hangs
+	done := make(chan error)
+	go func() {
+		done <- p.ConsumeTraces(context.Background(), simpleTraces())
+	}()
+	<-sending
+
+	// test
+	for i := 0; i < 3; i++ {
+		require.NoError(t, p.ConsumeTraces(context.Background(), simpleTracesWithServiceName()))
+	}
+
+	// verify
+	assert.Len(t, sinks["endpoint-2:4317"].AllTraces(), 3)
+	close(release)
+	require.NoError(t, <-done)
+	assert.Len(t, sinks["endpoint-1:4317"].AllTraces(), 1)
+}
+
+func TestConsumeTracesWithoutServiceNameToCatchAll(t *testing.T) {
+	cfg := serviceBasedRoutingConfig()
+	cfg.CatchAllEndpoint = "catch-all"
+	sinks := map[string]*consumertest.TracesSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks[endpoint] = sink
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	td := ptrace.NewTraces()
+	named := td.ResourceSpans().AppendEmpty()
+	named.Resource().Attributes().PutStr(conventions.AttributeServiceName, "service-name-1")
+	appendSimpleTraceWithID(named, [16]byte{1, 2, 3, 4})
+	appendSimpleTraceWithID(td.ResourceSpans().AppendEmpty(), [16]byte{1, 2, 3, 5})
+
+	// test
+	err = p.ConsumeTraces(context.Background(), td)
+
+	// verify
+	require.NoError(t, err)
+	require.Contains(t, sinks, "catch-all:4317")
+	require.Len(t, sinks["catch-all:4317"].AllTraces(), 1)
+	catchAll := sinks["catch-all:4317"].AllTraces()[0]
+	assert.Equal(t, pcommon.TraceID([16]byte{1, 2, 3, 5}), catchAll.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).TraceID())
+
+	backend := endpointWithPort(lb.ring.endpointFor([]byte("service-name-1")))
+	require.Len(t, sinks[backend].AllTraces(), 1)
+	assert.Equal(t, 1, sinks[backend].AllTraces()[0].SpanCount())
+}
+
+func TestConsumeTracesUnroutableReleasesExporters(t *testing.T) {
+	// prepare
+	sink := new(consumertest.TracesSink)
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), serviceBasedRoutingConfig(), componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), serviceBasedRoutingConfig())
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// the batch with a service name is routed before the one without fails
+	td := ptrace.NewTraces()
+	named := td.ResourceSpans().AppendEmpty()
+	named.Resource().Attributes().PutStr(conventions.AttributeServiceName, "service-name-1")
+	appendSimpleTraceWithID(named, [16]byte{1, 2, 3, 4})
+	appendSimpleTraceWithID(td.ResourceSpans().AppendEmpty(), [16]byte{1, 2, 3, 5})
+
+	// test
+	err = p.ConsumeTraces(context.Background(), td)
+
+	// verify
+	assert.ErrorIs(t, err, errMissingServiceName)
+	assert.Empty(t, sink.AllTraces())
+
+	// the exporters must not wait for data that was never sent
+	for _, exp := range lb.exporters {
+		waited := make(chan struct{})
+		go func(exp *wrappedExporter) {
+			exp.consumeWG.Wait()
+			close(waited)
+		}(exp)
+		select {
+		case <-waited:
+		case <-time.After(time.Second):
+			t.Fatal("the exporter is still waiting for data to be consumed")
+		}
+	}
+}
+
 func TestServiceBasedRoutingForSameTraceId(t *testing.T) {
 	b := pcommon.TraceID([16]byte{1, 2, 3, 4})
 	for _, tt := range []struct {
@@ -274,6 +523,43 @@ func TestServiceBasedRoutingForSameTraceId(t *testing.T) {
 	}
 }
 
+func TestSchemaURLBasedRoutingForTraces(t *testing.T) {
+	// prepare
+	td := ptrace.NewTraces()
+	for _, tt := range []struct {
+		schemaURL string
+		service   string
+	}{
+		{"https://opentelemetry.io/schemas/1.9.0", "service-a"},
+		{"https://opentelemetry.io/schemas/1.9.0", "service-b"},
+		{"https://opentelemetry.io/schemas/1.21.0", "service-a"},
+		{"", "service-c"},
+	} {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.SetSchemaUrl(tt.schemaURL)
+		rs.Resource().Attributes().PutStr(conventions.AttributeServiceName, tt.service)
+		rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetTraceID([16]byte{1, 2, 3, 4})
+	}
+
+	// test
+	res, err := routingIdentifiersFromTraces(td, schemaURLRouting)
+
+	// verify
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{
+		"https://opentelemetry.io/schemas/1.9.0":  true,
+		"https://opentelemetry.io/schemas/1.21.0": true,
+		"service-c": true,
+	}, res)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), &Config{
+		Resolver:   ResolverSettings{Static: &StaticResolver{Hostnames: []string{"endpoint-1"}}},
+		RoutingKey: "schemaURL",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, schemaURLRouting, p.routingKey)
+}
+
 func TestConsumeTracesExporterNoEndpoint(t *testing.T) {
 	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
 		return newNopMockTracesExporter(), nil
@@ -403,18 +689,89 @@ func TestBatchWithTwoTraces(t *testing.T) {
 	assert.Equal(t, sink.AllTraces()[0].SpanCount(), 2)
 }
 
+func TestConsumeTracesPartialFailures(t *testing.T) {
+	for _, tt := range []struct {
+		desc            string
+		partialFailures bool
+	}{
+		{"partial failures enabled", true},
+		{"partial failures disabled", false},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfg := serviceBasedRoutingConfig()
+			cfg.RoutingKey = ""
+			cfg.PartialFailures = tt.partialFailures
+
+			// the second endpoint fails on its first attempt only
+			failedOnce := false
+			sinks := map[string]*consumertest.TracesSink{}
+			componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+				sink := new(consumertest.TracesSink)
+				sinks[endpoint] = sink
+				return newMockTracesExporter(func(ctx context.Context, td ptrace.Traces) error {
+					if endpoint == "endpoint-2:4317" && !failedOnce {
+						failedOnce = true
+						return errors.New("backend unavailable")
+					}
+					return sink.ConsumeTraces(ctx, td)
+				}), nil
+			}
+			lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+			require.NotNil(t, lb)
+			require.NoError(t, err)
+
+			p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+			require.NotNil(t, p)
+			require.NoError(t, err)
+
+			p.loadBalancer = lb
+			err = p.Start(context.Background(), componenttest.NewNopHost())
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, p.Shutdown(context.Background()))
+			}()
+
+			// one trace for each of the endpoints
+			td := ptrace.NewTraces()
+			found := map[string]bool{}
+			for i := 1; len(found) < 2; i++ {
+				tid := pcommon.TraceID([16]byte{byte(i)})
+				endpoint := lb.ring.endpointFor(tid[:])
+				if !found[endpoint] {
+					found[endpoint] = true
+					appendSimpleTraceWithID(td.ResourceSpans().AppendEmpty(), tid)
+				}
+			}
+
+			// test
+			err = p.ConsumeTraces(context.Background(), td)
+
+			// verify
+			require.Error(t, err)
+			var partial consumererror.Traces
+			if !tt.partialFailures {
+				assert.False(t, errors.As(err, &partial))
+				return
+			}
+			require.True(t, errors.As(err, &partial))
+			assert.Equal(t, 1, partial.Data().SpanCount())
+
+			// retrying the failed portion doesn't send the data again to the successful backend
+			require.NoError(t, p.ConsumeTraces(context.Background(), partial.Data()))
+			assert.Len(t, sinks["endpoint-1:4317"].AllTraces(), 1)
+			assert.Len(t, sinks["endpoint-2:4317"].AllTraces(), 1)
+		})
+	}
+}
+
 func TestNoTracesInBatch(t *testing.T) {
 	for _, tt := range []struct {
-		desc       string
-		batch      ptrace.Traces
-		routingKey routingKey
-		err        error
+		desc  string
+		batch ptrace.Traces
 	}{
 		{
 			"no resource spans",
 			ptrace.NewTraces(),
-			traceIDRouting,
-			errors.New("empty resource spans"),
 		},
 		{
 			"no instrumentation library spans",
@@ -423,8 +780,6 @@ func TestNoTracesInBatch(t *testing.T) {
 				batch.ResourceSpans().AppendEmpty()
 				return batch
 			}(),
-			traceIDRouting,
-			errors.New("empty scope spans"),
 		},
 		{
 			"no spans",
@@ -433,18 +788,123 @@ func TestNoTracesInBatch(t *testing.T) {
 				batch.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty()
 				return batch
 			}(),
-			svcRouting,
-			errors.New("empty spans"),
 		},
 	} {
-		t.Run(tt.desc, func(t *testing.T) {
-			res, err := routingIdentifiersFromTraces(tt.batch, tt.routingKey)
-			assert.Equal(t, err, tt.err)
-			assert.Equal(t, res, map[string]bool(nil))
+		for _, routingKey := range []routingKey{traceIDRouting, svcRouting, schemaURLRouting} {
+			t.Run(fmt.Sprintf("%s/%d", tt.desc, routingKey), func(t *testing.T) {
+				res, err := routingIdentifiersFromTraces(tt.batch, routingKey)
+				assert.Equal(t, errEmptyTraces, err)
+				assert.Equal(t, map[string]bool(nil), res)
+			})
+		}
+	}
+}
+
+func TestPartiallyEmptyTracesInBatch(t *testing.T) {
+	// prepare
+	batch := ptrace.NewTraces()
+	batch.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty()
+	rs := batch.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr(conventions.AttributeServiceName, serviceName1)
+	rs.ScopeSpans().AppendEmpty()
+	rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty().SetTraceID([16]byte{1, 2, 3, 4})
+
+	for _, tt := range []struct {
+		routingKey routingKey
+		expected   map[string]bool
+	}{
+		{traceIDRouting, map[string]bool{string([]byte{1, 2, 3, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}): true}},
+		{svcRouting, map[string]bool{serviceName1: true}},
+		{schemaURLRouting, map[string]bool{serviceName1: true}},
+	} {
+		t.Run(fmt.Sprintf("%d", tt.routingKey), func(t *testing.T) {
+			// test
+			res, err := routingIdentifiersFromTraces(batch, tt.routingKey)
+
+			// verify
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, res)
 		})
 	}
 }
 
+func TestConsumeTracesReroutesWhenEndpointLeavesTheRing(t *testing.T) {
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	var rebuildOnce sync.Once
+	var lb *loadBalancer
+	sinks := map[string]*consumertest.TracesSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks[endpoint] = sink
+		return newMockTracesExporter(func(ctx context.Context, td ptrace.Traces) error {
+			// the first backend receiving data becomes the only one in the ring, while the data for
+			// the other backend is still pending
+			rebuildOnce.Do(func() {
+				endpoints = []string{endpoint}
+				_, err := lb.res.resolve(ctx)
+				assert.NoError(t, err)
+			})
+			return sink.ConsumeTraces(ctx, td)
+		}), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), simpleConfig(), componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), simpleConfig())
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return endpoints, nil
+		},
+	}
+	p.loadBalancer = lb
+
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// find one trace ID for each of the endpoints
+	traceIDs := map[string]pcommon.TraceID{}
+	for i := byte(0); len(traceIDs) < len(endpoints); i++ {
+		traceID := pcommon.TraceID([16]byte{1, 2, 3, i})
+		endpoint := endpointWithPort(lb.ring.endpointFor(traceID[:]))
+		if _, ok := traceIDs[endpoint]; !ok {
+			traceIDs[endpoint] = traceID
+		}
+	}
+
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	for _, traceID := range traceIDs {
+		appendSimpleTraceWithID(rs, traceID)
+	}
+
+	// test
+	err = p.ConsumeTraces(context.Background(), td)
+
+	// verify
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	live := endpointWithPort(endpoints[0])
+	var received []pcommon.TraceID
+	for endpoint, sink := range sinks {
+		for _, td := range sink.AllTraces() {
+			assert.Equal(t, live, endpoint, "data was sent to a backend no longer in the ring")
+			spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+			for i := 0; i < spans.Len(); i++ {
+				received = append(received, spans.At(i).TraceID())
+			}
+		}
+	}
+	assert.ElementsMatch(t, []pcommon.TraceID{traceIDs["endpoint-1:4317"], traceIDs["endpoint-2:4317"]}, received)
+}
+
 func TestRollingUpdatesWhenConsumeTraces(t *testing.T) {
 	t.Skip("Flaky Test - See https://github.com/open-telemetry/opentelemetry-collector-contrib/issues/13331")
 
@@ -454,7 +914,7 @@ func TestRollingUpdatesWhenConsumeTraces(t *testing.T) {
 
 	// simulate rolling updates, the dns resolver should resolve in the following order
 	// ["127.0.0.1"] -> ["127.0.0.1", "127.0.0.2"] -> ["127.0.0.2"]
-	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second)
+	res, err := newDNSResolver(zap.NewNop(), "service-1", "", 5*time.Second, 1*time.Second, nil)
 	require.NoError(t, err)
 
 	mu := sync.Mutex{}
@@ -668,6 +1128,42 @@ func BenchmarkConsumeTraces_10E1000T(b *testing.B) {
 	benchConsumeTraces(b, 10, 1000)
 }
 
+func BenchmarkConsumeTracesSingleBackend(b *testing.B) {
+	for _, tt := range []struct {
+		desc     string
+		catchAll string
+	}{
+		{"fast_path", ""},
+		// a catch-all endpoint requires routing the spans one by one
+		{"routed", "endpoint-1"},
+	} {
+		b.Run(tt.desc, func(b *testing.B) {
+			config := simpleConfig()
+			config.CatchAllEndpoint = tt.catchAll
+			p, err := newTracesExporter(exportertest.NewNopCreateSettings(), config)
+			require.NoError(b, err)
+			p.loadBalancer.componentFactory = func(ctx context.Context, endpoint string) (component.Component, error) {
+				return newNopMockTracesExporter(), nil
+			}
+			require.NoError(b, p.Start(context.Background(), componenttest.NewNopHost()))
+
+			td := ptrace.NewTraces()
+			for i := 0; i < 100; i++ {
+				appendSimpleTraceWithID(td.ResourceSpans().AppendEmpty(), [16]byte{1, 2, 6, byte(i)})
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				require.NoError(b, p.ConsumeTraces(context.Background(), td))
+			}
+
+			b.StopTimer()
+			require.NoError(b, p.Shutdown(context.Background()))
+		})
+	}
+}
+
 func randomTraces() ptrace.Traces {
 	v1 := uint8(rand.Intn(256))
 	v2 := uint8(rand.Intn(256))
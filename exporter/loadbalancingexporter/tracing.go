@@ -0,0 +1,37 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	sendSpanName = "loadbalancer/send"
+
+	endpointAttribute = "endpoint"
+	itemsAttribute    = "items"
+)
+
+// startSendSpan starts the span for sending data to a backend, recording the endpoint and the number of items.
+// The returned context carries the span and should be used for the send.
+func (lb *loadBalancer) startSendSpan(ctx context.Context, endpoint string, items int) (context.Context, trace.Span) {
+	return lb.tracer.Start(ctx, sendSpanName, trace.WithAttributes(
+		attribute.String(endpointAttribute, endpoint),
+		attribute.Int(itemsAttribute, items),
+	))
+}
+
+// endSendSpan ends the span for sending data to a backend, recording the outcome of the send.
+func endSendSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,122 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSendSpansPerBackend(t *testing.T) {
+	// prepare
+	sr := tracetest.NewSpanRecorder()
+	params := exportertest.NewNopCreateSettings()
+	params.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	cfg := metricNameBasedRoutingConfig()
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newNopMockMetricsExporter(), nil
+	}
+	lb, err := newLoadBalancer(params, cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newMetricsExporter(params, cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// find one metric name for each of the endpoints
+	names := map[string]string{}
+	for i := 0; len(names) < 2; i++ {
+		name := fmt.Sprintf("metric-%d", i)
+		endpoint := lb.ring.endpointFor([]byte(name))
+		if _, ok := names[endpoint]; !ok {
+			names[endpoint] = name
+		}
+	}
+
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	for _, name := range names {
+		metric := metrics.AppendEmpty()
+		metric.SetName(name)
+		metric.SetEmptyGauge().DataPoints().AppendEmpty()
+	}
+
+	// test
+	err = p.ConsumeMetrics(context.Background(), md)
+
+	// verify
+	require.NoError(t, err)
+	spans := sr.Ended()
+	require.Len(t, spans, 2)
+	for i, endpoint := range []string{"endpoint-1", "endpoint-2"} {
+		assert.Equal(t, sendSpanName, spans[i].Name())
+		assert.Equal(t, []attribute.KeyValue{
+			attribute.String(endpointAttribute, endpoint),
+			attribute.Int(itemsAttribute, 1),
+		}, spans[i].Attributes())
+		assert.Equal(t, codes.Unset, spans[i].Status().Code)
+	}
+}
+
+func TestSendSpanRecordsFailure(t *testing.T) {
+	// prepare
+	sr := tracetest.NewSpanRecorder()
+	params := exportertest.NewNopCreateSettings()
+	params.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	cfg := simpleConfig()
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		return newMockLogsExporter(func(context.Context, plog.Logs) error {
+			return errors.New("backend unavailable")
+		}), nil
+	}
+	lb, err := newLoadBalancer(params, cfg, componentFactory)
+	require.NotNil(t, lb)
+	require.NoError(t, err)
+
+	p, err := newLogsExporter(params, cfg)
+	require.NotNil(t, p)
+	require.NoError(t, err)
+
+	p.loadBalancer = lb
+	err = p.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	err = p.ConsumeLogs(context.Background(), simpleLogs())
+
+	// verify
+	require.Error(t, err)
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), attribute.String(endpointAttribute, "endpoint-1"))
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "backend unavailable", spans[0].Status().Description)
+}
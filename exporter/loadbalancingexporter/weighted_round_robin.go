@@ -0,0 +1,114 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync/atomic"
+)
+
+var _ ring = (*weightedRoundRobinRing)(nil)
+
+// weightedRoundRobinRing routes the identifiers to the endpoints in turn, regardless of the identifiers themselves,
+// each endpoint receiving a share of them in proportion to its weight. It trades the affinity of the identifiers
+// for an even load on backends of different capacities, for the pipelines that don't need the affinity.
+type weightedRoundRobinRing struct {
+	// members holds the sorted endpoints, along with their weights
+	members []string
+	weights []int
+
+	// schedule holds the indexes of the members in the order they are picked, each member appearing as many times
+	// as its weight, interleaved with the other members
+	schedule []int
+	next     atomic.Uint64
+}
+
+// newWeightedRoundRobinRing builds a new round-robin ring for the given endpoints, whose weights are returned by
+// the given function.
+func newWeightedRoundRobinRing(endpoints []string, weight func(endpoint string) int) *weightedRoundRobinRing {
+	members := make([]string, len(endpoints))
+	copy(members, endpoints)
+	sort.Strings(members)
+
+	r := &weightedRoundRobinRing{
+		members: members,
+		weights: make([]int, len(members)),
+	}
+	total := 0
+	for i, member := range members {
+		r.weights[i] = weight(member)
+		total += r.weights[i]
+	}
+
+	// smooth weighted round-robin: at each turn, every member earns its weight, and the richest member is picked
+	// and pays the total, spreading the turns of each member over the whole schedule
+	current := make([]int, len(members))
+	r.schedule = make([]int, 0, total)
+	for len(r.schedule) < total {
+		picked := 0
+		for i := range members {
+			current[i] += r.weights[i]
+			if current[i] > current[picked] {
+				picked = i
+			}
+		}
+		current[picked] -= total
+		r.schedule = append(r.schedule, picked)
+	}
+	return r
+}
+
+func (r *weightedRoundRobinRing) endpointFor(_ []byte) string {
+	if r == nil || len(r.schedule) == 0 {
+		return ""
+	}
+	turn := r.next.Add(1) - 1
+	return r.members[r.schedule[turn%uint64(len(r.schedule))]]
+}
+
+// endpointForKnown ignores the previous endpoint: without affinity, the draining endpoints receive no more data.
+func (r *weightedRoundRobinRing) endpointForKnown(identifier []byte, _ string) string {
+	return r.endpointFor(identifier)
+}
+
+func (r *weightedRoundRobinRing) equal(candidate ring) bool {
+	other, ok := candidate.(*weightedRoundRobinRing)
+	if !ok || other == nil {
+		return false
+	}
+
+	if len(r.members) != len(other.members) {
+		return false
+	}
+	for i := range r.members {
+		if r.members[i] != other.members[i] || r.weights[i] != other.weights[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *weightedRoundRobinRing) endpoints() []string {
+	var endpoints []string
+	for i, member := range r.members {
+		if i == 0 || member != r.members[i-1] {
+			endpoints = append(endpoints, member)
+		}
+	}
+	return endpoints
+}
+
+func (r *weightedRoundRobinRing) fingerprint() string {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(weightedRoundRobinStrategy))
+	for i, member := range r.members {
+		hasher.Write([]byte{0})
+		hasher.Write([]byte(member))
+		hasher.Write(binary.BigEndian.AppendUint64(nil, uint64(r.weights[i])))
+	}
+	return fmt.Sprintf("%016x", hasher.Sum64())
+}
@@ -0,0 +1,146 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+)
+
+func weights(w map[string]int) func(string) int {
+	return func(endpoint string) int {
+		if weight, ok := w[endpoint]; ok {
+			return weight
+		}
+		return defaultEndpointWeight
+	}
+}
+
+func TestWeightedRoundRobinProportions(t *testing.T) {
+	// prepare
+	r := newWeightedRoundRobinRing([]string{"endpoint-3", "endpoint-1", "endpoint-2"}, weights(map[string]int{
+		"endpoint-2": 2,
+		"endpoint-3": 3,
+	}))
+
+	// test
+	counts := map[string]int{}
+	for i := 0; i < 6000; i++ {
+		counts[r.endpointFor([]byte("same-identifier"))]++
+
+		// verify that the endpoints are interleaved: each of them has its share of any full turn of the schedule
+		if (i+1)%6 == 0 {
+			assert.Equal(t, map[string]int{"endpoint-1": (i + 1) / 6, "endpoint-2": (i + 1) / 3, "endpoint-3": (i + 1) / 2}, counts)
+		}
+	}
+
+	// verify
+	assert.Equal(t, map[string]int{"endpoint-1": 1000, "endpoint-2": 2000, "endpoint-3": 3000}, counts)
+}
+
+func TestWeightedRoundRobinSmooth(t *testing.T) {
+	// prepare
+	r := newWeightedRoundRobinRing([]string{"endpoint-1", "endpoint-2"}, weights(map[string]int{"endpoint-1": 4}))
+
+	// test
+	var picked []string
+	for i := 0; i < 5; i++ {
+		picked = append(picked, r.endpointFor(nil))
+	}
+
+	// verify
+	assert.Equal(t, []string{"endpoint-1", "endpoint-1", "endpoint-2", "endpoint-1", "endpoint-1"}, picked)
+}
+
+func TestWeightedRoundRobinWithoutEndpoints(t *testing.T) {
+	r := newWeightedRoundRobinRing(nil, weights(nil))
+	assert.Equal(t, "", r.endpointFor([]byte("identifier")))
+	assert.Equal(t, "", r.endpointForKnown([]byte("identifier"), "endpoint-1"))
+	assert.Empty(t, r.endpoints())
+}
+
+func TestWeightedRoundRobinEqual(t *testing.T) {
+	r := newWeightedRoundRobinRing([]string{"endpoint-1", "endpoint-2"}, weights(map[string]int{"endpoint-1": 2}))
+
+	same := newWeightedRoundRobinRing([]string{"endpoint-2", "endpoint-1"}, weights(map[string]int{"endpoint-1": 2}))
+	assert.True(t, r.equal(same))
+	assert.Equal(t, r.fingerprint(), same.fingerprint())
+
+	otherWeights := newWeightedRoundRobinRing([]string{"endpoint-1", "endpoint-2"}, weights(map[string]int{"endpoint-1": 3}))
+	assert.False(t, r.equal(otherWeights))
+	assert.NotEqual(t, r.fingerprint(), otherWeights.fingerprint())
+
+	otherEndpoints := newWeightedRoundRobinRing([]string{"endpoint-1", "endpoint-3"}, weights(map[string]int{"endpoint-1": 2}))
+	assert.False(t, r.equal(otherEndpoints))
+	assert.False(t, r.equal(newRendezvousRing([]string{"endpoint-1", "endpoint-2"}, nil)))
+}
+
+func TestInvalidEndpointWeight(t *testing.T) {
+	cfg := simpleConfig()
+	cfg.EndpointSettings = map[string]EndpointSettings{"endpoint-1": {Weight: -1}}
+
+	_, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, nil)
+	assert.EqualError(t, err, `invalid weight -1 for the endpoint "endpoint-1", it must be positive`)
+}
+
+func TestConsumeTracesWeightedRoundRobin(t *testing.T) {
+	// prepare
+	cfg := simpleConfig()
+	cfg.HashStrategy = weightedRoundRobinStrategy
+	cfg.EndpointSettings = map[string]EndpointSettings{
+		"endpoint-1": {Weight: 1},
+		"endpoint-2": {Weight: 3},
+	}
+	endpoints := []string{"endpoint-1", "endpoint-2"}
+	sinks := map[string]*consumertest.TracesSink{}
+	componentFactory := func(ctx context.Context, endpoint string) (component.Component, error) {
+		sink := new(consumertest.TracesSink)
+		sinks[endpoint] = sink
+		return newMockTracesExporter(sink.ConsumeTraces), nil
+	}
+	lb, err := newLoadBalancer(exportertest.NewNopCreateSettings(), cfg, componentFactory)
+	require.NoError(t, err)
+	lb.res = &mockResolver{
+		triggerCallbacks: true,
+		onResolve: func(ctx context.Context) ([]string, error) {
+			return endpoints, nil
+		},
+	}
+
+	p, err := newTracesExporter(exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+	p.loadBalancer = lb
+	require.NoError(t, p.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		require.NoError(t, p.Shutdown(context.Background()))
+	}()
+
+	// test
+	for i := 0; i < 400; i++ {
+		// the batches are distributed without affinity, even with the same trace ID
+		require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+	}
+
+	// verify
+	assert.Len(t, sinks["endpoint-1:4317"].AllTraces(), 100)
+	assert.Len(t, sinks["endpoint-2:4317"].AllTraces(), 300)
+
+	// the new endpoint gets its share of the batches sent after the change
+	endpoints = []string{"endpoint-1", "endpoint-2", "endpoint-3"}
+	_, err = lb.res.resolve(context.Background())
+	require.NoError(t, err)
+	for i := 0; i < 500; i++ {
+		require.NoError(t, p.ConsumeTraces(context.Background(), simpleTraces()))
+	}
+	assert.Len(t, sinks["endpoint-1:4317"].AllTraces(), 200)
+	assert.Len(t, sinks["endpoint-2:4317"].AllTraces(), 600)
+	assert.Len(t, sinks["endpoint-3:4317"].AllTraces(), 100)
+}
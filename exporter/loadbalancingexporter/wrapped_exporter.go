@@ -6,13 +6,18 @@ package loadbalancingexporter // import "github.com/open-telemetry/opentelemetry
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/exporter"
 	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc/metadata"
 )
 
 // wrappedExporter is an exporter that waits for the data processing to complete before shutting down.
@@ -20,14 +25,89 @@ import (
 type wrappedExporter struct {
 	component.Component
 	consumeWG sync.WaitGroup
+
+	// removed is set once the endpoint is no longer part of the ring, so that data routed to this
+	// exporter before the ring was rebuilt can be routed again to the endpoint's new owner.
+	removed atomic.Bool
+
+	// inFlight is the number of sends in progress
+	inFlight atomic.Int64
+
+	// lastErr holds the error of the latest send, along with its time, cleared by the next successful send
+	lastErrLock sync.Mutex
+	lastErr     error
+	lastErrTime time.Time
+
+	// onResult, when set, is called with the result of each send
+	onResult func(err error)
+
+	// breaker opens the circuit of the endpoint after consecutive failed sends, when configured
+	breaker *circuitBreaker
+
+	// limiter limits the rate of the items sent to the endpoint, when configured
+	limiter *rateLimiter
+
+	// headers are sent by the load balancer in place of the exporter, merged with the metadata it forwards
+	headers metadata.MD
 }
 
 func newWrappedExporter(exp component.Component) *wrappedExporter {
 	return &wrappedExporter{Component: exp}
 }
 
+// markRemoved flags the exporter as no longer being part of the ring.
+func (we *wrappedExporter) markRemoved() {
+	we.removed.Store(true)
+}
+
+// isRemoved returns whether the exporter was removed from the ring.
+func (we *wrappedExporter) isRemoved() bool {
+	return we.removed.Load()
+}
+
+// recordResult keeps the error of a failed send as the last error, or clears it after a successful send, and
+// passes the result on to the onResult callback.
+func (we *wrappedExporter) recordResult(err error) {
+	we.lastErrLock.Lock()
+	if err == nil {
+		we.lastErr = nil
+		we.lastErrTime = time.Time{}
+	} else {
+		we.lastErr = err
+		we.lastErrTime = time.Now()
+	}
+	we.lastErrLock.Unlock()
+
+	we.breaker.record(err)
+	if we.onResult != nil {
+		we.onResult(err)
+	}
+}
+
+// lastError returns the time and the error of the latest send, if the latest send failed.
+func (we *wrappedExporter) lastError() (time.Time, error) {
+	we.lastErrLock.Lock()
+	defer we.lastErrLock.Unlock()
+	return we.lastErrTime, we.lastErr
+}
+
+// outstanding returns the number of sends in progress.
+func (we *wrappedExporter) outstanding() int64 {
+	return we.inFlight.Load()
+}
+
+// Shutdown waits for the sends in progress before shutting down the exporter, unless the context is done first.
 func (we *wrappedExporter) Shutdown(ctx context.Context) error {
-	we.consumeWG.Wait()
+	consumed := make(chan struct{})
+	go func() {
+		we.consumeWG.Wait()
+		close(consumed)
+	}()
+	select {
+	case <-consumed:
+	case <-ctx.Done():
+		return multierr.Combine(fmt.Errorf("sends still in progress: %w", ctx.Err()), we.Component.Shutdown(ctx))
+	}
 	return we.Component.Shutdown(ctx)
 }
 
@@ -36,7 +116,11 @@ func (we *wrappedExporter) ConsumeTraces(ctx context.Context, td ptrace.Traces)
 	if !ok {
 		return fmt.Errorf("unable to export traces, unexpected exporter type: expected exporter.Traces but got %T", we.Component)
 	}
-	return te.ConsumeTraces(ctx, td)
+	we.inFlight.Add(1)
+	err := te.ConsumeTraces(ctx, td)
+	we.inFlight.Add(-1)
+	we.recordResult(err)
+	return err
 }
 
 func (we *wrappedExporter) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
@@ -44,7 +128,11 @@ func (we *wrappedExporter) ConsumeMetrics(ctx context.Context, md pmetric.Metric
 	if !ok {
 		return fmt.Errorf("unable to export metrics, unexpected exporter type: expected exporter.Metrics but got %T", we.Component)
 	}
-	return me.ConsumeMetrics(ctx, md)
+	we.inFlight.Add(1)
+	err := me.ConsumeMetrics(ctx, md)
+	we.inFlight.Add(-1)
+	we.recordResult(err)
+	return err
 }
 
 func (we *wrappedExporter) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
@@ -52,5 +140,22 @@ func (we *wrappedExporter) ConsumeLogs(ctx context.Context, ld plog.Logs) error
 	if !ok {
 		return fmt.Errorf("unable to export logs, unexpected exporter type: expected exporter.Logs but got %T", we.Component)
 	}
-	return le.ConsumeLogs(ctx, ld)
+	we.inFlight.Add(1)
+	err := le.ConsumeLogs(ctx, ld)
+	we.inFlight.Add(-1)
+	we.recordResult(err)
+	return err
+}
+
+// sortedByEndpoint returns the given exporters sorted by their endpoints, so that the data is sent and the errors
+// are reported in a deterministic order.
+func sortedByEndpoint(endpoints map[*wrappedExporter]string) []*wrappedExporter {
+	exporters := make([]*wrappedExporter, 0, len(endpoints))
+	for exp := range endpoints {
+		exporters = append(exporters, exp)
+	}
+	sort.Slice(exporters, func(i, j int) bool {
+		return endpoints[exporters[i]] < endpoints[exporters[j]]
+	})
+	return exporters
 }
@@ -0,0 +1,69 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package loadbalancingexporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestWrappedExporterLastError(t *testing.T) {
+	// prepare
+	var sendErr error
+	we := newWrappedExporter(newMockTracesExporter(func(context.Context, ptrace.Traces) error {
+		return sendErr
+	}))
+	at, err := we.lastError()
+	require.NoError(t, err)
+	assert.True(t, at.IsZero())
+
+	// test
+	before := time.Now()
+	sendErr = errors.New("first failure")
+	assert.Error(t, we.ConsumeTraces(context.Background(), simpleTraces()))
+	sendErr = errors.New("second failure")
+	assert.Error(t, we.ConsumeTraces(context.Background(), simpleTraces()))
+
+	// verify
+	at, err = we.lastError()
+	assert.EqualError(t, err, "second failure")
+	assert.False(t, at.Before(before))
+
+	// a successful send clears the last error
+	sendErr = nil
+	require.NoError(t, we.ConsumeTraces(context.Background(), simpleTraces()))
+	at, err = we.lastError()
+	assert.NoError(t, err)
+	assert.True(t, at.IsZero())
+}
+
+func TestWrappedExporterOutstanding(t *testing.T) {
+	// prepare
+	sending := make(chan struct{})
+	release := make(chan struct{})
+	we := newWrappedExporter(newMockTracesExporter(func(context.Context, ptrace.Traces) error {
+		sending <- struct{}{}
+		<-release
+		return nil
+	}))
+	done := make(chan error)
+
+	// test
+	go func() {
+		done <- we.ConsumeTraces(context.Background(), simpleTraces())
+	}()
+	<-sending
+
+	// verify
+	assert.EqualValues(t, 1, we.outstanding())
+	close(release)
+	require.NoError(t, <-done)
+	assert.EqualValues(t, 0, we.outstanding())
+}